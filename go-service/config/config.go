@@ -1,5 +1,7 @@
 package config
 
+import "time"
+
 type Config struct {
 	Port               string // 服务运行端口
 	AppName            string // 应用名称
@@ -9,4 +11,50 @@ type Config struct {
 	WeChatAppID        string // 微信AppID
 	WeChatSecret       string // 微信AppSecret
 	PythonAIServiceURL string // Python AI服务URL
+	AIRoutingFile      string // AI后端路由与A/B实验配置文件 (JSON)，为空时只使用PythonAIServiceURL
+
+	EncryptionMasterKey        string        // 主密钥 (base64编码的32字节AES-256密钥)
+	EncryptionMasterKeyVersion int           // 主密钥版本号
+	EncryptionKeyFile          string        // 本地密钥文件路径，每行格式为 <版本>:<base64密钥>
+	KeyRotationInterval        time.Duration // 密钥轮换/重加密任务执行间隔
+
+	PIIDetectors        []string // 发送给AI服务前启用的隐私信息检测器，nil表示使用默认检测器
+	PIIRestoreDetectors []string // 在AI回复中还原占位符的检测器，nil表示使用默认设置
+
+	SafetyRulesFile           string // 危机识别规则文件 (JSON)，为空时使用内置规则
+	SafetyRiskThreshold       string // 触发危机干预的最低风险等级 (low/medium/high)
+	SafetyAIClassifierEnabled bool   // 是否同时调用AI服务的危机分类器
+
+	GuestDailyRequests int    // 游客每日请求次数上限，0表示不限制
+	GuestDailyTokens   int    // 游客每日token上限，0表示不限制
+	UserDailyRequests  int    // 注册用户每日请求次数上限，0表示不限制
+	UserDailyTokens    int    // 注册用户每日token上限，0表示不限制
+	QuotaTimeZone      string // 配额按天重置所用时区
+	AdminToken         string // 管理接口令牌 (X-Admin-Token)，为空时禁用管理接口
+
+	SummaryEveryNTurns   int           // 每累计N轮对话生成一次摘要
+	SummaryIdleAfter     time.Duration // 对话空闲多久后生成摘要
+	SummaryCheckInterval time.Duration // 空闲摘要任务检查间隔
+
+	ExportPDFFontPath string // PDF导出使用的TrueType字体路径，为空时使用内嵌字体
+
+	VoiceStorageDir     string // 语音消息文件存储目录
+	VoiceMaxUploadBytes int64  // 语音消息上传大小上限 (字节)
+	Transcriber         string // 语音识别引擎 (stub/http/command)
+	TranscriberCommand  string // command引擎执行的命令，{file}和{format}会被替换
+	TranscriberStubText string // stub引擎返回的固定文本，为空时语音识别不可用
+
+	CheckInEnabled        bool          // 是否启用助手主动问候
+	CheckInInterval       time.Duration // 主动问候调度检查间隔
+	CheckInDefaultTimes   []string      // 默认问候时间 (HH:MM)，nil表示使用默认值
+	CheckInMaxPerWeek     int           // 每用户每7天主动问候次数上限
+	CheckInQuietAfterChat time.Duration // 用户在此时间内发过消息则跳过本次问候
+
+	// 计划自适应调整
+	PlanAdjustEnabled  bool          // 是否定期评估计划并提出调整
+	PlanAdjustInterval time.Duration // 评估间隔
+
+	// 计划回收站
+	PlanTrashRetention time.Duration // 删除的计划在回收站保留多久后彻底删除，0表示一直保留
+	DeletedPlanRecords string        // 已删除计划的练习记录处理方式 (keep/hide/purge)
 }
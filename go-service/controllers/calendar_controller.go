@@ -0,0 +1,119 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"neuro-guide-go-service/config"
+	"neuro-guide-go-service/models"
+	"neuro-guide-go-service/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+const calendarContentType = "text/calendar; charset=utf-8"
+
+var calendarService *services.CalendarService
+
+// InitCalendarController initializes the calendar controller with config
+func InitCalendarController(cfg *config.Config) {
+	calendarService = services.NewCalendarService(cfg)
+}
+
+// respondCalendarError writes the error response for a failed calendar operation
+func respondCalendarError(c *gin.Context, err error, message string) {
+	if errors.Is(err, services.ErrCalendarFeedNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Calendar feed not found"})
+		return
+	}
+	respondPlanError(c, err, message)
+}
+
+// calendarFeedResponse returns a feed with its subscription URL
+func calendarFeedResponse(c *gin.Context, feed *models.CalendarFeed) gin.H {
+	scheme := "http"
+	if c.Request.TLS != nil || c.GetHeader("X-Forwarded-Proto") == "https" {
+		scheme = "https"
+	}
+
+	return gin.H{
+		"token":      feed.Token,
+		"url":        fmt.Sprintf("%s://%s/api/calendar/%s/plans.ics", scheme, c.Request.Host, feed.Token),
+		"created_at": feed.CreatedAt,
+	}
+}
+
+// GetCalendarFeed handles getting the current user's calendar subscription URL
+func GetCalendarFeed(c *gin.Context) {
+	userID := c.GetString("user_id")
+	if userID == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	feed, err := calendarService.GetFeed(userID)
+	if err != nil {
+		respondCalendarError(c, err, "Failed to get calendar feed")
+		return
+	}
+
+	c.JSON(http.StatusOK, calendarFeedResponse(c, feed))
+}
+
+// ResetCalendarFeed handles replacing the calendar subscription URL, e.g. after it was leaked
+func ResetCalendarFeed(c *gin.Context) {
+	userID := c.GetString("user_id")
+	if userID == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	feed, err := calendarService.ResetFeed(userID)
+	if err != nil {
+		respondCalendarError(c, err, "Failed to reset calendar feed")
+		return
+	}
+
+	c.JSON(http.StatusOK, calendarFeedResponse(c, feed))
+}
+
+// ExportPlanCalendar handles downloading one of the user's plans as an .ics file
+func ExportPlanCalendar(c *gin.Context) {
+	userID := c.GetString("user_id")
+	if userID == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	data, err := calendarService.PlanCalendar(userID, c.Param("id"))
+	if err != nil {
+		respondCalendarError(c, err, "Failed to export plan calendar")
+		return
+	}
+
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", "plan-"+c.Param("id")+".ics"))
+	c.Data(http.StatusOK, calendarContentType, data)
+}
+
+// GetFeedCalendar handles a calendar app reading all plans of a feed. The token is the only credential.
+func GetFeedCalendar(c *gin.Context) {
+	data, err := calendarService.FeedCalendar(c.Param("token"))
+	if err != nil {
+		respondCalendarError(c, err, "Failed to get calendar")
+		return
+	}
+
+	c.Data(http.StatusOK, calendarContentType, data)
+}
+
+// GetFeedPlanCalendar handles a calendar app reading one plan of a feed
+func GetFeedPlanCalendar(c *gin.Context) {
+	data, err := calendarService.FeedPlanCalendar(c.Param("token"), c.Param("id"))
+	if err != nil {
+		respondCalendarError(c, err, "Failed to get calendar")
+		return
+	}
+
+	c.Data(http.StatusOK, calendarContentType, data)
+}
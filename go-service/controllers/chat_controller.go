@@ -1,9 +1,14 @@
 package controllers
 
 import (
+	"errors"
+	"fmt"
+	"io"
 	"net/http"
+	"strings"
 
 	"neuro-guide-go-service/config"
+	"neuro-guide-go-service/models"
 	"neuro-guide-go-service/services"
 
 	"github.com/gin-gonic/gin"
@@ -11,15 +16,20 @@ import (
 
 var chatService *services.ChatService
 
+// maxVoiceUploadBytes limits the size of voice message uploads
+var maxVoiceUploadBytes int64
+
 // InitChatController initializes the chat controller with config
 func InitChatController(cfg *config.Config) {
-	chatService = services.NewChatService(cfg.PythonAIServiceURL)
+	chatService = services.NewChatService(cfg)
+	maxVoiceUploadBytes = cfg.VoiceMaxUploadBytes
 }
 
 // ChatMessageRequest represents a chat message request
 type ChatMessageRequest struct {
 	Message string                   `json:"message" binding:"required"`
 	Context []map[string]interface{} `json:"context,omitempty"`
+	Mode    string                   `json:"mode,omitempty"`
 }
 
 // SendMessage handles sending a chat message
@@ -35,16 +45,217 @@ func SendMessage(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	if req.Mode != "" && !models.IsValidChatMode(req.Mode) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Unsupported chat mode"})
+		return
+	}
+
+	// A retried request with the same key gets the original reply instead of a second answer
+	idempotencyKey, ok := readIdempotencyKey(c)
+	if !ok {
+		return
+	}
+
+	result, err := chatService.SendMessage(userID, services.SendMessageInput{
+		Message:        req.Message,
+		Mode:           req.Mode,
+		IdempotencyKey: idempotencyKey,
+	})
+	if err != nil {
+		respondSendError(c, err)
+		return
+	}
+
+	if result.Replayed {
+		c.Header("Idempotent-Replayed", "true")
+	}
+	c.JSON(http.StatusOK, replyResponse(result.Reply))
+}
+
+// maxIdempotencyKeyLength limits the Idempotency-Key header
+const maxIdempotencyKeyLength = 128
+
+// readIdempotencyKey returns the optional Idempotency-Key header.
+// It writes an error response and returns false when the key is invalid.
+func readIdempotencyKey(c *gin.Context) (string, bool) {
+	key := strings.TrimSpace(c.GetHeader("Idempotency-Key"))
+	if len(key) > maxIdempotencyKeyLength {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Idempotency-Key must be at most 128 characters"})
+		return "", false
+	}
+	return key, true
+}
+
+// respondSendError writes the error response for a failed chat message
+func respondSendError(c *gin.Context, err error) {
+	var quotaErr *services.QuotaExceededError
+	if errors.As(err, &quotaErr) {
+		c.JSON(http.StatusTooManyRequests, gin.H{
+			"error":      "Daily quota exceeded",
+			"limit_type": quotaErr.LimitType,
+			"limit":      quotaErr.Limit,
+			"used":       quotaErr.Used,
+			"reset_at":   quotaErr.ResetAt,
+		})
+		return
+	}
+	if errors.Is(err, services.ErrMessageInProgress) {
+		c.JSON(http.StatusConflict, gin.H{"error": "A message with this Idempotency-Key is still being processed"})
+		return
+	}
+	c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to send message"})
+}
+
+// replyResponse builds the response body for an assistant reply
+func replyResponse(reply *models.ChatMessage) gin.H {
+	response := gin.H{"id": reply.ID, "response": reply.Message}
+	if reply.Mode != "" {
+		response["mode"] = reply.Mode
+	}
+	if reply.Parts != nil {
+		response["parts"] = reply.Parts
+	}
+	if reply.Flagged {
+		response["flagged"] = true
+	}
+	return response
+}
+
+// SendVoiceMessage handles a voice message upload. The audio is transcribed
+// and the transcript is sent like a typed message.
+func SendVoiceMessage(c *gin.Context) {
+	userID := c.GetString("user_id")
+	if userID == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	mode := c.PostForm("mode")
+	if mode != "" && !models.IsValidChatMode(mode) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Unsupported chat mode"})
+		return
+	}
+
+	idempotencyKey, ok := readIdempotencyKey(c)
+	if !ok {
+		return
+	}
+
+	// A retried upload returns the stored reply without transcribing again
+	if idempotencyKey != "" {
+		reply, err := chatService.ReplayMessage(userID, idempotencyKey)
+		if err != nil {
+			respondSendError(c, err)
+			return
+		}
+		if reply != nil {
+			c.Header("Idempotent-Replayed", "true")
+			c.JSON(http.StatusOK, replyResponse(reply))
+			return
+		}
+	}
+
+	header, err := c.FormFile("audio")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "audio file is required"})
+		return
+	}
+	if header.Size > maxVoiceUploadBytes {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "Audio file too large"})
+		return
+	}
+
+	format := c.PostForm("format")
+	if format == "" {
+		format = header.Filename
+	}
+	format = services.NormalizeAudioFormat(format)
+	if format == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "audio format must be silk, mp3 or aac"})
+		return
+	}
+
+	f, err := header.Open()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read audio file"})
+		return
+	}
+	defer f.Close()
+
+	audio, err := io.ReadAll(io.LimitReader(f, maxVoiceUploadBytes))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read audio file"})
+		return
+	}
+
+	// Speech recognition is only spent on messages the quota allows to be sent
+	if err := usageService.CheckQuota(userID); err != nil {
+		respondSendError(c, err)
+		return
+	}
+
+	file, err := chatService.TranscribeVoice(userID, format, audio)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrEmptyTranscript):
+			c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "No speech recognized"})
+		case errors.Is(err, services.ErrTranscriptionUnavailable):
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Speech recognition is not available"})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to transcribe audio"})
+		}
+		return
+	}
+
+	result, err := chatService.SendMessage(userID, services.SendMessageInput{
+		Message:        file.Transcript,
+		Mode:           mode,
+		AudioID:        file.ID,
+		IdempotencyKey: idempotencyKey,
+	})
+	if err != nil {
+		chatService.DiscardVoice(file)
+		respondSendError(c, err)
+		return
+	}
+
+	if result.Replayed {
+		c.Header("Idempotent-Replayed", "true")
+	}
+	response := replyResponse(result.Reply)
+	response["transcript"] = file.Transcript
+	response["audio_id"] = file.ID
+	c.JSON(http.StatusOK, response)
+}
+
+// GetVoiceAudio handles downloading the audio of a voice message
+func GetVoiceAudio(c *gin.Context) {
+	userID := c.GetString("user_id")
+	if userID == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	file, err := chatService.GetAudioFile(userID, c.Param("id"))
+	if errors.Is(err, services.ErrAudioNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Audio not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get audio"})
+		return
+	}
 
-	// Send message to Python AI service without adding chat history context
-	// The Python service will manage context internally
-	response, err := chatService.SendMessage(userID, req.Message, nil)
+	content, err := chatService.OpenAudio(file)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to send message"})
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read audio"})
 		return
 	}
+	defer content.Close()
 
-	c.JSON(http.StatusOK, gin.H{"response": response})
+	c.DataFromReader(http.StatusOK, file.Size, services.AudioContentType(file.Format), content, map[string]string{
+		"Content-Disposition": fmt.Sprintf("inline; filename=%q", file.ID+"."+file.Format),
+	})
 }
 
 // GetChatHistory handles getting chat history
@@ -85,3 +296,137 @@ func ClearChatHistory(c *gin.Context) {
 
 	c.JSON(http.StatusOK, gin.H{"message": "Chat history cleared"})
 }
+
+// GetChatSummary handles getting the current title and summary of the conversation
+func GetChatSummary(c *gin.Context) {
+	userID := c.GetString("user_id")
+	if userID == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	summary, err := chatService.GetLatestSummary(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get summary"})
+		return
+	}
+	if summary == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Summary not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, summary)
+}
+
+// GetChatSummaries handles getting all summary versions of the conversation
+func GetChatSummaries(c *gin.Context) {
+	userID := c.GetString("user_id")
+	if userID == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	summaries, err := chatService.GetSummaryHistory(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get summaries"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"summaries": summaries})
+}
+
+// ExportChat handles downloading the conversation as Markdown, HTML or PDF
+func ExportChat(c *gin.Context) {
+	userID := c.GetString("user_id")
+	if userID == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	format := c.DefaultQuery("format", services.ExportFormatMarkdown)
+	switch format {
+	case services.ExportFormatMarkdown, services.ExportFormatHTML, services.ExportFormatPDF:
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "format must be markdown, html or pdf"})
+		return
+	}
+
+	from, to := c.Query("from"), c.Query("to")
+	for _, date := range []string{from, to} {
+		if date == "" {
+			continue
+		}
+		if err := services.ValidateUsageDate(date); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "from and to must be dates in YYYY-MM-DD format"})
+			return
+		}
+	}
+	if from != "" && to != "" && from > to {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "from must not be after to"})
+		return
+	}
+
+	file, err := chatService.ExportChat(userID, format, from, to)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to export chat"})
+		return
+	}
+
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", file.Filename))
+	c.Data(http.StatusOK, file.ContentType, file.Data)
+}
+
+// FeedbackRequest represents a thumbs rating of an assistant reply: 1 up, -1 down, 0 to clear
+type FeedbackRequest struct {
+	Rating *int `json:"rating" binding:"required"`
+}
+
+// SetMessageFeedback handles rating an assistant reply
+func SetMessageFeedback(c *gin.Context) {
+	userID := c.GetString("user_id")
+	if userID == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	var req FeedbackRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if !models.IsValidFeedback(*req.Rating) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "rating must be 1, -1 or 0"})
+		return
+	}
+
+	err := chatService.SetFeedback(userID, c.Param("id"), *req.Rating)
+	if errors.Is(err, services.ErrMessageNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Message not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save feedback"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Feedback saved", "rating": *req.Rating})
+}
+
+// GetExperimentStats handles the admin report comparing AI experiment variants
+func GetExperimentStats(c *gin.Context) {
+	to := c.DefaultQuery("to", usageService.Today())
+	from := c.DefaultQuery("from", to)
+
+	if services.ValidateUsageDate(from) != nil || services.ValidateUsageDate(to) != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "from and to must be dates in YYYY-MM-DD format"})
+		return
+	}
+
+	stats, err := chatService.GetVariantStats(from, to)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get experiment stats"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"from": from, "to": to, "variants": stats})
+}
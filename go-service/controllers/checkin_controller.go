@@ -0,0 +1,94 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+
+	"neuro-guide-go-service/config"
+	"neuro-guide-go-service/models"
+	"neuro-guide-go-service/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+var checkInService *services.CheckInService
+
+// InitCheckInController initializes the check-in controller with config
+func InitCheckInController(cfg *config.Config) {
+	checkInService = services.NewCheckInService(cfg)
+}
+
+// CheckInPreferenceRequest represents a request to change check-in settings
+type CheckInPreferenceRequest struct {
+	Enabled    *bool    `json:"enabled" binding:"required"`
+	Times      []string `json:"times"`
+	TimeZone   string   `json:"time_zone"`
+	MaxPerWeek *int     `json:"max_per_week"`
+}
+
+// GetCheckInPreference handles getting the user's check-in settings
+func GetCheckInPreference(c *gin.Context) {
+	userID := c.GetString("user_id")
+	if userID == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	pref, err := checkInService.GetPreference(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get check-in preference"})
+		return
+	}
+
+	c.JSON(http.StatusOK, pref)
+}
+
+// UpdateCheckInPreference handles opting in or out of check-ins and changing their times
+func UpdateCheckInPreference(c *gin.Context) {
+	userID := c.GetString("user_id")
+	if userID == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	var req CheckInPreferenceRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	current, err := checkInService.GetPreference(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get check-in preference"})
+		return
+	}
+
+	pref := &models.CheckInPreference{
+		UserID:        userID,
+		Enabled:       *req.Enabled,
+		Times:         current.Times,
+		TimeZone:      current.TimeZone,
+		MaxPerWeek:    current.MaxPerWeek,
+		LastCheckInAt: current.LastCheckInAt,
+	}
+	if req.Times != nil {
+		pref.Times = req.Times
+	}
+	if req.TimeZone != "" {
+		pref.TimeZone = req.TimeZone
+	}
+	if req.MaxPerWeek != nil {
+		pref.MaxPerWeek = *req.MaxPerWeek
+	}
+
+	if err := checkInService.UpdatePreference(pref); err != nil {
+		if errors.Is(err, services.ErrInvalidCheckInPreference) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update check-in preference"})
+		return
+	}
+
+	c.JSON(http.StatusOK, pref)
+}
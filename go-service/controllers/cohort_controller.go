@@ -0,0 +1,283 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"neuro-guide-go-service/config"
+	"neuro-guide-go-service/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+var cohortService *services.CohortService
+
+// InitCohortController initializes the cohort controller with config
+func InitCohortController(cfg *config.Config) {
+	cohortService = services.NewCohortService(cfg)
+}
+
+// CreateCohortRequest represents a request to start a cohort from one of the user's plans
+type CreateCohortRequest struct {
+	Name        string `json:"name" binding:"required,max=100"`
+	Description string `json:"description" binding:"max=2000"`
+	PlanID      string `json:"plan_id" binding:"required"`
+	MaxMembers  int    `json:"max_members" binding:"min=0,max=10000"`
+	DisplayName string `json:"display_name" binding:"max=50"`
+	services.PlanSchedule
+}
+
+// JoinCohortRequest represents a request to join a cohort with its join code
+type JoinCohortRequest struct {
+	Code        string `json:"code" binding:"required,max=20"`
+	DisplayName string `json:"display_name" binding:"max=50"`
+}
+
+// CohortAnnouncementRequest represents an announcement the organizer posts
+type CohortAnnouncementRequest struct {
+	Text string `json:"text" binding:"required,max=2000"`
+}
+
+// respondCohortError writes the error response for a failed cohort operation
+func respondCohortError(c *gin.Context, err error, message string) {
+	switch {
+	case errors.Is(err, services.ErrCohortNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Cohort not found"})
+	case errors.Is(err, services.ErrCohortAnnouncementNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Announcement not found"})
+	case errors.Is(err, services.ErrNotCohortOrganizer):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrCohortFull), errors.Is(err, services.ErrCohortEnded),
+		errors.Is(err, services.ErrAlreadyCohortMember), errors.Is(err, services.ErrOrganizerCannotLeave):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		respondPlanError(c, err, message)
+	}
+}
+
+// CreateCohort handles starting a cohort
+func CreateCohort(c *gin.Context) {
+	userID := c.GetString("user_id")
+	if userID == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	var req CreateCohortRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	cohort, err := cohortService.CreateCohort(userID, services.CohortInput{
+		Name:        req.Name,
+		Description: req.Description,
+		PlanID:      req.PlanID,
+		Schedule:    req.PlanSchedule,
+		MaxMembers:  req.MaxMembers,
+		DisplayName: req.DisplayName,
+	})
+	if err != nil {
+		respondCohortError(c, err, "Failed to create cohort")
+		return
+	}
+
+	c.JSON(http.StatusOK, cohort)
+}
+
+// JoinCohort handles joining a cohort with a join code
+func JoinCohort(c *gin.Context) {
+	userID := c.GetString("user_id")
+	if userID == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	var req JoinCohortRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	cohort, err := cohortService.JoinCohort(userID, req.Code, req.DisplayName)
+	if err != nil {
+		respondCohortError(c, err, "Failed to join cohort")
+		return
+	}
+
+	c.JSON(http.StatusOK, cohort)
+}
+
+// GetCohorts handles listing the cohorts the user is a member of
+func GetCohorts(c *gin.Context) {
+	userID := c.GetString("user_id")
+	if userID == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	cohorts, err := cohortService.ListCohorts(userID)
+	if err != nil {
+		respondCohortError(c, err, "Failed to get cohorts")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"cohorts": cohorts})
+}
+
+// GetCohort handles getting a cohort the user is a member of
+func GetCohort(c *gin.Context) {
+	userID := c.GetString("user_id")
+	if userID == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	cohort, err := cohortService.GetCohort(userID, c.Param("id"))
+	if err != nil {
+		respondCohortError(c, err, "Failed to get cohort")
+		return
+	}
+
+	c.JSON(http.StatusOK, cohort)
+}
+
+// DeleteCohort handles ending a cohort the user organizes
+func DeleteCohort(c *gin.Context) {
+	userID := c.GetString("user_id")
+	if userID == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	if err := cohortService.DeleteCohort(userID, c.Param("id")); err != nil {
+		respondCohortError(c, err, "Failed to delete cohort")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Cohort deleted"})
+}
+
+// GetCohortMembers handles listing the members of a cohort
+func GetCohortMembers(c *gin.Context) {
+	userID := c.GetString("user_id")
+	if userID == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	members, err := cohortService.ListMembers(userID, c.Param("id"))
+	if err != nil {
+		respondCohortError(c, err, "Failed to get cohort members")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"members": members})
+}
+
+// GetCohortProgress handles getting the anonymous day-by-day progress of a cohort
+func GetCohortProgress(c *gin.Context) {
+	userID := c.GetString("user_id")
+	if userID == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	progress, err := cohortService.Progress(userID, c.Param("id"), time.Now())
+	if err != nil {
+		respondCohortError(c, err, "Failed to get cohort progress")
+		return
+	}
+
+	c.JSON(http.StatusOK, progress)
+}
+
+// LeaveCohort handles leaving a cohort
+func LeaveCohort(c *gin.Context) {
+	userID := c.GetString("user_id")
+	if userID == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	if err := cohortService.LeaveCohort(userID, c.Param("id")); err != nil {
+		respondCohortError(c, err, "Failed to leave cohort")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Left cohort"})
+}
+
+// ResetCohortJoinCode handles replacing the join code of a cohort
+func ResetCohortJoinCode(c *gin.Context) {
+	userID := c.GetString("user_id")
+	if userID == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	cohort, err := cohortService.ResetJoinCode(userID, c.Param("id"))
+	if err != nil {
+		respondCohortError(c, err, "Failed to reset join code")
+		return
+	}
+
+	c.JSON(http.StatusOK, cohort)
+}
+
+// PostCohortAnnouncement handles posting an announcement to a cohort
+func PostCohortAnnouncement(c *gin.Context) {
+	userID := c.GetString("user_id")
+	if userID == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	var req CohortAnnouncementRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	announcement, err := cohortService.PostAnnouncement(userID, c.Param("id"), req.Text)
+	if err != nil {
+		respondCohortError(c, err, "Failed to post announcement")
+		return
+	}
+
+	c.JSON(http.StatusOK, announcement)
+}
+
+// GetCohortAnnouncements handles listing the announcements of a cohort
+func GetCohortAnnouncements(c *gin.Context) {
+	userID := c.GetString("user_id")
+	if userID == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	announcements, err := cohortService.ListAnnouncements(userID, c.Param("id"))
+	if err != nil {
+		respondCohortError(c, err, "Failed to get announcements")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"announcements": announcements})
+}
+
+// DeleteCohortAnnouncement handles removing an announcement from a cohort
+func DeleteCohortAnnouncement(c *gin.Context) {
+	userID := c.GetString("user_id")
+	if userID == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	if err := cohortService.DeleteAnnouncement(userID, c.Param("id"), c.Param("announcementId")); err != nil {
+		respondCohortError(c, err, "Failed to delete announcement")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Announcement deleted"})
+}
@@ -0,0 +1,264 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+
+	"neuro-guide-go-service/models"
+	"neuro-guide-go-service/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+var notebookService *services.NotebookService
+
+// InitNotebookController initializes the notebook controller
+func InitNotebookController() {
+	notebookService = services.NewNotebookService()
+}
+
+// SaveNotebookEntryRequest represents a request to bookmark an assistant message
+type SaveNotebookEntryRequest struct {
+	MessageID     string   `json:"message_id" binding:"required"`
+	Note          string   `json:"note"`
+	Tags          []string `json:"tags"`
+	CollectionIDs []string `json:"collection_ids"`
+}
+
+// UpdateNotebookEntryRequest represents a request to change an entry's annotations
+type UpdateNotebookEntryRequest struct {
+	Note          *string  `json:"note"`
+	Tags          []string `json:"tags"`
+	CollectionIDs []string `json:"collection_ids"`
+}
+
+// NotebookCollectionRequest represents a request to create or update a collection
+type NotebookCollectionRequest struct {
+	Name        string `json:"name" binding:"required,max=50"`
+	Description string `json:"description" binding:"max=500"`
+}
+
+// respondNotebookError writes the error response for a failed notebook operation
+func respondNotebookError(c *gin.Context, err error, message string) {
+	switch {
+	case errors.Is(err, services.ErrNotebookEntryNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Notebook entry not found"})
+	case errors.Is(err, services.ErrNotebookCollectionNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Collection not found"})
+	case errors.Is(err, services.ErrMessageNotSaveable):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Only your assistant messages can be saved"})
+	case errors.Is(err, services.ErrMessageAlreadySaved):
+		c.JSON(http.StatusConflict, gin.H{"error": "Message already saved to notebook"})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": message})
+	}
+}
+
+// SaveNotebookEntry handles bookmarking an assistant message
+func SaveNotebookEntry(c *gin.Context) {
+	userID := c.GetString("user_id")
+	if userID == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	var req SaveNotebookEntryRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	entry, err := notebookService.SaveEntry(userID, services.SaveNotebookEntryInput{
+		MessageID:     req.MessageID,
+		Note:          req.Note,
+		Tags:          req.Tags,
+		CollectionIDs: req.CollectionIDs,
+	})
+	if err != nil {
+		respondNotebookError(c, err, "Failed to save notebook entry")
+		return
+	}
+
+	c.JSON(http.StatusOK, entry)
+}
+
+// ListNotebookEntries handles listing and searching notebook entries
+func ListNotebookEntries(c *gin.Context) {
+	userID := c.GetString("user_id")
+	if userID == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	entries, err := notebookService.ListEntries(userID, services.NotebookQuery{
+		Query:        c.Query("q"),
+		Tag:          c.Query("tag"),
+		CollectionID: c.Query("collection_id"),
+	})
+	if err != nil {
+		respondNotebookError(c, err, "Failed to get notebook entries")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"entries": entries})
+}
+
+// GetNotebookEntry handles getting a notebook entry
+func GetNotebookEntry(c *gin.Context) {
+	userID := c.GetString("user_id")
+	if userID == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	entry, err := notebookService.GetEntry(userID, c.Param("id"))
+	if err != nil {
+		respondNotebookError(c, err, "Failed to get notebook entry")
+		return
+	}
+
+	c.JSON(http.StatusOK, entry)
+}
+
+// UpdateNotebookEntry handles changing the note, tags or collections of an entry
+func UpdateNotebookEntry(c *gin.Context) {
+	userID := c.GetString("user_id")
+	if userID == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	var req UpdateNotebookEntryRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	entry, err := notebookService.UpdateEntry(userID, c.Param("id"), services.UpdateNotebookEntryInput{
+		Note:          req.Note,
+		Tags:          req.Tags,
+		CollectionIDs: req.CollectionIDs,
+	})
+	if err != nil {
+		respondNotebookError(c, err, "Failed to update notebook entry")
+		return
+	}
+
+	c.JSON(http.StatusOK, entry)
+}
+
+// DeleteNotebookEntry handles removing an entry from the notebook
+func DeleteNotebookEntry(c *gin.Context) {
+	userID := c.GetString("user_id")
+	if userID == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	if err := notebookService.DeleteEntry(userID, c.Param("id")); err != nil {
+		respondNotebookError(c, err, "Failed to delete notebook entry")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Notebook entry deleted"})
+}
+
+// ListNotebookTags handles listing the tags used in the notebook
+func ListNotebookTags(c *gin.Context) {
+	userID := c.GetString("user_id")
+	if userID == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	tags, err := notebookService.ListTags(userID)
+	if err != nil {
+		respondNotebookError(c, err, "Failed to get tags")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"tags": tags})
+}
+
+// CreateNotebookCollection handles creating a collection
+func CreateNotebookCollection(c *gin.Context) {
+	userID := c.GetString("user_id")
+	if userID == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	var req NotebookCollectionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	collection := &models.NotebookCollection{
+		UserID:      userID,
+		Name:        req.Name,
+		Description: req.Description,
+	}
+	if err := notebookService.CreateCollection(collection); err != nil {
+		respondNotebookError(c, err, "Failed to create collection")
+		return
+	}
+
+	c.JSON(http.StatusOK, collection)
+}
+
+// ListNotebookCollections handles listing collections
+func ListNotebookCollections(c *gin.Context) {
+	userID := c.GetString("user_id")
+	if userID == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	collections, err := notebookService.ListCollections(userID)
+	if err != nil {
+		respondNotebookError(c, err, "Failed to get collections")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"collections": collections})
+}
+
+// UpdateNotebookCollection handles renaming a collection
+func UpdateNotebookCollection(c *gin.Context) {
+	userID := c.GetString("user_id")
+	if userID == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	var req NotebookCollectionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	collection, err := notebookService.UpdateCollection(userID, c.Param("id"), req.Name, req.Description)
+	if err != nil {
+		respondNotebookError(c, err, "Failed to update collection")
+		return
+	}
+
+	c.JSON(http.StatusOK, collection)
+}
+
+// DeleteNotebookCollection handles deleting a collection. Its entries are kept.
+func DeleteNotebookCollection(c *gin.Context) {
+	userID := c.GetString("user_id")
+	if userID == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	if err := notebookService.DeleteCollection(userID, c.Param("id")); err != nil {
+		respondNotebookError(c, err, "Failed to delete collection")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Collection deleted"})
+}
@@ -0,0 +1,99 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"neuro-guide-go-service/config"
+	"neuro-guide-go-service/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+var adjustmentService *services.PlanAdjustmentService
+
+// InitPlanAdjustmentController initializes the plan adjustment controller with config
+func InitPlanAdjustmentController(cfg *config.Config) {
+	adjustmentService = services.NewPlanAdjustmentService(cfg)
+}
+
+// respondAdjustmentError writes the error response for a failed adjustment operation
+func respondAdjustmentError(c *gin.Context, err error, message string) {
+	switch {
+	case errors.Is(err, services.ErrPlanAdjustmentNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Adjustment not found"})
+	case errors.Is(err, services.ErrPlanAdjustmentDecided):
+		c.JSON(http.StatusConflict, gin.H{"error": "Adjustment was already accepted, rejected or superseded"})
+	default:
+		respondPlanError(c, err, message)
+	}
+}
+
+// EvaluatePlan handles evaluating a plan's adherence now and proposing an adjustment
+func EvaluatePlan(c *gin.Context) {
+	userID := c.GetString("user_id")
+	if userID == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	adherence, adjustment, err := adjustmentService.EvaluatePlan(userID, c.Param("id"), time.Now())
+	if err != nil {
+		respondAdjustmentError(c, err, "Failed to evaluate plan")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"adherence": adherence, "adjustment": adjustment})
+}
+
+// GetPlanAdjustments handles listing the adjustments proposed for a plan
+func GetPlanAdjustments(c *gin.Context) {
+	userID := c.GetString("user_id")
+	if userID == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	adjustments, err := adjustmentService.ListAdjustments(userID, c.Param("id"))
+	if err != nil {
+		respondAdjustmentError(c, err, "Failed to get plan adjustments")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"adjustments": adjustments})
+}
+
+// AcceptPlanAdjustment handles accepting an adjustment, which saves a new plan version
+func AcceptPlanAdjustment(c *gin.Context) {
+	userID := c.GetString("user_id")
+	if userID == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	plan, err := adjustmentService.AcceptAdjustment(userID, c.Param("id"), c.Param("adjustmentId"))
+	if err != nil {
+		respondAdjustmentError(c, err, "Failed to accept adjustment")
+		return
+	}
+
+	c.JSON(http.StatusOK, plan)
+}
+
+// RejectPlanAdjustment handles rejecting an adjustment
+func RejectPlanAdjustment(c *gin.Context) {
+	userID := c.GetString("user_id")
+	if userID == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	adjustment, err := adjustmentService.RejectAdjustment(userID, c.Param("id"), c.Param("adjustmentId"))
+	if err != nil {
+		respondAdjustmentError(c, err, "Failed to reject adjustment")
+		return
+	}
+
+	c.JSON(http.StatusOK, adjustment)
+}
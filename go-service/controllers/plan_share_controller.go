@@ -0,0 +1,184 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"neuro-guide-go-service/config"
+	"neuro-guide-go-service/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+var shareService *services.PlanShareService
+
+// InitPlanShareController initializes the plan share controller with config
+func InitPlanShareController(cfg *config.Config) {
+	shareService = services.NewPlanShareService(cfg)
+}
+
+// CreatePlanShareRequest represents a request to create a share link. Without expires_at the link never expires.
+// Listed links appear in the public list of popular plans.
+type CreatePlanShareRequest struct {
+	ExpiresAt *time.Time `json:"expires_at"`
+	Listed    bool       `json:"listed"`
+}
+
+// UpdatePlanShareRequest represents a request to list or unlist a share link
+type UpdatePlanShareRequest struct {
+	Listed *bool `json:"listed" binding:"required"`
+}
+
+// respondShareError writes the error response for a failed share operation
+func respondShareError(c *gin.Context, err error, message string) {
+	switch {
+	case errors.Is(err, services.ErrPlanShareNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Share link not found or no longer active"})
+	case errors.Is(err, services.ErrInvalidShareExpiry):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		respondPlanError(c, err, message)
+	}
+}
+
+// CreatePlanShare handles creating a read-only share link for a plan
+func CreatePlanShare(c *gin.Context) {
+	userID := c.GetString("user_id")
+	if userID == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	// The expiry is optional
+	var req CreatePlanShareRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	share, err := shareService.CreateShare(userID, c.Param("id"), req.ExpiresAt, req.Listed)
+	if err != nil {
+		respondShareError(c, err, "Failed to share plan")
+		return
+	}
+
+	c.JSON(http.StatusOK, share)
+}
+
+// GetPlanShares handles listing the share links of a plan
+func GetPlanShares(c *gin.Context) {
+	userID := c.GetString("user_id")
+	if userID == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	shares, err := shareService.ListShares(userID, c.Param("id"))
+	if err != nil {
+		respondShareError(c, err, "Failed to get share links")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"shares": shares})
+}
+
+// UpdatePlanShare handles listing or unlisting a share link in the popular plans
+func UpdatePlanShare(c *gin.Context) {
+	userID := c.GetString("user_id")
+	if userID == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	var req UpdatePlanShareRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	share, err := shareService.SetShareListed(userID, c.Param("id"), c.Param("shareId"), *req.Listed)
+	if err != nil {
+		respondShareError(c, err, "Failed to update share link")
+		return
+	}
+
+	c.JSON(http.StatusOK, share)
+}
+
+// RevokePlanShare handles revoking a share link
+func RevokePlanShare(c *gin.Context) {
+	userID := c.GetString("user_id")
+	if userID == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	if err := shareService.RevokeShare(userID, c.Param("id"), c.Param("shareId")); err != nil {
+		respondShareError(c, err, "Failed to revoke share link")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Share link revoked"})
+}
+
+// GetSharedPlan handles opening a share link. No login is needed.
+func GetSharedPlan(c *gin.Context) {
+	plan, err := shareService.GetSharedPlan(c.Param("token"))
+	if err != nil {
+		respondShareError(c, err, "Failed to get shared plan")
+		return
+	}
+
+	c.JSON(http.StatusOK, plan)
+}
+
+// CloneSharedPlan handles copying a shared plan into the current user's plans
+func CloneSharedPlan(c *gin.Context) {
+	userID := c.GetString("user_id")
+	if userID == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	// The schedule is optional
+	var schedule services.PlanSchedule
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&schedule); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	plan, err := shareService.ClonePlan(userID, c.Param("token"), schedule)
+	if err != nil {
+		respondShareError(c, err, "Failed to clone plan")
+		return
+	}
+
+	c.JSON(http.StatusOK, plan)
+}
+
+// GetPopularPlans handles listing the most cloned plans whose owners listed their share links
+func GetPopularPlans(c *gin.Context) {
+	limit := int64(20)
+	if limitStr := c.Query("limit"); limitStr != "" {
+		parsed, err := strconv.ParseInt(limitStr, 10, 64)
+		if err != nil || parsed < 1 || parsed > 100 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "limit must be between 1 and 100"})
+			return
+		}
+		limit = parsed
+	}
+
+	plans, err := shareService.PopularPlans(limit)
+	if err != nil {
+		respondShareError(c, err, "Failed to get popular plans")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"plans": plans})
+}
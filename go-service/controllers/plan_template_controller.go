@@ -0,0 +1,211 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"neuro-guide-go-service/config"
+	"neuro-guide-go-service/models"
+	"neuro-guide-go-service/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+var templateService *services.PlanTemplateService
+
+// InitPlanTemplateController initializes the plan template controller with config
+func InitPlanTemplateController(cfg *config.Config) {
+	templateService = services.NewPlanTemplateService(cfg)
+}
+
+// PlanTemplateRequest represents a request to create or replace a plan template
+type PlanTemplateRequest struct {
+	Title       string            `json:"title" binding:"required,max=100"`
+	Description string            `json:"description" binding:"max=2000"`
+	Category    string            `json:"category" binding:"required"`
+	Difficulty  string            `json:"difficulty" binding:"required"`
+	Days        int               `json:"days" binding:"required"`
+	Tasks       []models.PlanTask `json:"tasks" binding:"required"`
+	Tags        []string          `json:"tags"`
+	Rationale   string            `json:"rationale" binding:"required,max=4000"`
+	Published   bool              `json:"published"`
+}
+
+func (req *PlanTemplateRequest) template() *models.PlanTemplate {
+	return &models.PlanTemplate{
+		Title:       req.Title,
+		Description: req.Description,
+		Category:    req.Category,
+		Difficulty:  req.Difficulty,
+		Days:        req.Days,
+		Tasks:       req.Tasks,
+		Tags:        req.Tags,
+		Rationale:   req.Rationale,
+		Published:   req.Published,
+	}
+}
+
+// respondTemplateError writes the error response for a failed template operation
+func respondTemplateError(c *gin.Context, err error, message string) {
+	if errors.Is(err, services.ErrPlanTemplateNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Plan template not found"})
+		return
+	}
+	respondPlanError(c, err, message)
+}
+
+// templateQuery reads the template filters from the query string
+func templateQuery(c *gin.Context) (services.TemplateQuery, bool) {
+	query := services.TemplateQuery{
+		Query:      c.Query("q"),
+		Category:   c.Query("category"),
+		Difficulty: c.Query("difficulty"),
+		Tag:        c.Query("tag"),
+	}
+	if maxDays := c.Query("max_days"); maxDays != "" {
+		parsed, err := strconv.Atoi(maxDays)
+		if err != nil || parsed < 1 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "max_days must be a positive number"})
+			return query, false
+		}
+		query.MaxDays = parsed
+	}
+	return query, true
+}
+
+// ListPlanTemplates handles browsing and searching published plan templates
+func ListPlanTemplates(c *gin.Context) {
+	query, ok := templateQuery(c)
+	if !ok {
+		return
+	}
+
+	templates, err := templateService.ListTemplates(query)
+	if err != nil {
+		respondTemplateError(c, err, "Failed to get plan templates")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"templates": templates})
+}
+
+// ListPlanTemplateCategories handles listing template categories with their template counts
+func ListPlanTemplateCategories(c *gin.Context) {
+	categories, err := templateService.ListCategories()
+	if err != nil {
+		respondTemplateError(c, err, "Failed to get template categories")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"categories": categories})
+}
+
+// GetPlanTemplate handles getting a published plan template
+func GetPlanTemplate(c *gin.Context) {
+	template, err := templateService.GetTemplate(c.Param("id"), false)
+	if err != nil {
+		respondTemplateError(c, err, "Failed to get plan template")
+		return
+	}
+
+	c.JSON(http.StatusOK, template)
+}
+
+// InstantiatePlanTemplate handles copying a template into a practice plan for the current user
+func InstantiatePlanTemplate(c *gin.Context) {
+	userID := c.GetString("user_id")
+	if userID == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	// The schedule is optional
+	var schedule services.PlanSchedule
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&schedule); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	plan, err := templateService.Instantiate(userID, c.Param("id"), schedule)
+	if err != nil {
+		respondTemplateError(c, err, "Failed to create plan from template")
+		return
+	}
+
+	c.JSON(http.StatusOK, plan)
+}
+
+// AdminListPlanTemplates handles listing all plan templates, including unpublished ones
+func AdminListPlanTemplates(c *gin.Context) {
+	query, ok := templateQuery(c)
+	if !ok {
+		return
+	}
+	query.IncludeUnpublished = true
+
+	templates, err := templateService.ListTemplates(query)
+	if err != nil {
+		respondTemplateError(c, err, "Failed to get plan templates")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"templates": templates})
+}
+
+// AdminGetPlanTemplate handles getting any plan template
+func AdminGetPlanTemplate(c *gin.Context) {
+	template, err := templateService.GetTemplate(c.Param("id"), true)
+	if err != nil {
+		respondTemplateError(c, err, "Failed to get plan template")
+		return
+	}
+
+	c.JSON(http.StatusOK, template)
+}
+
+// CreatePlanTemplate handles adding a template to the library
+func CreatePlanTemplate(c *gin.Context) {
+	var req PlanTemplateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	template := req.template()
+	if err := templateService.CreateTemplate(template); err != nil {
+		respondTemplateError(c, err, "Failed to create plan template")
+		return
+	}
+
+	c.JSON(http.StatusOK, template)
+}
+
+// UpdatePlanTemplate handles replacing the content of a template
+func UpdatePlanTemplate(c *gin.Context) {
+	var req PlanTemplateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	template, err := templateService.UpdateTemplate(c.Param("id"), req.template())
+	if err != nil {
+		respondTemplateError(c, err, "Failed to update plan template")
+		return
+	}
+
+	c.JSON(http.StatusOK, template)
+}
+
+// DeletePlanTemplate handles removing a template from the library
+func DeletePlanTemplate(c *gin.Context) {
+	if err := templateService.DeleteTemplate(c.Param("id")); err != nil {
+		respondTemplateError(c, err, "Failed to delete plan template")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Plan template deleted"})
+}
@@ -1,21 +1,215 @@
 package controllers
 
 import (
+	"errors"
+	"fmt"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
 
+	"neuro-guide-go-service/config"
 	"neuro-guide-go-service/models"
 	"neuro-guide-go-service/services"
 
 	"github.com/gin-gonic/gin"
 )
 
-var planService = services.NewPracticePlanService()
+var planService *services.PracticePlanService
+var planGenerator *services.PlanGeneratorService
+
+// InitPracticePlanController initializes the practice plan controller with config
+func InitPracticePlanController(cfg *config.Config) {
+	planService = services.NewPracticePlanService(cfg)
+	planGenerator = services.NewPlanGeneratorService(cfg)
+}
 
 // CreatePlanRequest represents a request to create a practice plan
 type CreatePlanRequest struct {
 	Title string            `json:"title" binding:"required"`
 	Days  int               `json:"days" binding:"required"`
 	Tasks []models.PlanTask `json:"tasks" binding:"required"`
+	Draft bool              `json:"draft"` // create the plan as a draft that starts when activated
+	services.PlanSchedule
+}
+
+// UpdatePlanRequest represents a request to replace the content of a practice plan
+type UpdatePlanRequest struct {
+	Title   string            `json:"title" binding:"required"`
+	Days    int               `json:"days" binding:"required"`
+	Tasks   []models.PlanTask `json:"tasks" binding:"required"`
+	Version int               `json:"version"` // version the edit is based on, 0 skips the check
+}
+
+// PatchPlanRequest represents a request to change some fields of a practice plan
+type PatchPlanRequest struct {
+	Title   *string `json:"title"`
+	Days    *int    `json:"days"`
+	Version int     `json:"version"`
+}
+
+// PatchPlanTaskRequest represents a request to change some fields of a plan task
+type PatchPlanTaskRequest struct {
+	Day             *int      `json:"day"`
+	Title           *string   `json:"title"`
+	Description     *string   `json:"description"`
+	ScientificBasis *string   `json:"scientific_basis"`
+	Type            *string   `json:"type"`
+	DurationMinutes *int      `json:"duration_minutes"`
+	TimeOfDay       *string   `json:"time_of_day"`
+	Optional        *bool     `json:"optional"`
+	ReferenceIDs    *[]string `json:"reference_ids"`
+	Version         int       `json:"version"`
+}
+
+// AddPlanTaskRequest represents a request to add a task to a plan
+type AddPlanTaskRequest struct {
+	Task    *models.PlanTask `json:"task" binding:"required"`
+	Version int              `json:"version"`
+}
+
+// RestorePlanVersionRequest represents a request to restore an earlier plan version
+type RestorePlanVersionRequest struct {
+	Version int `json:"version"` // current version the restore is based on, 0 skips the check
+}
+
+// GeneratePlanRequest represents a request to generate a practice plan
+type GeneratePlanRequest struct {
+	Goal string `json:"goal" binding:"required,max=1000"`
+	Days int    `json:"days" binding:"required,min=1,max=90"`
+}
+
+// ImportPlanRequest represents a request to create a plan from the Markdown plan format
+type ImportPlanRequest struct {
+	Markdown string `json:"markdown" binding:"required,max=100000"`
+	DryRun   bool   `json:"dry_run"` // only check the plan
+	services.PlanSchedule
+}
+
+// respondPlanError writes the error response for a failed plan operation
+func respondPlanError(c *gin.Context, err error, message string) {
+	var quotaErr *services.QuotaExceededError
+	var validationErr *services.PlanValidationError
+	var markdownErr *services.PlanMarkdownError
+	switch {
+	case errors.As(err, &quotaErr):
+		c.JSON(http.StatusTooManyRequests, gin.H{
+			"error":      "Daily quota exceeded",
+			"limit_type": quotaErr.LimitType,
+			"limit":      quotaErr.Limit,
+			"used":       quotaErr.Used,
+			"reset_at":   quotaErr.ResetAt,
+		})
+	case errors.As(err, &markdownErr):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid plan markdown", "errors": markdownErr.Errors})
+	case errors.As(err, &validationErr):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid plan", "problems": validationErr.Problems})
+	case errors.Is(err, services.ErrInvalidPlanRequest):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrPlanPreviewNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Plan preview not found"})
+	case errors.Is(err, services.ErrPlanNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Plan not found"})
+	case errors.Is(err, services.ErrPlanVersionNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Plan version not found"})
+	case errors.Is(err, services.ErrPlanTaskNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Task not found"})
+	case errors.Is(err, services.ErrPlanVersionConflict):
+		c.JSON(http.StatusConflict, gin.H{"error": "Plan was changed since the given version"})
+	case errors.Is(err, services.ErrPlanPaused), errors.Is(err, services.ErrPlanNotPaused), errors.Is(err, services.ErrPlanFinished),
+		errors.Is(err, services.ErrInvalidPlanTransition), errors.Is(err, services.ErrPlanNotEditable), errors.Is(err, services.ErrPlanStatusConflict):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": message})
+	}
+}
+
+// GeneratePlan handles generating a practice plan preview with the AI service
+func GeneratePlan(c *gin.Context) {
+	userID := c.GetString("user_id")
+	if userID == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	var req GeneratePlanRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	preview, err := planGenerator.GeneratePreview(userID, req.Goal, req.Days)
+	if err != nil {
+		// An invalid plan here is the AI service's fault, not the client's
+		var validationErr *services.PlanValidationError
+		if errors.As(err, &validationErr) {
+			c.JSON(http.StatusBadGateway, gin.H{"error": "Generated plan is invalid", "problems": validationErr.Problems})
+			return
+		}
+		respondPlanError(c, err, "Failed to generate plan")
+		return
+	}
+
+	c.JSON(http.StatusOK, preview)
+}
+
+// GetPlanPreview handles getting a generated plan preview
+func GetPlanPreview(c *gin.Context) {
+	userID := c.GetString("user_id")
+	if userID == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	preview, err := planGenerator.GetPreview(userID, c.Param("id"))
+	if err != nil {
+		respondPlanError(c, err, "Failed to get plan preview")
+		return
+	}
+
+	c.JSON(http.StatusOK, preview)
+}
+
+// AcceptPlanPreview handles saving a generated plan preview as a practice plan
+func AcceptPlanPreview(c *gin.Context) {
+	userID := c.GetString("user_id")
+	if userID == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	// The schedule is optional
+	var schedule services.PlanSchedule
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&schedule); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	plan, err := planGenerator.AcceptPreview(userID, c.Param("id"), schedule)
+	if err != nil {
+		respondPlanError(c, err, "Failed to accept plan preview")
+		return
+	}
+
+	c.JSON(http.StatusOK, plan)
+}
+
+// DiscardPlanPreview handles discarding a generated plan preview
+func DiscardPlanPreview(c *gin.Context) {
+	userID := c.GetString("user_id")
+	if userID == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	if err := planGenerator.DiscardPreview(userID, c.Param("id")); err != nil {
+		respondPlanError(c, err, "Failed to discard plan preview")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Plan preview discarded"})
 }
 
 // CreatePlan handles creating a practice plan
@@ -38,9 +232,12 @@ func CreatePlan(c *gin.Context) {
 		Days:   req.Days,
 		Tasks:  req.Tasks,
 	}
+	if req.Draft {
+		plan.Status = models.PlanStatusDraft
+	}
 
-	if err := planService.CreatePlan(plan); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create plan"})
+	if err := planService.CreatePlan(plan, req.PlanSchedule); err != nil {
+		respondPlanError(c, err, "Failed to create plan")
 		return
 	}
 
@@ -49,22 +246,52 @@ func CreatePlan(c *gin.Context) {
 
 // GetPlan handles getting a practice plan
 func GetPlan(c *gin.Context) {
+	userID := c.GetString("user_id")
+	if userID == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
 	planID := c.Param("id")
 	if planID == "" {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Plan ID is required"})
 		return
 	}
 
-	plan, err := planService.GetPlanByID(planID)
+	plan, err := planService.GetPlan(userID, planID)
 	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Plan not found"})
+		respondPlanError(c, err, "Failed to get plan")
+		return
+	}
+
+	plan.Progress, err = planService.Progress(plan, time.Now())
+	if err != nil {
+		respondPlanError(c, err, "Failed to get plan progress")
 		return
 	}
 
 	c.JSON(http.StatusOK, plan)
 }
 
-// GetPlans handles getting all practice plans for a user
+// GetPlanProgress handles getting how far a user is through a plan and how closely they followed it
+func GetPlanProgress(c *gin.Context) {
+	userID := c.GetString("user_id")
+	if userID == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	progress, err := planService.GetProgress(userID, c.Param("id"), time.Now())
+	if err != nil {
+		respondPlanError(c, err, "Failed to get plan progress")
+		return
+	}
+
+	c.JSON(http.StatusOK, progress)
+}
+
+// GetPlans handles getting the practice plans of a user. The optional status query
+// lists comma-separated statuses, or "all"; archived plans are left out by default.
 func GetPlans(c *gin.Context) {
 	userID := c.GetString("user_id")
 	if userID == "" {
@@ -72,27 +299,522 @@ func GetPlans(c *gin.Context) {
 		return
 	}
 
+	statuses := []string{
+		models.PlanStatusDraft, models.PlanStatusActive, models.PlanStatusPaused,
+		models.PlanStatusCompleted, models.PlanStatusAbandoned,
+	}
+	switch status := c.Query("status"); status {
+	case "":
+	case "all":
+		statuses = append(statuses, models.PlanStatusArchived)
+	default:
+		statuses = strings.Split(status, ",")
+	}
+
 	plans, err := planService.GetPlansByUserID(userID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get plans"})
 		return
 	}
 
+	c.JSON(http.StatusOK, gin.H{"plans": services.FilterPlansByStatus(plans, statuses)})
+}
+
+// GetDeletedPlans handles listing the plans in the user's trash
+func GetDeletedPlans(c *gin.Context) {
+	userID := c.GetString("user_id")
+	if userID == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	plans, err := planService.GetDeletedPlans(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get deleted plans"})
+		return
+	}
+
 	c.JSON(http.StatusOK, gin.H{"plans": plans})
 }
 
-// DeletePlan handles deleting a practice plan
+// DeletePlan handles moving a practice plan to the trash, or purging it with permanent=true
 func DeletePlan(c *gin.Context) {
+	userID := c.GetString("user_id")
+	if userID == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
 	planID := c.Param("id")
 	if planID == "" {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Plan ID is required"})
 		return
 	}
 
-	if err := planService.DeletePlan(planID); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete plan"})
+	if c.Query("permanent") == "true" {
+		if err := planService.PurgePlan(userID, planID); err != nil {
+			respondPlanError(c, err, "Failed to delete plan")
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "Plan permanently deleted"})
+		return
+	}
+
+	if err := planService.DeletePlan(userID, planID); err != nil {
+		respondPlanError(c, err, "Failed to delete plan")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Plan moved to trash"})
+}
+
+// RestorePlan handles taking a plan out of the trash
+func RestorePlan(c *gin.Context) {
+	userID := c.GetString("user_id")
+	if userID == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	plan, err := planService.RestorePlan(userID, c.Param("id"))
+	if err != nil {
+		respondPlanError(c, err, "Failed to restore plan")
+		return
+	}
+
+	c.JSON(http.StatusOK, plan)
+}
+
+// UpdatePlan handles replacing the title, length and tasks of a practice plan
+func UpdatePlan(c *gin.Context) {
+	userID := c.GetString("user_id")
+	if userID == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	var req UpdatePlanRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	plan, err := planService.UpdatePlan(userID, c.Param("id"), req.Version, func(plan *models.PracticePlan) error {
+		plan.Title = req.Title
+		plan.Days = req.Days
+		plan.Tasks = req.Tasks
+		return nil
+	})
+	if err != nil {
+		respondPlanError(c, err, "Failed to update plan")
+		return
+	}
+
+	c.JSON(http.StatusOK, plan)
+}
+
+// PatchPlan handles changing the title or length of a practice plan
+func PatchPlan(c *gin.Context) {
+	userID := c.GetString("user_id")
+	if userID == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	var req PatchPlanRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	plan, err := planService.UpdatePlan(userID, c.Param("id"), req.Version, func(plan *models.PracticePlan) error {
+		if req.Title != nil {
+			plan.Title = *req.Title
+		}
+		if req.Days != nil {
+			plan.Days = *req.Days
+		}
+		return nil
+	})
+	if err != nil {
+		respondPlanError(c, err, "Failed to update plan")
+		return
+	}
+
+	c.JSON(http.StatusOK, plan)
+}
+
+// AddPlanTask handles adding a task to a practice plan
+func AddPlanTask(c *gin.Context) {
+	userID := c.GetString("user_id")
+	if userID == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	var req AddPlanTaskRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	plan, err := planService.UpdatePlan(userID, c.Param("id"), req.Version, func(plan *models.PracticePlan) error {
+		task := *req.Task
+		task.ID = ""
+		plan.Tasks = append(plan.Tasks, task)
+		return nil
+	})
+	if err != nil {
+		respondPlanError(c, err, "Failed to add task")
+		return
+	}
+
+	c.JSON(http.StatusOK, plan)
+}
+
+// PatchPlanTask handles changing some fields of one task of a practice plan
+func PatchPlanTask(c *gin.Context) {
+	userID := c.GetString("user_id")
+	if userID == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	var req PatchPlanTaskRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	plan, err := planService.UpdatePlan(userID, c.Param("id"), req.Version, func(plan *models.PracticePlan) error {
+		i := services.FindTask(plan, c.Param("taskId"))
+		if i < 0 {
+			return services.ErrPlanTaskNotFound
+		}
+		task := &plan.Tasks[i]
+		if req.Day != nil {
+			task.Day = *req.Day
+		}
+		if req.Title != nil {
+			task.Title = *req.Title
+		}
+		if req.Description != nil {
+			task.Description = *req.Description
+		}
+		if req.ScientificBasis != nil {
+			task.ScientificBasis = *req.ScientificBasis
+		}
+		if req.Type != nil {
+			task.Type = *req.Type
+		}
+		if req.DurationMinutes != nil {
+			task.DurationMinutes = *req.DurationMinutes
+		}
+		if req.TimeOfDay != nil {
+			task.TimeOfDay = *req.TimeOfDay
+		}
+		if req.Optional != nil {
+			task.Optional = *req.Optional
+		}
+		if req.ReferenceIDs != nil {
+			task.ReferenceIDs = *req.ReferenceIDs
+		}
+		return nil
+	})
+	if err != nil {
+		respondPlanError(c, err, "Failed to update task")
+		return
+	}
+
+	c.JSON(http.StatusOK, plan)
+}
+
+// DeletePlanTask handles removing a task from a practice plan.
+// Records that completed the task keep its ID; the task stays in earlier versions.
+func DeletePlanTask(c *gin.Context) {
+	userID := c.GetString("user_id")
+	if userID == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	expectedVersion, _ := strconv.Atoi(c.Query("version"))
+	plan, err := planService.UpdatePlan(userID, c.Param("id"), expectedVersion, func(plan *models.PracticePlan) error {
+		i := services.FindTask(plan, c.Param("taskId"))
+		if i < 0 {
+			return services.ErrPlanTaskNotFound
+		}
+		plan.Tasks = append(plan.Tasks[:i], plan.Tasks[i+1:]...)
+		return nil
+	})
+	if err != nil {
+		respondPlanError(c, err, "Failed to delete task")
+		return
+	}
+
+	c.JSON(http.StatusOK, plan)
+}
+
+// GetPlanVersions handles listing the version history of a practice plan
+func GetPlanVersions(c *gin.Context) {
+	userID := c.GetString("user_id")
+	if userID == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	versions, err := planService.GetVersions(userID, c.Param("id"))
+	if err != nil {
+		respondPlanError(c, err, "Failed to get plan versions")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"versions": versions})
+}
+
+// GetPlanVersion handles getting one version of a practice plan
+func GetPlanVersion(c *gin.Context) {
+	userID := c.GetString("user_id")
+	if userID == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	version, err := strconv.Atoi(c.Param("version"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid version"})
+		return
+	}
+
+	snapshot, err := planService.GetVersion(userID, c.Param("id"), version)
+	if err != nil {
+		respondPlanError(c, err, "Failed to get plan version")
+		return
+	}
+
+	c.JSON(http.StatusOK, snapshot)
+}
+
+// DiffPlanVersions handles comparing two versions of a practice plan.
+// "to" defaults to the current version and "from" to the version before it.
+func DiffPlanVersions(c *gin.Context) {
+	userID := c.GetString("user_id")
+	if userID == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	planID := c.Param("id")
+	plan, err := planService.GetPlan(userID, planID)
+	if err != nil {
+		respondPlanError(c, err, "Failed to get plan")
+		return
+	}
+
+	to := plan.Version
+	if value := c.Query("to"); value != "" {
+		if to, err = strconv.Atoi(value); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid to version"})
+			return
+		}
+	}
+	from := to - 1
+	if value := c.Query("from"); value != "" {
+		if from, err = strconv.Atoi(value); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid from version"})
+			return
+		}
+	}
+
+	diff, err := planService.DiffVersions(userID, planID, from, to)
+	if err != nil {
+		respondPlanError(c, err, "Failed to compare plan versions")
+		return
+	}
+
+	c.JSON(http.StatusOK, diff)
+}
+
+// RestorePlanVersion handles restoring the content of an earlier plan version as a new version
+func RestorePlanVersion(c *gin.Context) {
+	userID := c.GetString("user_id")
+	if userID == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	version, err := strconv.Atoi(c.Param("version"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid version"})
+		return
+	}
+
+	// The body is optional
+	var req RestorePlanVersionRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	plan, err := planService.RestoreVersion(userID, c.Param("id"), version, req.Version)
+	if err != nil {
+		respondPlanError(c, err, "Failed to restore plan version")
+		return
+	}
+
+	c.JSON(http.StatusOK, plan)
+}
+
+// GetTodayPlans handles getting today's tasks of all the user's running plans
+func GetTodayPlans(c *gin.Context) {
+	userID := c.GetString("user_id")
+	if userID == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	plans, err := planService.GetToday(userID, time.Now())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get today's tasks"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"plans": plans})
+}
+
+// UpdatePlanSchedule handles changing the start date, time zone and rest days of a plan
+func UpdatePlanSchedule(c *gin.Context) {
+	userID := c.GetString("user_id")
+	if userID == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	var req services.PlanSchedule
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	plan, err := planService.UpdateSchedule(userID, c.Param("id"), req)
+	if err != nil {
+		respondPlanError(c, err, "Failed to update plan schedule")
+		return
+	}
+
+	c.JSON(http.StatusOK, plan)
+}
+
+// PausePlan handles pausing a plan. Paused days do not count towards the plan.
+func PausePlan(c *gin.Context) {
+	userID := c.GetString("user_id")
+	if userID == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	plan, err := planService.PausePlan(userID, c.Param("id"), time.Now())
+	if err != nil {
+		respondPlanError(c, err, "Failed to pause plan")
+		return
+	}
+
+	c.JSON(http.StatusOK, plan)
+}
+
+// ResumePlan handles resuming a paused plan
+func ResumePlan(c *gin.Context) {
+	userID := c.GetString("user_id")
+	if userID == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	plan, err := planService.ResumePlan(userID, c.Param("id"), time.Now())
+	if err != nil {
+		respondPlanError(c, err, "Failed to resume plan")
+		return
+	}
+
+	c.JSON(http.StatusOK, plan)
+}
+
+// ActivatePlan handles starting a draft plan
+func ActivatePlan(c *gin.Context) {
+	changePlanStatus(c, planService.ActivatePlan, "Failed to activate plan")
+}
+
+// AbandonPlan handles giving up a plan
+func AbandonPlan(c *gin.Context) {
+	changePlanStatus(c, planService.AbandonPlan, "Failed to abandon plan")
+}
+
+// ArchivePlan handles archiving a completed or abandoned plan
+func ArchivePlan(c *gin.Context) {
+	changePlanStatus(c, planService.ArchivePlan, "Failed to archive plan")
+}
+
+// UnarchivePlan handles returning an archived plan to its previous status
+func UnarchivePlan(c *gin.Context) {
+	changePlanStatus(c, planService.UnarchivePlan, "Failed to unarchive plan")
+}
+
+// changePlanStatus runs a lifecycle transition of the plan in the path
+func changePlanStatus(c *gin.Context, change func(userID, planID string, now time.Time) (*models.PracticePlan, error), message string) {
+	userID := c.GetString("user_id")
+	if userID == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	plan, err := change(userID, c.Param("id"), time.Now())
+	if err != nil {
+		respondPlanError(c, err, message)
+		return
+	}
+
+	c.JSON(http.StatusOK, plan)
+}
+
+// ImportPlan handles creating a plan from the Markdown plan format
+func ImportPlan(c *gin.Context) {
+	userID := c.GetString("user_id")
+	if userID == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	var req ImportPlanRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	plan, err := planService.ImportMarkdown(userID, req.Markdown, req.PlanSchedule, req.DryRun)
+	if err != nil {
+		respondPlanError(c, err, "Failed to import plan")
+		return
+	}
+
+	c.JSON(http.StatusOK, plan)
+}
+
+// ExportPlanMarkdown handles downloading a plan in the Markdown plan format
+func ExportPlanMarkdown(c *gin.Context) {
+	userID := c.GetString("user_id")
+	if userID == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	plan, err := planService.GetPlan(userID, c.Param("id"))
+	if err != nil {
+		respondPlanError(c, err, "Failed to export plan")
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "Plan deleted"})
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", "plan-"+plan.ID+".md"))
+	c.Data(http.StatusOK, "text/markdown; charset=utf-8", []byte(services.RenderPlanMarkdown(plan)))
 }
@@ -1,25 +1,47 @@
 package controllers
 
 import (
+	"errors"
 	"net/http"
 	"time"
 
+	"neuro-guide-go-service/config"
 	"neuro-guide-go-service/models"
 	"neuro-guide-go-service/services"
 
 	"github.com/gin-gonic/gin"
 )
 
-var recordService = services.NewPracticeRecordService()
+var recordService *services.PracticeRecordService
+
+// InitPracticeRecordController initializes the practice record controller with config
+func InitPracticeRecordController(cfg *config.Config) {
+	recordService = services.NewPracticeRecordService(cfg)
+}
 
 // CreateRecordRequest represents a request to create a practice record
 type CreateRecordRequest struct {
 	PlanID         string    `json:"plan_id" binding:"required"`
+	Day            int       `json:"day" binding:"omitempty,min=1"` // plan day, derived from date when omitted
 	Date           time.Time `json:"date" binding:"required"`
-	CompletedTasks []string  `json:"completed_tasks"`
+	CompletedTasks []string  `json:"completed_tasks"` // task IDs
 	Reflection     string    `json:"reflection"`
 }
 
+// respondRecordError writes the error response for a failed record operation
+func respondRecordError(c *gin.Context, err error, message string) {
+	switch {
+	case errors.Is(err, services.ErrPlanNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Plan not found"})
+	case errors.Is(err, services.ErrRecordNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Record not found"})
+	case errors.Is(err, services.ErrInvalidCompletedTasks):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": message})
+	}
+}
+
 // CreateRecord handles creating a practice record
 func CreateRecord(c *gin.Context) {
 	userID := c.GetString("user_id")
@@ -37,13 +59,14 @@ func CreateRecord(c *gin.Context) {
 	record := &models.PracticeRecord{
 		UserID:         userID,
 		PlanID:         req.PlanID,
+		Day:            req.Day,
 		Date:           req.Date,
 		CompletedTasks: req.CompletedTasks,
 		Reflection:     req.Reflection,
 	}
 
 	if err := recordService.CreateRecord(record); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create record"})
+		respondRecordError(c, err, "Failed to create record")
 		return
 	}
 
@@ -52,12 +75,19 @@ func CreateRecord(c *gin.Context) {
 
 // UpdateRecordRequest represents a request to update a practice record
 type UpdateRecordRequest struct {
+	Day            int      `json:"day" binding:"omitempty,min=1"`
 	CompletedTasks []string `json:"completed_tasks"`
 	Reflection     string   `json:"reflection"`
 }
 
 // UpdateRecord handles updating a practice record
 func UpdateRecord(c *gin.Context) {
+	userID := c.GetString("user_id")
+	if userID == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
 	recordID := c.Param("id")
 	if recordID == "" {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Record ID is required"})
@@ -70,21 +100,32 @@ func UpdateRecord(c *gin.Context) {
 		return
 	}
 
-	record, err := recordService.GetRecordByID(recordID)
+	record, err := recordService.GetRecordByID(userID, recordID)
 	if err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Record not found"})
 		return
 	}
 
-	if req.CompletedTasks != nil {
-		record.CompletedTasks = req.CompletedTasks
-	}
 	if req.Reflection != "" {
 		record.Reflection = req.Reflection
 	}
+	if req.Day != 0 {
+		record.Day = req.Day
+	}
+
+	// Changed tasks are checked against the current plan
+	if req.CompletedTasks != nil || req.Day != 0 {
+		if req.CompletedTasks != nil {
+			record.CompletedTasks = req.CompletedTasks
+		}
+		if err := recordService.LinkPlan(record); err != nil {
+			respondRecordError(c, err, "Failed to update record")
+			return
+		}
+	}
 
 	if err := recordService.UpdateRecord(record); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update record"})
+		respondRecordError(c, err, "Failed to update record")
 		return
 	}
 
@@ -93,13 +134,19 @@ func UpdateRecord(c *gin.Context) {
 
 // GetRecord handles getting a practice record
 func GetRecord(c *gin.Context) {
+	userID := c.GetString("user_id")
+	if userID == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
 	recordID := c.Param("id")
 	if recordID == "" {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Record ID is required"})
 		return
 	}
 
-	record, err := recordService.GetRecordByID(recordID)
+	record, err := recordService.GetRecordByID(userID, recordID)
 	if err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Record not found"})
 		return
@@ -118,7 +165,7 @@ func GetRecords(c *gin.Context) {
 
 	planID := c.Query("plan_id")
 	if planID != "" {
-		records, err := recordService.GetRecordsByPlanID(planID)
+		records, err := recordService.GetRecordsByPlanID(userID, planID)
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get records"})
 			return
@@ -0,0 +1,203 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"neuro-guide-go-service/config"
+	"neuro-guide-go-service/models"
+	"neuro-guide-go-service/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+var referenceService *services.ReferenceService
+
+// InitReferenceController initializes the reference controller with config
+func InitReferenceController(cfg *config.Config) {
+	referenceService = services.NewReferenceService(cfg)
+}
+
+// ReferenceRequest represents a request to create or replace a reference
+type ReferenceRequest struct {
+	Authors  []models.ReferenceAuthor `json:"authors" binding:"required,max=100"`
+	Year     int                      `json:"year" binding:"required"`
+	Title    string                   `json:"title" binding:"required,max=1000"`
+	Journal  string                   `json:"journal" binding:"max=300"`
+	Volume   string                   `json:"volume" binding:"max=20"`
+	Issue    string                   `json:"issue" binding:"max=20"`
+	Pages    string                   `json:"pages" binding:"max=40"`
+	DOI      string                   `json:"doi" binding:"max=300"`
+	PubMedID string                   `json:"pubmed_id" binding:"max=20"`
+}
+
+func (req *ReferenceRequest) reference() *models.Reference {
+	return &models.Reference{
+		Authors:  req.Authors,
+		Year:     req.Year,
+		Title:    req.Title,
+		Journal:  req.Journal,
+		Volume:   req.Volume,
+		Issue:    req.Issue,
+		Pages:    req.Pages,
+		DOI:      req.DOI,
+		PubMedID: req.PubMedID,
+	}
+}
+
+// ResolveReferencesRequest represents a request to find the references a text cites
+type ResolveReferencesRequest struct {
+	Text string `json:"text" binding:"required,max=10000"`
+}
+
+// respondReferenceError writes the error response for a failed reference operation
+func respondReferenceError(c *gin.Context, err error, message string) {
+	switch {
+	case errors.Is(err, services.ErrReferenceNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Reference not found"})
+	case errors.Is(err, services.ErrInvalidReference):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrDuplicateReference):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		respondPlanError(c, err, message)
+	}
+}
+
+// ListReferences handles searching the reference library
+func ListReferences(c *gin.Context) {
+	query := services.ReferenceQuery{Query: c.Query("q")}
+	if year := c.Query("year"); year != "" {
+		parsed, err := strconv.Atoi(year)
+		if err != nil || parsed < 1 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "year must be a positive number"})
+			return
+		}
+		query.Year = parsed
+	}
+
+	refs, err := referenceService.ListReferences(query)
+	if err != nil {
+		respondReferenceError(c, err, "Failed to list references")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"references": refs})
+}
+
+// GetReference handles getting a reference, formatted in both bibliography styles
+func GetReference(c *gin.Context) {
+	ref, err := referenceService.GetReference(c.Param("id"))
+	if err != nil {
+		respondReferenceError(c, err, "Failed to get reference")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"reference": ref,
+		"apa":       services.FormatAPA(ref),
+		"gbt7714":   services.FormatGBT7714(ref),
+	})
+}
+
+// ResolveReferences handles finding the references a free-form text cites
+func ResolveReferences(c *gin.Context) {
+	var req ResolveReferencesRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	refs, err := referenceService.ResolveText(req.Text)
+	if err != nil {
+		respondReferenceError(c, err, "Failed to resolve references")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"references": refs})
+}
+
+// GetPlanReferences handles resolving the references of a plan, task by task
+func GetPlanReferences(c *gin.Context) {
+	userID := c.GetString("user_id")
+	if userID == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	refs, err := referenceService.PlanReferences(userID, c.Param("id"))
+	if err != nil {
+		respondReferenceError(c, err, "Failed to get plan references")
+		return
+	}
+
+	c.JSON(http.StatusOK, refs)
+}
+
+// GetPlanBibliography handles rendering the references of a plan in a bibliography style
+func GetPlanBibliography(c *gin.Context) {
+	userID := c.GetString("user_id")
+	if userID == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	style := c.DefaultQuery("style", models.BibliographyAPA)
+	if !models.IsValidBibliographyStyle(style) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "style must be apa or gbt7714"})
+		return
+	}
+
+	entries, err := referenceService.PlanBibliography(userID, c.Param("id"), style)
+	if err != nil {
+		respondReferenceError(c, err, "Failed to render bibliography")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"style": style, "entries": entries})
+}
+
+// CreateReference handles adding a reference to the library
+func CreateReference(c *gin.Context) {
+	var req ReferenceRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ref := req.reference()
+	if err := referenceService.CreateReference(ref); err != nil {
+		respondReferenceError(c, err, "Failed to create reference")
+		return
+	}
+
+	c.JSON(http.StatusOK, ref)
+}
+
+// UpdateReference handles replacing a reference
+func UpdateReference(c *gin.Context) {
+	var req ReferenceRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ref, err := referenceService.UpdateReference(c.Param("id"), req.reference())
+	if err != nil {
+		respondReferenceError(c, err, "Failed to update reference")
+		return
+	}
+
+	c.JSON(http.StatusOK, ref)
+}
+
+// DeleteReference handles removing a reference from the library
+func DeleteReference(c *gin.Context) {
+	if err := referenceService.DeleteReference(c.Param("id")); err != nil {
+		respondReferenceError(c, err, "Failed to delete reference")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Reference deleted"})
+}
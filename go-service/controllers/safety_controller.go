@@ -0,0 +1,75 @@
+package controllers
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+
+	"neuro-guide-go-service/config"
+	"neuro-guide-go-service/models"
+	"neuro-guide-go-service/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+var safetyService *services.SafetyService
+
+// InitSafetyController initializes the safety controller with config
+func InitSafetyController(cfg *config.Config) {
+	safetyService = services.NewSafetyService(cfg)
+}
+
+// ReviewSafetyEventRequest represents the outcome of reviewing a safety event
+type ReviewSafetyEventRequest struct {
+	Note string `json:"note" binding:"max=2000"`
+}
+
+// GetSafetyEvents handles listing safety events for review. Pending events are listed by default;
+// status=reviewed or status=all lists the others.
+func GetSafetyEvents(c *gin.Context) {
+	status := c.DefaultQuery("status", models.SafetyEventPendingReview)
+	switch status {
+	case models.SafetyEventPendingReview, models.SafetyEventReviewed:
+	case "all":
+		status = ""
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "status must be pending_review, reviewed or all"})
+		return
+	}
+
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	if err != nil || limit < 1 || limit > 200 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "limit must be between 1 and 200"})
+		return
+	}
+
+	events, err := safetyService.ListEvents(status, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get safety events"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"events": events})
+}
+
+// ReviewSafetyEvent handles marking a safety event as reviewed
+func ReviewSafetyEvent(c *gin.Context) {
+	var req ReviewSafetyEventRequest
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	event, err := safetyService.ReviewEvent(c.Param("id"), req.Note)
+	if errors.Is(err, services.ErrSafetyEventNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Safety event not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to review safety event"})
+		return
+	}
+
+	c.JSON(http.StatusOK, event)
+}
@@ -0,0 +1,76 @@
+package controllers
+
+import (
+	"net/http"
+	"strconv"
+
+	"neuro-guide-go-service/config"
+	"neuro-guide-go-service/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+var usageService *services.UsageService
+
+// InitUsageController initializes the usage controller with config
+func InitUsageController(cfg *config.Config) {
+	usageService = services.NewUsageService(cfg)
+}
+
+// GetUsage handles getting the current user's usage and quota
+func GetUsage(c *gin.Context) {
+	userID := c.GetString("user_id")
+	if userID == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	days := 7
+	if daysStr := c.Query("days"); daysStr != "" {
+		parsed, err := strconv.Atoi(daysStr)
+		if err != nil || parsed < 1 || parsed > 90 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "days must be between 1 and 90"})
+			return
+		}
+		days = parsed
+	}
+
+	status, err := usageService.GetUsageStatus(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get usage"})
+		return
+	}
+
+	history, err := usageService.GetUsageHistory(userID, days)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get usage"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"today":    status.Today,
+		"limits":   status.Limits,
+		"is_guest": status.IsGuest,
+		"reset_at": status.ResetAt,
+		"history":  history,
+	})
+}
+
+// GetUsageReport handles the admin usage report aggregated per day
+func GetUsageReport(c *gin.Context) {
+	to := c.DefaultQuery("to", usageService.Today())
+	from := c.DefaultQuery("from", to)
+
+	if services.ValidateUsageDate(from) != nil || services.ValidateUsageDate(to) != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "from and to must be dates in YYYY-MM-DD format"})
+		return
+	}
+
+	report, err := usageService.GetUsageReport(from, to)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get usage report"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"from": from, "to": to, "report": report})
+}
@@ -3,10 +3,14 @@ package main
 import (
 	"log"
 	"os"
+	"strconv"
+	"strings"
+	"time"
 
 	"neuro-guide-go-service/config"
 	"neuro-guide-go-service/database"
 	"neuro-guide-go-service/routes"
+	"neuro-guide-go-service/services"
 
 	"github.com/joho/godotenv"
 )
@@ -27,6 +31,50 @@ func main() {
 		WeChatAppID:        os.Getenv("WECHAT_APP_ID"),
 		WeChatSecret:       os.Getenv("WECHAT_APP_SECRET"),
 		PythonAIServiceURL: os.Getenv("PYTHON_AI_SERVICE_URL"),
+		AIRoutingFile:      os.Getenv("AI_ROUTING_FILE"),
+
+		EncryptionMasterKey:        os.Getenv("ENCRYPTION_MASTER_KEY"),
+		EncryptionMasterKeyVersion: getEnvInt("ENCRYPTION_MASTER_KEY_VERSION", 1),
+		EncryptionKeyFile:          os.Getenv("ENCRYPTION_KEY_FILE"),
+		KeyRotationInterval:        getEnvDuration("KEY_ROTATION_INTERVAL", time.Hour),
+
+		PIIDetectors:        getEnvList("PII_DETECTORS"),
+		PIIRestoreDetectors: getEnvList("PII_RESTORE_DETECTORS"),
+
+		SafetyRulesFile:           os.Getenv("SAFETY_RULES_FILE"),
+		SafetyRiskThreshold:       os.Getenv("SAFETY_RISK_THRESHOLD"),
+		SafetyAIClassifierEnabled: os.Getenv("SAFETY_AI_CLASSIFIER_ENABLED") == "true",
+
+		GuestDailyRequests: getEnvInt("QUOTA_GUEST_DAILY_REQUESTS", 20),
+		GuestDailyTokens:   getEnvInt("QUOTA_GUEST_DAILY_TOKENS", 20000),
+		UserDailyRequests:  getEnvInt("QUOTA_USER_DAILY_REQUESTS", 200),
+		UserDailyTokens:    getEnvInt("QUOTA_USER_DAILY_TOKENS", 200000),
+		QuotaTimeZone:      os.Getenv("QUOTA_TIME_ZONE"),
+		AdminToken:         os.Getenv("ADMIN_TOKEN"),
+
+		SummaryEveryNTurns:   getEnvInt("SUMMARY_EVERY_N_TURNS", 10),
+		SummaryIdleAfter:     getEnvDuration("SUMMARY_IDLE_AFTER", 30*time.Minute),
+		SummaryCheckInterval: getEnvDuration("SUMMARY_CHECK_INTERVAL", 5*time.Minute),
+
+		ExportPDFFontPath: os.Getenv("EXPORT_PDF_FONT_PATH"),
+
+		VoiceStorageDir:     os.Getenv("VOICE_STORAGE_DIR"),
+		VoiceMaxUploadBytes: int64(getEnvInt("VOICE_MAX_UPLOAD_BYTES", 10<<20)),
+		Transcriber:         os.Getenv("TRANSCRIBER"),
+		TranscriberCommand:  os.Getenv("TRANSCRIBER_COMMAND"),
+		TranscriberStubText: os.Getenv("TRANSCRIBER_STUB_TEXT"),
+
+		CheckInEnabled:        os.Getenv("CHECKIN_ENABLED") != "false",
+		CheckInInterval:       getEnvDuration("CHECKIN_CHECK_INTERVAL", 5*time.Minute),
+		CheckInDefaultTimes:   getEnvList("CHECKIN_DEFAULT_TIMES"),
+		CheckInMaxPerWeek:     getEnvInt("CHECKIN_MAX_PER_WEEK", 3),
+		CheckInQuietAfterChat: getEnvDuration("CHECKIN_QUIET_AFTER_CHAT", time.Hour),
+
+		PlanAdjustEnabled:  os.Getenv("PLAN_ADJUST_ENABLED") != "false",
+		PlanAdjustInterval: getEnvDuration("PLAN_ADJUST_INTERVAL", 24*time.Hour),
+
+		PlanTrashRetention: getEnvDuration("PLAN_TRASH_RETENTION", 30*24*time.Hour),
+		DeletedPlanRecords: os.Getenv("DELETED_PLAN_RECORDS"),
 	}
 
 	if cfg.Port == "" {
@@ -41,11 +89,79 @@ func main() {
 		cfg.MongoDBName = "neuro_guide" // 默认数据库名
 	}
 
+	if cfg.VoiceStorageDir == "" {
+		cfg.VoiceStorageDir = "data/audio" // 默认语音文件目录
+	}
+
 	// 初始化数据库
 	if err := database.InitDB(cfg); err != nil {
 		log.Fatalf("Failed to initialize database: %v", err)
 	}
 
+	// 初始化字段加密
+	if err := services.InitEncryption(cfg); err != nil {
+		log.Fatalf("Failed to initialize encryption: %v", err)
+	}
+	if es := services.GetEncryptionService(); es != nil {
+		es.StartKeyRotationJob(cfg.KeyRotationInterval)
+	}
+
+	// 创建聊天消息索引
+	if err := services.EnsureChatIndexes(); err != nil {
+		log.Fatalf("Failed to create chat indexes: %v", err)
+	}
+
+	// 创建用量统计索引
+	if err := services.EnsureUsageIndexes(); err != nil {
+		log.Fatalf("Failed to create usage indexes: %v", err)
+	}
+
+	// 创建对话摘要索引
+	if err := services.EnsureSummaryIndexes(); err != nil {
+		log.Fatalf("Failed to create summary indexes: %v", err)
+	}
+
+	// 创建笔记本索引
+	if err := services.EnsureNotebookIndexes(); err != nil {
+		log.Fatalf("Failed to create notebook indexes: %v", err)
+	}
+
+	// 创建修行计划索引
+	if err := services.EnsurePlanIndexes(); err != nil {
+		log.Fatalf("Failed to create plan indexes: %v", err)
+	}
+
+	// 创建文献索引
+	if err := services.EnsureReferenceIndexes(); err != nil {
+		log.Fatalf("Failed to create reference indexes: %v", err)
+	}
+
+	// 创建挑战小组索引
+	if err := services.EnsureCohortIndexes(); err != nil {
+		log.Fatalf("Failed to create cohort indexes: %v", err)
+	}
+
+	// 加载PDF导出字体
+	services.LoadPDFFont(cfg.ExportPDFFontPath)
+
+	// 启动空闲对话摘要任务
+	services.NewSummaryService(cfg).StartIdleSummarizer(cfg.SummaryCheckInterval)
+
+	// 启动主动问候任务
+	if cfg.CheckInEnabled {
+		services.NewCheckInService(cfg).StartScheduler(cfg.CheckInInterval)
+	}
+
+	// 启动计划自适应调整评估任务
+	if cfg.PlanAdjustEnabled {
+		services.NewPlanAdjustmentService(cfg).StartEvaluator(cfg.PlanAdjustInterval)
+	}
+
+	// 启动计划回收站清理任务
+	if cfg.PlanTrashRetention > 0 {
+		services.NewPracticePlanService(cfg).StartTrashPurger(cfg.PlanTrashRetention)
+	}
+
 	// 初始化路由
 	router := routes.InitRouter(cfg)
 
@@ -55,3 +171,42 @@ func main() {
 		log.Fatalf("Failed to start server: %v", err)
 	}
 }
+
+// getEnvInt reads an integer environment variable with a default value
+func getEnvInt(key string, defaultValue int) int {
+	if value := os.Getenv(key); value != "" {
+		if parsed, err := strconv.Atoi(value); err == nil {
+			return parsed
+		}
+		log.Printf("Invalid value for %s: %q, using default %d", key, value, defaultValue)
+	}
+	return defaultValue
+}
+
+// getEnvDuration reads a duration environment variable (e.g. "30m") with a default value
+func getEnvDuration(key string, defaultValue time.Duration) time.Duration {
+	if value := os.Getenv(key); value != "" {
+		if parsed, err := time.ParseDuration(value); err == nil {
+			return parsed
+		}
+		log.Printf("Invalid value for %s: %q, using default %s", key, value, defaultValue)
+	}
+	return defaultValue
+}
+
+// getEnvList reads a comma separated environment variable, returning nil when it is unset.
+// The value "none" yields an empty list.
+func getEnvList(key string) []string {
+	value, ok := os.LookupEnv(key)
+	if !ok {
+		return nil
+	}
+
+	items := []string{}
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" && item != "none" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
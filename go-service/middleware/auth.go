@@ -1,6 +1,7 @@
 package middleware
 
 import (
+	"crypto/subtle"
 	"net/http"
 	"strings"
 
@@ -11,10 +12,12 @@ import (
 )
 
 var wechatAuthService *services.WeChatAuthService
+var adminToken string
 
 // InitAuthMiddleware initializes the auth middleware with config
 func InitAuthMiddleware(cfg *config.Config) {
 	wechatAuthService = services.NewWeChatAuthService(cfg, services.NewUserService())
+	adminToken = cfg.AdminToken
 }
 
 // AuthMiddleware is a simple authentication middleware
@@ -84,4 +87,24 @@ func OptionalAuthMiddleware() gin.HandlerFunc {
 
 		c.Next()
 	}
-}
\ No newline at end of file
+}
+
+// AdminMiddleware protects admin endpoints with the X-Admin-Token header
+func AdminMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if adminToken == "" {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Admin API is disabled"})
+			c.Abort()
+			return
+		}
+
+		token := c.GetHeader("X-Admin-Token")
+		if subtle.ConstantTimeCompare([]byte(token), []byte(adminToken)) != 1 {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid admin token"})
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
@@ -0,0 +1,18 @@
+package models
+
+import (
+	"time"
+)
+
+// AudioFile is a voice message uploaded by a user
+type AudioFile struct {
+	ID          string    `json:"id" bson:"_id,omitempty"`
+	UserID      string    `json:"user_id" bson:"user_id"`
+	StorageKey  string    `json:"-" bson:"storage_key"`           // 文件在存储中的位置
+	Format      string    `json:"format" bson:"format"`           // silk, mp3 or aac
+	Size        int64     `json:"size" bson:"size"`               // 字节数
+	Transcript  string    `json:"transcript" bson:"transcript"`   // 语音识别文本，加密存储
+	Transcriber string    `json:"transcriber" bson:"transcriber"` // 使用的语音识别引擎
+	CreatedAt   time.Time `json:"created_at" bson:"created_at"`
+	KeyVersion  int       `json:"-" bson:"key_version,omitempty"`
+}
@@ -0,0 +1,13 @@
+package models
+
+import (
+	"time"
+)
+
+// CalendarFeed is a user's secret calendar subscription. Anyone with the token can read the user's plans.
+type CalendarFeed struct {
+	ID        string    `json:"id" bson:"_id,omitempty"`
+	UserID    string    `json:"user_id" bson:"user_id"`
+	Token     string    `json:"token" bson:"token"`
+	CreatedAt time.Time `json:"created_at" bson:"created_at"` // 令牌生成时间，重置后更新
+}
@@ -12,6 +12,96 @@ type ChatMessage struct {
 	Role            string           `json:"role" bson:"role"` // user or assistant
 	Timestamp       time.Time        `json:"timestamp" bson:"timestamp"`
 	EmotionAnalysis *EmotionAnalysis `json:"emotion_analysis,omitempty" bson:"emotion_analysis,omitempty"`
+	Flagged         bool             `json:"flagged,omitempty" bson:"flagged,omitempty"`     // 触发安全机制，待人工复核
+	KeyVersion      int              `json:"-" bson:"key_version,omitempty"`                 // 加密数据密钥版本
+	Mode            string           `json:"mode,omitempty" bson:"mode,omitempty"`           // 对话模式
+	Parts           *ResponseParts   `json:"parts,omitempty" bson:"-"`                       // 结构化回复内容，加密后存储在 parts 字段
+	AudioID         string           `json:"audio_id,omitempty" bson:"audio_id,omitempty"`   // 语音消息的音频文件
+	Status          string           `json:"status,omitempty" bson:"status,omitempty"`       // 用户消息的处理状态
+	ReplyID         string           `json:"reply_id,omitempty" bson:"reply_id,omitempty"`   // 用户消息对应的助手回复
+	IdempotencyKey  string           `json:"-" bson:"idempotency_key,omitempty"`             // 客户端提供的幂等键
+	Variant         *AIAssignment    `json:"variant,omitempty" bson:"variant,omitempty"`     // 生成回复的AI后端和实验分组
+	Proactive       bool             `json:"proactive,omitempty" bson:"proactive,omitempty"` // 助手主动发起的问候
+	Feedback        int              `json:"feedback,omitempty" bson:"feedback,omitempty"`   // 用户对助手回复的评价，1为赞，-1为踩
+}
+
+// AIAssignment records which AI backend, and which experiment variant if any, produced a reply
+type AIAssignment struct {
+	Backend    string `json:"backend" bson:"backend"`
+	Experiment string `json:"experiment,omitempty" bson:"experiment,omitempty"`
+	Variant    string `json:"variant,omitempty" bson:"variant,omitempty"`
+}
+
+// Processing status of a user message
+const (
+	MessageStatusPending   = "pending"   // 已保存，等待AI回复
+	MessageStatusCompleted = "completed" // 已与助手回复一同保存
+	MessageStatusFailed    = "failed"    // 未能生成回复，可使用同一幂等键重试
+)
+
+// Feedback a user can give on an assistant reply
+const (
+	FeedbackThumbsUp   = 1
+	FeedbackThumbsDown = -1
+)
+
+// IsValidFeedback reports whether rating is a thumbs rating, or 0 to clear it
+func IsValidFeedback(rating int) bool {
+	return rating == 0 || rating == FeedbackThumbsUp || rating == FeedbackThumbsDown
+}
+
+// Chat modes supported by the AI service
+const (
+	ChatModeChat                = "chat"                 // 自由对话
+	ChatModeQuoteInterpretation = "quote_interpretation" // 修行语录神经科学解读
+	ChatModeSymptomMechanism    = "symptom_mechanism"    // 症状-机制解释
+	ChatModeKnowledgeQA         = "knowledge_qa"         // 知识问答
+)
+
+// IsValidChatMode reports whether mode is a supported chat mode
+func IsValidChatMode(mode string) bool {
+	switch mode {
+	case ChatModeChat, ChatModeQuoteInterpretation, ChatModeSymptomMechanism, ChatModeKnowledgeQA:
+		return true
+	}
+	return false
+}
+
+// ResponseParts holds the structured parts of an assistant reply so clients can render them as cards
+type ResponseParts struct {
+	Mechanisms []Mechanism         `json:"mechanisms,omitempty"`
+	Practices  []SuggestedPractice `json:"practices,omitempty"`
+	Citations  []Citation          `json:"citations,omitempty"`
+}
+
+// IsEmpty reports whether the reply has no structured parts
+func (p *ResponseParts) IsEmpty() bool {
+	return p == nil || (len(p.Mechanisms) == 0 && len(p.Practices) == 0 && len(p.Citations) == 0)
+}
+
+// Mechanism describes the neural mechanism behind a quote or symptom
+type Mechanism struct {
+	Name         string   `json:"name"`
+	BrainRegions []string `json:"brain_regions,omitempty"` // 相关脑区，如前额叶皮层、杏仁核
+	Pathways     []string `json:"pathways,omitempty"`      // 相关神经通路或神经递质系统
+	Description  string   `json:"description,omitempty"`
+}
+
+// SuggestedPractice is a practice the assistant recommends
+type SuggestedPractice struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	Duration    string `json:"duration,omitempty"`
+}
+
+// Citation points to a knowledge base document the reply is based on
+type Citation struct {
+	SourceDocumentID string `json:"source_document_id"`
+	Title            string `json:"title,omitempty"`
+	Excerpt          string `json:"excerpt,omitempty"`
+	DOI              string `json:"doi,omitempty"`
+	PubMedID         string `json:"pubmed_id,omitempty"`
+	ReferenceID      string `json:"reference_id,omitempty"` // 与文献库中的文献对应时设置
 }
 
 // EmotionAnalysis represents emotion analysis result
@@ -19,3 +109,15 @@ type EmotionAnalysis struct {
 	Emotion    string  `json:"emotion" bson:"emotion"`
 	Confidence float64 `json:"confidence" bson:"confidence"`
 }
+
+// VariantStats aggregates the replies produced by one experiment variant
+type VariantStats struct {
+	Experiment string  `json:"experiment" bson:"experiment"`
+	Variant    string  `json:"variant" bson:"variant"`
+	Backend    string  `json:"backend" bson:"backend"`
+	Replies    int     `json:"replies" bson:"replies"`
+	Users      int     `json:"users" bson:"users"`
+	ActiveDays float64 `json:"active_days" bson:"active_days"` // 平均每个用户有对话的天数，用于比较留存
+	ThumbsUp   int     `json:"thumbs_up" bson:"thumbs_up"`     // 被用户点赞的回复数
+	ThumbsDown int     `json:"thumbs_down" bson:"thumbs_down"` // 被用户点踩的回复数
+}
@@ -0,0 +1,16 @@
+package models
+
+import (
+	"time"
+)
+
+// CheckInPreference controls the proactive check-in messages a user receives
+type CheckInPreference struct {
+	UserID        string     `json:"user_id" bson:"user_id"`
+	Enabled       bool       `json:"enabled" bson:"enabled"`           // false表示用户已关闭主动问候
+	Times         []string   `json:"times" bson:"times"`               // 本地时间 HH:MM
+	TimeZone      string     `json:"time_zone" bson:"time_zone"`       // IANA时区，如 Asia/Shanghai
+	MaxPerWeek    int        `json:"max_per_week" bson:"max_per_week"` // 每7天最多主动问候次数
+	LastCheckInAt *time.Time `json:"last_checkin_at,omitempty" bson:"last_checkin_at,omitempty"`
+	UpdatedAt     time.Time  `json:"updated_at" bson:"updated_at"`
+}
@@ -0,0 +1,67 @@
+package models
+
+import (
+	"time"
+)
+
+// Cohort member roles
+const (
+	CohortRoleOrganizer = "organizer"
+	CohortRoleMember    = "member"
+)
+
+// Cohort is a group of users who follow the same plan on the same schedule, such as a
+// 21-day mindfulness challenge. Every member practices with their own copy of the plan.
+type Cohort struct {
+	ID           string     `json:"id" bson:"_id,omitempty"`
+	Name         string     `json:"name" bson:"name"`
+	Description  string     `json:"description,omitempty" bson:"description,omitempty"`
+	OrganizerID  string     `json:"-" bson:"organizer_id"`
+	IsOrganizer  bool       `json:"is_organizer" bson:"-"`                // 请求者是否为组织者
+	JoinCode     string     `json:"join_code,omitempty" bson:"join_code"` // 只返回给组织者
+	SourcePlanID string     `json:"source_plan_id" bson:"source_plan_id"` // 组织者用于创建挑战的计划
+	Title        string     `json:"title" bson:"title"`
+	Days         int        `json:"days" bson:"days"`
+	Tasks        []PlanTask `json:"tasks" bson:"tasks"`
+	StartDate    string     `json:"start_date" bson:"start_date"`
+	TimeZone     string     `json:"time_zone,omitempty" bson:"time_zone,omitempty"`
+	RestWeekdays []int      `json:"rest_weekdays,omitempty" bson:"rest_weekdays,omitempty"`
+	MaxMembers   int        `json:"max_members,omitempty" bson:"max_members,omitempty"` // 0表示不限
+	MemberCount  int        `json:"member_count" bson:"member_count"`
+	CreatedAt    time.Time  `json:"created_at" bson:"created_at"`
+}
+
+// CohortMember is a user's membership of a cohort
+type CohortMember struct {
+	ID          string    `json:"id" bson:"_id,omitempty"`
+	CohortID    string    `json:"cohort_id" bson:"cohort_id"`
+	UserID      string    `json:"-" bson:"user_id"`
+	DisplayName string    `json:"display_name" bson:"display_name"`
+	Role        string    `json:"role" bson:"role"`
+	PlanID      string    `json:"-" bson:"plan_id"` // 成员自己的计划副本
+	JoinedAt    time.Time `json:"joined_at" bson:"joined_at"`
+}
+
+// CohortAnnouncement is a message the organizer posts to a cohort
+type CohortAnnouncement struct {
+	ID        string    `json:"id" bson:"_id,omitempty"`
+	CohortID  string    `json:"cohort_id" bson:"cohort_id"`
+	Text      string    `json:"text" bson:"text"`
+	CreatedAt time.Time `json:"created_at" bson:"created_at"`
+}
+
+// CohortProgress is how a cohort is doing, without showing how any member is doing
+type CohortProgress struct {
+	CohortID string              `json:"cohort_id"`
+	Members  int                 `json:"members"`
+	Today    int                 `json:"today"` // cohort plan day today, 0 before the start
+	Days     []CohortDayProgress `json:"days"`  // days that have started
+}
+
+// CohortDayProgress is the share of members who practiced on one plan day
+type CohortDayProgress struct {
+	Day            int     `json:"day"`
+	Date           string  `json:"date"`
+	CompletionRate float64 `json:"completion_rate"` // 完成当天全部必做任务的成员比例
+	PracticeRate   float64 `json:"practice_rate"`   // 完成至少一个任务的成员比例
+}
@@ -0,0 +1,19 @@
+package models
+
+import (
+	"time"
+)
+
+// ConversationSummary is one version of the rolling summary of a user's conversation
+type ConversationSummary struct {
+	ID           string    `json:"id" bson:"_id,omitempty"`
+	UserID       string    `json:"user_id" bson:"user_id"`
+	Version      int       `json:"version" bson:"version"`
+	Title        string    `json:"title" bson:"title"`
+	Summary      string    `json:"summary" bson:"summary"`
+	MessageCount int       `json:"message_count" bson:"message_count"` // messages covered by this version
+	CoveredUntil time.Time `json:"covered_until" bson:"covered_until"` // timestamp of the last summarized message
+	Trigger      string    `json:"trigger" bson:"trigger"`             // turns or idle
+	CreatedAt    time.Time `json:"created_at" bson:"created_at"`
+	KeyVersion   int       `json:"-" bson:"key_version,omitempty"`
+}
@@ -0,0 +1,34 @@
+package models
+
+import (
+	"time"
+)
+
+// NotebookEntry is an assistant answer saved to a user's notebook.
+// The answer is copied so the entry survives clearing the chat history.
+type NotebookEntry struct {
+	ID               string         `json:"id" bson:"_id,omitempty"`
+	UserID           string         `json:"user_id" bson:"user_id"`
+	MessageID        string         `json:"message_id" bson:"message_id"`               // 来源助手消息
+	Content          string         `json:"content" bson:"content"`                     // 回答内容快照，加密存储
+	Parts            *ResponseParts `json:"parts,omitempty" bson:"-"`                   // 结构化内容快照，加密后存储在 parts 字段
+	Mode             string         `json:"mode,omitempty" bson:"mode,omitempty"`       // 来源消息的对话模式
+	MessageTimestamp time.Time      `json:"message_timestamp" bson:"message_timestamp"` // 来源消息时间
+	Note             string         `json:"note" bson:"note"`                           // 用户笔记，加密存储
+	Tags             []string       `json:"tags" bson:"tags"`
+	CollectionIDs    []string       `json:"collection_ids" bson:"collection_ids"`
+	CreatedAt        time.Time      `json:"created_at" bson:"created_at"`
+	UpdatedAt        time.Time      `json:"updated_at" bson:"updated_at"`
+	KeyVersion       int            `json:"-" bson:"key_version,omitempty"`
+}
+
+// NotebookCollection groups notebook entries
+type NotebookCollection struct {
+	ID          string    `json:"id" bson:"_id,omitempty"`
+	UserID      string    `json:"user_id" bson:"user_id"`
+	Name        string    `json:"name" bson:"name"`
+	Description string    `json:"description" bson:"description"`
+	EntryCount  int       `json:"entry_count" bson:"-"`
+	CreatedAt   time.Time `json:"created_at" bson:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at" bson:"updated_at"`
+}
@@ -0,0 +1,56 @@
+package models
+
+import (
+	"time"
+)
+
+// Adjustment kinds
+const (
+	AdjustmentLighten  = "lighten"  // 连续未完成后减轻任务
+	AdjustmentProgress = "progress" // 连续完成后加强任务
+)
+
+// Adjustment statuses
+const (
+	AdjustmentPending    = "pending"
+	AdjustmentAccepted   = "accepted"
+	AdjustmentRejected   = "rejected"
+	AdjustmentSuperseded = "superseded" // 被更新的评估或计划修改取代
+)
+
+// SkippedTask is a task that was missed on several days of the evaluated period.
+// Daily tasks have a new ID every day, so they are grouped by title.
+type SkippedTask struct {
+	Title     string `json:"title"`
+	Scheduled int    `json:"scheduled"`
+	Missed    int    `json:"missed"`
+}
+
+// PlanAdherence summarizes how a user followed a plan over the last evaluated days
+type PlanAdherence struct {
+	FromDay         int           `json:"from_day"`
+	ToDay           int           `json:"to_day"`
+	CompletionRate  float64       `json:"completion_rate"`  // 必做任务完成比例
+	CompletedStreak int           `json:"completed_streak"` // 最近连续完成全部必做任务的天数
+	MissedStreak    int           `json:"missed_streak"`    // 最近连续未完成任何必做任务的天数
+	SkippedTasks    []SkippedTask `json:"skipped_tasks"`
+	Reflections     int           `json:"reflections"`
+	Sentiment       float64       `json:"sentiment"` // 感悟情绪倾向，-1 消极到 1 积极
+}
+
+// PlanAdjustment is a proposed change to the remaining days of a plan
+type PlanAdjustment struct {
+	ID            string        `json:"id" bson:"_id,omitempty"`
+	PlanID        string        `json:"plan_id" bson:"plan_id"`
+	UserID        string        `json:"user_id" bson:"user_id"`
+	BaseVersion   int           `json:"base_version" bson:"base_version"` // 提议所基于的计划版本
+	Kind          string        `json:"kind" bson:"kind"`
+	Reason        string        `json:"reason" bson:"reason"`
+	Adherence     PlanAdherence `json:"adherence" bson:"adherence"`
+	Tasks         []PlanTask    `json:"tasks" bson:"tasks"` // 调整后的全部任务
+	Changes       []TaskChange  `json:"changes" bson:"changes"`
+	Status        string        `json:"status" bson:"status"`
+	ResultVersion int           `json:"result_version,omitempty" bson:"result_version,omitempty"` // 采纳后生成的计划版本
+	CreatedAt     time.Time     `json:"created_at" bson:"created_at"`
+	DecidedAt     *time.Time    `json:"decided_at,omitempty" bson:"decided_at,omitempty"`
+}
@@ -0,0 +1,31 @@
+package models
+
+import (
+	"time"
+)
+
+// PlanPreview is a generated practice plan waiting for the user to accept or discard it
+type PlanPreview struct {
+	ID         string        `json:"id" bson:"_id,omitempty"`
+	UserID     string        `json:"user_id" bson:"user_id"`
+	Goal       string        `json:"goal" bson:"goal"` // 加密存储
+	Title      string        `json:"title" bson:"title"`
+	Days       int           `json:"days" bson:"days"`
+	Tasks      []PlanTask    `json:"tasks" bson:"tasks"`
+	Variant    *AIAssignment `json:"variant,omitempty" bson:"variant,omitempty"`
+	CreatedAt  time.Time     `json:"created_at" bson:"created_at"`
+	ExpiresAt  time.Time     `json:"expires_at" bson:"expires_at"` // 过期后由TTL索引删除
+	KeyVersion int           `json:"-" bson:"key_version,omitempty"`
+}
+
+// Plan returns the practice plan the preview becomes when accepted
+func (p *PlanPreview) Plan() *PracticePlan {
+	return &PracticePlan{
+		UserID:  p.UserID,
+		Title:   p.Title,
+		Days:    p.Days,
+		Tasks:   p.Tasks,
+		Source:  PlanSourceGenerated,
+		Variant: p.Variant,
+	}
+}
@@ -0,0 +1,24 @@
+package models
+
+// PlanProgress is how far a user is through a plan and how closely they followed it.
+// Only plan days that are over count; today is still in progress.
+type PlanProgress struct {
+	PlanID                  string          `json:"plan_id"`
+	Today                   int             `json:"today"` // 今天是计划第几天，未开始为0
+	TotalDays               int             `json:"total_days"`
+	DaysElapsed             int             `json:"days_elapsed"`              // 已结束的计划日数
+	TasksScheduled          int             `json:"tasks_scheduled"`           // 已结束计划日安排的任务数（含选做）
+	TasksCompleted          int             `json:"tasks_completed"`           // 其中完成的任务数
+	RequiredScheduled       int             `json:"required_scheduled"`        // 已结束计划日的必做任务数
+	RequiredCompleted       int             `json:"required_completed"`        // 其中完成的必做任务数
+	AdherencePercent        float64         `json:"adherence_percent"`         // 必做任务完成百分比
+	PracticedDays           int             `json:"practiced_days"`            // 至少完成一个任务的计划日数
+	MissedDays              []MissedPlanDay `json:"missed_days"`               // 没有完成任何必做任务的计划日
+	ProjectedCompletionDate string          `json:"projected_completion_date"` // 按当前日程预计完成日期，YYYY-MM-DD
+}
+
+// MissedPlanDay is a plan day on which none of the required tasks were completed
+type MissedPlanDay struct {
+	Day  int    `json:"day"`
+	Date string `json:"date,omitempty"`
+}
@@ -0,0 +1,37 @@
+package models
+
+import (
+	"time"
+)
+
+// PlanShare is a read-only link to a practice plan that works without logging in
+type PlanShare struct {
+	ID        string     `json:"id" bson:"_id,omitempty"`
+	Token     string     `json:"token" bson:"token"` // 链接中的随机令牌
+	PlanID    string     `json:"plan_id" bson:"plan_id"`
+	UserID    string     `json:"user_id" bson:"user_id"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty" bson:"expires_at,omitempty"` // 为空表示不过期
+	Listed    bool       `json:"listed" bson:"listed,omitempty"`                   // 所有者同意在热门计划中公开展示
+	RevokedAt *time.Time `json:"revoked_at,omitempty" bson:"revoked_at,omitempty"`
+	CreatedAt time.Time  `json:"created_at" bson:"created_at"`
+}
+
+// IsActive reports whether the link can still be opened at the given time
+func (s *PlanShare) IsActive(now time.Time) bool {
+	if s.RevokedAt != nil {
+		return false
+	}
+	return s.ExpiresAt == nil || now.Before(*s.ExpiresAt)
+}
+
+// SharedPlan is what a share link shows. It leaves out the owner and their schedule.
+type SharedPlan struct {
+	Token      string     `json:"token"`
+	PlanID     string     `json:"plan_id"`
+	Title      string     `json:"title"`
+	Days       int        `json:"days"`
+	Tasks      []PlanTask `json:"tasks"`
+	ClonedFrom string     `json:"cloned_from,omitempty"`
+	CloneCount int        `json:"clone_count"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"`
+}
@@ -0,0 +1,72 @@
+package models
+
+import (
+	"time"
+)
+
+// Template categories
+const (
+	TemplateCategoryMindfulness            = "mindfulness"             // 正念
+	TemplateCategoryCognitiveRestructuring = "cognitive_restructuring" // 认知重构
+	TemplateCategoryNeuroplasticity        = "neuroplasticity"         // 神经可塑性训练
+	TemplateCategoryEmotionRegulation      = "emotion_regulation"      // 情绪调节
+	TemplateCategorySleep                  = "sleep"                   // 睡眠改善
+)
+
+// TemplateCategories lists the template categories in display order
+var TemplateCategories = []string{
+	TemplateCategoryMindfulness,
+	TemplateCategoryCognitiveRestructuring,
+	TemplateCategoryNeuroplasticity,
+	TemplateCategoryEmotionRegulation,
+	TemplateCategorySleep,
+}
+
+// Template difficulties
+const (
+	DifficultyBeginner     = "beginner"
+	DifficultyIntermediate = "intermediate"
+	DifficultyAdvanced     = "advanced"
+)
+
+// IsValidTemplateCategory reports whether c is a template category
+func IsValidTemplateCategory(c string) bool {
+	for _, category := range TemplateCategories {
+		if c == category {
+			return true
+		}
+	}
+	return false
+}
+
+// IsValidDifficulty reports whether d is a template difficulty
+func IsValidDifficulty(d string) bool {
+	switch d {
+	case DifficultyBeginner, DifficultyIntermediate, DifficultyAdvanced:
+		return true
+	}
+	return false
+}
+
+// PlanTemplate is a curated practice plan users can copy into their own plans
+type PlanTemplate struct {
+	ID            string     `json:"id" bson:"_id,omitempty"`
+	Title         string     `json:"title" bson:"title"`
+	Description   string     `json:"description" bson:"description"`
+	Category      string     `json:"category" bson:"category"`
+	Difficulty    string     `json:"difficulty" bson:"difficulty"`
+	Days          int        `json:"days" bson:"days"`
+	Tasks         []PlanTask `json:"tasks" bson:"tasks"`
+	Tags          []string   `json:"tags" bson:"tags"`
+	Rationale     string     `json:"rationale" bson:"rationale"`           // 神经科学原理
+	Published     bool       `json:"published" bson:"published"`           // 未发布的模板只有管理员可见
+	InstanceCount int        `json:"instance_count" bson:"instance_count"` // 被用户使用的次数
+	CreatedAt     time.Time  `json:"created_at" bson:"created_at"`
+	UpdatedAt     time.Time  `json:"updated_at" bson:"updated_at"`
+}
+
+// TemplateCategoryCount is the number of published templates in a category
+type TemplateCategoryCount struct {
+	Category string `json:"category"`
+	Count    int    `json:"count"`
+}
@@ -0,0 +1,57 @@
+package models
+
+import (
+	"time"
+)
+
+// Plan version changes
+const (
+	PlanChangeCreated  = "created"
+	PlanChangeUpdated  = "updated"
+	PlanChangeRestored = "restored"
+	PlanChangeAdjusted = "adjusted"
+)
+
+// PlanVersion is an immutable snapshot of a practice plan after a change
+type PlanVersion struct {
+	ID           string     `json:"id" bson:"_id,omitempty"`
+	PlanID       string     `json:"plan_id" bson:"plan_id"`
+	UserID       string     `json:"user_id" bson:"user_id"`
+	Version      int        `json:"version" bson:"version"`
+	Title        string     `json:"title" bson:"title"`
+	Days         int        `json:"days" bson:"days"`
+	Tasks        []PlanTask `json:"tasks" bson:"tasks"`
+	Change       string     `json:"change" bson:"change"`                                   // created, updated, restored or adjusted
+	RestoredFrom int        `json:"restored_from,omitempty" bson:"restored_from,omitempty"` // 恢复自的版本
+	CreatedAt    time.Time  `json:"created_at" bson:"created_at"`
+}
+
+// ValueChange is the old and new value of a changed plan field
+type ValueChange struct {
+	From interface{} `json:"from"`
+	To   interface{} `json:"to"`
+}
+
+// Task changes in a plan diff
+const (
+	TaskAdded    = "added"
+	TaskRemoved  = "removed"
+	TaskModified = "modified"
+)
+
+// TaskChange describes a task that differs between two plan versions
+type TaskChange struct {
+	TaskID string    `json:"task_id" bson:"task_id"`
+	Change string    `json:"change" bson:"change"`
+	Before *PlanTask `json:"before,omitempty" bson:"before,omitempty"`
+	After  *PlanTask `json:"after,omitempty" bson:"after,omitempty"`
+}
+
+// PlanDiff lists the differences between two versions of a plan
+type PlanDiff struct {
+	FromVersion int          `json:"from_version"`
+	ToVersion   int          `json:"to_version"`
+	Title       *ValueChange `json:"title,omitempty"`
+	Days        *ValueChange `json:"days,omitempty"`
+	Tasks       []TaskChange `json:"tasks"`
+}
@@ -4,20 +4,125 @@ import (
 	"time"
 )
 
+// Plan sources
+const (
+	PlanSourceManual    = "manual"
+	PlanSourceGenerated = "generated"
+	PlanSourceTemplate  = "template"
+	PlanSourceClone     = "clone"
+	PlanSourceMarkdown  = "markdown"
+	PlanSourceCohort    = "cohort"
+)
+
+// Plan lifecycle statuses
+const (
+	PlanStatusDraft     = "draft"     // not started yet, days do not count
+	PlanStatusActive    = "active"    // running
+	PlanStatusPaused    = "paused"    // paused days do not count
+	PlanStatusCompleted = "completed" // the last day has passed
+	PlanStatusAbandoned = "abandoned" // given up before the last day
+	PlanStatusArchived  = "archived"  // put away after it completed or was abandoned
+)
+
 // PracticePlan represents a practice plan in the system
 type PracticePlan struct {
-	ID        string     `json:"id" bson:"_id,omitempty"`
-	UserID    string     `json:"user_id" bson:"user_id"`
-	Title     string     `json:"title" bson:"title"`
-	Days      int        `json:"days" bson:"days"`
-	Tasks     []PlanTask `json:"tasks" bson:"tasks"`
-	CreatedAt time.Time  `json:"created_at" bson:"created_at"`
+	ID            string             `json:"id" bson:"_id,omitempty"`
+	UserID        string             `json:"user_id" bson:"user_id"`
+	Title         string             `json:"title" bson:"title"`
+	Days          int                `json:"days" bson:"days"`
+	Tasks         []PlanTask         `json:"tasks" bson:"tasks"`
+	Source        string             `json:"source,omitempty" bson:"source,omitempty"`               // how the plan was created, empty for older plans
+	Variant       *AIAssignment      `json:"variant,omitempty" bson:"variant,omitempty"`             // AI backend that generated the plan
+	TemplateID    string             `json:"template_id,omitempty" bson:"template_id,omitempty"`     // 来源模板
+	ClonedFrom    string             `json:"cloned_from,omitempty" bson:"cloned_from,omitempty"`     // 克隆来源计划
+	CloneCount    int                `json:"clone_count,omitempty" bson:"clone_count,omitempty"`     // 被克隆次数
+	CohortID      string             `json:"cohort_id,omitempty" bson:"cohort_id,omitempty"`         // 所属挑战
+	Version       int                `json:"version" bson:"version,omitempty"`                       // 当前版本号，从1开始
+	StartDate     string             `json:"start_date,omitempty" bson:"start_date,omitempty"`       // 开始日期 YYYY-MM-DD（计划时区）
+	TimeZone      string             `json:"time_zone,omitempty" bson:"time_zone,omitempty"`         // IANA时区
+	RestWeekdays  []int              `json:"rest_weekdays,omitempty" bson:"rest_weekdays,omitempty"` // 休息日，0表示周日
+	Pauses        []PlanPause        `json:"pauses,omitempty" bson:"pauses,omitempty"`
+	Status        string             `json:"status" bson:"status,omitempty"`                           // 生命周期状态，旧计划为空
+	StatusHistory []PlanStatusChange `json:"status_history,omitempty" bson:"status_history,omitempty"` // 状态变更记录
+	DeletedAt     *time.Time         `json:"deleted_at,omitempty" bson:"deleted_at,omitempty"`         // 移入回收站的时间
+	Progress      *PlanProgress      `json:"progress,omitempty" bson:"-"`                              // 进度，只在计划详情中返回
+	CreatedAt     time.Time          `json:"created_at" bson:"created_at"`
+	UpdatedAt     time.Time          `json:"updated_at" bson:"updated_at,omitempty"`
+}
+
+// PlanStatusChange is a lifecycle transition of a plan
+type PlanStatusChange struct {
+	From string    `json:"from,omitempty" bson:"from,omitempty"` // empty when the plan was created
+	To   string    `json:"to" bson:"to"`
+	At   time.Time `json:"at" bson:"at"`
+}
+
+// PlanPause is a period in which a plan was paused. Paused days do not count towards the plan.
+type PlanPause struct {
+	From string `json:"from" bson:"from"`                 // 暂停日期 YYYY-MM-DD
+	To   string `json:"to,omitempty" bson:"to,omitempty"` // 恢复日期，为空表示仍在暂停
+}
+
+// IsPaused reports whether the plan is paused now
+func (p *PracticePlan) IsPaused() bool {
+	return len(p.Pauses) > 0 && p.Pauses[len(p.Pauses)-1].To == ""
+}
+
+// CurrentVersion returns the plan's version. Plans created before versioning are version 1.
+func (p *PracticePlan) CurrentVersion() int {
+	if p.Version == 0 {
+		return 1
+	}
+	return p.Version
+}
+
+// Task types
+const (
+	TaskTypeBreathing  = "breathing"
+	TaskTypeMeditation = "meditation"
+	TaskTypeJournaling = "journaling"
+	TaskTypeExercise   = "exercise"
+	TaskTypeReading    = "reading"
+	TaskTypeOther      = "other"
+)
+
+// Times of day a task is meant for
+const (
+	TimeOfDayMorning   = "morning"
+	TimeOfDayAfternoon = "afternoon"
+	TimeOfDayEvening   = "evening"
+	TimeOfDayBedtime   = "bedtime"
+	TimeOfDayAnytime   = "anytime"
+)
+
+// IsValidTaskType reports whether t is a supported task type
+func IsValidTaskType(t string) bool {
+	switch t {
+	case TaskTypeBreathing, TaskTypeMeditation, TaskTypeJournaling, TaskTypeExercise, TaskTypeReading, TaskTypeOther:
+		return true
+	}
+	return false
+}
+
+// IsValidTimeOfDay reports whether t is a supported time of day
+func IsValidTimeOfDay(t string) bool {
+	switch t {
+	case TimeOfDayMorning, TimeOfDayAfternoon, TimeOfDayEvening, TimeOfDayBedtime, TimeOfDayAnytime:
+		return true
+	}
+	return false
 }
 
-// PlanTask represents a task in a practice plan
+// PlanTask represents a task in a practice plan. A day can have several tasks.
 type PlanTask struct {
-	Day             int    `json:"day" bson:"day"`
-	Title           string `json:"title" bson:"title"`
-	Description     string `json:"description" bson:"description"`
-	ScientificBasis string `json:"scientific_basis" bson:"scientific_basis"`
+	ID              string   `json:"id" bson:"id"` // 任务ID，计划修改后保持不变
+	Day             int      `json:"day" bson:"day"`
+	Title           string   `json:"title" bson:"title"`
+	Description     string   `json:"description" bson:"description"`
+	ScientificBasis string   `json:"scientific_basis" bson:"scientific_basis"`
+	Type            string   `json:"type,omitempty" bson:"type,omitempty"`                         // breathing, meditation, journaling...
+	DurationMinutes int      `json:"duration_minutes,omitempty" bson:"duration_minutes,omitempty"` // 预计用时
+	TimeOfDay       string   `json:"time_of_day,omitempty" bson:"time_of_day,omitempty"`           // morning, evening, bedtime...
+	Optional        bool     `json:"optional,omitempty" bson:"optional,omitempty"`                 // 选做任务
+	ReferenceIDs    []string `json:"reference_ids,omitempty" bson:"reference_ids,omitempty"`       // 科学依据引用的文献
 }
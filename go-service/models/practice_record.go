@@ -6,12 +6,16 @@ import (
 
 // PracticeRecord represents a practice record in the system
 type PracticeRecord struct {
-	ID             string    `json:"id" bson:"_id,omitempty"`
-	UserID         string    `json:"user_id" bson:"user_id"`
-	PlanID         string    `json:"plan_id" bson:"plan_id"`
-	Date           time.Time `json:"date" bson:"date"`
-	CompletedTasks []string  `json:"completed_tasks" bson:"completed_tasks"`
-	Reflection     string    `json:"reflection" bson:"reflection"`
-	CreatedAt      time.Time `json:"created_at" bson:"created_at"`
-	UpdatedAt      time.Time `json:"updated_at" bson:"updated_at"`
+	ID             string     `json:"id" bson:"_id,omitempty"`
+	UserID         string     `json:"user_id" bson:"user_id"`
+	PlanID         string     `json:"plan_id" bson:"plan_id"`
+	PlanVersion    int        `json:"plan_version,omitempty" bson:"plan_version,omitempty"` // 记录时计划的版本
+	Day            int        `json:"day,omitempty" bson:"day,omitempty"`                   // 计划第几天
+	Date           time.Time  `json:"date" bson:"date"`
+	CompletedTasks []string   `json:"completed_tasks" bson:"completed_tasks"` // 完成的任务ID
+	Reflection     string     `json:"reflection" bson:"reflection"`
+	CreatedAt      time.Time  `json:"created_at" bson:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at" bson:"updated_at"`
+	KeyVersion     int        `json:"-" bson:"key_version,omitempty"`     // 加密数据密钥版本
+	PlanDeletedAt  *time.Time `json:"-" bson:"plan_deleted_at,omitempty"` // 所属计划被删除时隐藏记录
 }
@@ -0,0 +1,55 @@
+package models
+
+import (
+	"time"
+)
+
+// Bibliography styles
+const (
+	BibliographyAPA     = "apa"     // APA 7th edition
+	BibliographyGBT7714 = "gbt7714" // GB/T 7714-2015 顺序编码制
+)
+
+// IsValidBibliographyStyle reports whether s is a supported bibliography style
+func IsValidBibliographyStyle(s string) bool {
+	return s == BibliographyAPA || s == BibliographyGBT7714
+}
+
+// Reference is a scientific publication that plan tasks and AI answers cite
+type Reference struct {
+	ID        string            `json:"id" bson:"_id,omitempty"`
+	Authors   []ReferenceAuthor `json:"authors" bson:"authors"`
+	Year      int               `json:"year" bson:"year"`
+	Title     string            `json:"title" bson:"title"`
+	Journal   string            `json:"journal,omitempty" bson:"journal,omitempty"`
+	Volume    string            `json:"volume,omitempty" bson:"volume,omitempty"`
+	Issue     string            `json:"issue,omitempty" bson:"issue,omitempty"`
+	Pages     string            `json:"pages,omitempty" bson:"pages,omitempty"`         // 页码范围，如 564-570
+	DOI       string            `json:"doi,omitempty" bson:"doi,omitempty"`             // 小写，不含 https://doi.org/ 前缀
+	PubMedID  string            `json:"pubmed_id,omitempty" bson:"pubmed_id,omitempty"` // PMID
+	CreatedAt time.Time         `json:"created_at" bson:"created_at"`
+	UpdatedAt time.Time         `json:"updated_at" bson:"updated_at"`
+}
+
+// ReferenceAuthor is an author of a reference. Chinese names keep the surname in Family
+// and the given name in Given.
+type ReferenceAuthor struct {
+	Family string `json:"family" bson:"family"`
+	Given  string `json:"given,omitempty" bson:"given,omitempty"`
+}
+
+// PlanReferences are the references a plan cites, task by task
+type PlanReferences struct {
+	PlanID     string           `json:"plan_id"`
+	References []*Reference     `json:"references"` // in order of first citation
+	Tasks      []TaskReferences `json:"tasks"`
+}
+
+// TaskReferences are the references of one plan task
+type TaskReferences struct {
+	TaskID       string   `json:"task_id"`
+	Day          int      `json:"day"`
+	Title        string   `json:"title"`
+	ReferenceIDs []string `json:"reference_ids"`
+	Linked       bool     `json:"linked"` // the task links its references; otherwise they were matched from the scientific basis
+}
@@ -0,0 +1,27 @@
+package models
+
+import (
+	"time"
+)
+
+// Safety event review statuses
+const (
+	SafetyEventPendingReview = "pending_review"
+	SafetyEventReviewed      = "reviewed"
+)
+
+// SafetyEvent records a chat message that triggered the crisis safety layer
+type SafetyEvent struct {
+	ID           string     `json:"id" bson:"_id,omitempty"`
+	UserID       string     `json:"user_id" bson:"user_id"`
+	MessageID    string     `json:"message_id" bson:"message_id"`
+	RiskLevel    string     `json:"risk_level" bson:"risk_level"` // low, medium or high
+	Categories   []string   `json:"categories" bson:"categories"`
+	MatchedRules []string   `json:"matched_rules" bson:"matched_rules"`
+	Sources      []string   `json:"sources" bson:"sources"` // rules and/or ai
+	RuleSet      string     `json:"rule_set" bson:"rule_set"`
+	Status       string     `json:"status" bson:"status"` // pending_review or reviewed
+	CreatedAt    time.Time  `json:"created_at" bson:"created_at"`
+	ReviewedAt   *time.Time `json:"reviewed_at,omitempty" bson:"reviewed_at,omitempty"`
+	ReviewNote   string     `json:"review_note,omitempty" bson:"review_note,omitempty"` // 复核备注
+}
@@ -0,0 +1,30 @@
+package models
+
+import (
+	"time"
+)
+
+// Usage represents a user's AI usage for one day
+type Usage struct {
+	ID               string    `json:"id" bson:"_id,omitempty"`
+	UserID           string    `json:"user_id" bson:"user_id"`
+	Date             string    `json:"date" bson:"date"` // YYYY-MM-DD in the quota time zone
+	Requests         int       `json:"requests" bson:"requests"`
+	PromptTokens     int       `json:"prompt_tokens" bson:"prompt_tokens"`
+	CompletionTokens int       `json:"completion_tokens" bson:"completion_tokens"`
+	UpdatedAt        time.Time `json:"updated_at" bson:"updated_at"`
+}
+
+// TotalTokens returns the sum of prompt and completion tokens
+func (u *Usage) TotalTokens() int {
+	return u.PromptTokens + u.CompletionTokens
+}
+
+// UsageReportEntry is one day of aggregated usage across all users
+type UsageReportEntry struct {
+	Date             string `json:"date" bson:"_id"`
+	Users            int    `json:"users" bson:"users"`
+	Requests         int    `json:"requests" bson:"requests"`
+	PromptTokens     int    `json:"prompt_tokens" bson:"prompt_tokens"`
+	CompletionTokens int    `json:"completion_tokens" bson:"completion_tokens"`
+}
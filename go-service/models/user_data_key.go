@@ -0,0 +1,17 @@
+package models
+
+import (
+	"time"
+)
+
+// UserDataKey represents a per-user data encryption key wrapped by a master key
+type UserDataKey struct {
+	ID               string     `json:"id" bson:"_id,omitempty"`
+	UserID           string     `json:"user_id" bson:"user_id"`
+	Version          int        `json:"version" bson:"version"`
+	MasterKeyVersion int        `json:"master_key_version" bson:"master_key_version"`
+	WrappedKey       string     `json:"-" bson:"wrapped_key"`
+	Status           string     `json:"status" bson:"status"` // active or retired
+	CreatedAt        time.Time  `json:"created_at" bson:"created_at"`
+	RetiredAt        *time.Time `json:"retired_at,omitempty" bson:"retired_at,omitempty"`
+}
@@ -3,14 +3,29 @@ package routes
 import (
 	"neuro-guide-go-service/config"
 	"neuro-guide-go-service/controllers"
+	"neuro-guide-go-service/middleware"
 
 	"github.com/gin-gonic/gin"
 )
 
 // InitRouter initializes the router and routes
 func InitRouter(cfg *config.Config) *gin.Engine {
-	// Initialize controllers
+	// Initialize middleware and controllers
+	middleware.InitAuthMiddleware(cfg)
 	controllers.InitUserController(cfg)
+	controllers.InitChatController(cfg)
+	controllers.InitUsageController(cfg)
+	controllers.InitNotebookController()
+	controllers.InitCheckInController(cfg)
+	controllers.InitPracticePlanController(cfg)
+	controllers.InitPracticeRecordController(cfg)
+	controllers.InitPlanTemplateController(cfg)
+	controllers.InitPlanShareController(cfg)
+	controllers.InitPlanAdjustmentController(cfg)
+	controllers.InitCalendarController(cfg)
+	controllers.InitReferenceController(cfg)
+	controllers.InitCohortController(cfg)
+	controllers.InitSafetyController(cfg)
 
 	r := gin.Default()
 
@@ -36,6 +51,168 @@ func InitRouter(cfg *config.Config) *gin.Engine {
 			// 更新用户资料
 			user.PUT("/profile/:id", controllers.UpdateUserProfile)
 		}
+
+		// 对话相关路由
+		chat := api.Group("/chat", middleware.AuthMiddleware())
+		{
+			chat.POST("/message", controllers.SendMessage)
+			chat.GET("/history", controllers.GetChatHistory)
+			chat.PUT("/messages/:id/feedback", controllers.SetMessageFeedback)
+			chat.DELETE("/history", controllers.ClearChatHistory)
+			chat.GET("/summary", controllers.GetChatSummary)
+			chat.GET("/summaries", controllers.GetChatSummaries)
+			chat.GET("/export", controllers.ExportChat)
+			chat.POST("/voice", controllers.SendVoiceMessage)
+			chat.GET("/voice/:id", controllers.GetVoiceAudio)
+		}
+
+		// 收藏笔记本
+		notebook := api.Group("/notebook", middleware.AuthMiddleware())
+		{
+			notebook.POST("/entries", controllers.SaveNotebookEntry)
+			notebook.GET("/entries", controllers.ListNotebookEntries)
+			notebook.GET("/entries/:id", controllers.GetNotebookEntry)
+			notebook.PUT("/entries/:id", controllers.UpdateNotebookEntry)
+			notebook.DELETE("/entries/:id", controllers.DeleteNotebookEntry)
+			notebook.GET("/tags", controllers.ListNotebookTags)
+			notebook.POST("/collections", controllers.CreateNotebookCollection)
+			notebook.GET("/collections", controllers.ListNotebookCollections)
+			notebook.PUT("/collections/:id", controllers.UpdateNotebookCollection)
+			notebook.DELETE("/collections/:id", controllers.DeleteNotebookCollection)
+		}
+
+		// 修行计划相关路由
+		plan := api.Group("/plan", middleware.AuthMiddleware())
+		{
+			plan.POST("/generate", controllers.GeneratePlan)
+			plan.GET("/previews/:id", controllers.GetPlanPreview)
+			plan.POST("/previews/:id/accept", controllers.AcceptPlanPreview)
+			plan.DELETE("/previews/:id", controllers.DiscardPlanPreview)
+			plan.POST("", controllers.CreatePlan)
+			plan.POST("/import", controllers.ImportPlan)
+			plan.GET("/list", controllers.GetPlans)
+			plan.GET("/trash", controllers.GetDeletedPlans)
+			plan.GET("/today", controllers.GetTodayPlans)
+			plan.GET("/calendar-feed", controllers.GetCalendarFeed)
+			plan.POST("/calendar-feed/reset", controllers.ResetCalendarFeed)
+			plan.GET("/:id", controllers.GetPlan)
+			plan.GET("/:id/progress", controllers.GetPlanProgress)
+			plan.PUT("/:id", controllers.UpdatePlan)
+			plan.PATCH("/:id", controllers.PatchPlan)
+			plan.DELETE("/:id", controllers.DeletePlan)
+			plan.PUT("/:id/schedule", controllers.UpdatePlanSchedule)
+			plan.POST("/:id/pause", controllers.PausePlan)
+			plan.POST("/:id/resume", controllers.ResumePlan)
+			plan.POST("/:id/activate", controllers.ActivatePlan)
+			plan.POST("/:id/abandon", controllers.AbandonPlan)
+			plan.POST("/:id/archive", controllers.ArchivePlan)
+			plan.POST("/:id/unarchive", controllers.UnarchivePlan)
+			plan.POST("/:id/restore", controllers.RestorePlan)
+			plan.POST("/:id/tasks", controllers.AddPlanTask)
+			plan.PATCH("/:id/tasks/:taskId", controllers.PatchPlanTask)
+			plan.DELETE("/:id/tasks/:taskId", controllers.DeletePlanTask)
+			plan.GET("/:id/versions", controllers.GetPlanVersions)
+			plan.GET("/:id/versions/:version", controllers.GetPlanVersion)
+			plan.POST("/:id/versions/:version/restore", controllers.RestorePlanVersion)
+			plan.GET("/:id/diff", controllers.DiffPlanVersions)
+			plan.POST("/:id/shares", controllers.CreatePlanShare)
+			plan.GET("/:id/shares", controllers.GetPlanShares)
+			plan.PATCH("/:id/shares/:shareId", controllers.UpdatePlanShare)
+			plan.DELETE("/:id/shares/:shareId", controllers.RevokePlanShare)
+			plan.GET("/:id/calendar.ics", controllers.ExportPlanCalendar)
+			plan.GET("/:id/markdown", controllers.ExportPlanMarkdown)
+			plan.GET("/:id/references", controllers.GetPlanReferences)
+			plan.GET("/:id/bibliography", controllers.GetPlanBibliography)
+			plan.POST("/:id/evaluate", controllers.EvaluatePlan)
+			plan.GET("/:id/adjustments", controllers.GetPlanAdjustments)
+			plan.POST("/:id/adjustments/:adjustmentId/accept", controllers.AcceptPlanAdjustment)
+			plan.POST("/:id/adjustments/:adjustmentId/reject", controllers.RejectPlanAdjustment)
+		}
+
+		// 计划模板库，浏览无需登录
+		templates := api.Group("/plan-templates")
+		{
+			templates.GET("", controllers.ListPlanTemplates)
+			templates.GET("/categories", controllers.ListPlanTemplateCategories)
+			templates.GET("/:id", controllers.GetPlanTemplate)
+			templates.POST("/:id/instantiate", middleware.AuthMiddleware(), controllers.InstantiatePlanTemplate)
+		}
+
+		// 文献库，查询无需登录
+		references := api.Group("/references")
+		{
+			references.GET("", controllers.ListReferences)
+			references.POST("/resolve", controllers.ResolveReferences)
+			references.GET("/:id", controllers.GetReference)
+		}
+
+		// 挑战小组相关路由
+		cohorts := api.Group("/cohorts", middleware.AuthMiddleware())
+		{
+			cohorts.POST("", controllers.CreateCohort)
+			cohorts.GET("", controllers.GetCohorts)
+			cohorts.POST("/join", controllers.JoinCohort)
+			cohorts.GET("/:id", controllers.GetCohort)
+			cohorts.DELETE("/:id", controllers.DeleteCohort)
+			cohorts.GET("/:id/members", controllers.GetCohortMembers)
+			cohorts.GET("/:id/progress", controllers.GetCohortProgress)
+			cohorts.POST("/:id/leave", controllers.LeaveCohort)
+			cohorts.POST("/:id/join-code/reset", controllers.ResetCohortJoinCode)
+			cohorts.POST("/:id/announcements", controllers.PostCohortAnnouncement)
+			cohorts.GET("/:id/announcements", controllers.GetCohortAnnouncements)
+			cohorts.DELETE("/:id/announcements/:announcementId", controllers.DeleteCohortAnnouncement)
+		}
+
+		// 计划分享链接，查看无需登录
+		shared := api.Group("/shared-plans")
+		{
+			shared.GET("/popular", controllers.GetPopularPlans)
+			shared.GET("/:token", controllers.GetSharedPlan)
+			shared.POST("/:token/clone", middleware.AuthMiddleware(), controllers.CloneSharedPlan)
+		}
+
+		// 日历订阅，令牌即凭证
+		calendar := api.Group("/calendar")
+		{
+			calendar.GET("/:token/plans.ics", controllers.GetFeedCalendar)
+			calendar.GET("/:token/plans/:id", controllers.GetFeedPlanCalendar)
+		}
+
+		// 练习记录相关路由
+		record := api.Group("/record", middleware.AuthMiddleware())
+		{
+			record.POST("/checkin", controllers.CreateRecord)
+			record.GET("/list", controllers.GetRecords)
+			record.GET("/:id", controllers.GetRecord)
+			record.PUT("/:id", controllers.UpdateRecord)
+		}
+
+		// 主动问候设置
+		checkin := api.Group("/checkin", middleware.AuthMiddleware())
+		{
+			checkin.GET("/preferences", controllers.GetCheckInPreference)
+			checkin.PUT("/preferences", controllers.UpdateCheckInPreference)
+		}
+
+		// 用量与配额
+		api.GET("/usage", middleware.AuthMiddleware(), controllers.GetUsage)
+
+		// 管理接口
+		admin := api.Group("/admin", middleware.AdminMiddleware())
+		{
+			admin.GET("/usage", controllers.GetUsageReport)
+			admin.GET("/experiments", controllers.GetExperimentStats)
+			admin.GET("/safety-events", controllers.GetSafetyEvents)
+			admin.POST("/safety-events/:id/review", controllers.ReviewSafetyEvent)
+			admin.GET("/plan-templates", controllers.AdminListPlanTemplates)
+			admin.POST("/plan-templates", controllers.CreatePlanTemplate)
+			admin.GET("/plan-templates/:id", controllers.AdminGetPlanTemplate)
+			admin.PUT("/plan-templates/:id", controllers.UpdatePlanTemplate)
+			admin.DELETE("/plan-templates/:id", controllers.DeletePlanTemplate)
+			admin.POST("/references", controllers.CreateReference)
+			admin.PUT("/references/:id", controllers.UpdateReference)
+			admin.DELETE("/references/:id", controllers.DeleteReference)
+		}
 	}
 
 	return r
@@ -0,0 +1,228 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"log"
+	"os"
+
+	"neuro-guide-go-service/config"
+	"neuro-guide-go-service/models"
+)
+
+// defaultAIBackend is the backend name of Config.PythonAIServiceURL
+const defaultAIBackend = "default"
+
+// User cohorts that routes and experiments can target
+const (
+	CohortGuest      = "guest"
+	CohortRegistered = "registered"
+)
+
+// AIBackend is an AI service deployment, e.g. a model or prompt version
+type AIBackend struct {
+	Name string `json:"name"`
+	URL  string `json:"url"`
+}
+
+// AIRoute sends matching requests to a fixed backend
+type AIRoute struct {
+	Modes   []string `json:"modes,omitempty"`  // chat modes, empty matches all
+	Cohort  string   `json:"cohort,omitempty"` // guest or registered, empty matches all
+	Backend string   `json:"backend"`
+}
+
+// AIVariant is one arm of an experiment
+type AIVariant struct {
+	Name    string `json:"name"`
+	Backend string `json:"backend"`
+	Weight  int    `json:"weight"`
+}
+
+// AIExperiment splits matching requests between variants by weight.
+// A user always gets the same variant of an experiment.
+type AIExperiment struct {
+	Name     string      `json:"name"`
+	Enabled  bool        `json:"enabled"`
+	Modes    []string    `json:"modes,omitempty"`
+	Cohort   string      `json:"cohort,omitempty"`
+	Variants []AIVariant `json:"variants"`
+}
+
+// AIRoutingConfig is the content of the AI routing file.
+// Experiments are checked first, then routes, in file order. Requests matching
+// neither go to the default backend.
+type AIRoutingConfig struct {
+	Backends       []AIBackend    `json:"backends"`
+	Routes         []AIRoute      `json:"routes,omitempty"`
+	Experiments    []AIExperiment `json:"experiments,omitempty"`
+	DefaultBackend string         `json:"default_backend,omitempty"` // defaults to Config.PythonAIServiceURL
+}
+
+// AITarget is the backend selected for one request
+type AITarget struct {
+	URL        string
+	Assignment models.AIAssignment
+}
+
+// AIRouter selects the AI backend for each chat request
+type AIRouter struct {
+	config   *AIRoutingConfig
+	backends map[string]string // name -> URL
+}
+
+// NewAIRouter creates an AIRouter from cfg.AIRoutingFile.
+// Without a routing file, or when it is invalid, every request goes to cfg.PythonAIServiceURL.
+func NewAIRouter(cfg *config.Config) *AIRouter {
+	routing := &AIRoutingConfig{}
+	if cfg.AIRoutingFile != "" {
+		loaded, err := LoadAIRoutingConfig(cfg.AIRoutingFile)
+		if err != nil {
+			log.Printf("Failed to load AI routing, using %s only: %v", cfg.PythonAIServiceURL, err)
+		} else {
+			routing = loaded
+		}
+	}
+
+	router, err := newAIRouter(routing, cfg.PythonAIServiceURL)
+	if err != nil {
+		log.Printf("Invalid AI routing, using %s only: %v", cfg.PythonAIServiceURL, err)
+		router, _ = newAIRouter(&AIRoutingConfig{}, cfg.PythonAIServiceURL)
+	}
+	return router
+}
+
+// LoadAIRoutingConfig reads an AI routing file
+func LoadAIRoutingConfig(path string) (*AIRoutingConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var routing AIRoutingConfig
+	if err := json.Unmarshal(data, &routing); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	return &routing, nil
+}
+
+// newAIRouter validates a routing config and creates a router from it
+func newAIRouter(routing *AIRoutingConfig, defaultURL string) (*AIRouter, error) {
+	backends := map[string]string{defaultAIBackend: defaultURL}
+	for _, backend := range routing.Backends {
+		if backend.Name == "" || backend.URL == "" {
+			return nil, fmt.Errorf("backend needs a name and url")
+		}
+		backends[backend.Name] = backend.URL
+	}
+
+	known := func(name string) error {
+		if _, ok := backends[name]; !ok {
+			return fmt.Errorf("unknown backend %q", name)
+		}
+		return nil
+	}
+
+	if routing.DefaultBackend != "" {
+		if err := known(routing.DefaultBackend); err != nil {
+			return nil, err
+		}
+	}
+	for _, route := range routing.Routes {
+		if err := known(route.Backend); err != nil {
+			return nil, err
+		}
+	}
+	for _, exp := range routing.Experiments {
+		if exp.Name == "" || len(exp.Variants) == 0 {
+			return nil, fmt.Errorf("experiment needs a name and variants")
+		}
+		for _, variant := range exp.Variants {
+			if variant.Name == "" || variant.Weight <= 0 {
+				return nil, fmt.Errorf("experiment %s: variant needs a name and a positive weight", exp.Name)
+			}
+			if err := known(variant.Backend); err != nil {
+				return nil, fmt.Errorf("experiment %s: %w", exp.Name, err)
+			}
+		}
+	}
+
+	return &AIRouter{config: routing, backends: backends}, nil
+}
+
+// userCohort returns the routing cohort of a user. Unknown users are treated as guests.
+func userCohort(users *UserService, userID string) string {
+	user, err := users.GetUserByID(userID)
+	if err != nil || user.IsGuest {
+		return CohortGuest
+	}
+	return CohortRegistered
+}
+
+// matches reports whether a mode and cohort filter applies to a request
+func matches(modes []string, cohort, mode, userCohort string) bool {
+	if cohort != "" && cohort != userCohort {
+		return false
+	}
+	if len(modes) == 0 {
+		return true
+	}
+	for _, m := range modes {
+		if m == mode {
+			return true
+		}
+	}
+	return false
+}
+
+// Route selects the backend for a user's request in a chat mode
+func (r *AIRouter) Route(userID, mode, cohort string) AITarget {
+	for _, exp := range r.config.Experiments {
+		if !exp.Enabled || !matches(exp.Modes, exp.Cohort, mode, cohort) {
+			continue
+		}
+		variant := pickVariant(exp, userID)
+		return AITarget{
+			URL: r.backends[variant.Backend],
+			Assignment: models.AIAssignment{
+				Backend:    variant.Backend,
+				Experiment: exp.Name,
+				Variant:    variant.Name,
+			},
+		}
+	}
+
+	backend := r.config.DefaultBackend
+	if backend == "" {
+		backend = defaultAIBackend
+	}
+	for _, route := range r.config.Routes {
+		if matches(route.Modes, route.Cohort, mode, cohort) {
+			backend = route.Backend
+			break
+		}
+	}
+
+	return AITarget{URL: r.backends[backend], Assignment: models.AIAssignment{Backend: backend}}
+}
+
+// pickVariant assigns a user to a variant by hashing the user into the experiment's total weight
+func pickVariant(exp AIExperiment, userID string) AIVariant {
+	total := 0
+	for _, variant := range exp.Variants {
+		total += variant.Weight
+	}
+
+	h := fnv.New32a()
+	h.Write([]byte(exp.Name + ":" + userID))
+	bucket := int(h.Sum32() % uint32(total))
+
+	for _, variant := range exp.Variants {
+		if bucket < variant.Weight {
+			return variant
+		}
+		bucket -= variant.Weight
+	}
+	return exp.Variants[len(exp.Variants)-1]
+}
@@ -0,0 +1,72 @@
+package services
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAIRouter_Route(t *testing.T) {
+	router, err := newAIRouter(&AIRoutingConfig{
+		Backends: []AIBackend{
+			{Name: "qa-model", URL: "http://qa:8000"},
+			{Name: "prompt-v2", URL: "http://v2:8000"},
+		},
+		Routes: []AIRoute{
+			{Modes: []string{"knowledge_qa"}, Backend: "qa-model"},
+		},
+		Experiments: []AIExperiment{
+			{Name: "paused", Enabled: false, Variants: []AIVariant{{Name: "a", Backend: "prompt-v2", Weight: 1}}},
+			{
+				Name:    "prompt-v2",
+				Enabled: true,
+				Modes:   []string{"chat"},
+				Cohort:  CohortRegistered,
+				Variants: []AIVariant{
+					{Name: "control", Backend: "default", Weight: 1},
+					{Name: "treatment", Backend: "prompt-v2", Weight: 1},
+				},
+			},
+		},
+	}, "http://ai:8000")
+	assert.NoError(t, err)
+
+	target := router.Route("u1", "knowledge_qa", CohortRegistered)
+	assert.Equal(t, "http://qa:8000", target.URL)
+	assert.Equal(t, "qa-model", target.Assignment.Backend)
+	assert.Empty(t, target.Assignment.Experiment)
+
+	// Guests are not part of the experiment
+	target = router.Route("u1", "chat", CohortGuest)
+	assert.Equal(t, "http://ai:8000", target.URL)
+	assert.Empty(t, target.Assignment.Experiment)
+
+	// Registered users are split between the variants and keep their variant
+	counts := map[string]int{}
+	for i := 0; i < 1000; i++ {
+		userID := fmt.Sprintf("user-%d", i)
+		first := router.Route(userID, "chat", CohortRegistered)
+		assert.Equal(t, "prompt-v2", first.Assignment.Experiment)
+		assert.Equal(t, first, router.Route(userID, "chat", CohortRegistered))
+		counts[first.Assignment.Variant]++
+	}
+	assert.InDelta(t, 500, counts["control"], 100)
+	assert.InDelta(t, 500, counts["treatment"], 100)
+}
+
+func TestNewAIRouter_Validation(t *testing.T) {
+	_, err := newAIRouter(&AIRoutingConfig{Routes: []AIRoute{{Backend: "missing"}}}, "http://ai:8000")
+	assert.Error(t, err)
+
+	_, err = newAIRouter(&AIRoutingConfig{Experiments: []AIExperiment{
+		{Name: "exp", Variants: []AIVariant{{Name: "a", Backend: "default", Weight: 0}}},
+	}}, "http://ai:8000")
+	assert.Error(t, err)
+
+	router, err := newAIRouter(&AIRoutingConfig{}, "http://ai:8000")
+	assert.NoError(t, err)
+	target := router.Route("u", "chat", CohortGuest)
+	assert.Equal(t, "http://ai:8000", target.URL)
+	assert.Equal(t, "default", target.Assignment.Backend)
+}
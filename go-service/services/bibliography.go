@@ -0,0 +1,203 @@
+package services
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"unicode"
+
+	"neuro-guide-go-service/models"
+)
+
+// apaMaxAuthors is the number of authors APA lists before eliding the rest
+const apaMaxAuthors = 20
+
+// gbtMaxAuthors is the number of authors GB/T 7714 lists before "et al."
+const gbtMaxAuthors = 3
+
+// isCJK reports whether s contains Chinese characters
+func isCJK(s string) bool {
+	for _, r := range s {
+		if unicode.Is(unicode.Han, r) {
+			return true
+		}
+	}
+	return false
+}
+
+// authorInitials returns the initials of given names, each followed by sep.
+// Hyphenated names keep the hyphen: "Jean-Paul" gives "J.-P." with "." as sep.
+func authorInitials(given, sep string) []string {
+	var initials []string
+	for _, name := range strings.Fields(given) {
+		var parts []string
+		for _, part := range strings.Split(name, "-") {
+			runes := []rune(strings.TrimSuffix(part, "."))
+			if len(runes) > 0 {
+				parts = append(parts, string(unicode.ToUpper(runes[0]))+sep)
+			}
+		}
+		if len(parts) > 0 {
+			initials = append(initials, strings.Join(parts, "-"))
+		}
+	}
+	return initials
+}
+
+// apaAuthor formats an author as "Davidson, R. J.". Chinese names are written in full.
+func apaAuthor(author models.ReferenceAuthor) string {
+	if isCJK(author.Family) {
+		return author.Family + author.Given
+	}
+	initials := authorInitials(author.Given, ".")
+	if len(initials) == 0 {
+		return author.Family
+	}
+	return author.Family + ", " + strings.Join(initials, " ")
+}
+
+// gbtAuthor formats an author as "DAVIDSON R J". Chinese names are written in full.
+func gbtAuthor(author models.ReferenceAuthor) string {
+	if isCJK(author.Family) {
+		return author.Family + author.Given
+	}
+	name := strings.ToUpper(author.Family)
+	if initials := authorInitials(author.Given, ""); len(initials) > 0 {
+		name += " " + strings.Join(initials, " ")
+	}
+	return name
+}
+
+// apaAuthors joins authors the APA way: "A, B, & C", with the authors after
+// the 19th elided up to the last one when there are more than 20
+func apaAuthors(authors []models.ReferenceAuthor) string {
+	names := make([]string, len(authors))
+	for i, author := range authors {
+		names[i] = apaAuthor(author)
+	}
+
+	switch {
+	case len(names) == 0:
+		return ""
+	case len(names) == 1:
+		return names[0]
+	case len(names) > apaMaxAuthors:
+		return strings.Join(names[:apaMaxAuthors-1], ", ") + ", . . . " + names[len(names)-1]
+	}
+	return strings.Join(names[:len(names)-1], ", ") + ", & " + names[len(names)-1]
+}
+
+// gbtAuthors joins authors the GB/T 7714 way: at most three, then "et al." or "等"
+func gbtAuthors(authors []models.ReferenceAuthor) string {
+	var names []string
+	for i, author := range authors {
+		if i == gbtMaxAuthors {
+			if isCJK(authors[0].Family) {
+				names = append(names, "等")
+			} else {
+				names = append(names, "et al")
+			}
+			break
+		}
+		names = append(names, gbtAuthor(author))
+	}
+	return strings.Join(names, ", ")
+}
+
+// withPeriod ends s with a period unless it already ends with a sentence mark
+func withPeriod(s string) string {
+	if s == "" || strings.ContainsAny(s[len(s)-1:], ".?!") || strings.HasSuffix(s, "。") ||
+		strings.HasSuffix(s, "？") || strings.HasSuffix(s, "！") {
+		return s
+	}
+	return s + "."
+}
+
+// volumeIssue formats "65(4)", "65" or "(4)"
+func volumeIssue(ref *models.Reference) string {
+	s := ref.Volume
+	if ref.Issue != "" {
+		s += "(" + ref.Issue + ")"
+	}
+	return s
+}
+
+// FormatAPA formats a reference in APA 7th edition style:
+//
+//	Davidson, R. J., & Kabat-Zinn, J. (2003). Title. Journal, 65(4), 564–570. https://doi.org/10.1097/...
+func FormatAPA(ref *models.Reference) string {
+	var b strings.Builder
+	b.WriteString(withPeriod(apaAuthors(ref.Authors)))
+	fmt.Fprintf(&b, " (%d). %s", ref.Year, withPeriod(ref.Title))
+
+	if ref.Journal != "" {
+		source := []string{ref.Journal}
+		if vi := volumeIssue(ref); vi != "" {
+			source = append(source, vi)
+		}
+		if ref.Pages != "" {
+			source = append(source, strings.ReplaceAll(ref.Pages, "-", "–"))
+		}
+		b.WriteString(" " + strings.Join(source, ", ") + ".")
+	}
+
+	switch {
+	case ref.DOI != "":
+		b.WriteString(" https://doi.org/" + ref.DOI)
+	case ref.PubMedID != "":
+		b.WriteString(" https://pubmed.ncbi.nlm.nih.gov/" + ref.PubMedID + "/")
+	}
+	return b.String()
+}
+
+// FormatGBT7714 formats a reference in GB/T 7714-2015 style, without its number:
+//
+//	DAVIDSON R J, KABAT-ZINN J, SCHUMACHER J, et al. Title[J]. Journal, 2003, 65(4): 564-570. DOI:10.1097/....
+func FormatGBT7714(ref *models.Reference) string {
+	var b strings.Builder
+	b.WriteString(gbtAuthors(ref.Authors) + ". " + strings.TrimSuffix(ref.Title, "."))
+
+	if ref.Journal != "" {
+		fmt.Fprintf(&b, "[J]. %s, %d", ref.Journal, ref.Year)
+		if vi := volumeIssue(ref); vi != "" {
+			b.WriteString(", " + vi)
+		}
+		if ref.Pages != "" {
+			b.WriteString(": " + ref.Pages)
+		}
+	} else {
+		fmt.Fprintf(&b, "[Z]. %d", ref.Year)
+	}
+	b.WriteString(".")
+
+	if ref.DOI != "" {
+		b.WriteString(" DOI:" + ref.DOI + ".")
+	}
+	return b.String()
+}
+
+// RenderBibliography formats references in a style. APA entries are sorted by author and year;
+// GB/T 7714 entries keep the order of citation and are numbered.
+func RenderBibliography(refs []*models.Reference, style string) []string {
+	entries := make([]string, 0, len(refs))
+
+	if style == models.BibliographyGBT7714 {
+		for i, ref := range refs {
+			entries = append(entries, fmt.Sprintf("[%d] %s", i+1, FormatGBT7714(ref)))
+		}
+		return entries
+	}
+
+	sorted := append([]*models.Reference(nil), refs...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		a, b := apaAuthors(sorted[i].Authors), apaAuthors(sorted[j].Authors)
+		if !strings.EqualFold(a, b) {
+			return strings.ToLower(a) < strings.ToLower(b)
+		}
+		return sorted[i].Year < sorted[j].Year
+	})
+	for _, ref := range sorted {
+		entries = append(entries, FormatAPA(ref))
+	}
+	return entries
+}
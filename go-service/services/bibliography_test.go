@@ -0,0 +1,95 @@
+package services
+
+import (
+	"testing"
+
+	"neuro-guide-go-service/models"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func davidson2003() *models.Reference {
+	return &models.Reference{
+		Authors: []models.ReferenceAuthor{
+			{Family: "Davidson", Given: "Richard J."},
+			{Family: "Kabat-Zinn", Given: "Jon"},
+			{Family: "Schumacher", Given: "Jessica"},
+			{Family: "Rosenkranz", Given: "Melissa"},
+		},
+		Year:    2003,
+		Title:   "Alterations in brain and immune function produced by mindfulness meditation",
+		Journal: "Psychosomatic Medicine",
+		Volume:  "65",
+		Issue:   "4",
+		Pages:   "564-570",
+		DOI:     "10.1097/01.psy.0000077505.67574.e3",
+	}
+}
+
+func TestFormatAPA(t *testing.T) {
+	assert.Equal(t,
+		"Davidson, R. J., Kabat-Zinn, J., Schumacher, J., & Rosenkranz, M. (2003). "+
+			"Alterations in brain and immune function produced by mindfulness meditation. "+
+			"Psychosomatic Medicine, 65(4), 564–570. https://doi.org/10.1097/01.psy.0000077505.67574.e3",
+		FormatAPA(davidson2003()))
+
+	ref := &models.Reference{
+		Authors:  []models.ReferenceAuthor{{Family: "Hölzel", Given: "Britta K."}, {Family: "Lazar", Given: "Sara W."}},
+		Year:     2011,
+		Title:    "How does mindfulness meditation work?",
+		PubMedID: "26168376",
+	}
+	assert.Equal(t, "Hölzel, B. K., & Lazar, S. W. (2011). How does mindfulness meditation work? "+
+		"https://pubmed.ncbi.nlm.nih.gov/26168376/", FormatAPA(ref))
+}
+
+func TestFormatGBT7714(t *testing.T) {
+	assert.Equal(t,
+		"DAVIDSON R J, KABAT-ZINN J, SCHUMACHER J, et al. "+
+			"Alterations in brain and immune function produced by mindfulness meditation[J]. "+
+			"Psychosomatic Medicine, 2003, 65(4): 564-570. DOI:10.1097/01.psy.0000077505.67574.e3.",
+		FormatGBT7714(davidson2003()))
+
+	ref := &models.Reference{
+		Authors: []models.ReferenceAuthor{
+			{Family: "王", Given: "晓明"}, {Family: "李", Given: "华"}, {Family: "张", Given: "伟"}, {Family: "陈", Given: "静"},
+		},
+		Year:    2019,
+		Title:   "正念训练对焦虑的影响",
+		Journal: "心理学报",
+		Volume:  "51",
+	}
+	assert.Equal(t, "王晓明, 李华, 张伟, 等. 正念训练对焦虑的影响[J]. 心理学报, 2019, 51.", FormatGBT7714(ref))
+}
+
+func TestRenderBibliography(t *testing.T) {
+	later := &models.Reference{
+		Authors: []models.ReferenceAuthor{{Family: "Davidson", Given: "Richard J."}},
+		Year:    2004,
+		Title:   "Well-being and affective style",
+	}
+	first := &models.Reference{
+		Authors: []models.ReferenceAuthor{{Family: "Bishop", Given: "Scott R."}},
+		Year:    2004,
+		Title:   "Mindfulness: A proposed operational definition",
+	}
+	refs := []*models.Reference{later, first}
+
+	apa := RenderBibliography(refs, models.BibliographyAPA)
+	assert.Equal(t, []string{
+		"Bishop, S. R. (2004). Mindfulness: A proposed operational definition.",
+		"Davidson, R. J. (2004). Well-being and affective style.",
+	}, apa)
+
+	gbt := RenderBibliography(refs, models.BibliographyGBT7714)
+	assert.Equal(t, []string{
+		"[1] DAVIDSON R J. Well-being and affective style[Z]. 2004.",
+		"[2] BISHOP S R. Mindfulness: A proposed operational definition[Z]. 2004.",
+	}, gbt)
+}
+
+func TestAuthorInitials(t *testing.T) {
+	assert.Equal(t, []string{"J.-P."}, authorInitials("Jean-Paul", "."))
+	assert.Equal(t, []string{"R", "J"}, authorInitials("richard J.", ""))
+	assert.Empty(t, authorInitials("", "."))
+}
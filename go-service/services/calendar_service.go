@@ -0,0 +1,272 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"neuro-guide-go-service/config"
+	"neuro-guide-go-service/database"
+	"neuro-guide-go-service/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// ErrCalendarFeedNotFound is returned for an unknown or reset feed token
+var ErrCalendarFeedNotFound = errors.New("calendar feed not found")
+
+const (
+	icsDateLayout     = "20060102"
+	icsUTCLayout      = "20060102T150405Z"
+	icsLineOctets     = 75
+	defaultTaskLength = 15 * time.Minute // 未指定时长的任务在日历中的时长
+	taskAlarmBefore   = "-PT10M"         // 定时任务提前10分钟提醒
+	allDayTaskAlarm   = "PT9H"           // 全天任务当天9点提醒
+)
+
+// taskStartTimes is the local start time of tasks by time of day. Tasks for any time are all-day events.
+var taskStartTimes = map[string]struct{ hour, minute int }{
+	models.TimeOfDayMorning:   {7, 30},
+	models.TimeOfDayAfternoon: {14, 0},
+	models.TimeOfDayEvening:   {19, 0},
+	models.TimeOfDayBedtime:   {22, 0},
+}
+
+// CalendarService serves practice plans as iCalendar feeds
+type CalendarService struct {
+	feeds *mongo.Collection
+	plans *PracticePlanService
+}
+
+// NewCalendarService creates a new instance of CalendarService
+func NewCalendarService(cfg *config.Config) *CalendarService {
+	return &CalendarService{
+		feeds: database.Database.Collection("calendar_feeds"),
+		plans: NewPracticePlanService(cfg),
+	}
+}
+
+// GetFeed returns the user's calendar feed, creating it on first use
+func (cs *CalendarService) GetFeed(userID string) (*models.CalendarFeed, error) {
+	token, err := newShareToken()
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var feed models.CalendarFeed
+	err = cs.feeds.FindOneAndUpdate(ctx,
+		bson.M{"user_id": userID},
+		bson.M{"$setOnInsert": bson.M{"user_id": userID, "token": token, "created_at": time.Now()}},
+		options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After),
+	).Decode(&feed)
+	if err != nil {
+		return nil, err
+	}
+	return &feed, nil
+}
+
+// ResetFeed gives the user's feed a new token. Subscriptions with the old URL stop working.
+func (cs *CalendarService) ResetFeed(userID string) (*models.CalendarFeed, error) {
+	token, err := newShareToken()
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var feed models.CalendarFeed
+	err = cs.feeds.FindOneAndUpdate(ctx,
+		bson.M{"user_id": userID},
+		bson.M{"$set": bson.M{"user_id": userID, "token": token, "created_at": time.Now()}},
+		options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After),
+	).Decode(&feed)
+	if err != nil {
+		return nil, err
+	}
+	return &feed, nil
+}
+
+// findFeed loads a feed by its token
+func (cs *CalendarService) findFeed(token string) (*models.CalendarFeed, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var feed models.CalendarFeed
+	err := cs.feeds.FindOne(ctx, bson.M{"token": token}).Decode(&feed)
+	if err == mongo.ErrNoDocuments {
+		return nil, ErrCalendarFeedNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &feed, nil
+}
+
+// FeedCalendar renders the active, paused and completed plans of the feed's user
+func (cs *CalendarService) FeedCalendar(token string) ([]byte, error) {
+	feed, err := cs.findFeed(token)
+	if err != nil {
+		return nil, err
+	}
+
+	plans, err := cs.plans.GetPlansByUserID(feed.UserID)
+	if err != nil {
+		return nil, err
+	}
+	plans = FilterPlansByStatus(plans, []string{models.PlanStatusActive, models.PlanStatusPaused, models.PlanStatusCompleted})
+	return RenderPlanCalendar("修行计划", plans, cs.plans.location, time.Now()), nil
+}
+
+// FeedPlanCalendar renders one plan of the feed's user
+func (cs *CalendarService) FeedPlanCalendar(token, planID string) ([]byte, error) {
+	feed, err := cs.findFeed(token)
+	if err != nil {
+		return nil, err
+	}
+	return cs.PlanCalendar(feed.UserID, planID)
+}
+
+// PlanCalendar renders one of the user's plans
+func (cs *CalendarService) PlanCalendar(userID, planID string) ([]byte, error) {
+	plan, err := cs.plans.GetPlan(userID, planID)
+	if err != nil {
+		return nil, err
+	}
+	return RenderPlanCalendar(plan.Title, []*models.PracticePlan{plan}, cs.plans.location, time.Now()), nil
+}
+
+// RenderPlanCalendar renders plans as an RFC 5545 calendar with one event per task.
+// Event UIDs are stable and their SEQUENCE is the plan version, so subscribed
+// calendars update the events when a plan changes.
+func RenderPlanCalendar(name string, plans []*models.PracticePlan, fallback *time.Location, now time.Time) []byte {
+	var b strings.Builder
+	writeICSLine(&b, "BEGIN:VCALENDAR")
+	writeICSLine(&b, "VERSION:2.0")
+	writeICSLine(&b, "PRODID:-//Neuro Guide//Practice Plans//ZH")
+	writeICSLine(&b, "CALSCALE:GREGORIAN")
+	writeICSLine(&b, "METHOD:PUBLISH")
+	writeICSLine(&b, "X-WR-CALNAME:"+escapeICSText(name))
+	writeICSLine(&b, "REFRESH-INTERVAL;VALUE=DURATION:PT1H")
+	writeICSLine(&b, "X-PUBLISHED-TTL:PT1H")
+
+	stamp := now.UTC().Format(icsUTCLayout)
+	for _, plan := range plans {
+		dates := PlanDates(plan, fallback)
+		loc := planLocation(plan, fallback)
+
+		modified := plan.UpdatedAt
+		if modified.IsZero() {
+			modified = plan.CreatedAt
+		}
+
+		for _, task := range plan.Tasks {
+			date, ok := dates[task.Day]
+			if !ok {
+				continue
+			}
+			writeTaskEvent(&b, plan, task, date, loc, stamp, modified)
+		}
+	}
+
+	writeICSLine(&b, "END:VCALENDAR")
+	return []byte(b.String())
+}
+
+// writeTaskEvent writes the event of one task on its plan date
+func writeTaskEvent(b *strings.Builder, plan *models.PracticePlan, task models.PlanTask, date time.Time, loc *time.Location, stamp string, modified time.Time) {
+	summary := task.Title
+	if task.Optional {
+		summary += "（选做）"
+	}
+
+	writeICSLine(b, "BEGIN:VEVENT")
+	writeICSLine(b, fmt.Sprintf("UID:%s-%s@neuro-guide", plan.ID, task.ID))
+	writeICSLine(b, "DTSTAMP:"+stamp)
+	writeICSLine(b, "LAST-MODIFIED:"+modified.UTC().Format(icsUTCLayout))
+	writeICSLine(b, fmt.Sprintf("SEQUENCE:%d", plan.CurrentVersion()))
+
+	start, timed := taskStartTimes[task.TimeOfDay]
+	if timed {
+		// UTC times need no VTIMEZONE component
+		begin := time.Date(date.Year(), date.Month(), date.Day(), start.hour, start.minute, 0, 0, loc)
+		length := time.Duration(task.DurationMinutes) * time.Minute
+		if length == 0 {
+			length = defaultTaskLength
+		}
+		writeICSLine(b, "DTSTART:"+begin.UTC().Format(icsUTCLayout))
+		writeICSLine(b, "DTEND:"+begin.Add(length).UTC().Format(icsUTCLayout))
+	} else {
+		writeICSLine(b, "DTSTART;VALUE=DATE:"+date.Format(icsDateLayout))
+		writeICSLine(b, "DTEND;VALUE=DATE:"+date.AddDate(0, 0, 1).Format(icsDateLayout))
+		writeICSLine(b, "TRANSP:TRANSPARENT")
+	}
+
+	writeICSLine(b, "SUMMARY:"+escapeICSText(summary))
+	writeICSLine(b, "DESCRIPTION:"+escapeICSText(taskNotes(plan, task)))
+	if task.Type != "" {
+		writeICSLine(b, "CATEGORIES:"+escapeICSText(task.Type))
+	}
+
+	trigger := allDayTaskAlarm
+	if timed {
+		trigger = taskAlarmBefore
+	}
+	writeICSLine(b, "BEGIN:VALARM")
+	writeICSLine(b, "ACTION:DISPLAY")
+	writeICSLine(b, "DESCRIPTION:"+escapeICSText(summary))
+	writeICSLine(b, "TRIGGER:"+trigger)
+	writeICSLine(b, "END:VALARM")
+	writeICSLine(b, "END:VEVENT")
+}
+
+// taskNotes returns the event notes of a task
+func taskNotes(plan *models.PracticePlan, task models.PlanTask) string {
+	var parts []string
+	if task.Description != "" {
+		parts = append(parts, task.Description)
+	}
+	if task.ScientificBasis != "" {
+		parts = append(parts, "科学依据："+task.ScientificBasis)
+	}
+	if task.DurationMinutes > 0 {
+		parts = append(parts, fmt.Sprintf("时长：%d分钟", task.DurationMinutes))
+	}
+	parts = append(parts, fmt.Sprintf("%s · 第%d/%d天", plan.Title, task.Day, plan.Days))
+	return strings.Join(parts, "\n\n")
+}
+
+// escapeICSText escapes a TEXT property value
+func escapeICSText(text string) string {
+	return strings.NewReplacer(
+		`\`, `\\`,
+		";", `\;`,
+		",", `\,`,
+		"\r\n", `\n`,
+		"\n", `\n`,
+	).Replace(text)
+}
+
+// writeICSLine writes a content line, folding it into lines of at most 75 octets
+// without splitting UTF-8 characters
+func writeICSLine(b *strings.Builder, line string) {
+	width := 0
+	for _, r := range line {
+		size := utf8.RuneLen(r)
+		if width+size > icsLineOctets {
+			b.WriteString("\r\n ")
+			width = 1
+		}
+		b.WriteRune(r)
+		width += size
+	}
+	b.WriteString("\r\n")
+}
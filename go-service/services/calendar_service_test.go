@@ -0,0 +1,89 @@
+package services
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"neuro-guide-go-service/models"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func calendarPlan() *models.PracticePlan {
+	return &models.PracticePlan{
+		ID:        "plan-1",
+		Title:     "7天呼吸练习",
+		Days:      2,
+		Version:   3,
+		StartDate: "2024-05-06",
+		TimeZone:  "Asia/Shanghai",
+		UpdatedAt: time.Date(2024, 5, 1, 8, 0, 0, 0, time.UTC),
+		Tasks: []models.PlanTask{
+			{ID: "t1", Day: 1, Title: "腹式呼吸", Description: "坐直, 缓慢吸气; 呼气", ScientificBasis: "慢呼吸激活副交感神经",
+				Type: models.TaskTypeBreathing, TimeOfDay: models.TimeOfDayMorning, DurationMinutes: 10},
+			{ID: "t2", Day: 2, Title: "情绪日记", TimeOfDay: models.TimeOfDayAnytime, Optional: true},
+		},
+	}
+}
+
+// unfoldICS joins folded lines and splits the calendar into content lines
+func unfoldICS(data []byte) []string {
+	return strings.Split(strings.TrimSuffix(strings.ReplaceAll(string(data), "\r\n ", ""), "\r\n"), "\r\n")
+}
+
+func TestRenderPlanCalendar(t *testing.T) {
+	loc := time.FixedZone("CST", 8*3600)
+	now := time.Date(2024, 5, 2, 0, 0, 0, 0, time.UTC)
+	lines := unfoldICS(RenderPlanCalendar("修行计划", []*models.PracticePlan{calendarPlan()}, loc, now))
+
+	assert.Equal(t, "BEGIN:VCALENDAR", lines[0])
+	assert.Equal(t, "END:VCALENDAR", lines[len(lines)-1])
+	assert.Contains(t, lines, "X-WR-CALNAME:修行计划")
+
+	// A morning task at 07:30 in Shanghai
+	assert.Contains(t, lines, "UID:plan-1-t1@neuro-guide")
+	assert.Contains(t, lines, "DTSTAMP:20240502T000000Z")
+	assert.Contains(t, lines, "LAST-MODIFIED:20240501T080000Z")
+	assert.Contains(t, lines, "SEQUENCE:3")
+	assert.Contains(t, lines, "DTSTART:20240505T233000Z")
+	assert.Contains(t, lines, "DTEND:20240505T234000Z")
+	assert.Contains(t, lines, "SUMMARY:腹式呼吸")
+	assert.Contains(t, lines, `DESCRIPTION:坐直\, 缓慢吸气\; 呼气\n\n科学依据：慢呼吸激活副交感神经\n\n时长：10分钟\n\n7天呼吸练习 · 第1/2天`)
+	assert.Contains(t, lines, "CATEGORIES:breathing")
+	assert.Contains(t, lines, "TRIGGER:-PT10M")
+
+	// A task for any time of day is an all-day event on the next day
+	assert.Contains(t, lines, "DTSTART;VALUE=DATE:20240507")
+	assert.Contains(t, lines, "DTEND;VALUE=DATE:20240508")
+	assert.Contains(t, lines, "SUMMARY:情绪日记（选做）")
+	assert.Contains(t, lines, "TRIGGER:PT9H")
+
+	assert.Equal(t, 2, strings.Count(strings.Join(lines, "\n"), "BEGIN:VEVENT"))
+	assert.Equal(t, 2, strings.Count(strings.Join(lines, "\n"), "BEGIN:VALARM"))
+}
+
+func TestRenderPlanCalendarSkipsUnscheduledDays(t *testing.T) {
+	plan := calendarPlan()
+	plan.Pauses = []models.PlanPause{{From: "2024-05-07"}}
+
+	data := RenderPlanCalendar("修行计划", []*models.PracticePlan{plan}, time.UTC, time.Now())
+	assert.Equal(t, 1, strings.Count(string(data), "BEGIN:VEVENT"))
+}
+
+func TestWriteICSLineFolds(t *testing.T) {
+	var b strings.Builder
+	line := "DESCRIPTION:" + strings.Repeat("神经可塑性", 10)
+	writeICSLine(&b, line)
+
+	folded := strings.Split(strings.TrimSuffix(b.String(), "\r\n"), "\r\n")
+	assert.Greater(t, len(folded), 1)
+	for _, l := range folded {
+		assert.LessOrEqual(t, len(l), 75)
+	}
+	assert.Equal(t, line, strings.ReplaceAll(strings.TrimSuffix(b.String(), "\r\n"), "\r\n ", ""))
+}
+
+func TestEscapeICSText(t *testing.T) {
+	assert.Equal(t, `a\\b\;c\,d\ne`, escapeICSText("a\\b;c,d\ne"))
+}
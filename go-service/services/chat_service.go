@@ -4,11 +4,14 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"log"
 	"net/http"
 	"time"
 
+	"neuro-guide-go-service/config"
 	"neuro-guide-go-service/database"
 	"neuro-guide-go-service/models"
 
@@ -20,97 +23,493 @@ import (
 
 // ChatService handles chat-related business logic
 type ChatService struct {
-	collection         *mongo.Collection
-	pythonAIServiceURL string
-	httpClient         *http.Client
+	collection  *mongo.Collection
+	httpClient  *http.Client
+	router      *AIRouter
+	userService *UserService
+	redactor    *PIIRedactor
+	safety      *SafetyService
+	usage       *UsageService
+	summaries   *SummaryService
+	voice       *VoiceService
+	references  *ReferenceService
 }
 
 // NewChatService creates a new instance of ChatService
-func NewChatService(pythonAIServiceURL string) *ChatService {
+func NewChatService(cfg *config.Config) *ChatService {
 	return &ChatService{
-		collection:         database.Database.Collection("chat_messages"),
-		pythonAIServiceURL: pythonAIServiceURL,
+		collection: database.Database.Collection("chat_messages"),
 		httpClient: &http.Client{
-			Timeout: 30 * time.Second,
+			Timeout: aiRequestTimeout,
 		},
+		router:      NewAIRouter(cfg),
+		userService: NewUserService(),
+		redactor:    newConfiguredPIIRedactor(cfg.PIIDetectors, cfg.PIIRestoreDetectors),
+		safety:      NewSafetyService(cfg),
+		usage:       NewUsageService(cfg),
+		summaries:   NewSummaryService(cfg),
+		voice:       NewVoiceService(cfg),
+		references:  NewReferenceService(cfg),
 	}
 }
 
+// EnsureChatIndexes creates the indexes chat message persistence relies on
+func EnsureChatIndexes() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	// An idempotency key can be used once per user
+	_, err := database.Database.Collection("chat_messages").Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "user_id", Value: 1}, {Key: "idempotency_key", Value: 1}},
+		Options: options.Index().SetUnique(true).
+			SetPartialFilterExpression(bson.M{"idempotency_key": bson.M{"$exists": true}}),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create idempotency key index: %w", err)
+	}
+	return nil
+}
+
+// answeredMessages excludes user messages that are still pending or failed
+var answeredMessages = bson.M{"$nin": []string{models.MessageStatusPending, models.MessageStatusFailed}}
+
 // ChatRequest represents a chat request to Python AI service
 type ChatRequest struct {
 	UserID  string                   `json:"user_id"`
 	Message string                   `json:"message"`
 	Context []map[string]interface{} `json:"context,omitempty"`
+	Summary string                   `json:"summary,omitempty"` // rolling summary of earlier conversation
+	Mode    string                   `json:"mode,omitempty"`
 }
 
 // ChatResponse represents a response from Python AI service
 type ChatResponse struct {
-	Response string `json:"response"`
+	Response string                `json:"response"`
+	Parts    *models.ResponseParts `json:"parts,omitempty"`
+	Usage    *TokenUsage           `json:"usage,omitempty"`
 }
 
-// SendMessage sends a message to the Python AI service and saves it
-func (cs *ChatService) SendMessage(userID, message string, context []map[string]interface{}) (string, error) {
+// ErrMessageInProgress is returned when a message with the same idempotency key is still being answered
+var ErrMessageInProgress = errors.New("a message with this idempotency key is in progress")
+
+// ErrMessageNotFound is returned when a message does not exist or belongs to another user
+var ErrMessageNotFound = errors.New("message not found")
+
+// errMessageReclaimed is returned when a retry took over a message while it was being answered
+var errMessageReclaimed = errors.New("message was reclaimed by a retry")
+
+// aiRequestTimeout bounds a call to the AI service
+const aiRequestTimeout = 30 * time.Second
+
+// abandonedMessageAfter is how long a message may stay pending. A message pending for
+// longer lost its request, e.g. to a crash, and its idempotency key can be retried.
+const abandonedMessageAfter = 2 * aiRequestTimeout
+
+// SendMessageInput is a chat message sent by a user
+type SendMessageInput struct {
+	Message        string
+	Context        []map[string]interface{}
+	Mode           string // one of the models.ChatMode* values, empty for free chat
+	AudioID        string // voice message the text was transcribed from
+	IdempotencyKey string // client key that makes retries return the stored reply
+}
+
+// SendMessageResult is the outcome of SendMessage
+type SendMessageResult struct {
+	Reply    *models.ChatMessage
+	Replayed bool // the reply was stored by an earlier request with the same idempotency key
+}
+
+// SendMessage sends a message to the Python AI service and saves it.
+// The user message is saved as pending first and completed together with the
+// assistant reply, or marked failed. A repeated idempotency key returns the stored reply.
+func (cs *ChatService) SendMessage(userID string, input SendMessageInput) (*SendMessageResult, error) {
+	mode := input.Mode
+	if mode == "" {
+		mode = models.ChatModeChat
+	}
+	if !models.IsValidChatMode(mode) {
+		return nil, fmt.Errorf("unsupported chat mode %q", mode)
+	}
+
+	userMsg := &models.ChatMessage{
+		ID:             primitive.NewObjectID().Hex(),
+		UserID:         userID,
+		Message:        input.Message,
+		Role:           "user",
+		Timestamp:      time.Now(),
+		Mode:           mode,
+		AudioID:        input.AudioID,
+		Status:         models.MessageStatusPending,
+		IdempotencyKey: input.IdempotencyKey,
+	}
+
+	if input.IdempotencyKey != "" {
+		reply, err := cs.claimIdempotencyKey(userMsg)
+		if err != nil {
+			return nil, err
+		}
+		if reply != nil {
+			return &SendMessageResult{Reply: reply, Replayed: true}, nil
+		}
+	} else if err := cs.SaveMessage(userMsg); err != nil {
+		return nil, err
+	}
+
+	reply, err := cs.generateReply(userMsg, input.Context)
+	if err != nil {
+		cs.markFailed(userMsg, err)
+		return nil, err
+	}
+
+	go cs.summaries.MaybeSummarize(userID)
+
+	return &SendMessageResult{Reply: reply}, nil
+}
+
+// generateReply answers a pending user message and saves the exchange
+func (cs *ChatService) generateReply(userMsg *models.ChatMessage, context []map[string]interface{}) (*models.ChatMessage, error) {
+	userID := userMsg.UserID
+
+	// Replace personal information with placeholders before it leaves the service
+	redacted := cs.redactor.Redact(userMsg.Message)
+	redactedContext, redactions := cs.redactor.RedactContext(context, redacted.Redactions)
+
+	// Users in crisis get a vetted response instead of an AI answer
+	assessment := cs.safety.Assess(userID, redacted.Text)
+	if cs.safety.RequiresIntervention(assessment) {
+		return cs.respondToCrisis(userMsg, assessment)
+	}
+
+	// Enforce the daily request and token quotas
+	if err := cs.usage.CheckQuota(userID); err != nil {
+		return nil, err
+	}
+
 	// Prepare request to Python AI service
 	reqBody := ChatRequest{
 		UserID:  userID,
-		Message: message,
-		Context: context,
+		Message: redacted.Text,
+		Context: redactedContext,
+		Mode:    userMsg.Mode,
+	}
+
+	// Earlier conversation is passed as a summary instead of the full history
+	if summary, err := cs.summaries.GetLatestSummary(userID); err != nil {
+		log.Printf("Failed to load conversation summary for %s: %v", userID, err)
+	} else if summary != nil {
+		result := cs.redactor.redactWith(summary.Summary, redactions)
+		reqBody.Summary, redactions = result.Text, result.Redactions
+	}
+
+	target := cs.router.Route(userID, userMsg.Mode, cs.cohortOf(userID))
+	chatResp, err := cs.callAIService(target.URL, &reqBody)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := cs.usage.RecordUsage(userID, chatResp.Usage); err != nil {
+		log.Printf("Failed to record usage for %s: %v", userID, err)
+	}
+
+	// Put back the values the user is allowed to see in the reply
+	chatResp.Response = cs.redactor.Restore(chatResp.Response, redactions)
+	cs.restoreParts(chatResp.Parts, redactions)
+
+	// The reply is useful without reference links, so a failed lookup is only logged
+	if err := cs.references.LinkCitations(chatResp.Parts); err != nil {
+		log.Printf("Failed to link citations to references: %v", err)
+	}
+
+	assistantMsg := &models.ChatMessage{
+		ID:        primitive.NewObjectID().Hex(),
+		UserID:    userID,
+		Message:   chatResp.Response,
+		Role:      "assistant",
+		Timestamp: time.Now(),
+		Mode:      userMsg.Mode,
+		Variant:   &target.Assignment,
+	}
+	if !chatResp.Parts.IsEmpty() {
+		assistantMsg.Parts = chatResp.Parts
+	}
+
+	if err := cs.completeExchange(userMsg, assistantMsg); err != nil {
+		return nil, fmt.Errorf("failed to save reply: %w", err)
+	}
+
+	return assistantMsg, nil
+}
+
+// claimIdempotencyKey saves the pending user message under its idempotency key.
+// When the key was used before it returns the stored reply, ErrMessageInProgress,
+// or reclaims the failed or abandoned message for a retry.
+func (cs *ChatService) claimIdempotencyKey(userMsg *models.ChatMessage) (*models.ChatMessage, error) {
+	err := cs.SaveMessage(userMsg)
+	if err == nil {
+		return nil, nil
+	}
+	if !mongo.IsDuplicateKeyError(err) {
+		return nil, err
 	}
 
+	existing, err := cs.findByIdempotencyKey(userMsg.UserID, userMsg.IdempotencyKey)
+	if err != nil {
+		return nil, err
+	}
+
+	if isRetryable(existing) {
+		reclaimed, err := cs.reclaimMessage(existing, userMsg)
+		if err != nil {
+			return nil, err
+		}
+		if !reclaimed {
+			return nil, ErrMessageInProgress
+		}
+		userMsg.ID = existing.ID
+		return nil, nil
+	}
+	if existing.Status == models.MessageStatusPending {
+		return nil, ErrMessageInProgress
+	}
+
+	return cs.getMessage(existing.UserID, existing.ReplyID)
+}
+
+// isRetryable reports whether a user message failed or was abandoned while pending
+func isRetryable(msg *models.ChatMessage) bool {
+	switch msg.Status {
+	case models.MessageStatusFailed:
+		return true
+	case models.MessageStatusPending:
+		return time.Since(msg.Timestamp) > abandonedMessageAfter
+	}
+	return false
+}
+
+// ReplayMessage returns the stored reply for an idempotency key, or nil when
+// the key is unused or its message failed or was abandoned
+func (cs *ChatService) ReplayMessage(userID, idempotencyKey string) (*models.ChatMessage, error) {
+	existing, err := cs.findByIdempotencyKey(userID, idempotencyKey)
+	if err == mongo.ErrNoDocuments {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if isRetryable(existing) {
+		return nil, nil
+	}
+	if existing.Status == models.MessageStatusPending {
+		return nil, ErrMessageInProgress
+	}
+	return cs.getMessage(userID, existing.ReplyID)
+}
+
+// findByIdempotencyKey retrieves the user message saved under an idempotency key
+func (cs *ChatService) findByIdempotencyKey(userID, idempotencyKey string) (*models.ChatMessage, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var stored storedChatMessage
+	err := cs.collection.FindOne(ctx, bson.M{"user_id": userID, "idempotency_key": idempotencyKey}).Decode(&stored)
+	if err != nil {
+		return nil, err
+	}
+	return decryptMessage(&stored)
+}
+
+// getMessage retrieves one of a user's messages by ID
+func (cs *ChatService) getMessage(userID, messageID string) (*models.ChatMessage, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var stored storedChatMessage
+	err := cs.collection.FindOne(ctx, bson.M{"_id": messageObjectID(messageID), "user_id": userID}).Decode(&stored)
+	if err != nil {
+		return nil, err
+	}
+	return decryptMessage(&stored)
+}
+
+// reclaimMessage moves a failed or abandoned user message back to pending with the
+// content of the retry. It reports false when another retry got there first.
+func (cs *ChatService) reclaimMessage(existing, retry *models.ChatMessage) (bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	encrypted, keyVersion, err := encryptFields(retry.UserID, retry.Message)
+	if err != nil {
+		return false, err
+	}
+
+	set := bson.M{
+		"status":    models.MessageStatusPending,
+		"timestamp": retry.Timestamp,
+		"message":   encrypted[0],
+		"mode":      retry.Mode,
+	}
+	unset := bson.M{}
+	if keyVersion > 0 {
+		set["key_version"] = keyVersion
+	} else {
+		unset["key_version"] = ""
+	}
+	if retry.AudioID != "" {
+		set["audio_id"] = retry.AudioID
+	} else {
+		unset["audio_id"] = ""
+	}
+	update := bson.M{"$set": set}
+	if len(unset) > 0 {
+		update["$unset"] = unset
+	}
+
+	// The status and timestamp identify the attempt that is taken over
+	result, err := cs.collection.UpdateOne(ctx, bson.M{
+		"_id":       messageObjectID(existing.ID),
+		"status":    existing.Status,
+		"timestamp": existing.Timestamp,
+	}, update)
+	if err != nil {
+		return false, err
+	}
+	return result.ModifiedCount == 1, nil
+}
+
+// completeExchange saves the assistant reply and completes the user message atomically
+func (cs *ChatService) completeExchange(userMsg, assistantMsg *models.ChatMessage) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	update := bson.M{"status": models.MessageStatusCompleted, "reply_id": assistantMsg.ID}
+	if userMsg.Flagged {
+		update["flagged"] = true
+	}
+
+	return withTransaction(ctx, func(ctx context.Context) error {
+		if err := cs.insertMessage(ctx, assistantMsg); err != nil {
+			return err
+		}
+		result, err := cs.collection.UpdateOne(ctx, pendingAttempt(userMsg), bson.M{"$set": update})
+		if err != nil {
+			return err
+		}
+		if result.MatchedCount == 0 {
+			return errMessageReclaimed
+		}
+		return nil
+	}, func(ctx context.Context) {
+		if _, err := cs.collection.DeleteOne(ctx, bson.M{"_id": messageObjectID(assistantMsg.ID)}); err != nil {
+			log.Printf("Failed to remove reply %s of incomplete exchange: %v", assistantMsg.ID, err)
+		}
+	})
+}
+
+// markFailed records that no reply could be generated for a user message
+func (cs *ChatService) markFailed(userMsg *models.ChatMessage, cause error) {
+	log.Printf("Failed to answer message %s of %s: %v", userMsg.ID, userMsg.UserID, cause)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	_, err := cs.collection.UpdateOne(ctx, pendingAttempt(userMsg), bson.M{"$set": bson.M{"status": models.MessageStatusFailed}})
+	if err != nil {
+		log.Printf("Failed to mark message %s as failed: %v", userMsg.ID, err)
+	}
+}
+
+// pendingAttempt matches a user message while it is still pending in this attempt,
+// so an attempt that was abandoned and reclaimed by a retry cannot complete it
+func pendingAttempt(userMsg *models.ChatMessage) bson.M {
+	return bson.M{
+		"_id":       messageObjectID(userMsg.ID),
+		"status":    models.MessageStatusPending,
+		"timestamp": userMsg.Timestamp,
+	}
+}
+
+// messageObjectID converts a message ID to the form it is stored under
+func messageObjectID(id string) interface{} {
+	if objID, err := primitive.ObjectIDFromHex(id); err == nil {
+		return objID
+	}
+	return id
+}
+
+// restoreParts puts back the redacted values in the free-text fields of the structured parts
+func (cs *ChatService) restoreParts(parts *models.ResponseParts, redactions []Redaction) {
+	if parts == nil {
+		return
+	}
+	for i := range parts.Mechanisms {
+		parts.Mechanisms[i].Description = cs.redactor.Restore(parts.Mechanisms[i].Description, redactions)
+	}
+	for i := range parts.Practices {
+		parts.Practices[i].Description = cs.redactor.Restore(parts.Practices[i].Description, redactions)
+	}
+}
+
+// cohortOf returns the routing cohort of a user
+func (cs *ChatService) cohortOf(userID string) string {
+	return userCohort(cs.userService, userID)
+}
+
+// callAIService posts a chat request to an AI backend
+func (cs *ChatService) callAIService(baseURL string, reqBody *ChatRequest) (*ChatResponse, error) {
 	jsonData, err := json.Marshal(reqBody)
 	if err != nil {
-		return "", fmt.Errorf("failed to marshal request: %w", err)
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
 	}
 
 	// Call Python AI service
 	resp, err := cs.httpClient.Post(
-		fmt.Sprintf("%s/chat", cs.pythonAIServiceURL),
+		fmt.Sprintf("%s/chat", baseURL),
 		"application/json",
 		bytes.NewBuffer(jsonData),
 	)
 	if err != nil {
-		return "", fmt.Errorf("failed to call AI service: %w", err)
+		return nil, fmt.Errorf("failed to call AI service: %w", err)
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
 		body, _ := io.ReadAll(resp.Body)
-		return "", fmt.Errorf("AI service returned error: %s", string(body))
+		return nil, fmt.Errorf("AI service returned error: %s", string(body))
 	}
 
 	// Parse response
 	var chatResp ChatResponse
 	if err := json.NewDecoder(resp.Body).Decode(&chatResp); err != nil {
-		return "", fmt.Errorf("failed to decode response: %w", err)
+		return nil, fmt.Errorf("failed to decode response: %w", err)
 	}
 
-	// Save user message
-	userMsg := models.ChatMessage{
-		ID:        primitive.NewObjectID().Hex(),
-		UserID:    userID,
-		Message:   message,
-		Role:      "user",
-		Timestamp: time.Now(),
-	}
-	if err := cs.SaveMessage(&userMsg); err != nil {
-		// Log error but don't fail the request
-		fmt.Printf("Failed to save user message: %v\n", err)
+	return &chatResp, nil
+}
+
+// respondToCrisis saves the flagged exchange, records a safety event for review
+// and returns the vetted crisis response
+func (cs *ChatService) respondToCrisis(userMsg *models.ChatMessage, assessment *SafetyAssessment) (*models.ChatMessage, error) {
+	userMsg.Flagged = true
+	if err := cs.safety.RecordEvent(userMsg.UserID, userMsg.ID, assessment); err != nil {
+		log.Printf("Failed to record safety event for %s: %v", userMsg.UserID, err)
 	}
 
-	// Save assistant response
-	assistantMsg := models.ChatMessage{
+	assistantMsg := &models.ChatMessage{
 		ID:        primitive.NewObjectID().Hex(),
-		UserID:    userID,
-		Message:   chatResp.Response,
+		UserID:    userMsg.UserID,
+		Message:   cs.safety.CrisisResponse(),
 		Role:      "assistant",
 		Timestamp: time.Now(),
+		Flagged:   true,
 	}
-	if err := cs.SaveMessage(&assistantMsg); err != nil {
-		// Log error but don't fail the request
-		fmt.Printf("Failed to save assistant message: %v\n", err)
+	if err := cs.completeExchange(userMsg, assistantMsg); err != nil {
+		// The crisis response is shown even when it could not be saved
+		log.Printf("Failed to save crisis response for %s: %v", userMsg.UserID, err)
 	}
 
-	return chatResp.Response, nil
+	return assistantMsg, nil
 }
 
 // SaveMessage saves a chat message to the database
@@ -118,31 +517,131 @@ func (cs *ChatService) SaveMessage(message *models.ChatMessage) error {
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
-	// Convert string ID to ObjectID if needed
+	return cs.insertMessage(ctx, message)
+}
+
+// insertMessage encrypts and inserts a chat message
+func (cs *ChatService) insertMessage(ctx context.Context, message *models.ChatMessage) error {
+	// Structured parts are stored as encrypted JSON next to the message content
+	var partsJSON []byte
+	if !message.Parts.IsEmpty() {
+		var err error
+		if partsJSON, err = json.Marshal(message.Parts); err != nil {
+			return fmt.Errorf("failed to marshal response parts: %w", err)
+		}
+	}
+	encrypted, keyVersion, err := encryptFields(message.UserID, message.Message, string(partsJSON))
+	if err != nil {
+		return err
+	}
+
+	doc := bson.M{
+		"user_id":   message.UserID,
+		"message":   encrypted[0],
+		"role":      message.Role,
+		"timestamp": message.Timestamp,
+	}
 	if message.ID != "" {
-		objID, err := primitive.ObjectIDFromHex(message.ID)
-		if err == nil {
-			_, err = cs.collection.InsertOne(ctx, bson.M{
-				"_id":       objID,
-				"user_id":   message.UserID,
-				"message":   message.Message,
-				"role":      message.Role,
-				"timestamp": message.Timestamp,
-			})
-			return err
+		doc["_id"] = messageObjectID(message.ID)
+	}
+	if keyVersion > 0 {
+		doc["key_version"] = keyVersion
+	}
+	if message.Flagged {
+		doc["flagged"] = true
+	}
+	if message.Mode != "" {
+		doc["mode"] = message.Mode
+	}
+	if message.AudioID != "" {
+		doc["audio_id"] = message.AudioID
+	}
+	if message.Status != "" {
+		doc["status"] = message.Status
+	}
+	if message.ReplyID != "" {
+		doc["reply_id"] = message.ReplyID
+	}
+	if message.IdempotencyKey != "" {
+		doc["idempotency_key"] = message.IdempotencyKey
+	}
+	if message.Variant != nil {
+		doc["variant"] = message.Variant
+	}
+	if message.Proactive {
+		doc["proactive"] = true
+	}
+	if encrypted[1] != "" {
+		doc["parts"] = encrypted[1]
+	}
+
+	result, err := cs.collection.InsertOne(ctx, doc)
+	if err != nil {
+		return err
+	}
+	if message.ID == "" {
+		if objID, ok := result.InsertedID.(primitive.ObjectID); ok {
+			message.ID = objID.Hex()
+		}
+	}
+	return nil
+}
+
+// storedChatMessage is the database form of a chat message, with the structured parts encrypted
+type storedChatMessage struct {
+	models.ChatMessage `bson:",inline"`
+	EncryptedParts     string `bson:"parts,omitempty"`
+}
+
+// decryptMessage decrypts the content and structured parts of a stored message
+func decryptMessage(stored *storedChatMessage) (*models.ChatMessage, error) {
+	msg := stored.ChatMessage
+
+	plaintext, err := decryptField(msg.UserID, msg.Message)
+	if err != nil {
+		return nil, err
+	}
+	msg.Message = plaintext
+
+	if stored.EncryptedParts != "" {
+		partsJSON, err := decryptField(msg.UserID, stored.EncryptedParts)
+		if err != nil {
+			return nil, err
+		}
+		var parts models.ResponseParts
+		if err := json.Unmarshal([]byte(partsJSON), &parts); err != nil {
+			return nil, fmt.Errorf("failed to decode response parts: %w", err)
 		}
+		msg.Parts = &parts
+	}
+
+	return &msg, nil
+}
+
+// decodeMessages reads and decrypts every message of a cursor
+func decodeMessages(ctx context.Context, cursor *mongo.Cursor) ([]*models.ChatMessage, error) {
+	var stored []*storedChatMessage
+	if err := cursor.All(ctx, &stored); err != nil {
+		return nil, err
 	}
 
-	_, err := cs.collection.InsertOne(ctx, message)
-	return err
+	messages := make([]*models.ChatMessage, 0, len(stored))
+	for _, s := range stored {
+		msg, err := decryptMessage(s)
+		if err != nil {
+			return nil, err
+		}
+		messages = append(messages, msg)
+	}
+	return messages, nil
 }
 
-// GetChatHistory retrieves chat history for a user
+// GetChatHistory retrieves the answered messages of a user; pending and failed attempts are left out
 func (cs *ChatService) GetChatHistory(userID string, limit int64) ([]*models.ChatMessage, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
-	filter := bson.M{"user_id": userID}
+	filter := bson.M{"user_id": userID, "status": answeredMessages}
 	opts := options.Find().SetSort(bson.M{"timestamp": -1}).SetLimit(limit)
 
 	cursor, err := cs.collection.Find(ctx, filter, opts)
@@ -151,8 +650,9 @@ func (cs *ChatService) GetChatHistory(userID string, limit int64) ([]*models.Cha
 	}
 	defer cursor.Close(ctx)
 
-	var messages []*models.ChatMessage
-	if err := cursor.All(ctx, &messages); err != nil {
+	// Decrypt message content
+	messages, err := decodeMessages(ctx, cursor)
+	if err != nil {
 		return nil, err
 	}
 
@@ -170,6 +670,229 @@ func (cs *ChatService) ClearChatHistory(userID string) error {
 	defer cancel()
 
 	filter := bson.M{"user_id": userID}
-	_, err := cs.collection.DeleteMany(ctx, filter)
-	return err
+	if _, err := cs.collection.DeleteMany(ctx, filter); err != nil {
+		return err
+	}
+
+	// Summaries and voice messages belong to the cleared messages and go with them
+	if err := cs.summaries.DeleteSummaries(userID); err != nil {
+		return err
+	}
+	return cs.voice.DeleteUserAudio(userID)
+}
+
+// TranscribeVoice stores and transcribes a voice message
+func (cs *ChatService) TranscribeVoice(userID, format string, audio []byte) (*models.AudioFile, error) {
+	return cs.voice.SaveAndTranscribe(userID, format, audio)
+}
+
+// DiscardVoice removes a transcribed voice message whose text could not be sent
+func (cs *ChatService) DiscardVoice(file *models.AudioFile) {
+	if err := cs.voice.DeleteAudio(file); err != nil {
+		log.Printf("Failed to delete unsent audio %s: %v", file.ID, err)
+	}
+}
+
+// GetAudioFile retrieves a user's voice message
+func (cs *ChatService) GetAudioFile(userID, audioID string) (*models.AudioFile, error) {
+	return cs.voice.GetAudioFile(userID, audioID)
+}
+
+// OpenAudio returns the content of a voice message
+func (cs *ChatService) OpenAudio(file *models.AudioFile) (io.ReadCloser, error) {
+	return cs.voice.OpenAudio(file)
+}
+
+// GetLatestSummary returns the current title and summary of a user's conversation
+func (cs *ChatService) GetLatestSummary(userID string) (*models.ConversationSummary, error) {
+	return cs.summaries.GetLatestSummary(userID)
+}
+
+// GetSummaryHistory returns all summary versions of a user's conversation
+func (cs *ChatService) GetSummaryHistory(userID string) ([]*models.ConversationSummary, error) {
+	return cs.summaries.GetSummaryHistory(userID)
+}
+
+// GetMessagesInRange retrieves a user's messages in chronological order,
+// optionally limited to [from, to)
+func (cs *ChatService) GetMessagesInRange(userID string, from, to *time.Time) ([]*models.ChatMessage, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	filter := bson.M{"user_id": userID, "status": answeredMessages}
+	timeRange := bson.M{}
+	if from != nil {
+		timeRange["$gte"] = *from
+	}
+	if to != nil {
+		timeRange["$lt"] = *to
+	}
+	if len(timeRange) > 0 {
+		filter["timestamp"] = timeRange
+	}
+	opts := options.Find().SetSort(bson.M{"timestamp": 1})
+
+	cursor, err := cs.collection.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	return decodeMessages(ctx, cursor)
+}
+
+// ExportChat renders a user's conversation, or the part of it between the
+// from and to dates (YYYY-MM-DD, inclusive, empty for open-ended), in the given format
+func (cs *ChatService) ExportChat(userID, format, fromDate, toDate string) (*ExportedFile, error) {
+	export := &ChatExport{
+		Title:      "神经科学修行对话记录",
+		ExportedAt: time.Now(),
+		Location:   cs.usage.location,
+	}
+
+	if fromDate != "" {
+		from, err := time.ParseInLocation(usageDateLayout, fromDate, export.Location)
+		if err != nil {
+			return nil, fmt.Errorf("invalid from date: %w", err)
+		}
+		export.From = &from
+	}
+	if toDate != "" {
+		to, err := time.ParseInLocation(usageDateLayout, toDate, export.Location)
+		if err != nil {
+			return nil, fmt.Errorf("invalid to date: %w", err)
+		}
+		export.To = &to
+	}
+
+	var end *time.Time
+	if export.To != nil {
+		next := export.To.AddDate(0, 0, 1)
+		end = &next
+	}
+
+	messages, err := cs.GetMessagesInRange(userID, export.From, end)
+	if err != nil {
+		return nil, err
+	}
+	export.Messages = messages
+
+	if summary, err := cs.summaries.GetLatestSummary(userID); err == nil && summary != nil && summary.Title != "" {
+		export.Title = summary.Title
+	}
+
+	return RenderChatExport(export, format)
+}
+
+// SetFeedback records the user's thumbs rating of an assistant reply. A rating of 0 clears it.
+func (cs *ChatService) SetFeedback(userID, messageID string, rating int) error {
+	if !models.IsValidFeedback(rating) {
+		return fmt.Errorf("invalid feedback rating %d", rating)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	update := bson.M{"$unset": bson.M{"feedback": ""}}
+	if rating != 0 {
+		update = bson.M{"$set": bson.M{"feedback": rating}}
+	}
+
+	result, err := cs.collection.UpdateOne(ctx,
+		bson.M{"_id": messageObjectID(messageID), "user_id": userID, "role": "assistant"}, update)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return ErrMessageNotFound
+	}
+	return nil
+}
+
+// GetVariantStats aggregates assistant replies and their feedback per experiment variant between
+// the from and to dates (YYYY-MM-DD, inclusive) in the quota time zone
+func (cs *ChatService) GetVariantStats(fromDate, toDate string) ([]*models.VariantStats, error) {
+	loc := cs.usage.location
+	from, err := time.ParseInLocation(usageDateLayout, fromDate, loc)
+	if err != nil {
+		return nil, fmt.Errorf("invalid from date: %w", err)
+	}
+	to, err := time.ParseInLocation(usageDateLayout, toDate, loc)
+	if err != nil {
+		return nil, fmt.Errorf("invalid to date: %w", err)
+	}
+	to = to.AddDate(0, 0, 1)
+
+	_, offset := from.Zone()
+	timezone := fmt.Sprintf("%+03d:%02d", offset/3600, abs(offset%3600)/60)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{
+			"role":               "assistant",
+			"variant.experiment": bson.M{"$exists": true},
+			"timestamp":          bson.M{"$gte": from, "$lt": to},
+		}}},
+		// One document per user and variant with the days the user chatted
+		{{Key: "$group", Value: bson.M{
+			"_id": bson.M{
+				"experiment": "$variant.experiment",
+				"variant":    "$variant.variant",
+				"backend":    "$variant.backend",
+				"user_id":    "$user_id",
+			},
+			"replies":     bson.M{"$sum": 1},
+			"thumbs_up":   bson.M{"$sum": bson.M{"$cond": bson.A{bson.M{"$eq": bson.A{"$feedback", models.FeedbackThumbsUp}}, 1, 0}}},
+			"thumbs_down": bson.M{"$sum": bson.M{"$cond": bson.A{bson.M{"$eq": bson.A{"$feedback", models.FeedbackThumbsDown}}, 1, 0}}},
+			"days": bson.M{"$addToSet": bson.M{
+				"$dateToString": bson.M{"format": "%Y-%m-%d", "date": "$timestamp", "timezone": timezone},
+			}},
+		}}},
+		{{Key: "$group", Value: bson.M{
+			"_id": bson.M{
+				"experiment": "$_id.experiment",
+				"variant":    "$_id.variant",
+				"backend":    "$_id.backend",
+			},
+			"replies":     bson.M{"$sum": "$replies"},
+			"users":       bson.M{"$sum": 1},
+			"active_days": bson.M{"$avg": bson.M{"$size": "$days"}},
+			"thumbs_up":   bson.M{"$sum": "$thumbs_up"},
+			"thumbs_down": bson.M{"$sum": "$thumbs_down"},
+		}}},
+		{{Key: "$project", Value: bson.M{
+			"_id":         0,
+			"experiment":  "$_id.experiment",
+			"variant":     "$_id.variant",
+			"backend":     "$_id.backend",
+			"replies":     1,
+			"users":       1,
+			"active_days": 1,
+			"thumbs_up":   1,
+			"thumbs_down": 1,
+		}}},
+		{{Key: "$sort", Value: bson.D{{Key: "experiment", Value: 1}, {Key: "variant", Value: 1}}}},
+	}
+
+	cursor, err := cs.collection.Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var stats []*models.VariantStats
+	if err := cursor.All(ctx, &stats); err != nil {
+		return nil, err
+	}
+
+	return stats, nil
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
 }
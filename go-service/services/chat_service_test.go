@@ -0,0 +1,75 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"neuro-guide-go-service/models"
+
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+func TestDecryptMessage_Parts(t *testing.T) {
+	stored := &storedChatMessage{
+		ChatMessage: models.ChatMessage{UserID: "u1", Message: "解读", Role: "assistant", Mode: models.ChatModeQuoteInterpretation},
+		EncryptedParts: `{"mechanisms":[{"name":"知行断层","brain_regions":["前额叶皮层"],"pathways":["多巴胺奖赏通路"]}],` +
+			`"citations":[{"source_document_id":"doc-1","title":"传习录"}]}`,
+	}
+
+	msg, err := decryptMessage(stored)
+	assert.NoError(t, err)
+	assert.Equal(t, "解读", msg.Message)
+	assert.Equal(t, []string{"前额叶皮层"}, msg.Parts.Mechanisms[0].BrainRegions)
+	assert.Equal(t, "doc-1", msg.Parts.Citations[0].SourceDocumentID)
+
+	stored.EncryptedParts = ""
+	msg, err = decryptMessage(stored)
+	assert.NoError(t, err)
+	assert.Nil(t, msg.Parts)
+}
+
+func TestResponseParts_IsEmpty(t *testing.T) {
+	var parts *models.ResponseParts
+	assert.True(t, parts.IsEmpty())
+	assert.True(t, (&models.ResponseParts{}).IsEmpty())
+	assert.False(t, (&models.ResponseParts{Practices: []models.SuggestedPractice{{Name: "正念呼吸"}}}).IsEmpty())
+}
+
+func TestIsValidChatMode(t *testing.T) {
+	for _, mode := range []string{models.ChatModeChat, models.ChatModeQuoteInterpretation, models.ChatModeSymptomMechanism, models.ChatModeKnowledgeQA} {
+		assert.True(t, models.IsValidChatMode(mode), mode)
+	}
+	assert.False(t, models.IsValidChatMode("poetry"))
+	assert.False(t, models.IsValidChatMode(""))
+}
+
+func TestIsTransactionUnsupported(t *testing.T) {
+	assert.False(t, isTransactionUnsupported(nil))
+	assert.True(t, isTransactionUnsupported(mongo.CommandError{Code: 20, Message: "Transaction numbers are only allowed on a replica set member or mongos"}))
+	assert.False(t, isTransactionUnsupported(mongo.CommandError{Code: 112, Message: "WriteConflict"}))
+}
+
+func TestMessageObjectID(t *testing.T) {
+	id := primitive.NewObjectID()
+	assert.Equal(t, id, messageObjectID(id.Hex()))
+	assert.Equal(t, "legacy-id", messageObjectID("legacy-id"))
+}
+
+func TestIsRetryable(t *testing.T) {
+	assert.True(t, isRetryable(&models.ChatMessage{Status: models.MessageStatusFailed, Timestamp: time.Now()}))
+	assert.False(t, isRetryable(&models.ChatMessage{Status: models.MessageStatusPending, Timestamp: time.Now()}))
+	assert.True(t, isRetryable(&models.ChatMessage{
+		Status:    models.MessageStatusPending,
+		Timestamp: time.Now().Add(-abandonedMessageAfter - time.Second),
+	}))
+	assert.False(t, isRetryable(&models.ChatMessage{Status: models.MessageStatusCompleted}))
+}
+
+func TestSetFeedbackRejectsInvalidRating(t *testing.T) {
+	err := (&ChatService{}).SetFeedback("user-1", primitive.NewObjectID().Hex(), 5)
+	assert.EqualError(t, err, "invalid feedback rating 5")
+	assert.True(t, models.IsValidFeedback(models.FeedbackThumbsDown))
+	assert.True(t, models.IsValidFeedback(0))
+}
@@ -0,0 +1,485 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"regexp"
+	"sort"
+	"time"
+
+	"neuro-guide-go-service/config"
+	"neuro-guide-go-service/database"
+	"neuro-guide-go-service/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// ErrInvalidCheckInPreference is returned for invalid check-in settings
+var ErrInvalidCheckInPreference = errors.New("invalid check-in preference")
+
+// checkInTimePattern matches a local time of day in HH:MM format
+var checkInTimePattern = regexp.MustCompile(`^([01][0-9]|2[0-3]):[0-5][0-9]$`)
+
+// CheckInPlan is the part of the user's active plan sent with a check-in request
+type CheckInPlan struct {
+	Title     string            `json:"title"`
+	Day       int               `json:"day"`
+	TotalDays int               `json:"total_days"`
+	Tasks     []models.PlanTask `json:"tasks"`
+}
+
+// EmotionSample is a recent emotion analysis of one of the user's messages
+type EmotionSample struct {
+	Emotion    string    `json:"emotion"`
+	Confidence float64   `json:"confidence"`
+	Timestamp  time.Time `json:"timestamp"`
+}
+
+// CheckInRequest represents a check-in request to Python AI service
+type CheckInRequest struct {
+	UserID    string          `json:"user_id"`
+	LocalTime string          `json:"local_time"`
+	Plan      *CheckInPlan    `json:"plan,omitempty"`
+	Emotions  []EmotionSample `json:"emotions,omitempty"`
+	Summary   string          `json:"summary,omitempty"`
+}
+
+// CheckInService sends proactive assistant messages at the times users prefer
+type CheckInService struct {
+	preferences        *mongo.Collection
+	plans              *mongo.Collection
+	messages           *mongo.Collection
+	chat               *ChatService
+	summaries          *SummaryService
+	redactor           *PIIRedactor
+	pythonAIServiceURL string
+	httpClient         *http.Client
+
+	defaultTimes      []string
+	defaultMaxPerWeek int
+	location          *time.Location
+	quietAfterChat    time.Duration
+}
+
+// NewCheckInService creates a new instance of CheckInService
+func NewCheckInService(cfg *config.Config) *CheckInService {
+	defaultTimes := cfg.CheckInDefaultTimes
+	if defaultTimes == nil {
+		defaultTimes = []string{"20:30"}
+	}
+
+	return &CheckInService{
+		preferences:        database.Database.Collection("checkin_preferences"),
+		plans:              database.Database.Collection("practice_plans"),
+		messages:           database.Database.Collection("chat_messages"),
+		chat:               NewChatService(cfg),
+		summaries:          NewSummaryService(cfg),
+		redactor:           newConfiguredPIIRedactor(cfg.PIIDetectors, cfg.PIIRestoreDetectors),
+		pythonAIServiceURL: cfg.PythonAIServiceURL,
+		httpClient:         &http.Client{Timeout: 30 * time.Second},
+		defaultTimes:       defaultTimes,
+		defaultMaxPerWeek:  cfg.CheckInMaxPerWeek,
+		location:           defaultUserLocation(cfg),
+		quietAfterChat:     cfg.CheckInQuietAfterChat,
+	}
+}
+
+// defaultPreference returns the preference of a user who never changed the settings
+func (cis *CheckInService) defaultPreference(userID string) *models.CheckInPreference {
+	return &models.CheckInPreference{
+		UserID:     userID,
+		Enabled:    true,
+		Times:      cis.defaultTimes,
+		TimeZone:   cis.location.String(),
+		MaxPerWeek: cis.defaultMaxPerWeek,
+	}
+}
+
+// GetPreference returns a user's check-in preference, or the defaults
+func (cis *CheckInService) GetPreference(userID string) (*models.CheckInPreference, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var pref models.CheckInPreference
+	err := cis.preferences.FindOne(ctx, bson.M{"user_id": userID}).Decode(&pref)
+	if err == mongo.ErrNoDocuments {
+		return cis.defaultPreference(userID), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &pref, nil
+}
+
+// UpdatePreference validates and saves a user's check-in preference.
+// Users can lower the weekly cap but not raise it above the configured maximum.
+func (cis *CheckInService) UpdatePreference(pref *models.CheckInPreference) error {
+	if err := cis.validatePreference(pref); err != nil {
+		return err
+	}
+	pref.UpdatedAt = time.Now()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	_, err := cis.preferences.UpdateOne(ctx,
+		bson.M{"user_id": pref.UserID},
+		bson.M{"$set": bson.M{
+			"enabled":      pref.Enabled,
+			"times":        pref.Times,
+			"time_zone":    pref.TimeZone,
+			"max_per_week": pref.MaxPerWeek,
+			"updated_at":   pref.UpdatedAt,
+		}},
+		options.Update().SetUpsert(true),
+	)
+	return err
+}
+
+// validatePreference checks times, time zone and cap of a preference
+func (cis *CheckInService) validatePreference(pref *models.CheckInPreference) error {
+	if len(pref.Times) > 3 {
+		return fmt.Errorf("%w: at most 3 times a day", ErrInvalidCheckInPreference)
+	}
+	seen := make(map[string]bool)
+	times := []string{}
+	for _, t := range pref.Times {
+		if !checkInTimePattern.MatchString(t) {
+			return fmt.Errorf("%w: time %q must be HH:MM", ErrInvalidCheckInPreference, t)
+		}
+		if !seen[t] {
+			seen[t] = true
+			times = append(times, t)
+		}
+	}
+	sort.Strings(times)
+	pref.Times = times
+
+	if pref.TimeZone == "" {
+		pref.TimeZone = cis.location.String()
+	} else if _, err := time.LoadLocation(pref.TimeZone); err != nil {
+		return fmt.Errorf("%w: unknown time zone %q", ErrInvalidCheckInPreference, pref.TimeZone)
+	}
+
+	if pref.MaxPerWeek < 0 || pref.MaxPerWeek > cis.defaultMaxPerWeek {
+		return fmt.Errorf("%w: max_per_week must be between 0 and %d", ErrInvalidCheckInPreference, cis.defaultMaxPerWeek)
+	}
+	return nil
+}
+
+// preferenceLocation returns the time zone of a preference
+func (cis *CheckInService) preferenceLocation(pref *models.CheckInPreference) *time.Location {
+	if pref.TimeZone != "" {
+		if loc, err := time.LoadLocation(pref.TimeZone); err == nil {
+			return loc
+		}
+	}
+	return cis.location
+}
+
+// dueCheckInSlot returns the preferred time that has passed within the window
+// and has no check-in yet, if any
+func dueCheckInSlot(times []string, loc *time.Location, now time.Time, window time.Duration, last *time.Time) (time.Time, bool) {
+	local := now.In(loc)
+	for _, t := range times {
+		slotTime, err := time.ParseInLocation("15:04", t, loc)
+		if err != nil {
+			continue
+		}
+		slot := time.Date(local.Year(), local.Month(), local.Day(), slotTime.Hour(), slotTime.Minute(), 0, 0, loc)
+		if slot.After(now) || now.Sub(slot) >= window {
+			continue
+		}
+		if last != nil && !last.Before(slot) {
+			continue
+		}
+		return slot, true
+	}
+	return time.Time{}, false
+}
+
+// StartScheduler periodically sends due check-ins
+func (cis *CheckInService) StartScheduler(interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			if err := cis.sendDueCheckIns(time.Now(), 2*interval); err != nil {
+				log.Printf("Check-in scheduler failed: %v", err)
+			}
+		}
+	}()
+}
+
+// sendDueCheckIns runs one pass of the scheduler over users with an active plan
+func (cis *CheckInService) sendDueCheckIns(now time.Time, window time.Duration) error {
+	plans, err := cis.activePlans(now)
+	if err != nil {
+		return err
+	}
+
+	for userID, plan := range plans {
+		pref, err := cis.GetPreference(userID)
+		if err != nil {
+			log.Printf("Failed to load check-in preference for %s: %v", userID, err)
+			continue
+		}
+		if !pref.Enabled || pref.MaxPerWeek == 0 {
+			continue
+		}
+
+		loc := cis.preferenceLocation(pref)
+		if _, due := dueCheckInSlot(pref.Times, loc, now, window, pref.LastCheckInAt); !due {
+			continue
+		}
+
+		allowed, err := cis.allowedToSend(userID, pref, now)
+		if err != nil {
+			log.Printf("Failed to check check-in limits for %s: %v", userID, err)
+			continue
+		}
+		if !allowed {
+			continue
+		}
+
+		if err := cis.sendCheckIn(userID, plan, now, loc); err != nil {
+			log.Printf("Failed to send check-in to %s: %v", userID, err)
+		}
+	}
+
+	return nil
+}
+
+// activePlans returns the newest running plan of every user that has a practice day at now
+func (cis *CheckInService) activePlans(now time.Time) (map[string]*models.PracticePlan, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	cursor, err := cis.plans.Find(ctx, runningPlanFilter(), options.Find().SetSort(bson.M{"created_at": -1}))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	active := make(map[string]*models.PracticePlan)
+	for cursor.Next(ctx) {
+		var plan models.PracticePlan
+		if err := cursor.Decode(&plan); err != nil {
+			return nil, err
+		}
+		// A newer plan that has not started or already ended does not hide an older running one
+		if active[plan.UserID] != nil {
+			continue
+		}
+		if _, ok := currentPlanDay(&plan, now, cis.location); ok {
+			active[plan.UserID] = &plan
+		}
+	}
+	return active, cursor.Err()
+}
+
+// allowedToSend applies the weekly cap and skips users who are chatting or were
+// recently flagged by the safety layer
+func (cis *CheckInService) allowedToSend(userID string, pref *models.CheckInPreference, now time.Time) (bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	sent, err := cis.messages.CountDocuments(ctx, bson.M{
+		"user_id":   userID,
+		"proactive": true,
+		"timestamp": bson.M{"$gte": now.AddDate(0, 0, -7)},
+	})
+	if err != nil {
+		return false, err
+	}
+	if int(sent) >= pref.MaxPerWeek {
+		return false, nil
+	}
+
+	// Do not interrupt an ongoing conversation
+	recent, err := cis.messages.CountDocuments(ctx, bson.M{
+		"user_id":   userID,
+		"role":      "user",
+		"timestamp": bson.M{"$gte": now.Add(-cis.quietAfterChat)},
+	})
+	if err != nil {
+		return false, err
+	}
+	if recent > 0 {
+		return false, nil
+	}
+
+	// Users in crisis are left to the human review process
+	flagged, err := cis.messages.CountDocuments(ctx, bson.M{
+		"user_id":   userID,
+		"flagged":   true,
+		"timestamp": bson.M{"$gte": now.Add(-24 * time.Hour)},
+	})
+	if err != nil {
+		return false, err
+	}
+	return flagged == 0, nil
+}
+
+// loadRecentEmotions returns the emotion analyses of the user's messages of the last three days
+func loadRecentEmotions(messages *mongo.Collection, userID string, now time.Time) ([]EmotionSample, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	filter := bson.M{
+		"user_id":          userID,
+		"emotion_analysis": bson.M{"$exists": true},
+		"timestamp":        bson.M{"$gte": now.AddDate(0, 0, -3)},
+	}
+	opts := options.Find().SetSort(bson.M{"timestamp": -1}).SetLimit(20).
+		SetProjection(bson.M{"emotion_analysis": 1, "timestamp": 1})
+
+	cursor, err := messages.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var docs []struct {
+		EmotionAnalysis *models.EmotionAnalysis `bson:"emotion_analysis"`
+		Timestamp       time.Time               `bson:"timestamp"`
+	}
+	if err := cursor.All(ctx, &docs); err != nil {
+		return nil, err
+	}
+
+	emotions := []EmotionSample{}
+	for _, doc := range docs {
+		if doc.EmotionAnalysis == nil {
+			continue
+		}
+		emotions = append(emotions, EmotionSample{
+			Emotion:    doc.EmotionAnalysis.Emotion,
+			Confidence: doc.EmotionAnalysis.Confidence,
+			Timestamp:  doc.Timestamp,
+		})
+	}
+	return emotions, nil
+}
+
+// sendCheckIn generates a check-in message and inserts it into the conversation
+func (cis *CheckInService) sendCheckIn(userID string, plan *models.PracticePlan, now time.Time, loc *time.Location) error {
+	day, _ := currentPlanDay(plan, now, cis.location)
+	tasks := []models.PlanTask{}
+	for _, task := range plan.Tasks {
+		if task.Day == day {
+			tasks = append(tasks, task)
+		}
+	}
+
+	reqBody := CheckInRequest{
+		UserID:    userID,
+		LocalTime: now.In(loc).Format("2006-01-02 15:04"),
+		Plan:      &CheckInPlan{Title: plan.Title, Day: day, TotalDays: plan.Days, Tasks: tasks},
+	}
+
+	emotions, err := loadRecentEmotions(cis.messages, userID, now)
+	if err != nil {
+		return err
+	}
+	reqBody.Emotions = emotions
+
+	// The summary carries what the user said recently, without personal details
+	var redactions []Redaction
+	if summary, err := cis.summaries.GetLatestSummary(userID); err != nil {
+		log.Printf("Failed to load conversation summary for %s: %v", userID, err)
+	} else if summary != nil {
+		result := cis.redactor.Redact(summary.Summary)
+		reqBody.Summary, redactions = result.Text, result.Redactions
+	}
+
+	text, err := cis.callAIService(&reqBody)
+	if err != nil {
+		return err
+	}
+	if text == "" {
+		// The AI service decided there is nothing worth saying
+		return cis.recordCheckIn(userID, now)
+	}
+
+	message := &models.ChatMessage{
+		ID:        primitive.NewObjectID().Hex(),
+		UserID:    userID,
+		Message:   cis.redactor.Restore(text, redactions),
+		Role:      "assistant",
+		Timestamp: now,
+		Proactive: true,
+	}
+	if err := cis.chat.SaveMessage(message); err != nil {
+		return err
+	}
+
+	return cis.recordCheckIn(userID, now)
+}
+
+// recordCheckIn remembers when the last check-in slot was handled
+func (cis *CheckInService) recordCheckIn(userID string, at time.Time) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	pref, err := cis.GetPreference(userID)
+	if err != nil {
+		return err
+	}
+
+	_, err = cis.preferences.UpdateOne(ctx,
+		bson.M{"user_id": userID},
+		bson.M{
+			"$set": bson.M{"last_checkin_at": at},
+			"$setOnInsert": bson.M{
+				"enabled":      pref.Enabled,
+				"times":        pref.Times,
+				"time_zone":    pref.TimeZone,
+				"max_per_week": pref.MaxPerWeek,
+				"updated_at":   at,
+			},
+		},
+		options.Update().SetUpsert(true),
+	)
+	return err
+}
+
+// callAIService posts a check-in request to the Python AI service
+func (cis *CheckInService) callAIService(reqBody *CheckInRequest) (string, error) {
+	jsonData, err := json.Marshal(reqBody)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	resp, err := cis.httpClient.Post(
+		fmt.Sprintf("%s/chat/checkin", cis.pythonAIServiceURL),
+		"application/json",
+		bytes.NewBuffer(jsonData),
+	)
+	if err != nil {
+		return "", fmt.Errorf("failed to call AI service: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return "", fmt.Errorf("AI service returned error: %s", string(body))
+	}
+
+	var chatResp ChatResponse
+	if err := json.NewDecoder(resp.Body).Decode(&chatResp); err != nil {
+		return "", fmt.Errorf("failed to decode response: %w", err)
+	}
+
+	return chatResp.Response, nil
+}
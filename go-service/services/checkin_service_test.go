@@ -0,0 +1,71 @@
+package services
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"neuro-guide-go-service/models"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDueCheckInSlot(t *testing.T) {
+	loc := time.FixedZone("CST", 8*3600)
+	times := []string{"08:00", "20:30"}
+	window := 10 * time.Minute
+
+	now := time.Date(2024, 5, 1, 20, 35, 0, 0, loc)
+	slot, due := dueCheckInSlot(times, loc, now, window, nil)
+	assert.True(t, due)
+	assert.True(t, slot.Equal(time.Date(2024, 5, 1, 20, 30, 0, 0, loc)))
+
+	// Already sent for this slot
+	last := time.Date(2024, 5, 1, 20, 31, 0, 0, loc)
+	_, due = dueCheckInSlot(times, loc, now, window, &last)
+	assert.False(t, due)
+
+	// Sent yesterday
+	last = time.Date(2024, 4, 30, 20, 31, 0, 0, loc)
+	_, due = dueCheckInSlot(times, loc, now, window, &last)
+	assert.True(t, due)
+
+	// Outside the window and before the slot
+	_, due = dueCheckInSlot(times, loc, time.Date(2024, 5, 1, 21, 0, 0, 0, loc), window, nil)
+	assert.False(t, due)
+	_, due = dueCheckInSlot(times, loc, time.Date(2024, 5, 1, 20, 29, 0, 0, loc), window, nil)
+	assert.False(t, due)
+
+	// Preferred times are local to the user's time zone
+	_, due = dueCheckInSlot(times, loc, time.Date(2024, 5, 1, 12, 32, 0, 0, time.UTC), window, nil)
+	assert.True(t, due)
+}
+
+func TestCurrentPlanDay(t *testing.T) {
+	loc := time.FixedZone("CST", 8*3600)
+	plan := &models.PracticePlan{Days: 7, CreatedAt: time.Date(2024, 5, 1, 22, 0, 0, 0, loc)}
+
+	day, active := currentPlanDay(plan, time.Date(2024, 5, 1, 23, 0, 0, 0, loc), loc)
+	assert.Equal(t, 1, day)
+	assert.True(t, active)
+
+	day, active = currentPlanDay(plan, time.Date(2024, 5, 7, 8, 0, 0, 0, loc), loc)
+	assert.Equal(t, 7, day)
+	assert.True(t, active)
+
+	_, active = currentPlanDay(plan, time.Date(2024, 5, 8, 8, 0, 0, 0, loc), loc)
+	assert.False(t, active)
+}
+
+func TestCheckInService_ValidatePreference(t *testing.T) {
+	cis := &CheckInService{location: time.UTC, defaultMaxPerWeek: 3}
+
+	pref := &models.CheckInPreference{Times: []string{"21:00", "08:00", "21:00"}, MaxPerWeek: 2}
+	assert.NoError(t, cis.validatePreference(pref))
+	assert.Equal(t, []string{"08:00", "21:00"}, pref.Times)
+	assert.Equal(t, "UTC", pref.TimeZone)
+
+	assert.True(t, errors.Is(cis.validatePreference(&models.CheckInPreference{Times: []string{"8:00"}}), ErrInvalidCheckInPreference))
+	assert.True(t, errors.Is(cis.validatePreference(&models.CheckInPreference{TimeZone: "Mars/Base"}), ErrInvalidCheckInPreference))
+	assert.True(t, errors.Is(cis.validatePreference(&models.CheckInPreference{MaxPerWeek: 7}), ErrInvalidCheckInPreference))
+}
@@ -0,0 +1,724 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"strings"
+	"time"
+
+	"neuro-guide-go-service/config"
+	"neuro-guide-go-service/database"
+	"neuro-guide-go-service/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// ErrCohortNotFound is returned when a cohort does not exist or the user is not a member
+var ErrCohortNotFound = errors.New("cohort not found")
+
+// ErrCohortFull is returned when joining a cohort that has reached its member limit
+var ErrCohortFull = errors.New("cohort is full")
+
+// ErrCohortEnded is returned when joining a cohort whose last day has passed
+var ErrCohortEnded = errors.New("cohort has ended")
+
+// ErrAlreadyCohortMember is returned when joining a cohort twice
+var ErrAlreadyCohortMember = errors.New("already a member of this cohort")
+
+// ErrNotCohortOrganizer is returned when a member tries something only the organizer can do
+var ErrNotCohortOrganizer = errors.New("only the organizer can do this")
+
+// ErrOrganizerCannotLeave is returned when the organizer leaves their own cohort
+var ErrOrganizerCannotLeave = errors.New("the organizer cannot leave the cohort")
+
+// ErrCohortAnnouncementNotFound is returned when an announcement does not exist
+var ErrCohortAnnouncementNotFound = errors.New("announcement not found")
+
+// joinCodeAlphabet leaves out characters that are easily confused, such as 0 and O
+const joinCodeAlphabet = "ABCDEFGHJKLMNPQRSTUVWXYZ23456789"
+
+// joinCodeLength is the number of characters of a join code
+const joinCodeLength = 8
+
+// CohortInput is a request to start a cohort from one of the organizer's plans
+type CohortInput struct {
+	Name        string
+	Description string
+	PlanID      string
+	Schedule    PlanSchedule
+	MaxMembers  int    // 0 means no limit
+	DisplayName string // organizer's name in the member list
+}
+
+// CohortService manages cohorts of users who follow the same plan together
+type CohortService struct {
+	cohorts       *mongo.Collection
+	members       *mongo.Collection
+	announcements *mongo.Collection
+	records       *mongo.Collection
+	plans         *PracticePlanService
+	users         *UserService
+}
+
+// NewCohortService creates a new instance of CohortService
+func NewCohortService(cfg *config.Config) *CohortService {
+	return &CohortService{
+		cohorts:       database.Database.Collection("cohorts"),
+		members:       database.Database.Collection("cohort_members"),
+		announcements: database.Database.Collection("cohort_announcements"),
+		records:       database.Database.Collection("practice_records"),
+		plans:         NewPracticePlanService(cfg),
+		users:         NewUserService(),
+	}
+}
+
+// EnsureCohortIndexes creates the indexes cohorts rely on
+func EnsureCohortIndexes() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	// Cohorts are joined by code
+	_, err := database.Database.Collection("cohorts").Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "join_code", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create cohort index: %w", err)
+	}
+
+	// A user joins a cohort once
+	_, err = database.Database.Collection("cohort_members").Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "cohort_id", Value: 1}, {Key: "user_id", Value: 1}}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.D{{Key: "user_id", Value: 1}}},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create cohort member indexes: %w", err)
+	}
+
+	_, err = database.Database.Collection("cohort_announcements").Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "cohort_id", Value: 1}, {Key: "created_at", Value: -1}},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create cohort announcement index: %w", err)
+	}
+	return nil
+}
+
+// newJoinCode returns a random join code
+func newJoinCode() (string, error) {
+	code := make([]byte, joinCodeLength)
+	max := big.NewInt(int64(len(joinCodeAlphabet)))
+	for i := range code {
+		n, err := rand.Int(rand.Reader, max)
+		if err != nil {
+			return "", err
+		}
+		code[i] = joinCodeAlphabet[n.Int64()]
+	}
+	return string(code), nil
+}
+
+// cohortPlan returns the shared plan and schedule of a cohort as a plan
+func cohortPlan(cohort *models.Cohort) *models.PracticePlan {
+	return &models.PracticePlan{
+		Title:        cohort.Title,
+		Days:         cohort.Days,
+		Tasks:        cohort.Tasks,
+		StartDate:    cohort.StartDate,
+		TimeZone:     cohort.TimeZone,
+		RestWeekdays: cohort.RestWeekdays,
+	}
+}
+
+// CreateCohort starts a cohort with a copy of one of the organizer's plans. The organizer
+// joins it and, like every member, practices with their own copy of the plan.
+func (cs *CohortService) CreateCohort(userID string, input CohortInput) (*models.Cohort, error) {
+	source, err := cs.plans.GetPlan(userID, input.PlanID)
+	if err != nil {
+		return nil, err
+	}
+
+	shared := &models.PracticePlan{Days: source.Days, Tasks: append([]models.PlanTask(nil), source.Tasks...)}
+	if err := applySchedule(shared, input.Schedule, cs.plans.location, time.Now()); err != nil {
+		return nil, err
+	}
+	if problems := scheduleProblems(shared); len(problems) > 0 {
+		return nil, &PlanValidationError{Problems: problems}
+	}
+	if input.MaxMembers < 0 {
+		return nil, &PlanValidationError{Problems: []string{"max members must not be negative"}}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	objID := primitive.NewObjectID()
+	cohort := &models.Cohort{
+		ID:           objID.Hex(),
+		Name:         strings.TrimSpace(input.Name),
+		Description:  strings.TrimSpace(input.Description),
+		OrganizerID:  userID,
+		SourcePlanID: source.ID,
+		Title:        source.Title,
+		Days:         shared.Days,
+		Tasks:        shared.Tasks,
+		StartDate:    shared.StartDate,
+		TimeZone:     shared.TimeZone,
+		RestWeekdays: shared.RestWeekdays,
+		MaxMembers:   input.MaxMembers,
+		CreatedAt:    time.Now(),
+	}
+
+	doc := bson.M{
+		"_id":            objID,
+		"name":           cohort.Name,
+		"organizer_id":   cohort.OrganizerID,
+		"source_plan_id": cohort.SourcePlanID,
+		"title":          cohort.Title,
+		"days":           cohort.Days,
+		"tasks":          cohort.Tasks,
+		"start_date":     cohort.StartDate,
+		"member_count":   0,
+		"created_at":     cohort.CreatedAt,
+	}
+	if cohort.Description != "" {
+		doc["description"] = cohort.Description
+	}
+	if cohort.TimeZone != "" {
+		doc["time_zone"] = cohort.TimeZone
+	}
+	if len(cohort.RestWeekdays) > 0 {
+		doc["rest_weekdays"] = cohort.RestWeekdays
+	}
+	if cohort.MaxMembers > 0 {
+		doc["max_members"] = cohort.MaxMembers
+	}
+
+	// Join codes are random, so a collision is retried with a new one
+	for attempt := 0; ; attempt++ {
+		if cohort.JoinCode, err = newJoinCode(); err != nil {
+			return nil, err
+		}
+		doc["join_code"] = cohort.JoinCode
+		_, err = cs.cohorts.InsertOne(ctx, doc)
+		if !mongo.IsDuplicateKeyError(err) || attempt == 2 {
+			break
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := cs.addMember(cohort, userID, input.DisplayName, models.CohortRoleOrganizer); err != nil {
+		if _, delErr := cs.cohorts.DeleteOne(ctx, bson.M{"_id": objID}); delErr != nil {
+			log.Printf("Failed to remove cohort %s: %v", cohort.ID, delErr)
+		}
+		return nil, err
+	}
+	cohort.MemberCount = 1
+	cohort.IsOrganizer = true
+
+	return cohort, nil
+}
+
+// JoinCohort adds the user to the cohort with a join code and gives them their own copy of its plan
+func (cs *CohortService) JoinCohort(userID, code, displayName string) (*models.Cohort, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var cohort models.Cohort
+	err := cs.cohorts.FindOne(ctx, bson.M{"join_code": strings.ToUpper(strings.TrimSpace(code))}).Decode(&cohort)
+	if err == mongo.ErrNoDocuments {
+		return nil, ErrCohortNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if PlanDayAt(cohortPlan(&cohort), time.Now(), cs.plans.location).Status == PlanDayFinished {
+		return nil, ErrCohortEnded
+	}
+
+	if _, err := cs.addMember(&cohort, userID, displayName, models.CohortRoleMember); err != nil {
+		return nil, err
+	}
+
+	cohort.MemberCount++
+	viewCohortAs(&cohort, models.CohortRoleMember)
+	return &cohort, nil
+}
+
+// addMember takes a place in the cohort, creates the member's plan and records the membership.
+// Each step is undone when a later one fails.
+func (cs *CohortService) addMember(cohort *models.Cohort, userID, displayName, role string) (*models.CohortMember, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	cohortID := messageObjectID(cohort.ID)
+	count, err := cs.members.CountDocuments(ctx, bson.M{"cohort_id": cohort.ID, "user_id": userID})
+	if err != nil {
+		return nil, err
+	}
+	if count > 0 {
+		return nil, ErrAlreadyCohortMember
+	}
+
+	result, err := cs.cohorts.UpdateOne(ctx, bson.M{"_id": cohortID, "$or": bson.A{
+		bson.M{"max_members": bson.M{"$exists": false}},
+		bson.M{"$expr": bson.M{"$lt": bson.A{"$member_count", "$max_members"}}},
+	}}, bson.M{"$inc": bson.M{"member_count": 1}})
+	if err != nil {
+		return nil, err
+	}
+	if result.MatchedCount == 0 {
+		return nil, ErrCohortFull
+	}
+	releasePlace := func() {
+		if _, err := cs.cohorts.UpdateOne(ctx, bson.M{"_id": cohortID}, bson.M{"$inc": bson.M{"member_count": -1}}); err != nil {
+			log.Printf("Failed to release place in cohort %s: %v", cohort.ID, err)
+		}
+	}
+
+	plan := &models.PracticePlan{
+		UserID:   userID,
+		Title:    cohort.Title,
+		Days:     cohort.Days,
+		Tasks:    append([]models.PlanTask(nil), cohort.Tasks...),
+		Source:   models.PlanSourceCohort,
+		CohortID: cohort.ID,
+	}
+	schedule := PlanSchedule{StartDate: cohort.StartDate, TimeZone: cohort.TimeZone, RestWeekdays: cohort.RestWeekdays}
+	if err := cs.plans.CreatePlan(plan, schedule); err != nil {
+		releasePlace()
+		return nil, err
+	}
+
+	objID := primitive.NewObjectID()
+	member := &models.CohortMember{
+		ID:          objID.Hex(),
+		CohortID:    cohort.ID,
+		UserID:      userID,
+		DisplayName: cs.displayName(userID, displayName),
+		Role:        role,
+		PlanID:      plan.ID,
+		JoinedAt:    time.Now(),
+	}
+	_, err = cs.members.InsertOne(ctx, bson.M{
+		"_id":          objID,
+		"cohort_id":    member.CohortID,
+		"user_id":      member.UserID,
+		"display_name": member.DisplayName,
+		"role":         member.Role,
+		"plan_id":      member.PlanID,
+		"joined_at":    member.JoinedAt,
+	})
+	if err != nil {
+		if purgeErr := cs.plans.PurgePlan(userID, plan.ID); purgeErr != nil {
+			log.Printf("Failed to remove plan %s: %v", plan.ID, purgeErr)
+		}
+		releasePlace()
+		if mongo.IsDuplicateKeyError(err) {
+			return nil, ErrAlreadyCohortMember
+		}
+		return nil, err
+	}
+
+	return member, nil
+}
+
+// displayName returns the name a member is listed under: the requested one, their nickname, or a placeholder
+func (cs *CohortService) displayName(userID, requested string) string {
+	if name := strings.TrimSpace(requested); name != "" {
+		return name
+	}
+	if user, err := cs.users.GetUserByID(userID); err == nil && user.Nickname != "" {
+		return user.Nickname
+	}
+	return "修行者"
+}
+
+// membership returns the user's membership of a cohort. Non-members get ErrCohortNotFound,
+// so cohorts stay private to their members.
+func (cs *CohortService) membership(ctx context.Context, userID, cohortID string) (*models.CohortMember, error) {
+	var member models.CohortMember
+	err := cs.members.FindOne(ctx, bson.M{"cohort_id": cohortID, "user_id": userID}).Decode(&member)
+	if err == mongo.ErrNoDocuments {
+		return nil, ErrCohortNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &member, nil
+}
+
+// viewCohortAs prepares a cohort for a member with the given role: only the organizer
+// sees the join code, and members learn whether they organize it but not who does
+func viewCohortAs(cohort *models.Cohort, role string) {
+	cohort.IsOrganizer = role == models.CohortRoleOrganizer
+	if !cohort.IsOrganizer {
+		cohort.JoinCode = ""
+	}
+}
+
+// findCohort loads a cohort a user is a member of. The join code is only kept for the organizer.
+func (cs *CohortService) findCohort(ctx context.Context, userID, cohortID string) (*models.Cohort, *models.CohortMember, error) {
+	objID, err := primitive.ObjectIDFromHex(cohortID)
+	if err != nil {
+		return nil, nil, ErrCohortNotFound
+	}
+
+	member, err := cs.membership(ctx, userID, cohortID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var cohort models.Cohort
+	err = cs.cohorts.FindOne(ctx, bson.M{"_id": objID}).Decode(&cohort)
+	if err == mongo.ErrNoDocuments {
+		return nil, nil, ErrCohortNotFound
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	viewCohortAs(&cohort, member.Role)
+	return &cohort, member, nil
+}
+
+// organizerCohort loads a cohort the user organizes
+func (cs *CohortService) organizerCohort(ctx context.Context, userID, cohortID string) (*models.Cohort, error) {
+	cohort, member, err := cs.findCohort(ctx, userID, cohortID)
+	if err != nil {
+		return nil, err
+	}
+	if member.Role != models.CohortRoleOrganizer {
+		return nil, ErrNotCohortOrganizer
+	}
+	return cohort, nil
+}
+
+// GetCohort retrieves a cohort the user is a member of
+func (cs *CohortService) GetCohort(userID, cohortID string) (*models.Cohort, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	cohort, _, err := cs.findCohort(ctx, userID, cohortID)
+	return cohort, err
+}
+
+// ListCohorts returns the cohorts the user is a member of, most recently joined first
+func (cs *CohortService) ListCohorts(userID string) ([]*models.Cohort, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	cursor, err := cs.members.Find(ctx, bson.M{"user_id": userID}, options.Find().SetSort(bson.M{"joined_at": -1}))
+	if err != nil {
+		return nil, err
+	}
+	var memberships []*models.CohortMember
+	if err := cursor.All(ctx, &memberships); err != nil {
+		return nil, err
+	}
+
+	var ids []interface{}
+	for _, member := range memberships {
+		ids = append(ids, messageObjectID(member.CohortID))
+	}
+
+	cohorts := []*models.Cohort{}
+	if len(ids) == 0 {
+		return cohorts, nil
+	}
+
+	cursor, err = cs.cohorts.Find(ctx, bson.M{"_id": bson.M{"$in": ids}})
+	if err != nil {
+		return nil, err
+	}
+	var found []*models.Cohort
+	if err := cursor.All(ctx, &found); err != nil {
+		return nil, err
+	}
+
+	byID := make(map[string]*models.Cohort, len(found))
+	for _, cohort := range found {
+		byID[cohort.ID] = cohort
+	}
+	for _, member := range memberships {
+		cohort, ok := byID[member.CohortID]
+		if !ok {
+			continue
+		}
+		viewCohortAs(cohort, member.Role)
+		cohorts = append(cohorts, cohort)
+	}
+	return cohorts, nil
+}
+
+// ListMembers returns the member list of a cohort, in order of joining
+func (cs *CohortService) ListMembers(userID, cohortID string) ([]*models.CohortMember, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if _, err := cs.membership(ctx, userID, cohortID); err != nil {
+		return nil, err
+	}
+
+	cursor, err := cs.members.Find(ctx, bson.M{"cohort_id": cohortID}, options.Find().SetSort(bson.M{"joined_at": 1}))
+	if err != nil {
+		return nil, err
+	}
+
+	members := []*models.CohortMember{}
+	if err := cursor.All(ctx, &members); err != nil {
+		return nil, err
+	}
+	return members, nil
+}
+
+// LeaveCohort removes the user from a cohort. Their copy of the plan stays theirs.
+func (cs *CohortService) LeaveCohort(userID, cohortID string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	member, err := cs.membership(ctx, userID, cohortID)
+	if err != nil {
+		return err
+	}
+	if member.Role == models.CohortRoleOrganizer {
+		return ErrOrganizerCannotLeave
+	}
+
+	result, err := cs.members.DeleteOne(ctx, bson.M{"_id": messageObjectID(member.ID)})
+	if err != nil {
+		return err
+	}
+	if result.DeletedCount == 0 {
+		return ErrCohortNotFound
+	}
+
+	_, err = cs.cohorts.UpdateOne(ctx, bson.M{"_id": messageObjectID(cohortID)}, bson.M{"$inc": bson.M{"member_count": -1}})
+	return err
+}
+
+// DeleteCohort ends a cohort the user organizes. Members keep their copies of the plan.
+func (cs *CohortService) DeleteCohort(userID, cohortID string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if _, err := cs.organizerCohort(ctx, userID, cohortID); err != nil {
+		return err
+	}
+
+	if _, err := cs.cohorts.DeleteOne(ctx, bson.M{"_id": messageObjectID(cohortID)}); err != nil {
+		return err
+	}
+	if _, err := cs.members.DeleteMany(ctx, bson.M{"cohort_id": cohortID}); err != nil {
+		return err
+	}
+	_, err := cs.announcements.DeleteMany(ctx, bson.M{"cohort_id": cohortID})
+	return err
+}
+
+// ResetJoinCode replaces the join code of a cohort the user organizes. The old code stops working.
+func (cs *CohortService) ResetJoinCode(userID, cohortID string) (*models.Cohort, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	cohort, err := cs.organizerCohort(ctx, userID, cohortID)
+	if err != nil {
+		return nil, err
+	}
+
+	code, err := newJoinCode()
+	if err != nil {
+		return nil, err
+	}
+	if _, err := cs.cohorts.UpdateOne(ctx, bson.M{"_id": messageObjectID(cohortID)},
+		bson.M{"$set": bson.M{"join_code": code}}); err != nil {
+		return nil, err
+	}
+
+	cohort.JoinCode = code
+	return cohort, nil
+}
+
+// PostAnnouncement posts a message to a cohort the user organizes
+func (cs *CohortService) PostAnnouncement(userID, cohortID, text string) (*models.CohortAnnouncement, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if _, err := cs.organizerCohort(ctx, userID, cohortID); err != nil {
+		return nil, err
+	}
+
+	objID := primitive.NewObjectID()
+	announcement := &models.CohortAnnouncement{
+		ID:        objID.Hex(),
+		CohortID:  cohortID,
+		Text:      strings.TrimSpace(text),
+		CreatedAt: time.Now(),
+	}
+	_, err := cs.announcements.InsertOne(ctx, bson.M{
+		"_id":        objID,
+		"cohort_id":  announcement.CohortID,
+		"text":       announcement.Text,
+		"created_at": announcement.CreatedAt,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return announcement, nil
+}
+
+// ListAnnouncements returns the announcements of a cohort the user is a member of, newest first
+func (cs *CohortService) ListAnnouncements(userID, cohortID string) ([]*models.CohortAnnouncement, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if _, err := cs.membership(ctx, userID, cohortID); err != nil {
+		return nil, err
+	}
+
+	cursor, err := cs.announcements.Find(ctx, bson.M{"cohort_id": cohortID}, options.Find().SetSort(bson.M{"created_at": -1}))
+	if err != nil {
+		return nil, err
+	}
+
+	announcements := []*models.CohortAnnouncement{}
+	if err := cursor.All(ctx, &announcements); err != nil {
+		return nil, err
+	}
+	return announcements, nil
+}
+
+// DeleteAnnouncement removes an announcement from a cohort the user organizes
+func (cs *CohortService) DeleteAnnouncement(userID, cohortID, announcementID string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if _, err := cs.organizerCohort(ctx, userID, cohortID); err != nil {
+		return err
+	}
+
+	objID, err := primitive.ObjectIDFromHex(announcementID)
+	if err != nil {
+		return ErrCohortAnnouncementNotFound
+	}
+	result, err := cs.announcements.DeleteOne(ctx, bson.M{"_id": objID, "cohort_id": cohortID})
+	if err != nil {
+		return err
+	}
+	if result.DeletedCount == 0 {
+		return ErrCohortAnnouncementNotFound
+	}
+	return nil
+}
+
+// Progress returns how the members of a cohort are doing day by day. Only shares of
+// members are reported, never who completed what.
+func (cs *CohortService) Progress(userID, cohortID string, now time.Time) (*models.CohortProgress, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	cohort, _, err := cs.findCohort(ctx, userID, cohortID)
+	if err != nil {
+		return nil, err
+	}
+
+	cursor, err := cs.members.Find(ctx, bson.M{"cohort_id": cohortID}, options.Find().SetProjection(bson.M{"plan_id": 1}))
+	if err != nil {
+		return nil, err
+	}
+	var members []*models.CohortMember
+	if err := cursor.All(ctx, &members); err != nil {
+		return nil, err
+	}
+
+	planIDs := make([]string, len(members))
+	for i, member := range members {
+		planIDs[i] = member.PlanID
+	}
+
+	// Completed tasks of each member's plan, per plan day
+	cursor, err = cs.records.Aggregate(ctx, practicedDaysPipeline(bson.M{"plan_id": bson.M{"$in": planIDs}}))
+	if err != nil {
+		return nil, err
+	}
+	var practiced []practicedDay
+	if err := cursor.All(ctx, &practiced); err != nil {
+		return nil, err
+	}
+
+	return cohortProgress(cohort, len(members), practiced, now, cs.plans.location), nil
+}
+
+// cohortProgress computes the share of members who practiced on each plan day that has started
+func cohortProgress(cohort *models.Cohort, members int, practiced []practicedDay, now time.Time, fallback *time.Location) *models.CohortProgress {
+	plan := cohortPlan(cohort)
+	progress := &models.CohortProgress{CohortID: cohort.ID, Members: members, Days: []models.CohortDayProgress{}}
+
+	today := PlanDayAt(plan, now, fallback)
+	progress.Today = today.Day
+	if today.Status == PlanDayFinished {
+		progress.Today = cohort.Days
+	}
+
+	// Required tasks of each day; days without any count every task
+	required := make(map[int][]string)
+	all := make(map[int][]string)
+	for _, task := range cohort.Tasks {
+		all[task.Day] = append(all[task.Day], task.ID)
+		if !task.Optional {
+			required[task.Day] = append(required[task.Day], task.ID)
+		}
+	}
+
+	completed := make(map[int]int)
+	practicedCount := make(map[int]int)
+	for _, p := range practiced {
+		done := make(map[string]bool, len(p.Tasks))
+		for _, id := range p.Tasks {
+			done[id] = true
+		}
+
+		practicedAny, allRequired := false, true
+		for _, id := range all[p.Day] {
+			practicedAny = practicedAny || done[id]
+		}
+		tasks := required[p.Day]
+		if len(tasks) == 0 {
+			tasks = all[p.Day]
+		}
+		for _, id := range tasks {
+			allRequired = allRequired && done[id]
+		}
+
+		if practicedAny {
+			practicedCount[p.Day]++
+		}
+		if practicedAny && allRequired {
+			completed[p.Day]++
+		}
+	}
+
+	dates := PlanDates(plan, fallback)
+	for day := 1; day <= progress.Today; day++ {
+		entry := models.CohortDayProgress{Day: day}
+		if date, ok := dates[day]; ok {
+			entry.Date = date.Format(planDateLayout)
+		}
+		if members > 0 {
+			entry.CompletionRate = float64(completed[day]) / float64(members)
+			entry.PracticeRate = float64(practicedCount[day]) / float64(members)
+		}
+		progress.Days = append(progress.Days, entry)
+	}
+	return progress
+}
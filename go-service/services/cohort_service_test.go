@@ -0,0 +1,62 @@
+package services
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"neuro-guide-go-service/models"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewJoinCode(t *testing.T) {
+	code, err := newJoinCode()
+	assert.NoError(t, err)
+	assert.Len(t, code, joinCodeLength)
+	for _, r := range code {
+		assert.True(t, strings.ContainsRune(joinCodeAlphabet, r), string(r))
+	}
+}
+
+func TestCohortProgress(t *testing.T) {
+	loc := time.FixedZone("CST", 8*3600)
+	cohort := &models.Cohort{
+		ID:        "c1",
+		Days:      3,
+		StartDate: "2024-05-06",
+		Tasks: []models.PlanTask{
+			{ID: "d1-breath", Day: 1},
+			{ID: "d1-walk", Day: 1, Optional: true},
+			{ID: "d2-scan", Day: 2},
+			{ID: "d3-sit", Day: 3},
+		},
+	}
+	practiced := []practicedDay{
+		{PlanID: "p1", Day: 1, Tasks: []string{"d1-breath", "d1-walk"}},
+		{PlanID: "p2", Day: 1, Tasks: []string{"d1-walk"}}, // optional task only
+		{PlanID: "p3", Day: 1, Tasks: []string{"d1-breath"}},
+		{PlanID: "p1", Day: 2, Tasks: []string{"d2-scan"}},
+		{PlanID: "p1", Day: 2, Tasks: []string{"unknown"}},
+	}
+
+	progress := cohortProgress(cohort, 4, practiced, time.Date(2024, 5, 7, 9, 0, 0, 0, loc), loc)
+	assert.Equal(t, "c1", progress.CohortID)
+	assert.Equal(t, 4, progress.Members)
+	assert.Equal(t, 2, progress.Today)
+	assert.Equal(t, []models.CohortDayProgress{
+		{Day: 1, Date: "2024-05-06", CompletionRate: 0.5, PracticeRate: 0.75},
+		{Day: 2, Date: "2024-05-07", CompletionRate: 0.25, PracticeRate: 0.25},
+	}, progress.Days)
+
+	// Before the start there is nothing to report
+	progress = cohortProgress(cohort, 4, nil, time.Date(2024, 5, 5, 9, 0, 0, 0, loc), loc)
+	assert.Equal(t, 0, progress.Today)
+	assert.Empty(t, progress.Days)
+
+	// After the end every day is reported
+	progress = cohortProgress(cohort, 0, nil, time.Date(2024, 6, 1, 9, 0, 0, 0, loc), loc)
+	assert.Equal(t, 3, progress.Today)
+	assert.Len(t, progress.Days, 3)
+	assert.Zero(t, progress.Days[2].CompletionRate)
+}
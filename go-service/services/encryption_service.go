@@ -0,0 +1,662 @@
+package services
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"neuro-guide-go-service/config"
+	"neuro-guide-go-service/database"
+	"neuro-guide-go-service/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// encryptedFieldPrefix marks a field value produced by EncryptField.
+// The full format is "enc:v1:<data key version>:<base64(nonce|ciphertext)>".
+const encryptedFieldPrefix = "enc:v1:"
+
+const (
+	dataKeyStatusActive  = "active"
+	dataKeyStatusRetired = "retired"
+)
+
+// ErrEncryptionDisabled is returned when an encrypted value is read but no master key is configured
+var ErrEncryptionDisabled = errors.New("field encryption is not configured")
+
+// encryptedCollection describes the document fields of a collection that hold per-user encrypted content
+type encryptedCollection struct {
+	Collection string
+	Fields     []string
+}
+
+// encryptedCollections lists every field the re-encryption job has to maintain.
+// Documents record the data key version of their fields in "key_version".
+var encryptedCollections = []encryptedCollection{
+	{Collection: "chat_messages", Fields: []string{"message", "parts"}},
+	{Collection: "practice_records", Fields: []string{"reflection"}},
+	{Collection: "conversation_summaries", Fields: []string{"title", "summary"}},
+	{Collection: "audio_files", Fields: []string{"transcript"}},
+	{Collection: "notebook_entries", Fields: []string{"content", "note", "parts"}},
+	{Collection: "plan_previews", Fields: []string{"goal"}},
+}
+
+// fieldEncryption is the process-wide encryption service, nil when encryption is disabled
+var fieldEncryption *EncryptionService
+
+// EncryptionService implements envelope encryption for sensitive fields.
+// Every user gets a random AES-256 data key which is stored wrapped by a versioned master key.
+type EncryptionService struct {
+	dataKeys             *mongo.Collection
+	masterKeys           map[int][]byte
+	currentMasterVersion int
+
+	mu    sync.RWMutex
+	cache map[string][]byte // user_id:version -> unwrapped data key
+}
+
+// InitEncryption loads the master keys from config and enables field encryption.
+// Encryption stays disabled when neither a master key nor a key file is configured.
+func InitEncryption(cfg *config.Config) error {
+	masterKeys, current, err := loadMasterKeys(cfg)
+	if err != nil {
+		return err
+	}
+	if len(masterKeys) == 0 {
+		log.Println("Field encryption disabled: no master key configured")
+		return nil
+	}
+
+	es := &EncryptionService{
+		dataKeys:             database.Database.Collection("user_data_keys"),
+		masterKeys:           masterKeys,
+		currentMasterVersion: current,
+		cache:                make(map[string][]byte),
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	_, err = es.dataKeys.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "user_id", Value: 1}, {Key: "version", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create data key index: %w", err)
+	}
+
+	fieldEncryption = es
+	log.Printf("Field encryption enabled with master key version %d", current)
+	return nil
+}
+
+// GetEncryptionService returns the active encryption service, or nil when encryption is disabled
+func GetEncryptionService() *EncryptionService {
+	return fieldEncryption
+}
+
+// loadMasterKeys collects master keys from the key file and the environment
+func loadMasterKeys(cfg *config.Config) (map[int][]byte, int, error) {
+	keys := make(map[int][]byte)
+
+	if cfg.EncryptionKeyFile != "" {
+		data, err := os.ReadFile(cfg.EncryptionKeyFile)
+		if err != nil {
+			return nil, 0, fmt.Errorf("failed to read key file: %w", err)
+		}
+		fileKeys, err := parseMasterKeyFile(string(data))
+		if err != nil {
+			return nil, 0, err
+		}
+		for version, key := range fileKeys {
+			keys[version] = key
+		}
+	}
+
+	if cfg.EncryptionMasterKey != "" {
+		version := cfg.EncryptionMasterKeyVersion
+		if version <= 0 {
+			version = 1
+		}
+		key, err := decodeMasterKey(cfg.EncryptionMasterKey)
+		if err != nil {
+			return nil, 0, err
+		}
+		keys[version] = key
+	}
+
+	current := 0
+	for version := range keys {
+		if version > current {
+			current = version
+		}
+	}
+
+	return keys, current, nil
+}
+
+// parseMasterKeyFile parses a key file with one "<version>:<base64 key>" entry per line.
+// Blank lines and lines starting with '#' are ignored.
+func parseMasterKeyFile(content string) (map[int][]byte, error) {
+	keys := make(map[int][]byte)
+
+	for i, line := range strings.Split(content, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		parts := strings.SplitN(line, ":", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("key file line %d: expected <version>:<key>", i+1)
+		}
+
+		version, err := strconv.Atoi(strings.TrimSpace(parts[0]))
+		if err != nil || version <= 0 {
+			return nil, fmt.Errorf("key file line %d: invalid version %q", i+1, parts[0])
+		}
+		if _, exists := keys[version]; exists {
+			return nil, fmt.Errorf("key file line %d: duplicate version %d", i+1, version)
+		}
+
+		key, err := decodeMasterKey(strings.TrimSpace(parts[1]))
+		if err != nil {
+			return nil, fmt.Errorf("key file line %d: %w", i+1, err)
+		}
+		keys[version] = key
+	}
+
+	return keys, nil
+}
+
+// decodeMasterKey decodes a base64 encoded AES-256 key
+func decodeMasterKey(encoded string) ([]byte, error) {
+	key, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("invalid master key encoding: %w", err)
+	}
+	if len(key) != 32 {
+		return nil, fmt.Errorf("master key must be 32 bytes, got %d", len(key))
+	}
+	return key, nil
+}
+
+// sealWithKey encrypts plaintext with AES-256-GCM, binding it to the additional data
+func sealWithKey(key, plaintext, additionalData []byte) ([]byte, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+
+	return gcm.Seal(nonce, nonce, plaintext, additionalData), nil
+}
+
+// openWithKey decrypts data produced by sealWithKey
+func openWithKey(key, sealed, additionalData []byte) ([]byte, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(sealed) < gcm.NonceSize() {
+		return nil, errors.New("ciphertext too short")
+	}
+
+	nonce, ciphertext := sealed[:gcm.NonceSize()], sealed[gcm.NonceSize():]
+	return gcm.Open(nil, nonce, ciphertext, additionalData)
+}
+
+// IsEncryptedValue reports whether a stored field value is encrypted
+func IsEncryptedValue(value string) bool {
+	return strings.HasPrefix(value, encryptedFieldPrefix)
+}
+
+// parseEncryptedValue splits an encrypted field value into data key version and sealed bytes
+func parseEncryptedValue(value string) (int, []byte, error) {
+	parts := strings.SplitN(strings.TrimPrefix(value, encryptedFieldPrefix), ":", 2)
+	if len(parts) != 2 {
+		return 0, nil, errors.New("malformed encrypted value")
+	}
+
+	version, err := strconv.Atoi(parts[0])
+	if err != nil {
+		return 0, nil, fmt.Errorf("malformed key version: %w", err)
+	}
+
+	sealed, err := base64.StdEncoding.DecodeString(parts[1])
+	if err != nil {
+		return 0, nil, fmt.Errorf("malformed ciphertext: %w", err)
+	}
+
+	return version, sealed, nil
+}
+
+// formatEncryptedValue builds the stored representation of an encrypted field
+func formatEncryptedValue(version int, sealed []byte) string {
+	return fmt.Sprintf("%s%d:%s", encryptedFieldPrefix, version, base64.StdEncoding.EncodeToString(sealed))
+}
+
+// EncryptField encrypts a field value with the user's active data key.
+// It returns the stored representation and the data key version used.
+func (es *EncryptionService) EncryptField(userID, plaintext string) (string, int, error) {
+	encrypted, version, err := es.EncryptFields(userID, plaintext)
+	if err != nil {
+		return "", 0, err
+	}
+	return encrypted[0], version, nil
+}
+
+// EncryptFields encrypts the field values of one document with the same data key, so a
+// single key_version covers all of them. Empty values stay empty; the version is 0 when
+// every value is empty.
+func (es *EncryptionService) EncryptFields(userID string, plaintexts ...string) ([]string, int, error) {
+	encrypted := make([]string, len(plaintexts))
+	version := 0
+	var key []byte
+
+	for i, plaintext := range plaintexts {
+		if plaintext == "" {
+			continue
+		}
+		if key == nil {
+			var err error
+			if version, key, err = es.activeDataKey(userID); err != nil {
+				return nil, 0, err
+			}
+		}
+
+		sealed, err := sealWithKey(key, []byte(plaintext), []byte(userID))
+		if err != nil {
+			return nil, 0, fmt.Errorf("failed to encrypt field: %w", err)
+		}
+		encrypted[i] = formatEncryptedValue(version, sealed)
+	}
+
+	return encrypted, version, nil
+}
+
+// DecryptField decrypts a stored field value. Plaintext values written before
+// encryption was enabled are returned unchanged.
+func (es *EncryptionService) DecryptField(userID, value string) (string, error) {
+	if !IsEncryptedValue(value) {
+		return value, nil
+	}
+
+	version, sealed, err := parseEncryptedValue(value)
+	if err != nil {
+		return "", err
+	}
+
+	key, err := es.dataKey(userID, version)
+	if err != nil {
+		return "", err
+	}
+
+	plaintext, err := openWithKey(key, sealed, []byte(userID))
+	if err != nil {
+		return "", fmt.Errorf("failed to decrypt field: %w", err)
+	}
+
+	return string(plaintext), nil
+}
+
+// activeDataKey returns the user's active data key, creating one on first use
+func (es *EncryptionService) activeDataKey(userID string) (int, []byte, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var doc models.UserDataKey
+	err := es.dataKeys.FindOne(ctx, bson.M{"user_id": userID, "status": dataKeyStatusActive}).Decode(&doc)
+	if err == mongo.ErrNoDocuments {
+		return es.createDataKey(ctx, userID)
+	}
+	if err != nil {
+		return 0, nil, err
+	}
+
+	key, err := es.unwrapDataKey(&doc)
+	if err != nil {
+		return 0, nil, err
+	}
+	return doc.Version, key, nil
+}
+
+// dataKey returns a specific version of the user's data key
+func (es *EncryptionService) dataKey(userID string, version int) ([]byte, error) {
+	cacheKey := fmt.Sprintf("%s:%d", userID, version)
+
+	es.mu.RLock()
+	key, ok := es.cache[cacheKey]
+	es.mu.RUnlock()
+	if ok {
+		return key, nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var doc models.UserDataKey
+	if err := es.dataKeys.FindOne(ctx, bson.M{"user_id": userID, "version": version}).Decode(&doc); err != nil {
+		return nil, fmt.Errorf("data key %d for user not found: %w", version, err)
+	}
+
+	return es.unwrapDataKey(&doc)
+}
+
+// createDataKey generates a new active data key for a user wrapped by the current master key
+func (es *EncryptionService) createDataKey(ctx context.Context, userID string) (int, []byte, error) {
+	version := 1
+	var latest models.UserDataKey
+	opts := options.FindOne().SetSort(bson.M{"version": -1})
+	err := es.dataKeys.FindOne(ctx, bson.M{"user_id": userID}, opts).Decode(&latest)
+	if err == nil {
+		version = latest.Version + 1
+	} else if err != mongo.ErrNoDocuments {
+		return 0, nil, err
+	}
+
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return 0, nil, err
+	}
+
+	wrapped, err := sealWithKey(es.masterKeys[es.currentMasterVersion], key, []byte(userID))
+	if err != nil {
+		return 0, nil, fmt.Errorf("failed to wrap data key: %w", err)
+	}
+
+	_, err = es.dataKeys.InsertOne(ctx, bson.M{
+		"_id":                primitive.NewObjectID(),
+		"user_id":            userID,
+		"version":            version,
+		"master_key_version": es.currentMasterVersion,
+		"wrapped_key":        base64.StdEncoding.EncodeToString(wrapped),
+		"status":             dataKeyStatusActive,
+		"created_at":         time.Now(),
+	})
+	if mongo.IsDuplicateKeyError(err) {
+		// Another request created the key concurrently
+		return es.activeDataKey(userID)
+	}
+	if err != nil {
+		return 0, nil, err
+	}
+
+	es.cacheDataKey(userID, version, key)
+	return version, key, nil
+}
+
+// unwrapDataKey decrypts a stored data key with the master key it was wrapped by
+func (es *EncryptionService) unwrapDataKey(doc *models.UserDataKey) ([]byte, error) {
+	cacheKey := fmt.Sprintf("%s:%d", doc.UserID, doc.Version)
+
+	es.mu.RLock()
+	key, ok := es.cache[cacheKey]
+	es.mu.RUnlock()
+	if ok {
+		return key, nil
+	}
+
+	masterKey, ok := es.masterKeys[doc.MasterKeyVersion]
+	if !ok {
+		return nil, fmt.Errorf("master key version %d is not available", doc.MasterKeyVersion)
+	}
+
+	wrapped, err := base64.StdEncoding.DecodeString(doc.WrappedKey)
+	if err != nil {
+		return nil, fmt.Errorf("malformed wrapped key: %w", err)
+	}
+
+	key, err = openWithKey(masterKey, wrapped, []byte(doc.UserID))
+	if err != nil {
+		return nil, fmt.Errorf("failed to unwrap data key: %w", err)
+	}
+
+	es.cacheDataKey(doc.UserID, doc.Version, key)
+	return key, nil
+}
+
+func (es *EncryptionService) cacheDataKey(userID string, version int, key []byte) {
+	es.mu.Lock()
+	defer es.mu.Unlock()
+	es.cache[fmt.Sprintf("%s:%d", userID, version)] = key
+}
+
+// StartKeyRotationJob runs RotateKeys periodically in the background
+func (es *EncryptionService) StartKeyRotationJob(interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			if err := es.RotateKeys(context.Background()); err != nil {
+				log.Printf("Key rotation failed: %v", err)
+			}
+			<-ticker.C
+		}
+	}()
+}
+
+// RotateKeys retires data keys wrapped by an old master key and re-encrypts every
+// field still using a retired key or stored as plaintext. A writer that read a key just
+// before it was retired may still store data under it, so retired keys are only deleted
+// on a later run, once no document references them any more.
+func (es *EncryptionService) RotateKeys(ctx context.Context) error {
+	started := time.Now()
+
+	// Retire data keys that are not wrapped by the current master key
+	cursor, err := es.dataKeys.Find(ctx, bson.M{
+		"status":             dataKeyStatusActive,
+		"master_key_version": bson.M{"$ne": es.currentMasterVersion},
+	})
+	if err != nil {
+		return err
+	}
+	var stale []models.UserDataKey
+	if err := cursor.All(ctx, &stale); err != nil {
+		return err
+	}
+
+	for _, doc := range stale {
+		if _, err := es.dataKeys.UpdateOne(ctx,
+			bson.M{"user_id": doc.UserID, "version": doc.Version},
+			bson.M{"$set": bson.M{"status": dataKeyStatusRetired, "retired_at": time.Now()}},
+		); err != nil {
+			return err
+		}
+	}
+
+	// Re-encrypt fields that still use retired keys
+	cursor, err = es.dataKeys.Find(ctx, bson.M{"status": dataKeyStatusRetired})
+	if err != nil {
+		return err
+	}
+	var retired []models.UserDataKey
+	if err := cursor.All(ctx, &retired); err != nil {
+		return err
+	}
+
+	for _, doc := range retired {
+		filter := bson.M{"user_id": doc.UserID, "key_version": doc.Version}
+		if err := es.reencryptDocuments(ctx, filter); err != nil {
+			return err
+		}
+		if doc.RetiredAt == nil || !doc.RetiredAt.Before(started) {
+			continue
+		}
+
+		referenced, err := countEncryptedDocuments(ctx, filter)
+		if err != nil {
+			return err
+		}
+		if referenced > 0 {
+			continue
+		}
+		if _, err := es.dataKeys.DeleteOne(ctx, bson.M{"user_id": doc.UserID, "version": doc.Version}); err != nil {
+			return err
+		}
+
+		es.mu.Lock()
+		delete(es.cache, fmt.Sprintf("%s:%d", doc.UserID, doc.Version))
+		es.mu.Unlock()
+	}
+
+	// Encrypt legacy plaintext fields
+	return es.reencryptDocuments(ctx, bson.M{"key_version": bson.M{"$exists": false}})
+}
+
+// countEncryptedDocuments counts the documents matching filter across all encrypted collections
+func countEncryptedDocuments(ctx context.Context, filter bson.M) (int64, error) {
+	var total int64
+	for _, ec := range encryptedCollections {
+		count, err := database.Database.Collection(ec.Collection).CountDocuments(ctx, filter)
+		if err != nil {
+			return 0, err
+		}
+		total += count
+	}
+	return total, nil
+}
+
+// reencryptDocuments rewrites every encrypted field of the matching documents with the active data key
+func (es *EncryptionService) reencryptDocuments(ctx context.Context, filter bson.M) error {
+	for _, ec := range encryptedCollections {
+		collection := database.Database.Collection(ec.Collection)
+
+		cursor, err := collection.Find(ctx, filter)
+		if err != nil {
+			return err
+		}
+
+		for cursor.Next(ctx) {
+			var doc bson.M
+			if err := cursor.Decode(&doc); err != nil {
+				cursor.Close(ctx)
+				return err
+			}
+			if err := es.reencryptDocument(ctx, collection, ec.Fields, doc); err != nil {
+				cursor.Close(ctx)
+				return err
+			}
+		}
+
+		err = cursor.Err()
+		cursor.Close(ctx)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// reencryptDocument re-encrypts the given fields of one document with the user's active data key
+func (es *EncryptionService) reencryptDocument(ctx context.Context, collection *mongo.Collection, fields []string, doc bson.M) error {
+	userID, _ := doc["user_id"].(string)
+	if userID == "" {
+		return nil
+	}
+
+	var present, plaintexts []string
+	for _, field := range fields {
+		value, ok := doc[field].(string)
+		if !ok {
+			// Optional fields are not present on every document
+			continue
+		}
+
+		plaintext, err := es.DecryptField(userID, value)
+		if err != nil {
+			return err
+		}
+		present = append(present, field)
+		plaintexts = append(plaintexts, plaintext)
+	}
+
+	encrypted, keyVersion, err := es.EncryptFields(userID, plaintexts...)
+	if err != nil {
+		return err
+	}
+	set := bson.M{}
+	for i, field := range present {
+		set[field] = encrypted[i]
+	}
+	set["key_version"] = keyVersion
+
+	// A document changed since it was read keeps its new values; the next run picks it up
+	result, err := collection.UpdateOne(ctx, reencryptFilter(fields, doc), bson.M{"$set": set})
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		log.Printf("Skipping re-encryption of %s %v, it changed while being re-encrypted", collection.Name(), doc["_id"])
+	}
+	return nil
+}
+
+// reencryptFilter matches a document only while its encrypted fields and key version
+// still hold the values they had when it was read
+func reencryptFilter(fields []string, doc bson.M) bson.M {
+	filter := bson.M{"_id": doc["_id"]}
+	for _, field := range append([]string{"key_version"}, fields...) {
+		if value, ok := doc[field]; ok {
+			filter[field] = value
+		} else {
+			filter[field] = bson.M{"$exists": false}
+		}
+	}
+	return filter
+}
+
+// encryptField encrypts a value when field encryption is enabled
+func encryptField(userID, plaintext string) (string, int, error) {
+	if fieldEncryption == nil {
+		return plaintext, 0, nil
+	}
+	return fieldEncryption.EncryptField(userID, plaintext)
+}
+
+// encryptFields encrypts the values of one document when field encryption is enabled
+func encryptFields(userID string, plaintexts ...string) ([]string, int, error) {
+	if fieldEncryption == nil {
+		return append([]string(nil), plaintexts...), 0, nil
+	}
+	return fieldEncryption.EncryptFields(userID, plaintexts...)
+}
+
+// decryptField decrypts a value when field encryption is enabled
+func decryptField(userID, value string) (string, error) {
+	if fieldEncryption == nil {
+		if IsEncryptedValue(value) {
+			return "", ErrEncryptionDisabled
+		}
+		return value, nil
+	}
+	return fieldEncryption.DecryptField(userID, value)
+}
@@ -0,0 +1,109 @@
+package services
+
+import (
+	"bytes"
+	"encoding/base64"
+	"testing"
+
+	"neuro-guide-go-service/config"
+
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func testMasterKey(b byte) string {
+	return base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{b}, 32))
+}
+
+func TestSealAndOpenWithKey(t *testing.T) {
+	key := bytes.Repeat([]byte{1}, 32)
+
+	sealed, err := sealWithKey(key, []byte("今天有点焦虑"), []byte("user-1"))
+	assert.NoError(t, err)
+
+	plaintext, err := openWithKey(key, sealed, []byte("user-1"))
+	assert.NoError(t, err)
+	assert.Equal(t, "今天有点焦虑", string(plaintext))
+
+	// Ciphertext is bound to the user it was encrypted for
+	_, err = openWithKey(key, sealed, []byte("user-2"))
+	assert.Error(t, err)
+
+	// Tampering is detected
+	sealed[len(sealed)-1] ^= 0xff
+	_, err = openWithKey(key, sealed, []byte("user-1"))
+	assert.Error(t, err)
+}
+
+func TestEncryptedValueFormat(t *testing.T) {
+	value := formatEncryptedValue(3, []byte("sealed"))
+	assert.True(t, IsEncryptedValue(value))
+	assert.False(t, IsEncryptedValue("plain text"))
+
+	version, sealed, err := parseEncryptedValue(value)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, version)
+	assert.Equal(t, []byte("sealed"), sealed)
+
+	_, _, err = parseEncryptedValue("enc:v1:broken")
+	assert.Error(t, err)
+}
+
+func TestParseMasterKeyFile(t *testing.T) {
+	content := "# master keys\n1:" + testMasterKey(1) + "\n\n2:" + testMasterKey(2) + "\n"
+
+	keys, err := parseMasterKeyFile(content)
+	assert.NoError(t, err)
+	assert.Len(t, keys, 2)
+	assert.Equal(t, bytes.Repeat([]byte{2}, 32), keys[2])
+
+	_, err = parseMasterKeyFile("1:" + testMasterKey(1) + "\n1:" + testMasterKey(2))
+	assert.Error(t, err)
+
+	_, err = parseMasterKeyFile("1:" + base64.StdEncoding.EncodeToString([]byte("short")))
+	assert.Error(t, err)
+}
+
+func TestLoadMasterKeysPicksHighestVersion(t *testing.T) {
+	cfg := &config.Config{
+		EncryptionMasterKey:        testMasterKey(7),
+		EncryptionMasterKeyVersion: 4,
+	}
+
+	keys, current, err := loadMasterKeys(cfg)
+	assert.NoError(t, err)
+	assert.Equal(t, 4, current)
+	assert.Len(t, keys, 1)
+
+	keys, current, err = loadMasterKeys(&config.Config{})
+	assert.NoError(t, err)
+	assert.Equal(t, 0, current)
+	assert.Empty(t, keys)
+}
+
+func TestDecryptFieldWithoutEncryption(t *testing.T) {
+	value, err := decryptField("user-1", "plain text")
+	assert.NoError(t, err)
+	assert.Equal(t, "plain text", value)
+
+	_, err = decryptField("user-1", formatEncryptedValue(1, []byte("sealed")))
+	assert.ErrorIs(t, err, ErrEncryptionDisabled)
+}
+
+func TestReencryptFilterMatchesReadValues(t *testing.T) {
+	doc := bson.M{"_id": "id-1", "user_id": "user-1", "message": "enc:v1:abc", "key_version": 1}
+
+	assert.Equal(t, bson.M{
+		"_id":         "id-1",
+		"key_version": 1,
+		"message":     "enc:v1:abc",
+		"parts":       bson.M{"$exists": false},
+	}, reencryptFilter([]string{"message", "parts"}, doc))
+}
+
+func TestEncryptFieldsWithoutEncryption(t *testing.T) {
+	values, version, err := encryptFields("user-1", "content", "", "note")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"content", "", "note"}, values)
+	assert.Zero(t, version)
+}
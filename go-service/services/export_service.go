@@ -0,0 +1,193 @@
+package services
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"strings"
+	"time"
+
+	"neuro-guide-go-service/models"
+)
+
+// Supported chat export formats
+const (
+	ExportFormatMarkdown = "markdown"
+	ExportFormatHTML     = "html"
+	ExportFormatPDF      = "pdf"
+)
+
+// ChatExport holds the messages and metadata of an export
+type ChatExport struct {
+	Title      string
+	From       *time.Time
+	To         *time.Time
+	ExportedAt time.Time
+	Location   *time.Location
+	Messages   []*models.ChatMessage
+}
+
+// ExportedFile is a rendered export ready to be downloaded
+type ExportedFile struct {
+	Filename    string
+	ContentType string
+	Data        []byte
+}
+
+// roleLabel returns the display name of a message role
+func roleLabel(role string) string {
+	switch role {
+	case "user":
+		return "我"
+	case "assistant":
+		return "神经科学修行助手"
+	}
+	return role
+}
+
+func (e *ChatExport) formatTime(t time.Time) string {
+	return t.In(e.Location).Format("2006-01-02 15:04")
+}
+
+// rangeLabel describes the exported date range
+func (e *ChatExport) rangeLabel() string {
+	switch {
+	case e.From != nil && e.To != nil:
+		return fmt.Sprintf("%s 至 %s", e.From.In(e.Location).Format("2006-01-02"), e.To.In(e.Location).Format("2006-01-02"))
+	case e.From != nil:
+		return fmt.Sprintf("%s 起", e.From.In(e.Location).Format("2006-01-02"))
+	case e.To != nil:
+		return fmt.Sprintf("截至 %s", e.To.In(e.Location).Format("2006-01-02"))
+	}
+	return "全部对话"
+}
+
+// RenderChatExport renders an export in the requested format
+func RenderChatExport(export *ChatExport, format string) (*ExportedFile, error) {
+	if export.Location == nil {
+		export.Location = time.Local
+	}
+	base := fmt.Sprintf("chat-%s", export.ExportedAt.In(export.Location).Format("20060102-150405"))
+
+	switch format {
+	case ExportFormatMarkdown:
+		return &ExportedFile{
+			Filename:    base + ".md",
+			ContentType: "text/markdown; charset=utf-8",
+			Data:        []byte(RenderChatMarkdown(export)),
+		}, nil
+	case ExportFormatHTML:
+		data, err := RenderChatHTML(export)
+		if err != nil {
+			return nil, err
+		}
+		return &ExportedFile{Filename: base + ".html", ContentType: "text/html; charset=utf-8", Data: data}, nil
+	case ExportFormatPDF:
+		data, err := RenderChatPDF(export)
+		if err != nil {
+			return nil, err
+		}
+		return &ExportedFile{Filename: base + ".pdf", ContentType: "application/pdf", Data: data}, nil
+	}
+
+	return nil, fmt.Errorf("unsupported export format %q", format)
+}
+
+// RenderChatMarkdown renders the messages as a Markdown document
+func RenderChatMarkdown(export *ChatExport) string {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "# %s\n\n", export.Title)
+	fmt.Fprintf(&b, "- 范围：%s\n", export.rangeLabel())
+	fmt.Fprintf(&b, "- 导出时间：%s\n", export.formatTime(export.ExportedAt))
+	fmt.Fprintf(&b, "- 消息数：%d\n", len(export.Messages))
+
+	for _, msg := range export.Messages {
+		fmt.Fprintf(&b, "\n---\n\n### %s · %s\n\n", roleLabel(msg.Role), export.formatTime(msg.Timestamp))
+		b.WriteString(strings.TrimSpace(msg.Message))
+		b.WriteString("\n")
+	}
+
+	return b.String()
+}
+
+var chatHTMLTemplate = template.Must(template.New("chat").Funcs(template.FuncMap{
+	"role": roleLabel,
+}).Parse(`<!DOCTYPE html>
+<html lang="zh-CN">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>{{.Title}}</title>
+<style>
+body { font-family: -apple-system, "PingFang SC", "Noto Sans SC", "Microsoft YaHei", sans-serif; max-width: 760px; margin: 40px auto; padding: 0 16px; color: #222; line-height: 1.7; }
+h1 { font-size: 22px; margin-bottom: 4px; }
+.meta { color: #888; font-size: 13px; margin-bottom: 24px; }
+.message { border-radius: 8px; padding: 12px 16px; margin: 12px 0; }
+.message.user { background: #eef4ff; }
+.message.assistant { background: #f6f6f6; }
+.header { font-size: 13px; color: #666; margin-bottom: 6px; }
+.body { white-space: pre-wrap; word-break: break-word; }
+</style>
+</head>
+<body>
+<h1>{{.Title}}</h1>
+<div class="meta">范围：{{.Range}} · 导出时间：{{.ExportedAt}} · 共 {{len .Messages}} 条消息</div>
+{{range .Messages}}<div class="message {{.Role}}">
+<div class="header">{{role .Role}} · {{.Time}}</div>
+<div class="body">{{.Message}}</div>
+</div>
+{{end}}</body>
+</html>
+`))
+
+// RenderChatHTML renders the messages as a standalone HTML page
+func RenderChatHTML(export *ChatExport) ([]byte, error) {
+	type htmlMessage struct {
+		Role    string
+		Time    string
+		Message string
+	}
+
+	data := struct {
+		Title      string
+		Range      string
+		ExportedAt string
+		Messages   []htmlMessage
+	}{
+		Title:      export.Title,
+		Range:      export.rangeLabel(),
+		ExportedAt: export.formatTime(export.ExportedAt),
+	}
+	for _, msg := range export.Messages {
+		data.Messages = append(data.Messages, htmlMessage{
+			Role:    msg.Role,
+			Time:    export.formatTime(msg.Timestamp),
+			Message: strings.TrimSpace(msg.Message),
+		})
+	}
+
+	var buf bytes.Buffer
+	if err := chatHTMLTemplate.Execute(&buf, data); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// RenderChatPDF renders the messages as a PDF document
+func RenderChatPDF(export *ChatExport) ([]byte, error) {
+	doc := NewPDFDocument()
+
+	doc.AddText(export.Title, PDFTextStyle{Size: 18, Space: 4})
+	doc.AddText(fmt.Sprintf("范围：%s    导出时间：%s    共 %d 条消息",
+		export.rangeLabel(), export.formatTime(export.ExportedAt), len(export.Messages)),
+		PDFTextStyle{Size: 9, Gray: 0.5, Space: 12})
+
+	for _, msg := range export.Messages {
+		doc.AddText(fmt.Sprintf("%s · %s", roleLabel(msg.Role), export.formatTime(msg.Timestamp)),
+			PDFTextStyle{Size: 9, Gray: 0.4, Space: 2})
+		doc.AddText(strings.TrimSpace(msg.Message), PDFTextStyle{Size: 11, Space: 10})
+	}
+
+	return doc.Bytes()
+}
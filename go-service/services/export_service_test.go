@@ -0,0 +1,58 @@
+package services
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"neuro-guide-go-service/models"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestExport() *ChatExport {
+	loc := time.FixedZone("CST", 8*3600)
+	from := time.Date(2024, 3, 1, 0, 0, 0, 0, loc)
+	return &ChatExport{
+		Title:      "知行合一",
+		From:       &from,
+		ExportedAt: time.Date(2024, 3, 2, 9, 0, 0, 0, loc),
+		Location:   loc,
+		Messages: []*models.ChatMessage{
+			{Role: "user", Message: "什么是知行合一？", Timestamp: time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)},
+			{Role: "assistant", Message: "三大神经断层 <b>", Timestamp: time.Date(2024, 3, 1, 12, 1, 0, 0, time.UTC)},
+		},
+	}
+}
+
+func TestRenderChatMarkdown(t *testing.T) {
+	md := RenderChatMarkdown(newTestExport())
+
+	assert.True(t, strings.HasPrefix(md, "# 知行合一\n"))
+	assert.Contains(t, md, "2024-03-01 起")
+	assert.Contains(t, md, "### 我 · 2024-03-01 20:00")
+	assert.Contains(t, md, "### 神经科学修行助手 · 2024-03-01 20:01")
+	assert.Contains(t, md, "什么是知行合一？")
+}
+
+func TestRenderChatHTML(t *testing.T) {
+	html, err := RenderChatHTML(newTestExport())
+	assert.NoError(t, err)
+
+	assert.Contains(t, string(html), "<title>知行合一</title>")
+	assert.Contains(t, string(html), `class="message assistant"`)
+	assert.Contains(t, string(html), "三大神经断层 &lt;b&gt;")
+}
+
+func TestRenderChatExport(t *testing.T) {
+	file, err := RenderChatExport(newTestExport(), ExportFormatPDF)
+	assert.NoError(t, err)
+	assert.Equal(t, "chat-20240302-090000.pdf", file.Filename)
+	assert.Equal(t, "application/pdf", file.ContentType)
+	assert.True(t, bytes.HasPrefix(file.Data, []byte("%PDF-")))
+	assert.Contains(t, string(file.Data), "%%EOF")
+
+	_, err = RenderChatExport(newTestExport(), "docx")
+	assert.Error(t, err)
+}
@@ -0,0 +1,89 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ErrInvalidStorageKey is returned for keys that would escape the storage root
+var ErrInvalidStorageKey = errors.New("invalid storage key")
+
+// FileStorage stores uploaded files by key
+type FileStorage interface {
+	// Save writes the content under key and returns the number of bytes written
+	Save(key string, r io.Reader) (int64, error)
+	// Open returns a reader for the file stored under key
+	Open(key string) (io.ReadCloser, error)
+	// Delete removes the file stored under key
+	Delete(key string) error
+}
+
+// LocalFileStorage stores files in a directory on the local disk
+type LocalFileStorage struct {
+	root string
+}
+
+// NewLocalFileStorage creates a LocalFileStorage rooted at dir
+func NewLocalFileStorage(dir string) *LocalFileStorage {
+	return &LocalFileStorage{root: dir}
+}
+
+// path maps a key to a file path below the storage root
+func (s *LocalFileStorage) path(key string) (string, error) {
+	clean := filepath.Clean(filepath.FromSlash(key))
+	if clean == "." || filepath.IsAbs(clean) || clean == ".." || strings.HasPrefix(clean, ".."+string(filepath.Separator)) {
+		return "", ErrInvalidStorageKey
+	}
+	return filepath.Join(s.root, clean), nil
+}
+
+// Save writes the content under key
+func (s *LocalFileStorage) Save(key string, r io.Reader) (int64, error) {
+	path, err := s.path(key)
+	if err != nil {
+		return 0, err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o750); err != nil {
+		return 0, fmt.Errorf("failed to create storage directory: %w", err)
+	}
+
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o640)
+	if err != nil {
+		return 0, err
+	}
+
+	n, err := io.Copy(f, r)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(path)
+		return 0, err
+	}
+	return n, nil
+}
+
+// Open returns a reader for the file stored under key
+func (s *LocalFileStorage) Open(key string) (io.ReadCloser, error) {
+	path, err := s.path(key)
+	if err != nil {
+		return nil, err
+	}
+	return os.Open(path)
+}
+
+// Delete removes the file stored under key
+func (s *LocalFileStorage) Delete(key string) error {
+	path, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
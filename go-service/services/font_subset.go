@@ -0,0 +1,213 @@
+package services
+
+import (
+	"encoding/binary"
+	"errors"
+	"sort"
+)
+
+// Tables kept in a subset font; a CIDFontType2 addresses glyphs by id, so cmap, name,
+// post and the layout tables are not needed by PDF viewers
+var subsetFontTables = []string{"cvt ", "fpgm", "glyf", "head", "hhea", "hmtx", "loca", "maxp", "prep"}
+
+// Composite glyph flags
+const (
+	glyfArgsAreWords   = 0x0001
+	glyfHaveScale      = 0x0008
+	glyfMoreComponents = 0x0020
+	glyfHaveXYScale    = 0x0040
+	glyfHaveTwoByTwo   = 0x0080
+)
+
+var errNotTrueType = errors.New("font has no TrueType glyf outlines")
+
+// sfntTables maps table tags to their data
+type sfntTables map[string][]byte
+
+func readSFNTTables(data []byte) (sfntTables, error) {
+	if len(data) < 12 {
+		return nil, errors.New("font is too short")
+	}
+	numTables := int(binary.BigEndian.Uint16(data[4:]))
+	if len(data) < 12+16*numTables {
+		return nil, errors.New("font table directory is truncated")
+	}
+
+	tables := make(sfntTables, numTables)
+	for i := 0; i < numTables; i++ {
+		record := data[12+16*i:]
+		offset := int(binary.BigEndian.Uint32(record[8:]))
+		length := int(binary.BigEndian.Uint32(record[12:]))
+		if offset < 0 || length < 0 || offset+length > len(data) {
+			return nil, errors.New("font table is out of range")
+		}
+		tables[string(record[:4])] = data[offset : offset+length]
+	}
+	return tables, nil
+}
+
+// subsetTrueType returns a copy of a TrueType font whose glyf table only holds the outlines
+// of the given glyphs and the components they are built from. Glyph ids are unchanged, so
+// the subset can be referenced with an Identity CIDToGIDMap.
+func subsetTrueType(data []byte, glyphs []int) ([]byte, error) {
+	tables, err := readSFNTTables(data)
+	if err != nil {
+		return nil, err
+	}
+	head, maxp, loca, glyf := tables["head"], tables["maxp"], tables["loca"], tables["glyf"]
+	if glyf == nil || loca == nil {
+		return nil, errNotTrueType
+	}
+	if len(head) < 54 || len(maxp) < 6 {
+		return nil, errors.New("font head or maxp table is truncated")
+	}
+
+	numGlyphs := int(binary.BigEndian.Uint16(maxp[4:]))
+	longLoca := binary.BigEndian.Uint16(head[50:]) == 1
+	if (longLoca && len(loca) < 4*(numGlyphs+1)) || (!longLoca && len(loca) < 2*(numGlyphs+1)) {
+		return nil, errors.New("font loca table is truncated")
+	}
+	glyphData := func(gid int) []byte {
+		var start, end int
+		if longLoca {
+			start, end = int(binary.BigEndian.Uint32(loca[4*gid:])), int(binary.BigEndian.Uint32(loca[4*gid+4:]))
+		} else {
+			start, end = 2*int(binary.BigEndian.Uint16(loca[2*gid:])), 2*int(binary.BigEndian.Uint16(loca[2*gid+2:]))
+		}
+		if start >= end || end > len(glyf) {
+			return nil
+		}
+		return glyf[start:end]
+	}
+
+	// .notdef is always kept; composite glyphs pull in their components
+	keep := map[int]bool{0: true}
+	queue := append([]int{0}, glyphs...)
+	for len(queue) > 0 {
+		gid := queue[0]
+		queue = queue[1:]
+		if gid < 0 || gid >= numGlyphs {
+			continue
+		}
+		keep[gid] = true
+		for _, component := range glyphComponents(glyphData(gid)) {
+			if !keep[component] {
+				queue = append(queue, component)
+			}
+		}
+	}
+
+	// Rebuild glyf with a long loca; dropped glyphs become empty
+	var newGlyf []byte
+	newLoca := make([]byte, 4*(numGlyphs+1))
+	for gid := 0; gid < numGlyphs; gid++ {
+		binary.BigEndian.PutUint32(newLoca[4*gid:], uint32(len(newGlyf)))
+		if keep[gid] {
+			newGlyf = append(newGlyf, glyphData(gid)...)
+			for len(newGlyf)%4 != 0 {
+				newGlyf = append(newGlyf, 0)
+			}
+		}
+	}
+	binary.BigEndian.PutUint32(newLoca[4*numGlyphs:], uint32(len(newGlyf)))
+
+	newHead := append([]byte(nil), head...)
+	binary.BigEndian.PutUint32(newHead[8:], 0) // checkSumAdjustment, set below
+	binary.BigEndian.PutUint16(newHead[50:], 1)
+
+	out := sfntTables{"glyf": newGlyf, "loca": newLoca, "head": newHead}
+	for _, tag := range subsetFontTables {
+		if _, replaced := out[tag]; !replaced && tables[tag] != nil {
+			out[tag] = tables[tag]
+		}
+	}
+	return writeSFNT(out), nil
+}
+
+// glyphComponents returns the glyph ids a composite glyph is built from
+func glyphComponents(glyph []byte) []int {
+	if len(glyph) < 10 || int16(binary.BigEndian.Uint16(glyph)) >= 0 {
+		return nil
+	}
+
+	var components []int
+	for pos := 10; pos+4 <= len(glyph); {
+		flags := binary.BigEndian.Uint16(glyph[pos:])
+		components = append(components, int(binary.BigEndian.Uint16(glyph[pos+2:])))
+		pos += 4
+		if flags&glyfArgsAreWords != 0 {
+			pos += 4
+		} else {
+			pos += 2
+		}
+		switch {
+		case flags&glyfHaveScale != 0:
+			pos += 2
+		case flags&glyfHaveXYScale != 0:
+			pos += 4
+		case flags&glyfHaveTwoByTwo != 0:
+			pos += 8
+		}
+		if flags&glyfMoreComponents == 0 {
+			break
+		}
+	}
+	return components
+}
+
+// writeSFNT serializes tables into a TrueType font file
+func writeSFNT(tables sfntTables) []byte {
+	tags := make([]string, 0, len(tables))
+	for tag := range tables {
+		tags = append(tags, tag)
+	}
+	sort.Strings(tags)
+
+	// Binary search parameters of the table directory
+	entrySelector := 0
+	for 1<<(entrySelector+1) <= len(tags) {
+		entrySelector++
+	}
+	searchRange := 16 << entrySelector
+
+	header := make([]byte, 12+16*len(tags))
+	binary.BigEndian.PutUint32(header, 0x00010000)
+	binary.BigEndian.PutUint16(header[4:], uint16(len(tags)))
+	binary.BigEndian.PutUint16(header[6:], uint16(searchRange))
+	binary.BigEndian.PutUint16(header[8:], uint16(entrySelector))
+	binary.BigEndian.PutUint16(header[10:], uint16(16*len(tags)-searchRange))
+
+	out := make([]byte, len(header))
+	headOffset := -1
+	for i, tag := range tags {
+		table := tables[tag]
+		record := header[12+16*i:]
+		copy(record, tag)
+		binary.BigEndian.PutUint32(record[4:], sfntChecksum(table))
+		binary.BigEndian.PutUint32(record[8:], uint32(len(out)))
+		binary.BigEndian.PutUint32(record[12:], uint32(len(table)))
+		if tag == "head" {
+			headOffset = len(out)
+		}
+		out = append(out, table...)
+		for len(out)%4 != 0 {
+			out = append(out, 0)
+		}
+	}
+	copy(out, header)
+
+	if headOffset >= 0 {
+		binary.BigEndian.PutUint32(out[headOffset+8:], 0xb1b0afba-sfntChecksum(out))
+	}
+	return out
+}
+
+func sfntChecksum(data []byte) uint32 {
+	var sum uint32
+	for i := 0; i < len(data); i += 4 {
+		var word [4]byte
+		copy(word[:], data[i:])
+		sum += binary.BigEndian.Uint32(word[:])
+	}
+	return sum
+}
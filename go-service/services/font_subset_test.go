@@ -0,0 +1,64 @@
+package services
+
+import (
+	"bytes"
+	"encoding/binary"
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/image/font/gofont/goregular"
+	"golang.org/x/image/font/sfnt"
+)
+
+// subsetGlyphSize returns the glyf data length of gid in a long loca font
+func subsetGlyphSize(t *testing.T, data []byte, gid int) int {
+	tables, err := readSFNTTables(data)
+	assert.NoError(t, err)
+	loca := tables["loca"]
+	return int(binary.BigEndian.Uint32(loca[4*gid+4:]) - binary.BigEndian.Uint32(loca[4*gid:]))
+}
+
+func TestSubsetTrueType(t *testing.T) {
+	f, err := sfnt.Parse(goregular.TTF)
+	assert.NoError(t, err)
+	var buf sfnt.Buffer
+	h, _ := f.GlyphIndex(&buf, 'H')
+	z, _ := f.GlyphIndex(&buf, 'Z')
+	aring, _ := f.GlyphIndex(&buf, 'Å')
+
+	subset, err := subsetTrueType(goregular.TTF, []int{int(h), int(aring)})
+	assert.NoError(t, err)
+	assert.Less(t, len(subset), len(goregular.TTF)/2)
+
+	tables, err := readSFNTTables(subset)
+	assert.NoError(t, err)
+	assert.NotContains(t, tables, "cmap")
+	assert.Equal(t, uint16(1), binary.BigEndian.Uint16(tables["head"][50:]))
+	assert.Equal(t, uint32(0xb1b0afba), sfntChecksum(subset))
+
+	assert.Positive(t, subsetGlyphSize(t, subset, int(h)))
+	assert.Positive(t, subsetGlyphSize(t, subset, int(aring)))
+	assert.Positive(t, subsetGlyphSize(t, subset, 0))
+	assert.Zero(t, subsetGlyphSize(t, subset, int(z)))
+}
+
+func TestSubsetTrueTypeNeedsGlyf(t *testing.T) {
+	_, err := subsetTrueType([]byte("OTTO\x00\x00\x00\x00\x00\x00\x00\x00"), []int{1})
+	assert.ErrorIs(t, err, errNotTrueType)
+}
+
+func TestPDFDocumentEmbedsFontSubset(t *testing.T) {
+	previous := pdfFontData
+	pdfFontData = goregular.TTF
+	defer func() { pdfFontData = previous }()
+
+	doc := NewPDFDocument()
+	doc.AddText("Hello", PDFTextStyle{Size: 12})
+	data, err := doc.Bytes()
+	assert.NoError(t, err)
+
+	assert.Regexp(t, regexp.MustCompile(`/BaseFont /[A-Z]{6}\+GoRegular`), string(data))
+	assert.True(t, bytes.Contains(data, []byte("/FontFile2")))
+	assert.Less(t, len(data), len(goregular.TTF)/4)
+}
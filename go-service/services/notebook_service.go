@@ -0,0 +1,579 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"neuro-guide-go-service/database"
+	"neuro-guide-go-service/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// maxNotebookTags limits the tags of one notebook entry
+const maxNotebookTags = 20
+
+var (
+	// ErrNotebookEntryNotFound is returned when an entry does not exist or belongs to another user
+	ErrNotebookEntryNotFound = errors.New("notebook entry not found")
+	// ErrNotebookCollectionNotFound is returned when a collection does not exist or belongs to another user
+	ErrNotebookCollectionNotFound = errors.New("notebook collection not found")
+	// ErrMessageNotSaveable is returned when the source message is missing or not an assistant answer
+	ErrMessageNotSaveable = errors.New("only your assistant messages can be saved")
+	// ErrMessageAlreadySaved is returned when the message is already in the notebook
+	ErrMessageAlreadySaved = errors.New("message already saved to notebook")
+)
+
+// SaveNotebookEntryInput describes a message to bookmark
+type SaveNotebookEntryInput struct {
+	MessageID     string
+	Note          string
+	Tags          []string
+	CollectionIDs []string
+}
+
+// UpdateNotebookEntryInput changes the user's annotations of an entry. Nil fields are left unchanged.
+type UpdateNotebookEntryInput struct {
+	Note          *string
+	Tags          []string
+	CollectionIDs []string
+}
+
+// NotebookQuery filters notebook entries
+type NotebookQuery struct {
+	Query        string // matched against the answer and the note
+	Tag          string
+	CollectionID string
+}
+
+// NotebookService manages saved answers and their collections
+type NotebookService struct {
+	entries     *mongo.Collection
+	collections *mongo.Collection
+	messages    *mongo.Collection
+}
+
+// NewNotebookService creates a new instance of NotebookService
+func NewNotebookService() *NotebookService {
+	return &NotebookService{
+		entries:     database.Database.Collection("notebook_entries"),
+		collections: database.Database.Collection("notebook_collections"),
+		messages:    database.Database.Collection("chat_messages"),
+	}
+}
+
+// EnsureNotebookIndexes creates the indexes notebook entries rely on
+func EnsureNotebookIndexes() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	// A message is saved at most once, also when two saves race
+	_, err := database.Database.Collection("notebook_entries").Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "user_id", Value: 1}, {Key: "message_id", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create notebook entry index: %w", err)
+	}
+	return nil
+}
+
+// storedNotebookEntry is the database form of a notebook entry, with the structured parts encrypted
+type storedNotebookEntry struct {
+	models.NotebookEntry `bson:",inline"`
+	EncryptedParts       string `bson:"parts,omitempty"`
+}
+
+// normalizeTags trims, deduplicates and limits tags
+func normalizeTags(tags []string) []string {
+	seen := make(map[string]bool)
+	result := []string{}
+	for _, tag := range tags {
+		tag = strings.TrimSpace(tag)
+		if tag == "" || seen[tag] {
+			continue
+		}
+		seen[tag] = true
+		result = append(result, tag)
+		if len(result) == maxNotebookTags {
+			break
+		}
+	}
+	return result
+}
+
+// SaveEntry copies an assistant message into the user's notebook
+func (ns *NotebookService) SaveEntry(userID string, input SaveNotebookEntryInput) (*models.NotebookEntry, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var stored storedChatMessage
+	err := ns.messages.FindOne(ctx, bson.M{"_id": messageObjectID(input.MessageID), "user_id": userID}).Decode(&stored)
+	if err == mongo.ErrNoDocuments || (err == nil && stored.Role != "assistant") {
+		return nil, ErrMessageNotSaveable
+	}
+	if err != nil {
+		return nil, err
+	}
+	message, err := decryptMessage(&stored)
+	if err != nil {
+		return nil, err
+	}
+
+	count, err := ns.entries.CountDocuments(ctx, bson.M{"user_id": userID, "message_id": input.MessageID})
+	if err != nil {
+		return nil, err
+	}
+	if count > 0 {
+		return nil, ErrMessageAlreadySaved
+	}
+
+	collectionIDs, err := ns.validateCollections(ctx, userID, input.CollectionIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	entry := &models.NotebookEntry{
+		ID:               primitive.NewObjectID().Hex(),
+		UserID:           userID,
+		MessageID:        input.MessageID,
+		Content:          message.Message,
+		Parts:            message.Parts,
+		Mode:             message.Mode,
+		MessageTimestamp: message.Timestamp,
+		Note:             input.Note,
+		Tags:             normalizeTags(input.Tags),
+		CollectionIDs:    collectionIDs,
+		CreatedAt:        now,
+		UpdatedAt:        now,
+	}
+
+	doc, err := notebookEntryDocument(entry)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := ns.entries.InsertOne(ctx, doc); err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return nil, ErrMessageAlreadySaved
+		}
+		return nil, err
+	}
+
+	return entry, nil
+}
+
+// notebookEntryDocument builds the database document of an entry with its content encrypted
+func notebookEntryDocument(entry *models.NotebookEntry) (bson.M, error) {
+	objID, err := primitive.ObjectIDFromHex(entry.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	encrypted, keyVersion, err := encryptNotebookFields(entry)
+	if err != nil {
+		return nil, err
+	}
+
+	doc := bson.M{
+		"_id":               objID,
+		"user_id":           entry.UserID,
+		"message_id":        entry.MessageID,
+		"content":           encrypted["content"],
+		"message_timestamp": entry.MessageTimestamp,
+		"note":              encrypted["note"],
+		"tags":              entry.Tags,
+		"collection_ids":    entry.CollectionIDs,
+		"created_at":        entry.CreatedAt,
+		"updated_at":        entry.UpdatedAt,
+	}
+	if keyVersion > 0 {
+		doc["key_version"] = keyVersion
+	}
+	if entry.Mode != "" {
+		doc["mode"] = entry.Mode
+	}
+	if parts := encrypted["parts"]; parts != "" {
+		doc["parts"] = parts
+	}
+
+	return doc, nil
+}
+
+// encryptNotebookFields encrypts the content, note and parts of an entry with one data key
+func encryptNotebookFields(entry *models.NotebookEntry) (map[string]string, int, error) {
+	var partsJSON []byte
+	if !entry.Parts.IsEmpty() {
+		var err error
+		if partsJSON, err = json.Marshal(entry.Parts); err != nil {
+			return nil, 0, fmt.Errorf("failed to marshal response parts: %w", err)
+		}
+	}
+
+	encrypted, keyVersion, err := encryptFields(entry.UserID, entry.Content, entry.Note, string(partsJSON))
+	if err != nil {
+		return nil, 0, err
+	}
+	return map[string]string{"content": encrypted[0], "note": encrypted[1], "parts": encrypted[2]}, keyVersion, nil
+}
+
+// decryptNotebookEntry decrypts the content, note and parts of a stored entry
+func decryptNotebookEntry(stored *storedNotebookEntry) (*models.NotebookEntry, error) {
+	entry := stored.NotebookEntry
+
+	content, err := decryptField(entry.UserID, entry.Content)
+	if err != nil {
+		return nil, err
+	}
+	note, err := decryptField(entry.UserID, entry.Note)
+	if err != nil {
+		return nil, err
+	}
+	entry.Content, entry.Note = content, note
+
+	if stored.EncryptedParts != "" {
+		partsJSON, err := decryptField(entry.UserID, stored.EncryptedParts)
+		if err != nil {
+			return nil, err
+		}
+		var parts models.ResponseParts
+		if err := json.Unmarshal([]byte(partsJSON), &parts); err != nil {
+			return nil, fmt.Errorf("failed to decode response parts: %w", err)
+		}
+		entry.Parts = &parts
+	}
+
+	return &entry, nil
+}
+
+// GetEntry retrieves one of a user's notebook entries
+func (ns *NotebookService) GetEntry(userID, entryID string) (*models.NotebookEntry, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	objID, err := primitive.ObjectIDFromHex(entryID)
+	if err != nil {
+		return nil, ErrNotebookEntryNotFound
+	}
+
+	var stored storedNotebookEntry
+	err = ns.entries.FindOne(ctx, bson.M{"_id": objID, "user_id": userID}).Decode(&stored)
+	if err == mongo.ErrNoDocuments {
+		return nil, ErrNotebookEntryNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return decryptNotebookEntry(&stored)
+}
+
+// ListEntries returns a user's notebook entries, newest first.
+// Content is encrypted, so the text query is matched after decryption.
+func (ns *NotebookService) ListEntries(userID string, query NotebookQuery) ([]*models.NotebookEntry, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	filter := bson.M{"user_id": userID}
+	if query.Tag != "" {
+		filter["tags"] = query.Tag
+	}
+	if query.CollectionID != "" {
+		filter["collection_ids"] = query.CollectionID
+	}
+	opts := options.Find().SetSort(bson.M{"created_at": -1})
+
+	cursor, err := ns.entries.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var stored []*storedNotebookEntry
+	if err := cursor.All(ctx, &stored); err != nil {
+		return nil, err
+	}
+
+	entries := []*models.NotebookEntry{}
+	for _, s := range stored {
+		entry, err := decryptNotebookEntry(s)
+		if err != nil {
+			return nil, err
+		}
+		if matchesNotebookQuery(entry, query.Query) {
+			entries = append(entries, entry)
+		}
+	}
+
+	return entries, nil
+}
+
+// matchesNotebookQuery reports whether every word of the query appears in the entry
+func matchesNotebookQuery(entry *models.NotebookEntry, query string) bool {
+	words := strings.Fields(strings.ToLower(query))
+	if len(words) == 0 {
+		return true
+	}
+
+	text := strings.ToLower(entry.Content + "\n" + entry.Note + "\n" + strings.Join(entry.Tags, " "))
+	if entry.Parts != nil {
+		for _, m := range entry.Parts.Mechanisms {
+			text += "\n" + strings.ToLower(m.Name+" "+strings.Join(m.BrainRegions, " ")+" "+strings.Join(m.Pathways, " "))
+		}
+		for _, p := range entry.Parts.Practices {
+			text += "\n" + strings.ToLower(p.Name)
+		}
+	}
+
+	for _, word := range words {
+		if !strings.Contains(text, word) {
+			return false
+		}
+	}
+	return true
+}
+
+// UpdateEntry changes the note, tags or collections of an entry
+func (ns *NotebookService) UpdateEntry(userID, entryID string, input UpdateNotebookEntryInput) (*models.NotebookEntry, error) {
+	entry, err := ns.GetEntry(userID, entryID)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	set := bson.M{"updated_at": time.Now()}
+	if input.Note != nil {
+		// The content and parts are rewritten with the note so key_version covers all of them
+		entry.Note = *input.Note
+		encrypted, keyVersion, err := encryptNotebookFields(entry)
+		if err != nil {
+			return nil, err
+		}
+		set["content"] = encrypted["content"]
+		set["note"] = encrypted["note"]
+		if encrypted["parts"] != "" {
+			set["parts"] = encrypted["parts"]
+		}
+		if keyVersion > 0 {
+			set["key_version"] = keyVersion
+		}
+	}
+	if input.Tags != nil {
+		entry.Tags = normalizeTags(input.Tags)
+		set["tags"] = entry.Tags
+	}
+	if input.CollectionIDs != nil {
+		collectionIDs, err := ns.validateCollections(ctx, userID, input.CollectionIDs)
+		if err != nil {
+			return nil, err
+		}
+		entry.CollectionIDs = collectionIDs
+		set["collection_ids"] = collectionIDs
+	}
+	entry.UpdatedAt = set["updated_at"].(time.Time)
+
+	objID, _ := primitive.ObjectIDFromHex(entry.ID)
+	if _, err := ns.entries.UpdateOne(ctx, bson.M{"_id": objID, "user_id": userID}, bson.M{"$set": set}); err != nil {
+		return nil, err
+	}
+
+	return entry, nil
+}
+
+// DeleteEntry removes an entry from the notebook
+func (ns *NotebookService) DeleteEntry(userID, entryID string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	objID, err := primitive.ObjectIDFromHex(entryID)
+	if err != nil {
+		return ErrNotebookEntryNotFound
+	}
+
+	result, err := ns.entries.DeleteOne(ctx, bson.M{"_id": objID, "user_id": userID})
+	if err != nil {
+		return err
+	}
+	if result.DeletedCount == 0 {
+		return ErrNotebookEntryNotFound
+	}
+	return nil
+}
+
+// ListTags returns the tags a user has used, sorted
+func (ns *NotebookService) ListTags(userID string) ([]string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	values, err := ns.entries.Distinct(ctx, "tags", bson.M{"user_id": userID})
+	if err != nil {
+		return nil, err
+	}
+
+	tags := []string{}
+	for _, v := range values {
+		if tag, ok := v.(string); ok {
+			tags = append(tags, tag)
+		}
+	}
+	sort.Strings(tags)
+	return tags, nil
+}
+
+// validateCollections checks that every collection ID belongs to the user and removes duplicates
+func (ns *NotebookService) validateCollections(ctx context.Context, userID string, ids []string) ([]string, error) {
+	result := []string{}
+	seen := make(map[string]bool)
+	var objIDs []primitive.ObjectID
+	for _, id := range ids {
+		if seen[id] {
+			continue
+		}
+		objID, err := primitive.ObjectIDFromHex(id)
+		if err != nil {
+			return nil, ErrNotebookCollectionNotFound
+		}
+		seen[id] = true
+		result = append(result, id)
+		objIDs = append(objIDs, objID)
+	}
+	if len(objIDs) == 0 {
+		return result, nil
+	}
+
+	count, err := ns.collections.CountDocuments(ctx, bson.M{"_id": bson.M{"$in": objIDs}, "user_id": userID})
+	if err != nil {
+		return nil, err
+	}
+	if int(count) != len(objIDs) {
+		return nil, ErrNotebookCollectionNotFound
+	}
+	return result, nil
+}
+
+// CreateCollection creates a notebook collection
+func (ns *NotebookService) CreateCollection(collection *models.NotebookCollection) error {
+	collection.ID = primitive.NewObjectID().Hex()
+	collection.CreatedAt = time.Now()
+	collection.UpdatedAt = collection.CreatedAt
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	objID, _ := primitive.ObjectIDFromHex(collection.ID)
+	_, err := ns.collections.InsertOne(ctx, bson.M{
+		"_id":         objID,
+		"user_id":     collection.UserID,
+		"name":        collection.Name,
+		"description": collection.Description,
+		"created_at":  collection.CreatedAt,
+		"updated_at":  collection.UpdatedAt,
+	})
+	return err
+}
+
+// ListCollections returns a user's collections with their entry counts
+func (ns *NotebookService) ListCollections(userID string) ([]*models.NotebookCollection, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	cursor, err := ns.collections.Find(ctx, bson.M{"user_id": userID}, options.Find().SetSort(bson.M{"created_at": 1}))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var collections []*models.NotebookCollection
+	if err := cursor.All(ctx, &collections); err != nil {
+		return nil, err
+	}
+
+	// Count entries per collection
+	countCursor, err := ns.entries.Aggregate(ctx, mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"user_id": userID}}},
+		{{Key: "$unwind", Value: "$collection_ids"}},
+		{{Key: "$group", Value: bson.M{"_id": "$collection_ids", "count": bson.M{"$sum": 1}}}},
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer countCursor.Close(ctx)
+
+	var counts []struct {
+		ID    string `bson:"_id"`
+		Count int    `bson:"count"`
+	}
+	if err := countCursor.All(ctx, &counts); err != nil {
+		return nil, err
+	}
+	byID := make(map[string]int, len(counts))
+	for _, c := range counts {
+		byID[c.ID] = c.Count
+	}
+	for _, collection := range collections {
+		collection.EntryCount = byID[collection.ID]
+	}
+
+	return collections, nil
+}
+
+// UpdateCollection renames or re-describes a collection
+func (ns *NotebookService) UpdateCollection(userID, collectionID, name, description string) (*models.NotebookCollection, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	objID, err := primitive.ObjectIDFromHex(collectionID)
+	if err != nil {
+		return nil, ErrNotebookCollectionNotFound
+	}
+
+	var collection models.NotebookCollection
+	err = ns.collections.FindOneAndUpdate(ctx,
+		bson.M{"_id": objID, "user_id": userID},
+		bson.M{"$set": bson.M{"name": name, "description": description, "updated_at": time.Now()}},
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&collection)
+	if err == mongo.ErrNoDocuments {
+		return nil, ErrNotebookCollectionNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &collection, nil
+}
+
+// DeleteCollection removes a collection. Its entries stay in the notebook.
+func (ns *NotebookService) DeleteCollection(userID, collectionID string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	objID, err := primitive.ObjectIDFromHex(collectionID)
+	if err != nil {
+		return ErrNotebookCollectionNotFound
+	}
+
+	result, err := ns.collections.DeleteOne(ctx, bson.M{"_id": objID, "user_id": userID})
+	if err != nil {
+		return err
+	}
+	if result.DeletedCount == 0 {
+		return ErrNotebookCollectionNotFound
+	}
+
+	_, err = ns.entries.UpdateMany(ctx,
+		bson.M{"user_id": userID, "collection_ids": collectionID},
+		bson.M{"$pull": bson.M{"collection_ids": collectionID}},
+	)
+	return err
+}
@@ -0,0 +1,50 @@
+package services
+
+import (
+	"testing"
+
+	"neuro-guide-go-service/models"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNormalizeTags(t *testing.T) {
+	assert.Equal(t, []string{"知行合一", "焦虑"}, normalizeTags([]string{" 知行合一 ", "", "焦虑", "知行合一"}))
+	assert.Equal(t, []string{}, normalizeTags(nil))
+
+	many := make([]string, 30)
+	for i := range many {
+		many[i] = string(rune('a' + i))
+	}
+	assert.Len(t, normalizeTags(many), maxNotebookTags)
+}
+
+func TestMatchesNotebookQuery(t *testing.T) {
+	entry := &models.NotebookEntry{
+		Content: "知行合一的三大神经断层",
+		Note:    "睡前重读",
+		Tags:    []string{"王阳明"},
+		Parts: &models.ResponseParts{
+			Mechanisms: []models.Mechanism{{Name: "执行断层", BrainRegions: []string{"PFC"}}},
+		},
+	}
+
+	assert.True(t, matchesNotebookQuery(entry, ""))
+	assert.True(t, matchesNotebookQuery(entry, "神经断层"))
+	assert.True(t, matchesNotebookQuery(entry, "睡前 王阳明"))
+	assert.True(t, matchesNotebookQuery(entry, "pfc"))
+	assert.False(t, matchesNotebookQuery(entry, "神经断层 多巴胺"))
+}
+
+func TestDecryptNotebookEntry(t *testing.T) {
+	stored := &storedNotebookEntry{
+		NotebookEntry:  models.NotebookEntry{UserID: "u1", Content: "回答", Note: "笔记"},
+		EncryptedParts: `{"practices":[{"name":"正念呼吸"}]}`,
+	}
+
+	entry, err := decryptNotebookEntry(stored)
+	assert.NoError(t, err)
+	assert.Equal(t, "回答", entry.Content)
+	assert.Equal(t, "笔记", entry.Note)
+	assert.Equal(t, "正念呼吸", entry.Parts.Practices[0].Name)
+}
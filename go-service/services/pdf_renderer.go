@@ -0,0 +1,450 @@
+package services
+
+import (
+	"bytes"
+	"compress/zlib"
+	"embed"
+	"encoding/binary"
+	"fmt"
+	"hash/fnv"
+	"io/fs"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/sfnt"
+	"golang.org/x/image/math/fixed"
+)
+
+// embeddedFonts holds the TrueType fonts bundled into the binary.
+// services/fonts/README.md describes how the bundled CJK font subset is built.
+//
+//go:embed fonts
+var embeddedFonts embed.FS
+
+// A4 page geometry in PDF points
+const (
+	pdfPageWidth  = 595.0
+	pdfPageHeight = 842.0
+	pdfMargin     = 50.0
+)
+
+// PDFTextStyle describes how a block of text is drawn
+type PDFTextStyle struct {
+	Size  float64
+	Gray  float64 // 0 is black, 1 is white
+	Space float64 // extra space after the block
+}
+
+// pdfFont is a Type0 font usable by PDFDocument
+type pdfFont interface {
+	// encode returns the hex string operand for the text and records the glyphs used
+	encode(text string) string
+	// width returns the advance of r in 1/1000 text space units
+	width(r rune) float64
+	// objects writes the font objects starting at id and returns the Type0 font object id
+	objects(w *pdfObjectWriter) int
+}
+
+// PDFDocument is a minimal pure-Go PDF writer for flowing CJK text
+type PDFDocument struct {
+	font   pdfFont
+	pages  []*bytes.Buffer
+	cursor float64
+}
+
+var pdfFontData []byte
+
+// LoadPDFFont selects the font embedded into exported PDFs. A TrueType file at path takes
+// precedence over fonts bundled in the binary. Either way each PDF only embeds the glyphs
+// it uses. Without any font the unembedded STSong-Light font is referenced, which viewers
+// without CJK fonts cannot display, so that is logged as an error.
+func LoadPDFFont(path string) {
+	if path != "" {
+		data, err := os.ReadFile(path)
+		if err == nil {
+			if _, err = sfnt.Parse(data); err == nil {
+				pdfFontData = data
+				return
+			}
+		}
+		log.Printf("Failed to load PDF font %s, falling back: %v", path, err)
+	}
+
+	entries, _ := fs.ReadDir(embeddedFonts, "fonts")
+	for _, entry := range entries {
+		if !strings.HasSuffix(strings.ToLower(entry.Name()), ".ttf") {
+			continue
+		}
+		data, err := embeddedFonts.ReadFile("fonts/" + entry.Name())
+		if err != nil {
+			continue
+		}
+		if _, err := sfnt.Parse(data); err == nil {
+			pdfFontData = data
+			return
+		}
+	}
+	log.Printf("Error: no TrueType PDF font in services/fonts or EXPORT_PDF_FONT_PATH; " +
+		"exported PDFs reference the unembedded STSong-Light font and Chinese text may not render")
+}
+
+// NewPDFDocument creates an empty document using the configured font
+func NewPDFDocument() *PDFDocument {
+	var f pdfFont = &standardCJKFont{}
+	if pdfFontData != nil {
+		if embedded, err := newEmbeddedFont(pdfFontData); err == nil {
+			f = embedded
+		} else {
+			log.Printf("Failed to use embedded PDF font: %v", err)
+		}
+	}
+
+	doc := &PDFDocument{font: f}
+	doc.newPage()
+	return doc
+}
+
+func (d *PDFDocument) newPage() {
+	d.pages = append(d.pages, &bytes.Buffer{})
+	d.cursor = pdfPageHeight - pdfMargin
+}
+
+// AddText lays out text in the page width, wrapping lines and adding pages as needed
+func (d *PDFDocument) AddText(text string, style PDFTextStyle) {
+	lineHeight := style.Size * 1.5
+	maxWidth := pdfPageWidth - 2*pdfMargin
+
+	for _, paragraph := range strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n") {
+		for _, line := range d.wrap(paragraph, style.Size, maxWidth) {
+			if d.cursor-lineHeight < pdfMargin {
+				d.newPage()
+			}
+			d.cursor -= lineHeight
+
+			if line == "" {
+				continue
+			}
+			page := d.pages[len(d.pages)-1]
+			fmt.Fprintf(page, "BT %.2f g /F1 %.1f Tf %.2f %.2f Td <%s> Tj ET\n",
+				style.Gray, style.Size, pdfMargin, d.cursor, d.font.encode(line))
+		}
+	}
+
+	d.cursor -= style.Space
+}
+
+// wrap splits a paragraph into lines no wider than maxWidth points
+func (d *PDFDocument) wrap(paragraph string, size, maxWidth float64) []string {
+	if paragraph == "" {
+		return []string{""}
+	}
+
+	var lines []string
+	var line strings.Builder
+	lineWidth := 0.0
+
+	for _, r := range paragraph {
+		if r == '\t' {
+			r = ' '
+		}
+		w := d.font.width(r) * size / 1000
+		if lineWidth+w > maxWidth && line.Len() > 0 {
+			lines = append(lines, line.String())
+			line.Reset()
+			lineWidth = 0
+		}
+		line.WriteRune(r)
+		lineWidth += w
+	}
+	lines = append(lines, line.String())
+
+	return lines
+}
+
+// Bytes serializes the document
+func (d *PDFDocument) Bytes() ([]byte, error) {
+	w := &pdfObjectWriter{}
+	w.buf.WriteString("%PDF-1.7\n%\xe2\xe3\xcf\xd3\n")
+
+	// Reserve ids for the catalog and page tree
+	catalogID := w.reserve()
+	pagesID := w.reserve()
+
+	fontID := d.font.objects(w)
+
+	var pageIDs []int
+	for _, content := range d.pages {
+		contentID := w.stream(content.Bytes(), "")
+		pageID := w.object(fmt.Sprintf(
+			"<< /Type /Page /Parent %d 0 R /MediaBox [0 0 %.0f %.0f] /Resources << /Font << /F1 %d 0 R >> >> /Contents %d 0 R >>",
+			pagesID, pdfPageWidth, pdfPageHeight, fontID, contentID))
+		pageIDs = append(pageIDs, pageID)
+	}
+
+	var kids strings.Builder
+	for _, id := range pageIDs {
+		fmt.Fprintf(&kids, "%d 0 R ", id)
+	}
+	w.set(pagesID, fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.TrimSpace(kids.String()), len(pageIDs)))
+	w.set(catalogID, fmt.Sprintf("<< /Type /Catalog /Pages %d 0 R >>", pagesID))
+
+	return w.finish(catalogID)
+}
+
+// pdfObjectWriter writes numbered indirect objects and the cross-reference table
+type pdfObjectWriter struct {
+	buf     bytes.Buffer
+	offsets []int
+	pending map[int]string
+}
+
+func (w *pdfObjectWriter) reserve() int {
+	w.offsets = append(w.offsets, -1)
+	return len(w.offsets)
+}
+
+func (w *pdfObjectWriter) set(id int, body string) {
+	if w.pending == nil {
+		w.pending = make(map[int]string)
+	}
+	w.pending[id] = body
+}
+
+func (w *pdfObjectWriter) write(id int, body []byte) {
+	w.offsets[id-1] = w.buf.Len()
+	fmt.Fprintf(&w.buf, "%d 0 obj\n", id)
+	w.buf.Write(body)
+	w.buf.WriteString("\nendobj\n")
+}
+
+func (w *pdfObjectWriter) object(body string) int {
+	id := w.reserve()
+	w.write(id, []byte(body))
+	return id
+}
+
+// stream writes a Flate compressed stream object with optional extra dictionary entries
+func (w *pdfObjectWriter) stream(data []byte, extra string) int {
+	var compressed bytes.Buffer
+	zw := zlib.NewWriter(&compressed)
+	zw.Write(data)
+	zw.Close()
+
+	var body bytes.Buffer
+	fmt.Fprintf(&body, "<< /Length %d /Filter /FlateDecode %s>>\nstream\n", compressed.Len(), extra)
+	body.Write(compressed.Bytes())
+	body.WriteString("\nendstream")
+
+	id := w.reserve()
+	w.write(id, body.Bytes())
+	return id
+}
+
+func (w *pdfObjectWriter) finish(rootID int) ([]byte, error) {
+	ids := make([]int, 0, len(w.pending))
+	for id := range w.pending {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+	for _, id := range ids {
+		w.write(id, []byte(w.pending[id]))
+	}
+
+	xref := w.buf.Len()
+	fmt.Fprintf(&w.buf, "xref\n0 %d\n0000000000 65535 f \n", len(w.offsets)+1)
+	for id, offset := range w.offsets {
+		if offset < 0 {
+			return nil, fmt.Errorf("pdf object %d was never written", id+1)
+		}
+		fmt.Fprintf(&w.buf, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(&w.buf, "trailer\n<< /Size %d /Root %d 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(w.offsets)+1, rootID, xref)
+
+	return w.buf.Bytes(), nil
+}
+
+// standardCJKFont references the STSong-Light font every CJK capable PDF viewer provides
+type standardCJKFont struct{}
+
+func (f *standardCJKFont) encode(text string) string {
+	var b strings.Builder
+	for _, r := range text {
+		if r > 0xffff {
+			r = '?'
+		}
+		fmt.Fprintf(&b, "%04X", r)
+	}
+	return b.String()
+}
+
+func (f *standardCJKFont) width(r rune) float64 {
+	if r < 0x80 {
+		return 500
+	}
+	return 1000
+}
+
+func (f *standardCJKFont) objects(w *pdfObjectWriter) int {
+	descriptorID := w.object("<< /Type /FontDescriptor /FontName /STSong-Light /Flags 6 /FontBBox [-25 -254 1000 880] " +
+		"/ItalicAngle 0 /Ascent 880 /Descent -120 /CapHeight 880 /StemV 93 >>")
+	cidFontID := w.object(fmt.Sprintf("<< /Type /Font /Subtype /CIDFontType0 /BaseFont /STSong-Light "+
+		"/CIDSystemInfo << /Registry (Adobe) /Ordering (GB1) /Supplement 4 >> /FontDescriptor %d 0 R /DW 1000 /W [1 95 500] >>",
+		descriptorID))
+	return w.object(fmt.Sprintf("<< /Type /Font /Subtype /Type0 /BaseFont /STSong-Light-UniGB-UCS2-H "+
+		"/Encoding /UniGB-UCS2-H /DescendantFonts [%d 0 R] >>", cidFontID))
+}
+
+// embeddedTrueTypeFont embeds a TrueType font and addresses glyphs by id (Identity-H)
+type embeddedTrueTypeFont struct {
+	data   []byte
+	font   *sfnt.Font
+	buf    sfnt.Buffer
+	scale  float64
+	glyphs map[sfnt.GlyphIndex]rune
+	widths map[rune]float64
+}
+
+func newEmbeddedFont(data []byte) (*embeddedTrueTypeFont, error) {
+	f, err := sfnt.Parse(data)
+	if err != nil {
+		return nil, err
+	}
+	return &embeddedTrueTypeFont{
+		data:   data,
+		font:   f,
+		scale:  1000 / float64(f.UnitsPerEm()),
+		glyphs: make(map[sfnt.GlyphIndex]rune),
+		widths: make(map[rune]float64),
+	}, nil
+}
+
+func (f *embeddedTrueTypeFont) glyph(r rune) sfnt.GlyphIndex {
+	gi, err := f.font.GlyphIndex(&f.buf, r)
+	if err != nil {
+		return 0
+	}
+	return gi
+}
+
+func (f *embeddedTrueTypeFont) encode(text string) string {
+	var b strings.Builder
+	for _, r := range text {
+		gi := f.glyph(r)
+		if _, seen := f.glyphs[gi]; !seen && gi != 0 {
+			f.glyphs[gi] = r
+		}
+		fmt.Fprintf(&b, "%04X", uint16(gi))
+	}
+	return b.String()
+}
+
+func (f *embeddedTrueTypeFont) width(r rune) float64 {
+	if w, ok := f.widths[r]; ok {
+		return w
+	}
+
+	upem := fixed.Int26_6(f.font.UnitsPerEm())
+	advance, err := f.font.GlyphAdvance(&f.buf, f.glyph(r), upem, font.HintingNone)
+	w := 1000.0
+	if err == nil {
+		w = float64(advance) * f.scale
+	}
+	f.widths[r] = w
+	return w
+}
+
+func (f *embeddedTrueTypeFont) objects(w *pdfObjectWriter) int {
+	name := "EmbeddedCJK"
+	if full, err := f.font.Name(&f.buf, sfnt.NameIDPostScript); err == nil && full != "" {
+		name = strings.ReplaceAll(full, " ", "")
+	}
+
+	gids := make([]int, 0, len(f.glyphs))
+	for gi := range f.glyphs {
+		gids = append(gids, int(gi))
+	}
+	sort.Ints(gids)
+
+	// Only the outlines of the glyphs in the document are embedded
+	fontFile, err := subsetTrueType(f.data, gids)
+	if err == nil {
+		name = subsetTag(gids) + "+" + name
+	} else {
+		log.Printf("Failed to subset PDF font, embedding it whole: %v", err)
+		fontFile = f.data
+	}
+
+	bounds, _ := f.font.Bounds(&f.buf, fixed.Int26_6(f.font.UnitsPerEm()), font.HintingNone)
+	fontFileID := w.stream(fontFile, fmt.Sprintf("/Length1 %d ", len(fontFile)))
+	descriptorID := w.object(fmt.Sprintf("<< /Type /FontDescriptor /FontName /%s /Flags 4 /FontBBox [%d %d %d %d] "+
+		"/ItalicAngle 0 /Ascent %d /Descent %d /CapHeight %d /StemV 80 /FontFile2 %d 0 R >>",
+		name,
+		int(float64(bounds.Min.X)*f.scale), int(-float64(bounds.Max.Y)*f.scale),
+		int(float64(bounds.Max.X)*f.scale), int(-float64(bounds.Min.Y)*f.scale),
+		int(-float64(bounds.Min.Y)*f.scale), int(-float64(bounds.Max.Y)*f.scale), int(-float64(bounds.Min.Y)*f.scale),
+		fontFileID))
+
+	var widths, cmap strings.Builder
+	for _, gi := range gids {
+		r := f.glyphs[sfnt.GlyphIndex(gi)]
+		fmt.Fprintf(&widths, "%d [%d] ", gi, int(f.width(r)))
+	}
+
+	// ToUnicode lets viewers copy and search the text; bfchar blocks hold at most 100 entries
+	cmap.WriteString("/CIDInit /ProcSet findresource begin\n12 dict begin\nbegincmap\n" +
+		"/CIDSystemInfo << /Registry (Adobe) /Ordering (UCS) /Supplement 0 >> def\n" +
+		"/CMapName /Adobe-Identity-UCS def\n/CMapType 2 def\n" +
+		"1 begincodespacerange\n<0000> <FFFF>\nendcodespacerange\n")
+	for start := 0; start < len(gids); start += 100 {
+		end := start + 100
+		if end > len(gids) {
+			end = len(gids)
+		}
+		fmt.Fprintf(&cmap, "%d beginbfchar\n", end-start)
+		for _, gi := range gids[start:end] {
+			fmt.Fprintf(&cmap, "<%04X> <%s>\n", gi, utf16Hex(f.glyphs[sfnt.GlyphIndex(gi)]))
+		}
+		cmap.WriteString("endbfchar\n")
+	}
+	cmap.WriteString("endcmap\nCMapName currentdict /CMap defineresource pop\nend\nend\n")
+	toUnicodeID := w.stream([]byte(cmap.String()), "")
+
+	cidFontID := w.object(fmt.Sprintf("<< /Type /Font /Subtype /CIDFontType2 /BaseFont /%s "+
+		"/CIDSystemInfo << /Registry (Adobe) /Ordering (Identity) /Supplement 0 >> /FontDescriptor %d 0 R "+
+		"/CIDToGIDMap /Identity /DW 1000 /W [%s] >>", name, descriptorID, strings.TrimSpace(widths.String())))
+
+	return w.object(fmt.Sprintf("<< /Type /Font /Subtype /Type0 /BaseFont /%s /Encoding /Identity-H "+
+		"/DescendantFonts [%d 0 R] /ToUnicode %d 0 R >>", name, cidFontID, toUnicodeID))
+}
+
+// subsetTag derives the six letter tag PDF requires in front of the name of a subset font
+func subsetTag(gids []int) string {
+	h := fnv.New32a()
+	for _, gi := range gids {
+		binary.Write(h, binary.BigEndian, uint16(gi))
+	}
+	sum := h.Sum32()
+
+	tag := make([]byte, 6)
+	for i := range tag {
+		tag[i] = byte('A' + sum%26)
+		sum /= 26
+	}
+	return string(tag)
+}
+
+// utf16Hex returns the UTF-16BE hex encoding of r
+func utf16Hex(r rune) string {
+	if !utf8.ValidRune(r) {
+		r = utf8.RuneError
+	}
+	if r < 0x10000 {
+		return fmt.Sprintf("%04X", r)
+	}
+	r -= 0x10000
+	return fmt.Sprintf("%04X%04X", 0xd800+(r>>10), 0xdc00+(r&0x3ff))
+}
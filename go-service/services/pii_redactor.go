@@ -0,0 +1,329 @@
+package services
+
+import (
+	"fmt"
+	"log"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// Names of the built-in PII detectors
+const (
+	PIIDetectorPhone    = "phone"
+	PIIDetectorIDCard   = "id_card"
+	PIIDetectorEmail    = "email"
+	PIIDetectorBankCard = "bank_card"
+	PIIDetectorAddress  = "address"
+)
+
+// DefaultPIIDetectors lists the detectors enabled when none are configured
+var DefaultPIIDetectors = []string{PIIDetectorIDCard, PIIDetectorBankCard, PIIDetectorPhone, PIIDetectorEmail, PIIDetectorAddress}
+
+// DefaultPIIRestoreDetectors lists the detectors whose values are put back into AI replies by default.
+// ID card and bank card numbers are never echoed back unless configured explicitly.
+var DefaultPIIRestoreDetectors = []string{PIIDetectorPhone, PIIDetectorEmail, PIIDetectorAddress}
+
+// PIIDetector finds one kind of personal information in text
+type PIIDetector interface {
+	Name() string
+	// Detect returns the byte ranges [start, end) of every match
+	Detect(text string) [][2]int
+}
+
+// regexDetector is a PIIDetector backed by a regular expression and an optional validator
+type regexDetector struct {
+	name     string
+	pattern  *regexp.Regexp
+	validate func(text string, start, end int) (int, int, bool)
+}
+
+func (d *regexDetector) Name() string {
+	return d.name
+}
+
+func (d *regexDetector) Detect(text string) [][2]int {
+	var matches [][2]int
+	for _, loc := range d.pattern.FindAllStringIndex(text, -1) {
+		start, end := loc[0], loc[1]
+		if d.validate != nil {
+			var ok bool
+			if start, end, ok = d.validate(text, start, end); !ok {
+				continue
+			}
+		}
+		matches = append(matches, [2]int{start, end})
+	}
+	return matches
+}
+
+var (
+	phonePattern    = regexp.MustCompile(`(?:\+?86[- ]?)?1[3-9]\d{9}`)
+	idCardPattern   = regexp.MustCompile(`\d{17}[\dXx]`)
+	emailPattern    = regexp.MustCompile(`[A-Za-z0-9._%+\-]+@[A-Za-z0-9.\-]+\.[A-Za-z]{2,}`)
+	bankCardPattern = regexp.MustCompile(`\d(?:[ \-]?\d){15,18}`)
+	addressPattern  = regexp.MustCompile(`[\p{Han}\d]{2,30}?(?:路|街|大道|大街|巷|弄|胡同)\d+(?:号|號)(?:[\p{Han}\d\-]{0,12}?(?:栋|幢|座|楼|单元|室))*`)
+)
+
+// addressLeadIns are words that usually introduce an address and are not part of it
+var addressLeadIns = []string{"地址是", "住在", "位于", "搬到", "在", "是", "："}
+
+// newBuiltinPIIDetector creates a built-in detector by name
+func newBuiltinPIIDetector(name string) (PIIDetector, error) {
+	switch name {
+	case PIIDetectorPhone:
+		return &regexDetector{name: name, pattern: phonePattern, validate: digitBounded}, nil
+	case PIIDetectorIDCard:
+		return &regexDetector{name: name, pattern: idCardPattern, validate: validIDCard}, nil
+	case PIIDetectorEmail:
+		return &regexDetector{name: name, pattern: emailPattern}, nil
+	case PIIDetectorBankCard:
+		return &regexDetector{name: name, pattern: bankCardPattern, validate: validBankCard}, nil
+	case PIIDetectorAddress:
+		return &regexDetector{name: name, pattern: addressPattern, validate: trimAddressLeadIn}, nil
+	}
+	return nil, fmt.Errorf("unknown PII detector %q", name)
+}
+
+// digitBounded rejects matches that are part of a longer digit sequence
+func digitBounded(text string, start, end int) (int, int, bool) {
+	if start > 0 && isASCIIDigit(text[start-1]) {
+		return start, end, false
+	}
+	if end < len(text) && isASCIIDigit(text[end]) {
+		return start, end, false
+	}
+	return start, end, true
+}
+
+// validIDCard checks the GB 11643 checksum of an 18-digit resident ID number
+func validIDCard(text string, start, end int) (int, int, bool) {
+	if _, _, ok := digitBounded(text, start, end); !ok {
+		return start, end, false
+	}
+
+	weights := []int{7, 9, 10, 5, 8, 4, 2, 1, 6, 3, 7, 9, 10, 5, 8, 4, 2}
+	checks := "10X98765432"
+
+	id := strings.ToUpper(text[start:end])
+	sum := 0
+	for i, w := range weights {
+		sum += int(id[i]-'0') * w
+	}
+	return start, end, checks[sum%11] == id[17]
+}
+
+// validBankCard checks the Luhn checksum of a 16-19 digit card number
+func validBankCard(text string, start, end int) (int, int, bool) {
+	if _, _, ok := digitBounded(text, start, end); !ok {
+		return start, end, false
+	}
+
+	var digits []int
+	for i := start; i < end; i++ {
+		if isASCIIDigit(text[i]) {
+			digits = append(digits, int(text[i]-'0'))
+		}
+	}
+	if len(digits) < 16 || len(digits) > 19 {
+		return start, end, false
+	}
+
+	sum := 0
+	for i := len(digits) - 1; i >= 0; i-- {
+		d := digits[i]
+		if (len(digits)-1-i)%2 == 1 {
+			d *= 2
+			if d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+	}
+	return start, end, sum%10 == 0
+}
+
+// trimAddressLeadIn drops introductory words such as "我住在" from the start of an address match
+func trimAddressLeadIn(text string, start, end int) (int, int, bool) {
+	match := text[start:end]
+	for _, leadIn := range addressLeadIns {
+		if idx := strings.LastIndex(match, leadIn); idx >= 0 {
+			if cut := idx + len(leadIn); cut < len(match) && cut > 0 {
+				start += cut
+				match = text[start:end]
+			}
+		}
+	}
+	return start, end, utf8.RuneCountInString(match) >= 4
+}
+
+func isASCIIDigit(b byte) bool {
+	return b >= '0' && b <= '9'
+}
+
+// Redaction records one placeholder substitution
+type Redaction struct {
+	Placeholder string `json:"placeholder"`
+	Original    string `json:"-"`
+	Detector    string `json:"detector"`
+}
+
+// RedactionResult is the outcome of redacting a piece of text
+type RedactionResult struct {
+	Text       string
+	Redactions []Redaction
+}
+
+// PIIRedactor replaces personal information with reversible placeholders
+type PIIRedactor struct {
+	detectors []PIIDetector
+	restore   map[string]bool
+}
+
+// NewPIIRedactor creates a redactor with the named detectors, in priority order.
+// Values found by detectors listed in restore are put back by Restore.
+func NewPIIRedactor(detectors, restore []string) (*PIIRedactor, error) {
+	r := &PIIRedactor{restore: make(map[string]bool)}
+
+	for _, name := range detectors {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		detector, err := newBuiltinPIIDetector(name)
+		if err != nil {
+			return nil, err
+		}
+		r.detectors = append(r.detectors, detector)
+	}
+
+	for _, name := range restore {
+		r.restore[strings.TrimSpace(name)] = true
+	}
+
+	return r, nil
+}
+
+// newConfiguredPIIRedactor builds the redactor from config, falling back to the defaults on error
+func newConfiguredPIIRedactor(detectors, restore []string) *PIIRedactor {
+	if detectors == nil {
+		detectors = DefaultPIIDetectors
+	}
+	if restore == nil {
+		restore = DefaultPIIRestoreDetectors
+	}
+
+	redactor, err := NewPIIRedactor(detectors, restore)
+	if err != nil {
+		log.Printf("Invalid PII redaction config, using defaults: %v", err)
+		redactor, _ = NewPIIRedactor(DefaultPIIDetectors, DefaultPIIRestoreDetectors)
+	}
+	return redactor
+}
+
+// Redact replaces every detected value with a placeholder such as [PHONE_1].
+// The same value always maps to the same placeholder within one call.
+func (r *PIIRedactor) Redact(text string) *RedactionResult {
+	return r.redactWith(text, nil)
+}
+
+// redactWith redacts text, reusing and extending an existing set of redactions
+func (r *PIIRedactor) redactWith(text string, existing []Redaction) *RedactionResult {
+	type span struct {
+		start, end int
+		detector   string
+	}
+
+	var spans []span
+	for _, detector := range r.detectors {
+		for _, m := range detector.Detect(text) {
+			overlaps := false
+			for _, s := range spans {
+				if m[0] < s.end && s.start < m[1] {
+					overlaps = true
+					break
+				}
+			}
+			if !overlaps {
+				spans = append(spans, span{start: m[0], end: m[1], detector: detector.Name()})
+			}
+		}
+	}
+
+	result := &RedactionResult{Redactions: existing}
+	if len(spans) == 0 {
+		result.Text = text
+		return result
+	}
+
+	sort.Slice(spans, func(i, j int) bool { return spans[i].start < spans[j].start })
+
+	counts := make(map[string]int)
+	byValue := make(map[string]string)
+	for _, red := range existing {
+		counts[red.Detector]++
+		byValue[red.Detector+"\x00"+red.Original] = red.Placeholder
+	}
+
+	var b strings.Builder
+	last := 0
+	for _, s := range spans {
+		original := text[s.start:s.end]
+		key := s.detector + "\x00" + original
+
+		placeholder, ok := byValue[key]
+		if !ok {
+			counts[s.detector]++
+			placeholder = fmt.Sprintf("[%s_%d]", strings.ToUpper(s.detector), counts[s.detector])
+			byValue[key] = placeholder
+			result.Redactions = append(result.Redactions, Redaction{
+				Placeholder: placeholder,
+				Original:    original,
+				Detector:    s.detector,
+			})
+		}
+
+		b.WriteString(text[last:s.start])
+		b.WriteString(placeholder)
+		last = s.end
+	}
+	b.WriteString(text[last:])
+
+	result.Text = b.String()
+	return result
+}
+
+// RedactContext redacts the text values of conversation context entries,
+// sharing placeholders with the given redactions
+func (r *PIIRedactor) RedactContext(context []map[string]interface{}, redactions []Redaction) ([]map[string]interface{}, []Redaction) {
+	if context == nil {
+		return nil, redactions
+	}
+
+	redacted := make([]map[string]interface{}, len(context))
+	for i, entry := range context {
+		copied := make(map[string]interface{}, len(entry))
+		for k, v := range entry {
+			if text, ok := v.(string); ok && (k == "message" || k == "content") {
+				result := r.redactWith(text, redactions)
+				redactions = result.Redactions
+				copied[k] = result.Text
+				continue
+			}
+			copied[k] = v
+		}
+		redacted[i] = copied
+	}
+	return redacted, redactions
+}
+
+// Restore puts original values back for placeholders of restorable detectors
+func (r *PIIRedactor) Restore(text string, redactions []Redaction) string {
+	for _, red := range redactions {
+		if r.restore[red.Detector] {
+			text = strings.ReplaceAll(text, red.Placeholder, red.Original)
+		}
+	}
+	return text
+}
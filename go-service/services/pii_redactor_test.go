@@ -0,0 +1,107 @@
+package services
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestRedactor(t *testing.T) *PIIRedactor {
+	redactor, err := NewPIIRedactor(DefaultPIIDetectors, DefaultPIIRestoreDetectors)
+	assert.NoError(t, err)
+	return redactor
+}
+
+func TestPIIRedactor_Phone(t *testing.T) {
+	r := newTestRedactor(t)
+
+	result := r.Redact("我的手机号是13812345678，有事打给我")
+	assert.Equal(t, "我的手机号是[PHONE_1]，有事打给我", result.Text)
+	assert.Len(t, result.Redactions, 1)
+	assert.Equal(t, "13812345678", result.Redactions[0].Original)
+
+	// Part of a longer number is not a phone number
+	result = r.Redact("订单号 913812345678123")
+	assert.Empty(t, result.Redactions)
+}
+
+func TestPIIRedactor_IDCard(t *testing.T) {
+	r := newTestRedactor(t)
+
+	result := r.Redact("身份证11010519491231002X")
+	assert.Equal(t, "身份证[ID_CARD_1]", result.Text)
+
+	// Invalid checksum is ignored
+	result = r.Redact("身份证110105194912310021")
+	assert.Empty(t, result.Redactions)
+}
+
+func TestPIIRedactor_EmailAndBankCard(t *testing.T) {
+	r := newTestRedactor(t)
+
+	result := r.Redact("邮箱 zhang.san@example.com 卡号 6222 0202 0000 0000 006")
+	assert.NotContains(t, result.Text, "zhang.san@example.com")
+	assert.Contains(t, result.Text, "[EMAIL_1]")
+	assert.NotContains(t, result.Text, "6222 0202")
+	assert.Contains(t, result.Text, "[BANK_CARD_1]")
+
+	result = r.Redact("卡号4111111111111111")
+	assert.Equal(t, "卡号[BANK_CARD_1]", result.Text)
+
+	// Fails the Luhn check
+	result = r.Redact("卡号4111111111111112")
+	assert.Empty(t, result.Redactions)
+}
+
+func TestPIIRedactor_Address(t *testing.T) {
+	r := newTestRedactor(t)
+
+	result := r.Redact("我住在北京市朝阳区建国路88号，最近总失眠")
+	assert.Equal(t, "我住在[ADDRESS_1]，最近总失眠", result.Text)
+	assert.Equal(t, "北京市朝阳区建国路88号", result.Redactions[0].Original)
+}
+
+func TestPIIRedactor_SameValueSamePlaceholder(t *testing.T) {
+	r := newTestRedactor(t)
+
+	result := r.Redact("13812345678 和 13812345678 以及 13987654321")
+	assert.Equal(t, "[PHONE_1] 和 [PHONE_1] 以及 [PHONE_2]", result.Text)
+	assert.Len(t, result.Redactions, 2)
+}
+
+func TestPIIRedactor_Restore(t *testing.T) {
+	r := newTestRedactor(t)
+
+	result := r.Redact("电话13812345678，卡号4111111111111111")
+	reply := "已记录你的电话[PHONE_1]，请不要透露卡号[BANK_CARD_1]"
+
+	restored := r.Restore(reply, result.Redactions)
+	assert.Equal(t, "已记录你的电话13812345678，请不要透露卡号[BANK_CARD_1]", restored)
+}
+
+func TestPIIRedactor_RedactContextSharesPlaceholders(t *testing.T) {
+	r := newTestRedactor(t)
+
+	result := r.Redact("还是13812345678")
+	context, redactions := r.RedactContext([]map[string]interface{}{
+		{"role": "user", "message": "我的电话是13812345678"},
+		{"role": "user", "message": "邮箱a@b.cn"},
+	}, result.Redactions)
+
+	assert.Equal(t, "我的电话是[PHONE_1]", context[0]["message"])
+	assert.Equal(t, "邮箱[EMAIL_1]", context[1]["message"])
+	assert.Equal(t, "user", context[0]["role"])
+	assert.Len(t, redactions, 2)
+}
+
+func TestPIIRedactor_ConfigurableDetectors(t *testing.T) {
+	r, err := NewPIIRedactor([]string{PIIDetectorEmail}, nil)
+	assert.NoError(t, err)
+
+	result := r.Redact("13812345678 a@b.cn")
+	assert.Equal(t, "13812345678 [EMAIL_1]", result.Text)
+	assert.Equal(t, "13812345678 [EMAIL_1]", r.Restore(result.Text, result.Redactions))
+
+	_, err = NewPIIRedactor([]string{"unknown"}, nil)
+	assert.Error(t, err)
+}
@@ -0,0 +1,539 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"reflect"
+	"strings"
+	"time"
+
+	"neuro-guide-go-service/config"
+	"neuro-guide-go-service/database"
+	"neuro-guide-go-service/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// ErrPlanAdjustmentNotFound is returned when a plan has no such adjustment
+var ErrPlanAdjustmentNotFound = errors.New("plan adjustment not found")
+
+// ErrPlanAdjustmentDecided is returned when accepting or rejecting an adjustment that is no longer pending
+var ErrPlanAdjustmentDecided = errors.New("plan adjustment is no longer pending")
+
+// Adherence rules
+const (
+	adjustmentWindowDays    = 7   // 评估最近几天的记录
+	adjustmentMinDays       = 3   // 至少有几天可评估才提出调整
+	lightenMissedStreak     = 3   // 连续几天未完成必做任务后减轻
+	lightenCompletionRate   = 0.5 // 完成率低于此值时减轻
+	lightenSentiment        = -0.3
+	progressCompletedStreak = 5 // 连续几天全部完成后加强
+	progressCompletionRate  = 0.9
+	skippedTaskMisses       = 2                  // 同一任务被跳过几次算作常被跳过
+	rejectedCooldown        = 7 * 24 * time.Hour // 用户拒绝后多久内不再自动提议
+)
+
+// Words counted when estimating the mood of a reflection
+var (
+	positiveReflectionWords = []string{"平静", "放松", "专注", "轻松", "开心", "愉快", "进步", "有效", "舒服", "清晰",
+		"calm", "relaxed", "focused", "better", "easier"}
+	negativeReflectionWords = []string{"疲惫", "好累", "太累", "焦虑", "烦躁", "压力", "困难", "太难", "没时间", "坚持不",
+		"失眠", "沮丧", "痛苦", "tired", "anxious", "stressed", "too hard", "no time"}
+)
+
+// PlanAdjustmentService evaluates how users follow their plans and proposes adjustments
+type PlanAdjustmentService struct {
+	adjustments *mongo.Collection
+	records     *mongo.Collection
+	plans       *PracticePlanService
+}
+
+// NewPlanAdjustmentService creates a new instance of PlanAdjustmentService
+func NewPlanAdjustmentService(cfg *config.Config) *PlanAdjustmentService {
+	return &PlanAdjustmentService{
+		adjustments: database.Database.Collection("plan_adjustments"),
+		records:     database.Database.Collection("practice_records"),
+		plans:       NewPracticePlanService(cfg),
+	}
+}
+
+// reflectionSentiment scores a reflection from -1 (negative) to 1 (positive).
+// It reports false when the reflection contains none of the known words.
+func reflectionSentiment(text string) (float64, bool) {
+	text = strings.ToLower(text)
+
+	var positive, negative int
+	for _, word := range positiveReflectionWords {
+		positive += strings.Count(text, word)
+	}
+	for _, word := range negativeReflectionWords {
+		negative += strings.Count(text, word)
+	}
+	if positive+negative == 0 {
+		return 0, false
+	}
+	return float64(positive-negative) / float64(positive+negative), true
+}
+
+// lastEvaluatedDay returns the last plan day that is over. Today is still in progress.
+func lastEvaluatedDay(plan *models.PracticePlan, today PlanDay) int {
+	switch today.Status {
+	case PlanDayActive:
+		return today.Day - 1
+	case PlanDayRest, PlanDayPaused:
+		return today.Day
+	case PlanDayFinished:
+		return plan.Days
+	}
+	return 0
+}
+
+// EvaluateAdherence summarizes the records of the last evaluated days up to lastDay
+func EvaluateAdherence(plan *models.PracticePlan, records []*models.PracticeRecord, lastDay int) models.PlanAdherence {
+	from := lastDay - adjustmentWindowDays + 1
+	if from < 1 {
+		from = 1
+	}
+	adherence := models.PlanAdherence{FromDay: from, ToDay: lastDay, SkippedTasks: []models.SkippedTask{}}
+	if lastDay < 1 {
+		return adherence
+	}
+
+	completed := make(map[int]map[string]bool)
+	var sentiment float64
+	var scored int
+	for _, record := range records {
+		if record.Day < from || record.Day > lastDay {
+			continue
+		}
+		if completed[record.Day] == nil {
+			completed[record.Day] = make(map[string]bool)
+		}
+		for _, id := range record.CompletedTasks {
+			completed[record.Day][id] = true
+		}
+		if strings.TrimSpace(record.Reflection) != "" {
+			adherence.Reflections++
+			if score, ok := reflectionSentiment(record.Reflection); ok {
+				sentiment += score
+				scored++
+			}
+		}
+	}
+	if scored > 0 {
+		adherence.Sentiment = sentiment / float64(scored)
+	}
+
+	var required, done int
+	allDone := make(map[int]bool)
+	noneDone := make(map[int]bool)
+	skipped := make(map[string]*models.SkippedTask)
+	var order []string
+	for day := from; day <= lastDay; day++ {
+		var dayRequired, dayDone int
+		for _, task := range plan.Tasks {
+			if task.Day != day || task.Optional {
+				continue
+			}
+			dayRequired++
+			if skipped[task.Title] == nil {
+				skipped[task.Title] = &models.SkippedTask{Title: task.Title}
+				order = append(order, task.Title)
+			}
+			skipped[task.Title].Scheduled++
+			if completed[day][task.ID] {
+				dayDone++
+			} else {
+				skipped[task.Title].Missed++
+			}
+		}
+		required += dayRequired
+		done += dayDone
+		allDone[day] = dayDone == dayRequired
+		noneDone[day] = dayRequired > 0 && dayDone == 0
+	}
+	// Nothing was missed when no task was required
+	adherence.CompletionRate = 1
+	if required > 0 {
+		adherence.CompletionRate = float64(done) / float64(required)
+	}
+
+	for day := lastDay; day >= from && allDone[day]; day-- {
+		adherence.CompletedStreak++
+	}
+	for day := lastDay; day >= from && noneDone[day]; day-- {
+		adherence.MissedStreak++
+	}
+
+	for _, title := range order {
+		if task := skipped[title]; task.Missed >= skippedTaskMisses {
+			adherence.SkippedTasks = append(adherence.SkippedTasks, *task)
+		}
+	}
+
+	return adherence
+}
+
+// ProposeAdjustment applies the adherence rules to the days after the evaluated period.
+// It reports false when no change is warranted or the rules would not change any task.
+func ProposeAdjustment(plan *models.PracticePlan, adherence models.PlanAdherence) (kind, reason string, tasks []models.PlanTask, ok bool) {
+	fromDay := adherence.ToDay + 1
+	if adherence.ToDay-adherence.FromDay+1 < adjustmentMinDays || fromDay > plan.Days {
+		return "", "", nil, false
+	}
+
+	switch {
+	case adherence.MissedStreak >= lightenMissedStreak:
+		kind = models.AdjustmentLighten
+		reason = fmt.Sprintf("最近%d天没有完成必做任务，建议缩短之后的任务时长，并把常被跳过的任务改为选做", adherence.MissedStreak)
+	case adherence.CompletionRate < lightenCompletionRate:
+		kind = models.AdjustmentLighten
+		reason = fmt.Sprintf("最近%d天必做任务完成率为%.0f%%，建议缩短之后的任务时长，并把常被跳过的任务改为选做",
+			adherence.ToDay-adherence.FromDay+1, adherence.CompletionRate*100)
+	case adherence.Sentiment <= lightenSentiment && adherence.CompletionRate < progressCompletionRate:
+		kind = models.AdjustmentLighten
+		reason = "最近的练习感悟显示压力或疲惫较多，建议减轻之后的任务"
+	case adherence.CompletedStreak >= progressCompletedStreak && adherence.CompletionRate >= progressCompletionRate && adherence.Sentiment >= 0:
+		kind = models.AdjustmentProgress
+		reason = fmt.Sprintf("已连续%d天完成全部必做任务，建议适当延长之后的任务时长", adherence.CompletedStreak)
+	default:
+		return "", "", nil, false
+	}
+
+	tasks = append([]models.PlanTask(nil), plan.Tasks...)
+	if kind == models.AdjustmentLighten {
+		lightenTasks(tasks, fromDay, adherence.SkippedTasks)
+	} else {
+		progressTasks(tasks, fromDay)
+	}
+	if reflect.DeepEqual(tasks, plan.Tasks) {
+		return "", "", nil, false
+	}
+	return kind, reason, tasks, true
+}
+
+// lightenTasks shortens the tasks from fromDay on by a third and makes often skipped tasks optional.
+// Every day keeps at least one required task.
+func lightenTasks(tasks []models.PlanTask, fromDay int, skipped []models.SkippedTask) {
+	skippedTitles := make(map[string]bool, len(skipped))
+	for _, task := range skipped {
+		skippedTitles[task.Title] = true
+	}
+
+	hasRequired := make(map[int]bool)
+	for i := range tasks {
+		task := &tasks[i]
+		if task.Day < fromDay {
+			continue
+		}
+		if task.DurationMinutes > 5 {
+			task.DurationMinutes = roundToFive(task.DurationMinutes*2/3, false)
+			if task.DurationMinutes < 5 {
+				task.DurationMinutes = 5
+			}
+		}
+		if !task.Optional && skippedTitles[task.Title] {
+			task.Optional = true
+		} else if !task.Optional {
+			hasRequired[task.Day] = true
+		}
+	}
+
+	for i := range tasks {
+		task := &tasks[i]
+		if task.Day >= fromDay && task.Optional && skippedTitles[task.Title] && !hasRequired[task.Day] {
+			task.Optional = false
+			hasRequired[task.Day] = true
+		}
+	}
+}
+
+// progressTasks lengthens the timed tasks from fromDay on by a quarter
+func progressTasks(tasks []models.PlanTask, fromDay int) {
+	for i := range tasks {
+		task := &tasks[i]
+		if task.Day < fromDay || task.DurationMinutes == 0 {
+			continue
+		}
+		task.DurationMinutes = roundToFive(task.DurationMinutes*5/4, true)
+		if task.DurationMinutes > maxTaskMinutes {
+			task.DurationMinutes = maxTaskMinutes
+		}
+	}
+}
+
+// roundToFive rounds minutes to a multiple of five
+func roundToFive(minutes int, up bool) int {
+	if up && minutes%5 != 0 {
+		return minutes + 5 - minutes%5
+	}
+	return minutes - minutes%5
+}
+
+// EvaluatePlan evaluates a user's plan and stores a new pending adjustment when one is warranted.
+// Earlier pending adjustments of the plan are superseded. The adjustment is nil when nothing should change.
+func (pas *PlanAdjustmentService) EvaluatePlan(userID, planID string, now time.Time) (*models.PlanAdherence, *models.PlanAdjustment, error) {
+	plan, err := pas.plans.GetPlan(userID, planID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !isEditable(plan.Status) {
+		return nil, nil, ErrPlanNotEditable
+	}
+	return pas.evaluate(plan, now)
+}
+
+// evaluate runs the adherence rules on a plan
+func (pas *PlanAdjustmentService) evaluate(plan *models.PracticePlan, now time.Time) (*models.PlanAdherence, *models.PlanAdjustment, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	lastDay := lastEvaluatedDay(plan, PlanDayAt(plan, now, pas.plans.location))
+	records, err := pas.loadRecords(ctx, plan, lastDay)
+	if err != nil {
+		return nil, nil, err
+	}
+	adherence := EvaluateAdherence(plan, records, lastDay)
+
+	_, err = pas.adjustments.UpdateMany(ctx,
+		bson.M{"plan_id": plan.ID, "user_id": plan.UserID, "status": models.AdjustmentPending},
+		bson.M{"$set": bson.M{"status": models.AdjustmentSuperseded, "decided_at": now}})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	kind, reason, tasks, ok := ProposeAdjustment(plan, adherence)
+	if !ok {
+		return &adherence, nil, nil
+	}
+
+	changes := DiffPlanVersions(&models.PlanVersion{Tasks: plan.Tasks}, &models.PlanVersion{Tasks: tasks}).Tasks
+	objID := primitive.NewObjectID()
+	adjustment := &models.PlanAdjustment{
+		ID:          objID.Hex(),
+		PlanID:      plan.ID,
+		UserID:      plan.UserID,
+		BaseVersion: plan.CurrentVersion(),
+		Kind:        kind,
+		Reason:      reason,
+		Adherence:   adherence,
+		Tasks:       tasks,
+		Changes:     changes,
+		Status:      models.AdjustmentPending,
+		CreatedAt:   now,
+	}
+
+	_, err = pas.adjustments.InsertOne(ctx, bson.M{
+		"_id":          objID,
+		"plan_id":      adjustment.PlanID,
+		"user_id":      adjustment.UserID,
+		"base_version": adjustment.BaseVersion,
+		"kind":         adjustment.Kind,
+		"reason":       adjustment.Reason,
+		"adherence":    adjustment.Adherence,
+		"tasks":        adjustment.Tasks,
+		"changes":      adjustment.Changes,
+		"status":       adjustment.Status,
+		"created_at":   adjustment.CreatedAt,
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return &adherence, adjustment, nil
+}
+
+// loadRecords loads the plan's records of the evaluated period with their reflections decrypted
+func (pas *PlanAdjustmentService) loadRecords(ctx context.Context, plan *models.PracticePlan, lastDay int) ([]*models.PracticeRecord, error) {
+	if lastDay < 1 {
+		return nil, nil
+	}
+
+	filter := bson.M{
+		"user_id": plan.UserID,
+		"plan_id": plan.ID,
+		"day":     bson.M{"$gte": lastDay - adjustmentWindowDays + 1, "$lte": lastDay},
+	}
+	cursor, err := pas.records.Find(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var records []*models.PracticeRecord
+	if err := cursor.All(ctx, &records); err != nil {
+		return nil, err
+	}
+	for _, record := range records {
+		if err := decryptRecord(record); err != nil {
+			return nil, err
+		}
+	}
+	return records, nil
+}
+
+// ListAdjustments returns the adjustments proposed for a user's plan, newest first
+func (pas *PlanAdjustmentService) ListAdjustments(userID, planID string) ([]*models.PlanAdjustment, error) {
+	if _, err := pas.plans.GetPlan(userID, planID); err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	opts := options.Find().SetSort(bson.M{"created_at": -1})
+	cursor, err := pas.adjustments.Find(ctx, bson.M{"plan_id": planID, "user_id": userID}, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	adjustments := []*models.PlanAdjustment{}
+	if err := cursor.All(ctx, &adjustments); err != nil {
+		return nil, err
+	}
+	return adjustments, nil
+}
+
+// decide moves a pending adjustment to a final status
+func (pas *PlanAdjustmentService) decide(ctx context.Context, userID, planID, adjustmentID, status string) (*models.PlanAdjustment, error) {
+	objID, err := primitive.ObjectIDFromHex(adjustmentID)
+	if err != nil {
+		return nil, ErrPlanAdjustmentNotFound
+	}
+
+	var adjustment models.PlanAdjustment
+	err = pas.adjustments.FindOneAndUpdate(ctx,
+		bson.M{"_id": objID, "plan_id": planID, "user_id": userID, "status": models.AdjustmentPending},
+		bson.M{"$set": bson.M{"status": status, "decided_at": time.Now()}},
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&adjustment)
+	if err != mongo.ErrNoDocuments {
+		return &adjustment, err
+	}
+
+	count, err := pas.adjustments.CountDocuments(ctx, bson.M{"_id": objID, "plan_id": planID, "user_id": userID})
+	if err != nil {
+		return nil, err
+	}
+	if count == 0 {
+		return nil, ErrPlanAdjustmentNotFound
+	}
+	return nil, ErrPlanAdjustmentDecided
+}
+
+// AcceptAdjustment applies a pending adjustment to its plan as a new version.
+// The adjustment is superseded when the plan changed since it was proposed.
+func (pas *PlanAdjustmentService) AcceptAdjustment(userID, planID, adjustmentID string) (*models.PracticePlan, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	adjustment, err := pas.decide(ctx, userID, planID, adjustmentID, models.AdjustmentAccepted)
+	if err != nil {
+		return nil, err
+	}
+
+	plan, err := pas.plans.saveVersion(userID, planID, adjustment.BaseVersion, models.PlanChangeAdjusted, 0, func(plan *models.PracticePlan) error {
+		plan.Tasks = adjustment.Tasks
+		return nil
+	})
+	if err != nil {
+		// Give the adjustment back unless it can no longer apply
+		update := bson.M{"$set": bson.M{"status": models.AdjustmentPending}, "$unset": bson.M{"decided_at": ""}}
+		if errors.Is(err, ErrPlanVersionConflict) {
+			update = bson.M{"$set": bson.M{"status": models.AdjustmentSuperseded}}
+		}
+		if _, undoErr := pas.adjustments.UpdateOne(ctx, bson.M{"_id": messageObjectID(adjustment.ID)}, update); undoErr != nil {
+			log.Printf("Failed to reset adjustment %s: %v", adjustment.ID, undoErr)
+		}
+		return nil, err
+	}
+
+	if _, err := pas.adjustments.UpdateOne(ctx, bson.M{"_id": messageObjectID(adjustment.ID)},
+		bson.M{"$set": bson.M{"result_version": plan.Version}}); err != nil {
+		log.Printf("Failed to record result version of adjustment %s: %v", adjustment.ID, err)
+	}
+
+	return plan, nil
+}
+
+// RejectAdjustment declines a pending adjustment. The plan is not changed.
+func (pas *PlanAdjustmentService) RejectAdjustment(userID, planID, adjustmentID string) (*models.PlanAdjustment, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	return pas.decide(ctx, userID, planID, adjustmentID, models.AdjustmentRejected)
+}
+
+// StartEvaluator periodically evaluates running plans and proposes adjustments
+func (pas *PlanAdjustmentService) StartEvaluator(interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			if err := pas.evaluateDuePlans(time.Now()); err != nil {
+				log.Printf("Plan adjustment evaluator failed: %v", err)
+			}
+		}
+	}()
+}
+
+// evaluateDuePlans runs one pass of the evaluator over all running plans. Plans with a pending
+// adjustment, or whose last adjustment was rejected recently, are left alone.
+func (pas *PlanAdjustmentService) evaluateDuePlans(now time.Time) error {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	cursor, err := pas.plans.collection.Find(ctx, runningPlanFilter())
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		var plan models.PracticePlan
+		if err := cursor.Decode(&plan); err != nil {
+			log.Printf("Failed to decode plan: %v", err)
+			continue
+		}
+		fillLegacyTaskIDs(plan.Tasks)
+		pas.plans.settle(ctx, &plan, now)
+		if plan.Status != models.PlanStatusActive {
+			continue
+		}
+
+		status := PlanDayAt(&plan, now, pas.plans.location).Status
+		if status == PlanDayNotStarted || status == PlanDayFinished {
+			continue
+		}
+
+		busy, err := pas.adjustments.CountDocuments(ctx, bson.M{
+			"plan_id": plan.ID,
+			"$or": bson.A{
+				bson.M{"status": models.AdjustmentPending},
+				bson.M{"status": models.AdjustmentRejected, "decided_at": bson.M{"$gt": now.Add(-rejectedCooldown)}},
+			},
+		})
+		if err != nil {
+			log.Printf("Failed to check adjustments of plan %s: %v", plan.ID, err)
+			continue
+		}
+		if busy > 0 {
+			continue
+		}
+
+		if _, adjustment, err := pas.evaluate(&plan, now); err != nil {
+			log.Printf("Failed to evaluate plan %s: %v", plan.ID, err)
+		} else if adjustment != nil {
+			log.Printf("Proposed %s adjustment %s for plan %s", adjustment.Kind, adjustment.ID, plan.ID)
+		}
+	}
+
+	return cursor.Err()
+}
@@ -0,0 +1,153 @@
+package services
+
+import (
+	"fmt"
+	"testing"
+
+	"neuro-guide-go-service/models"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// adjustmentPlan returns a plan with a required 30 minute breathing task and a
+// required 10 minute journaling task on every day
+func adjustmentPlan(days int) *models.PracticePlan {
+	plan := &models.PracticePlan{ID: "plan-1", UserID: "user-1", Title: "正念练习", Days: days, Version: 1}
+	for day := 1; day <= days; day++ {
+		plan.Tasks = append(plan.Tasks,
+			models.PlanTask{ID: fmt.Sprintf("b%d", day), Day: day, Title: "腹式呼吸", DurationMinutes: 30},
+			models.PlanTask{ID: fmt.Sprintf("j%d", day), Day: day, Title: "情绪日记", DurationMinutes: 10},
+		)
+	}
+	return plan
+}
+
+func record(day int, reflection string, tasks ...string) *models.PracticeRecord {
+	return &models.PracticeRecord{Day: day, CompletedTasks: tasks, Reflection: reflection}
+}
+
+func TestReflectionSentiment(t *testing.T) {
+	score, ok := reflectionSentiment("今天练习后很平静，也更专注了")
+	assert.True(t, ok)
+	assert.Equal(t, 1.0, score)
+
+	score, ok = reflectionSentiment("工作压力大，太累了，但呼吸后放松一些")
+	assert.True(t, ok)
+	assert.InDelta(t, -1.0/3, score, 0.001)
+
+	_, ok = reflectionSentiment("完成了")
+	assert.False(t, ok)
+}
+
+func TestLastEvaluatedDay(t *testing.T) {
+	plan := adjustmentPlan(10)
+
+	assert.Equal(t, 0, lastEvaluatedDay(plan, PlanDay{Status: PlanDayNotStarted}))
+	assert.Equal(t, 3, lastEvaluatedDay(plan, PlanDay{Day: 4, Status: PlanDayActive}))
+	assert.Equal(t, 4, lastEvaluatedDay(plan, PlanDay{Day: 4, Status: PlanDayRest}))
+	assert.Equal(t, 4, lastEvaluatedDay(plan, PlanDay{Day: 4, Status: PlanDayPaused}))
+	assert.Equal(t, 10, lastEvaluatedDay(plan, PlanDay{Day: 10, Status: PlanDayFinished}))
+}
+
+func TestEvaluateAdherence(t *testing.T) {
+	plan := adjustmentPlan(14)
+	records := []*models.PracticeRecord{
+		record(1, "", "b1", "j1"), // outside the window
+		record(3, "", "b3", "j3"),
+		record(4, "很放松", "b4"),
+		record(5, "", "b5"),
+		record(5, "太累了"), // several records on one day are combined
+		record(6, ""),
+	}
+
+	adherence := EvaluateAdherence(plan, records, 9)
+	assert.Equal(t, 3, adherence.FromDay)
+	assert.Equal(t, 9, adherence.ToDay)
+	assert.InDelta(t, 4.0/14, adherence.CompletionRate, 0.001)
+	assert.Equal(t, 0, adherence.CompletedStreak)
+	assert.Equal(t, 4, adherence.MissedStreak)
+	assert.Equal(t, 2, adherence.Reflections)
+	assert.Equal(t, 0.0, adherence.Sentiment)
+	assert.Equal(t, []models.SkippedTask{
+		{Title: "腹式呼吸", Scheduled: 7, Missed: 4},
+		{Title: "情绪日记", Scheduled: 7, Missed: 6},
+	}, adherence.SkippedTasks)
+}
+
+func TestEvaluateAdherenceBeforeFirstDay(t *testing.T) {
+	adherence := EvaluateAdherence(adjustmentPlan(7), nil, 0)
+	assert.Equal(t, 0.0, adherence.CompletionRate)
+	assert.Empty(t, adherence.SkippedTasks)
+}
+
+func TestProposeAdjustmentLighten(t *testing.T) {
+	plan := adjustmentPlan(10)
+	records := []*models.PracticeRecord{record(1, "", "b1"), record(2, "", "b2")}
+	adherence := EvaluateAdherence(plan, records, 5)
+
+	kind, reason, tasks, ok := ProposeAdjustment(plan, adherence)
+	assert.True(t, ok)
+	assert.Equal(t, models.AdjustmentLighten, kind)
+	assert.Contains(t, reason, "最近3天")
+
+	// Past days are not changed
+	assert.Equal(t, plan.Tasks[:10], tasks[:10])
+
+	// Both tasks were skipped often, so the first one of each day stays required
+	for _, task := range tasks[10:] {
+		if task.Title == "腹式呼吸" {
+			assert.Equal(t, 20, task.DurationMinutes)
+			assert.False(t, task.Optional)
+		} else {
+			assert.Equal(t, 5, task.DurationMinutes)
+			assert.True(t, task.Optional)
+		}
+	}
+
+	// The plan itself is not changed
+	assert.Equal(t, 30, plan.Tasks[10].DurationMinutes)
+	assert.False(t, plan.Tasks[11].Optional)
+}
+
+func TestProposeAdjustmentProgress(t *testing.T) {
+	plan := adjustmentPlan(10)
+	var records []*models.PracticeRecord
+	for day := 1; day <= 5; day++ {
+		records = append(records, record(day, "很平静", fmt.Sprintf("b%d", day), fmt.Sprintf("j%d", day)))
+	}
+	adherence := EvaluateAdherence(plan, records, 5)
+
+	kind, _, tasks, ok := ProposeAdjustment(plan, adherence)
+	assert.True(t, ok)
+	assert.Equal(t, models.AdjustmentProgress, kind)
+	assert.Equal(t, 30, tasks[8].DurationMinutes)
+	assert.Equal(t, 40, tasks[10].DurationMinutes)
+	assert.Equal(t, 15, tasks[11].DurationMinutes)
+
+	// A negative mood holds back progression
+	for _, r := range records[2:] {
+		r.Reflection = "太累了"
+	}
+	adherence = EvaluateAdherence(plan, records, 5)
+	_, _, _, ok = ProposeAdjustment(plan, adherence)
+	assert.False(t, ok)
+}
+
+func TestProposeAdjustmentNeedsEnoughDays(t *testing.T) {
+	plan := adjustmentPlan(10)
+	adherence := EvaluateAdherence(plan, nil, 2)
+
+	_, _, _, ok := ProposeAdjustment(plan, adherence)
+	assert.False(t, ok)
+
+	// Nothing is left to adjust after the last day
+	adherence = EvaluateAdherence(plan, nil, 10)
+	_, _, _, ok = ProposeAdjustment(plan, adherence)
+	assert.False(t, ok)
+}
+
+func TestRoundToFive(t *testing.T) {
+	assert.Equal(t, 20, roundToFive(20, true))
+	assert.Equal(t, 25, roundToFive(21, true))
+	assert.Equal(t, 20, roundToFive(24, false))
+}
@@ -0,0 +1,398 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"neuro-guide-go-service/config"
+	"neuro-guide-go-service/database"
+	"neuro-guide-go-service/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// planGenerationMode is the routing mode of plan generation requests
+const planGenerationMode = "plan"
+
+// MaxPlanDays is the longest practice plan that can be generated
+const MaxPlanDays = 90
+
+// planPreviewTTL is how long a generated plan can be accepted
+const planPreviewTTL = 24 * time.Hour
+
+// ErrInvalidPlanRequest is returned for a generation request without a goal or with too many days
+var ErrInvalidPlanRequest = errors.New("invalid plan request")
+
+// ErrPlanPreviewNotFound is returned when a preview does not exist, expired or belongs to another user
+var ErrPlanPreviewNotFound = errors.New("plan preview not found")
+
+// PlanValidationError is returned when a generated plan does not match the plan model
+type PlanValidationError struct {
+	Problems []string
+}
+
+func (e *PlanValidationError) Error() string {
+	return "invalid practice plan: " + strings.Join(e.Problems, "; ")
+}
+
+// PlanGenerateRequest represents a plan generation request to Python AI service
+type PlanGenerateRequest struct {
+	UserID   string          `json:"user_id"`
+	Goal     string          `json:"goal"`
+	Days     int             `json:"days"`
+	Emotions []EmotionSample `json:"emotions,omitempty"`
+	Summary  string          `json:"summary,omitempty"`
+}
+
+// PlanGenerateResponse represents a generated plan from Python AI service
+type PlanGenerateResponse struct {
+	Title string            `json:"title"`
+	Days  int               `json:"days"`
+	Tasks []models.PlanTask `json:"tasks"`
+	Usage *TokenUsage       `json:"usage,omitempty"`
+}
+
+// PlanGeneratorService generates practice plans with the AI service.
+// Generated plans are stored as previews until the user accepts them.
+type PlanGeneratorService struct {
+	previews    *mongo.Collection
+	messages    *mongo.Collection
+	plans       *PracticePlanService
+	httpClient  *http.Client
+	router      *AIRouter
+	userService *UserService
+	redactor    *PIIRedactor
+	usage       *UsageService
+	summaries   *SummaryService
+}
+
+// NewPlanGeneratorService creates a new instance of PlanGeneratorService
+func NewPlanGeneratorService(cfg *config.Config) *PlanGeneratorService {
+	return &PlanGeneratorService{
+		previews: database.Database.Collection("plan_previews"),
+		messages: database.Database.Collection("chat_messages"),
+		plans:    NewPracticePlanService(cfg),
+		httpClient: &http.Client{
+			Timeout: 60 * time.Second,
+		},
+		router:      NewAIRouter(cfg),
+		userService: NewUserService(),
+		redactor:    newConfiguredPIIRedactor(cfg.PIIDetectors, cfg.PIIRestoreDetectors),
+		usage:       NewUsageService(cfg),
+		summaries:   NewSummaryService(cfg),
+	}
+}
+
+// GeneratePreview asks the AI service for a plan towards the user's goal and stores it as a preview
+func (pgs *PlanGeneratorService) GeneratePreview(userID, goal string, days int) (*models.PlanPreview, error) {
+	goal = strings.TrimSpace(goal)
+	if goal == "" {
+		return nil, fmt.Errorf("%w: goal is required", ErrInvalidPlanRequest)
+	}
+	if days < 1 || days > MaxPlanDays {
+		return nil, fmt.Errorf("%w: days must be between 1 and %d", ErrInvalidPlanRequest, MaxPlanDays)
+	}
+
+	// Enforce the daily request and token quotas
+	if err := pgs.usage.CheckQuota(userID); err != nil {
+		return nil, err
+	}
+
+	// Replace personal information with placeholders before it leaves the service
+	redacted := pgs.redactor.Redact(goal)
+	redactions := redacted.Redactions
+	reqBody := PlanGenerateRequest{
+		UserID: userID,
+		Goal:   redacted.Text,
+		Days:   days,
+	}
+
+	if emotions, err := loadRecentEmotions(pgs.messages, userID, time.Now()); err != nil {
+		log.Printf("Failed to load recent emotions for %s: %v", userID, err)
+	} else {
+		reqBody.Emotions = emotions
+	}
+
+	if summary, err := pgs.summaries.GetLatestSummary(userID); err != nil {
+		log.Printf("Failed to load conversation summary for %s: %v", userID, err)
+	} else if summary != nil {
+		result := pgs.redactor.redactWith(summary.Summary, redactions)
+		reqBody.Summary, redactions = result.Text, result.Redactions
+	}
+
+	target := pgs.router.Route(userID, planGenerationMode, userCohort(pgs.userService, userID))
+	planResp, err := pgs.callPlanAgent(target.URL, &reqBody)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := pgs.usage.RecordUsage(userID, planResp.Usage); err != nil {
+		log.Printf("Failed to record usage for %s: %v", userID, err)
+	}
+
+	plan := &models.PracticePlan{
+		UserID: userID,
+		Title:  strings.TrimSpace(planResp.Title),
+		Days:   planResp.Days,
+		Tasks:  planResp.Tasks,
+	}
+
+	// Task IDs are ours to give
+	for i := range plan.Tasks {
+		plan.Tasks[i].ID = ""
+	}
+	assignTaskIDs(plan.Tasks)
+
+	if err := ValidatePlan(plan, days); err != nil {
+		return nil, err
+	}
+
+	// Put back the values the user is allowed to see in the plan
+	plan.Title = pgs.redactor.Restore(plan.Title, redactions)
+	for i := range plan.Tasks {
+		task := &plan.Tasks[i]
+		task.Title = pgs.redactor.Restore(task.Title, redactions)
+		task.Description = pgs.redactor.Restore(task.Description, redactions)
+	}
+
+	now := time.Now()
+	preview := &models.PlanPreview{
+		ID:        primitive.NewObjectID().Hex(),
+		UserID:    userID,
+		Goal:      goal,
+		Title:     plan.Title,
+		Days:      plan.Days,
+		Tasks:     plan.Tasks,
+		Variant:   &target.Assignment,
+		CreatedAt: now,
+		ExpiresAt: now.Add(planPreviewTTL),
+	}
+	if err := pgs.insertPreview(preview); err != nil {
+		return nil, err
+	}
+
+	return preview, nil
+}
+
+// ValidatePlan checks that a generated plan covers the requested number of days
+// and that every task is complete. Days can have several tasks.
+func ValidatePlan(plan *models.PracticePlan, days int) error {
+	var problems []string
+
+	if plan.Title == "" {
+		problems = append(problems, "title is empty")
+	}
+	if plan.Days != days {
+		problems = append(problems, fmt.Sprintf("plan has %d days, %d were requested", plan.Days, days))
+	}
+	if len(plan.Tasks) == 0 {
+		problems = append(problems, "plan has no tasks")
+	}
+
+	covered := make(map[int]bool)
+	for i, task := range plan.Tasks {
+		if task.Day < 1 || task.Day > days {
+			problems = append(problems, fmt.Sprintf("task %d: day %d is outside 1-%d", i+1, task.Day, days))
+		} else if !task.Optional {
+			covered[task.Day] = true
+		}
+		if strings.TrimSpace(task.Title) == "" {
+			problems = append(problems, fmt.Sprintf("task %d: title is empty", i+1))
+		}
+		if strings.TrimSpace(task.Description) == "" {
+			problems = append(problems, fmt.Sprintf("task %d: description is empty", i+1))
+		}
+		if strings.TrimSpace(task.ScientificBasis) == "" {
+			problems = append(problems, fmt.Sprintf("task %d: scientific basis is empty", i+1))
+		}
+	}
+
+	problems = append(problems, taskProblems(plan.Tasks)...)
+
+	if len(plan.Tasks) > 0 {
+		for day := 1; day <= days; day++ {
+			if !covered[day] {
+				problems = append(problems, fmt.Sprintf("day %d has no required task", day))
+			}
+		}
+	}
+
+	if len(problems) > 0 {
+		return &PlanValidationError{Problems: problems}
+	}
+	return nil
+}
+
+// callPlanAgent posts a plan generation request to an AI backend
+func (pgs *PlanGeneratorService) callPlanAgent(baseURL string, reqBody *PlanGenerateRequest) (*PlanGenerateResponse, error) {
+	jsonData, err := json.Marshal(reqBody)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	resp, err := pgs.httpClient.Post(
+		fmt.Sprintf("%s/plan/generate", baseURL),
+		"application/json",
+		bytes.NewBuffer(jsonData),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to call AI service: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("AI service returned error: %s", string(body))
+	}
+
+	var planResp PlanGenerateResponse
+	if err := json.NewDecoder(resp.Body).Decode(&planResp); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+
+	return &planResp, nil
+}
+
+// insertPreview stores a preview with its goal encrypted
+func (pgs *PlanGeneratorService) insertPreview(preview *models.PlanPreview) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	objID, err := primitive.ObjectIDFromHex(preview.ID)
+	if err != nil {
+		return err
+	}
+
+	// Goals describe the user's state and are as sensitive as messages
+	goal, keyVersion, err := encryptField(preview.UserID, preview.Goal)
+	if err != nil {
+		return err
+	}
+
+	doc := bson.M{
+		"_id":        objID,
+		"user_id":    preview.UserID,
+		"goal":       goal,
+		"title":      preview.Title,
+		"days":       preview.Days,
+		"tasks":      preview.Tasks,
+		"variant":    preview.Variant,
+		"created_at": preview.CreatedAt,
+		"expires_at": preview.ExpiresAt,
+	}
+	if keyVersion > 0 {
+		doc["key_version"] = keyVersion
+	}
+
+	_, err = pgs.previews.InsertOne(ctx, doc)
+	return err
+}
+
+// GetPreview retrieves a user's plan preview by ID
+func (pgs *PlanGeneratorService) GetPreview(userID, previewID string) (*models.PlanPreview, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	return pgs.findPreview(ctx, userID, previewID)
+}
+
+// findPreview loads a preview that has not expired yet
+func (pgs *PlanGeneratorService) findPreview(ctx context.Context, userID, previewID string) (*models.PlanPreview, error) {
+	objID, err := primitive.ObjectIDFromHex(previewID)
+	if err != nil {
+		return nil, ErrPlanPreviewNotFound
+	}
+
+	// The TTL monitor runs once a minute, so expired previews can still be there
+	filter := bson.M{"_id": objID, "user_id": userID, "expires_at": bson.M{"$gt": time.Now()}}
+
+	var preview models.PlanPreview
+	err = pgs.previews.FindOne(ctx, filter).Decode(&preview)
+	if err == mongo.ErrNoDocuments {
+		return nil, ErrPlanPreviewNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	preview.Goal, err = decryptField(userID, preview.Goal)
+	if err != nil {
+		return nil, err
+	}
+
+	return &preview, nil
+}
+
+// AcceptPreview turns a preview into a practice plan running on the given schedule and removes the preview
+func (pgs *PlanGeneratorService) AcceptPreview(userID, previewID string, schedule PlanSchedule) (*models.PracticePlan, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var plan *models.PracticePlan
+	err := withTransaction(ctx, func(ctx context.Context) error {
+		preview, err := pgs.findPreview(ctx, userID, previewID)
+		if err != nil {
+			return err
+		}
+
+		plan = preview.Plan()
+		if err := applySchedule(plan, schedule, pgs.plans.location, time.Now()); err != nil {
+			return err
+		}
+		if err := validatePlanContent(plan); err != nil {
+			return err
+		}
+		if err := pgs.plans.insertPlan(ctx, plan); err != nil {
+			return err
+		}
+
+		// A concurrent accept that deleted the preview first wins
+		result, err := pgs.previews.DeleteOne(ctx, bson.M{"_id": messageObjectID(previewID), "user_id": userID})
+		if err != nil {
+			return err
+		}
+		if result.DeletedCount == 0 {
+			return ErrPlanPreviewNotFound
+		}
+		return nil
+	}, func(ctx context.Context) {
+		if plan != nil {
+			pgs.plans.removePlan(ctx, plan.ID)
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return plan, nil
+}
+
+// DiscardPreview deletes a user's plan preview
+func (pgs *PlanGeneratorService) DiscardPreview(userID, previewID string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	objID, err := primitive.ObjectIDFromHex(previewID)
+	if err != nil {
+		return ErrPlanPreviewNotFound
+	}
+
+	result, err := pgs.previews.DeleteOne(ctx, bson.M{"_id": objID, "user_id": userID})
+	if err != nil {
+		return err
+	}
+	if result.DeletedCount == 0 {
+		return ErrPlanPreviewNotFound
+	}
+	return nil
+}
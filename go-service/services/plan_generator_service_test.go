@@ -0,0 +1,77 @@
+package services
+
+import (
+	"errors"
+	"testing"
+
+	"neuro-guide-go-service/models"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func testPlan(days int) *models.PracticePlan {
+	plan := &models.PracticePlan{Title: "7天呼吸练习", Days: days}
+	for day := 1; day <= days; day++ {
+		plan.Tasks = append(plan.Tasks, models.PlanTask{
+			Day:             day,
+			Title:           "腹式呼吸",
+			Description:     "每次5分钟",
+			ScientificBasis: "慢呼吸激活副交感神经",
+		})
+	}
+	return plan
+}
+
+func TestValidatePlan(t *testing.T) {
+	assert.NoError(t, ValidatePlan(testPlan(3), 3))
+
+	// Several tasks on one day are allowed
+	plan := testPlan(2)
+	plan.Tasks = append(plan.Tasks, plan.Tasks[0])
+	assert.NoError(t, ValidatePlan(plan, 2))
+}
+
+func TestValidatePlanReportsProblems(t *testing.T) {
+	plan := testPlan(3)
+	plan.Title = ""
+	plan.Tasks[1].ScientificBasis = " "
+	plan.Tasks[2].Day = 5
+
+	err := ValidatePlan(plan, 3)
+	var validationErr *PlanValidationError
+	assert.True(t, errors.As(err, &validationErr))
+	assert.Equal(t, []string{
+		"title is empty",
+		"task 2: scientific basis is empty",
+		"task 3: day 5 is outside 1-3",
+		"day 3 has no required task",
+	}, validationErr.Problems)
+}
+
+func TestValidatePlanDayCount(t *testing.T) {
+	err := ValidatePlan(testPlan(5), 7)
+	var validationErr *PlanValidationError
+	assert.True(t, errors.As(err, &validationErr))
+	assert.Contains(t, validationErr.Problems, "plan has 5 days, 7 were requested")
+	assert.Contains(t, validationErr.Problems, "day 6 has no required task")
+
+	err = ValidatePlan(&models.PracticePlan{Title: "空计划", Days: 3}, 3)
+	assert.True(t, errors.As(err, &validationErr))
+	assert.Equal(t, []string{"plan has no tasks"}, validationErr.Problems)
+}
+
+func TestPlanPreviewPlan(t *testing.T) {
+	preview := &models.PlanPreview{
+		UserID:  "user1",
+		Title:   "计划",
+		Days:    1,
+		Tasks:   testPlan(1).Tasks,
+		Variant: &models.AIAssignment{Backend: "default"},
+	}
+
+	plan := preview.Plan()
+	assert.Equal(t, models.PlanSourceGenerated, plan.Source)
+	assert.Equal(t, "user1", plan.UserID)
+	assert.Equal(t, preview.Tasks, plan.Tasks)
+	assert.Equal(t, "default", plan.Variant.Backend)
+}
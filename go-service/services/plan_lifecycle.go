@@ -0,0 +1,438 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"neuro-guide-go-service/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// What happens to the practice records of a deleted plan
+const (
+	DeletedPlanRecordsKeep  = "keep"  // records stay visible, also after the plan is purged
+	DeletedPlanRecordsHide  = "hide"  // records are hidden while the plan is deleted and stay hidden after it is purged
+	DeletedPlanRecordsPurge = "purge" // records are hidden while the plan is deleted and purged with it
+)
+
+// trashPurgeInterval is how often deleted plans past their retention are purged
+const trashPurgeInterval = time.Hour
+
+// ErrInvalidPlanTransition is returned when a plan cannot move to the requested status
+var ErrInvalidPlanTransition = errors.New("invalid plan status transition")
+
+// ErrPlanNotEditable is returned when changing a plan that completed, was abandoned or archived
+var ErrPlanNotEditable = errors.New("plan can only be changed while it is a draft, active or paused")
+
+// ErrPlanStatusConflict is returned when a plan's status changed while it was being updated
+var ErrPlanStatusConflict = errors.New("plan status was changed concurrently")
+
+// planTransitions lists the statuses each status can move to. Archived plans return to
+// the status they were archived from.
+var planTransitions = map[string][]string{
+	models.PlanStatusDraft:     {models.PlanStatusActive, models.PlanStatusAbandoned},
+	models.PlanStatusActive:    {models.PlanStatusPaused, models.PlanStatusCompleted, models.PlanStatusAbandoned},
+	models.PlanStatusPaused:    {models.PlanStatusActive, models.PlanStatusAbandoned},
+	models.PlanStatusCompleted: {models.PlanStatusArchived},
+	models.PlanStatusAbandoned: {models.PlanStatusArchived},
+	models.PlanStatusArchived:  {models.PlanStatusCompleted, models.PlanStatusAbandoned},
+}
+
+// CanTransition reports whether a plan can move from one status to another
+func CanTransition(from, to string) bool {
+	for _, status := range planTransitions[from] {
+		if status == to {
+			return true
+		}
+	}
+	return false
+}
+
+// isEditable reports whether the content and schedule of a plan can be changed
+func isEditable(status string) bool {
+	switch status {
+	case models.PlanStatusDraft, models.PlanStatusActive, models.PlanStatusPaused:
+		return true
+	}
+	return false
+}
+
+// isRunning reports whether a plan's days are counting
+func isRunning(status string) bool {
+	return status == models.PlanStatusActive || status == models.PlanStatusPaused
+}
+
+// setStatus moves a plan to a status and records the transition
+func setStatus(plan *models.PracticePlan, to string, at time.Time) {
+	plan.StatusHistory = append(plan.StatusHistory, models.PlanStatusChange{From: plan.Status, To: to, At: at})
+	plan.Status = to
+}
+
+// completionTime returns when a plan's last day ended: midnight after it in the plan's time zone
+func completionTime(plan *models.PracticePlan, fallback *time.Location, now time.Time) time.Time {
+	if last, ok := PlanDates(plan, fallback)[plan.Days]; ok {
+		return last.AddDate(0, 0, 1)
+	}
+	return now
+}
+
+// settleStatus brings the status of a plan up to date. Plans created before lifecycle
+// statuses get one, and active plans complete once their last day has passed.
+// It reports whether the status changed.
+func settleStatus(plan *models.PracticePlan, now time.Time, fallback *time.Location) bool {
+	changed := false
+
+	if plan.Status == "" {
+		setStatus(plan, models.PlanStatusActive, plan.CreatedAt)
+		if plan.IsPaused() {
+			loc := planLocation(plan, fallback)
+			pausedAt, err := time.ParseInLocation(planDateLayout, plan.Pauses[len(plan.Pauses)-1].From, loc)
+			if err != nil {
+				pausedAt = now
+			}
+			setStatus(plan, models.PlanStatusPaused, pausedAt)
+		}
+		changed = true
+	}
+
+	if plan.Status == models.PlanStatusActive && PlanDayAt(plan, now, fallback).Status == PlanDayFinished {
+		setStatus(plan, models.PlanStatusCompleted, completionTime(plan, fallback, now))
+		changed = true
+	}
+
+	return changed
+}
+
+// statusFilter matches a plan whose stored status is still status
+func statusFilter(planID, status string) bson.M {
+	filter := bson.M{"_id": messageObjectID(planID), "deleted_at": bson.M{"$exists": false}}
+	if status == "" {
+		filter["status"] = bson.M{"$exists": false}
+	} else {
+		filter["status"] = status
+	}
+	return filter
+}
+
+// runningPlanFilter matches plans that are not deleted and whose days may be counting.
+// Plans created before lifecycle statuses have no status and are included.
+func runningPlanFilter() bson.M {
+	return bson.M{
+		"deleted_at": bson.M{"$exists": false},
+		"status": bson.M{"$nin": bson.A{
+			models.PlanStatusDraft, models.PlanStatusCompleted, models.PlanStatusAbandoned, models.PlanStatusArchived,
+		}},
+	}
+}
+
+// saveStatus stores the status of a plan, along with set, if its stored status is still stored
+func (pps *PracticePlanService) saveStatus(ctx context.Context, plan *models.PracticePlan, stored string, set bson.M) error {
+	if set == nil {
+		set = bson.M{}
+	}
+	set["status"] = plan.Status
+	set["status_history"] = plan.StatusHistory
+
+	result, err := pps.collection.UpdateOne(ctx, statusFilter(plan.ID, stored), bson.M{"$set": set})
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return ErrPlanStatusConflict
+	}
+	return nil
+}
+
+// settle brings the status of a loaded plan up to date and stores it. A failed write is
+// only logged, because the plan is settled again the next time it is loaded.
+func (pps *PracticePlanService) settle(ctx context.Context, plan *models.PracticePlan, now time.Time) {
+	stored := plan.Status
+	if !settleStatus(plan, now, pps.location) {
+		return
+	}
+	if err := pps.saveStatus(ctx, plan, stored, nil); err != nil {
+		log.Printf("Failed to update status of plan %s: %v", plan.ID, err)
+	}
+}
+
+// changeStatus moves a user's plan to another status. change may adjust the plan and
+// add fields to store with the new status.
+func (pps *PracticePlanService) changeStatus(userID, planID, to string, now time.Time, change func(plan *models.PracticePlan, set bson.M)) (*models.PracticePlan, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	plan, err := pps.findPlan(ctx, userID, planID)
+	if err != nil {
+		return nil, err
+	}
+	if !CanTransition(plan.Status, to) {
+		return nil, fmt.Errorf("%w: %s to %s", ErrInvalidPlanTransition, plan.Status, to)
+	}
+
+	stored := plan.Status
+	set := bson.M{}
+	if change != nil {
+		change(plan, set)
+	}
+	setStatus(plan, to, now)
+	if err := pps.saveStatus(ctx, plan, stored, set); err != nil {
+		return nil, err
+	}
+
+	plan.Version = plan.CurrentVersion()
+	return plan, nil
+}
+
+// ActivatePlan starts a draft plan. A start date that has already passed moves to today.
+func (pps *PracticePlanService) ActivatePlan(userID, planID string, now time.Time) (*models.PracticePlan, error) {
+	return pps.changeStatus(userID, planID, models.PlanStatusActive, now, func(plan *models.PracticePlan, set bson.M) {
+		loc := planLocation(plan, pps.location)
+		if today := localDate(now, loc); planStart(plan, loc).Before(today) {
+			plan.StartDate = today.Format(planDateLayout)
+			set["start_date"] = plan.StartDate
+		}
+	})
+}
+
+// AbandonPlan gives up a draft, active or paused plan
+func (pps *PracticePlanService) AbandonPlan(userID, planID string, now time.Time) (*models.PracticePlan, error) {
+	return pps.changeStatus(userID, planID, models.PlanStatusAbandoned, now, nil)
+}
+
+// ArchivePlan puts away a completed or abandoned plan
+func (pps *PracticePlanService) ArchivePlan(userID, planID string, now time.Time) (*models.PracticePlan, error) {
+	return pps.changeStatus(userID, planID, models.PlanStatusArchived, now, nil)
+}
+
+// UnarchivePlan returns an archived plan to the status it was archived from
+func (pps *PracticePlanService) UnarchivePlan(userID, planID string, now time.Time) (*models.PracticePlan, error) {
+	plan, err := pps.GetPlan(userID, planID)
+	if err != nil {
+		return nil, err
+	}
+	if plan.Status != models.PlanStatusArchived {
+		return nil, fmt.Errorf("%w: %s is not archived", ErrInvalidPlanTransition, plan.Status)
+	}
+
+	to := models.PlanStatusCompleted
+	for i := len(plan.StatusHistory) - 1; i >= 0; i-- {
+		if change := plan.StatusHistory[i]; change.To == models.PlanStatusArchived {
+			to = change.From
+			break
+		}
+	}
+	return pps.changeStatus(userID, planID, to, now, nil)
+}
+
+// FilterPlansByStatus returns the plans with one of the statuses
+func FilterPlansByStatus(plans []*models.PracticePlan, statuses []string) []*models.PracticePlan {
+	wanted := make(map[string]bool, len(statuses))
+	for _, status := range statuses {
+		wanted[status] = true
+	}
+
+	filtered := []*models.PracticePlan{}
+	for _, plan := range plans {
+		if wanted[plan.Status] {
+			filtered = append(filtered, plan)
+		}
+	}
+	return filtered
+}
+
+// DeletePlan moves a user's plan to the trash. Depending on the records policy its practice
+// records are hidden until the plan is restored. The plan is purged after the trash retention.
+func (pps *PracticePlanService) DeletePlan(userID, id string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return ErrPlanNotFound
+	}
+
+	now := time.Now()
+	result, err := pps.collection.UpdateOne(ctx,
+		bson.M{"_id": objID, "user_id": userID, "deleted_at": bson.M{"$exists": false}},
+		bson.M{"$set": bson.M{"deleted_at": now}})
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return ErrPlanNotFound
+	}
+
+	if pps.recordPolicy == DeletedPlanRecordsKeep {
+		return nil
+	}
+	_, err = pps.records.UpdateMany(ctx, bson.M{"user_id": userID, "plan_id": id},
+		bson.M{"$set": bson.M{"plan_deleted_at": now}})
+	return err
+}
+
+// RestorePlan takes a user's plan out of the trash and shows its practice records again
+func (pps *PracticePlanService) RestorePlan(userID, id string) (*models.PracticePlan, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, ErrPlanNotFound
+	}
+
+	result, err := pps.collection.UpdateOne(ctx,
+		bson.M{"_id": objID, "user_id": userID, "deleted_at": bson.M{"$exists": true}},
+		bson.M{"$unset": bson.M{"deleted_at": ""}})
+	if err != nil {
+		return nil, err
+	}
+	if result.MatchedCount == 0 {
+		return nil, ErrPlanNotFound
+	}
+
+	if _, err := pps.records.UpdateMany(ctx, bson.M{"user_id": userID, "plan_id": id},
+		bson.M{"$unset": bson.M{"plan_deleted_at": ""}}); err != nil {
+		return nil, err
+	}
+
+	return pps.GetPlan(userID, id)
+}
+
+// GetDeletedPlans returns the plans in a user's trash, most recently deleted first
+func (pps *PracticePlanService) GetDeletedPlans(userID string) ([]*models.PracticePlan, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	opts := options.Find().SetSort(bson.M{"deleted_at": -1})
+	cursor, err := pps.collection.Find(ctx, bson.M{"user_id": userID, "deleted_at": bson.M{"$exists": true}}, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	plans := []*models.PracticePlan{}
+	if err := cursor.All(ctx, &plans); err != nil {
+		return nil, err
+	}
+
+	for _, plan := range plans {
+		plan.Version = plan.CurrentVersion()
+		fillLegacyTaskIDs(plan.Tasks)
+	}
+	return plans, nil
+}
+
+// PurgePlan permanently deletes a user's plan, whether or not it is in the trash
+func (pps *PracticePlanService) PurgePlan(userID, id string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return ErrPlanNotFound
+	}
+
+	count, err := pps.collection.CountDocuments(ctx, bson.M{"_id": objID, "user_id": userID})
+	if err != nil {
+		return err
+	}
+	if count == 0 {
+		return ErrPlanNotFound
+	}
+
+	return pps.purgePlan(ctx, userID, id)
+}
+
+// purgePlan deletes a plan with its versions, share links and adjustments, and
+// applies the records policy to its practice records
+func (pps *PracticePlanService) purgePlan(ctx context.Context, userID, id string) error {
+	if _, err := pps.collection.DeleteOne(ctx, bson.M{"_id": messageObjectID(id), "user_id": userID}); err != nil {
+		return err
+	}
+
+	if _, err := pps.versions.DeleteMany(ctx, bson.M{"plan_id": id, "user_id": userID}); err != nil {
+		return err
+	}
+
+	// Share links of a purged plan stop working
+	if _, err := pps.shares.DeleteMany(ctx, bson.M{"plan_id": id, "user_id": userID}); err != nil {
+		return err
+	}
+
+	if _, err := pps.adjustments.DeleteMany(ctx, bson.M{"plan_id": id, "user_id": userID}); err != nil {
+		return err
+	}
+
+	records := bson.M{"user_id": userID, "plan_id": id}
+	var err error
+	switch pps.recordPolicy {
+	case DeletedPlanRecordsPurge:
+		_, err = pps.records.DeleteMany(ctx, records)
+	case DeletedPlanRecordsHide:
+		// Plans purged without going through the trash hide their records here
+		records["plan_deleted_at"] = bson.M{"$exists": false}
+		_, err = pps.records.UpdateMany(ctx, records, bson.M{"$set": bson.M{"plan_deleted_at": time.Now()}})
+	}
+	return err
+}
+
+// StartTrashPurger periodically purges plans that have been in the trash longer than retention
+func (pps *PracticePlanService) StartTrashPurger(retention time.Duration) {
+	go func() {
+		ticker := time.NewTicker(trashPurgeInterval)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			if err := pps.purgeTrash(time.Now().Add(-retention)); err != nil {
+				log.Printf("Plan trash purge failed: %v", err)
+			}
+		}
+	}()
+}
+
+// purgeTrash purges the plans deleted before cutoff
+func (pps *PracticePlanService) purgeTrash(cutoff time.Time) error {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	opts := options.Find().SetProjection(bson.M{"user_id": 1})
+	cursor, err := pps.collection.Find(ctx, bson.M{"deleted_at": bson.M{"$lt": cutoff}}, opts)
+	if err != nil {
+		return err
+	}
+
+	var plans []struct {
+		ID     primitive.ObjectID `bson:"_id"`
+		UserID string             `bson:"user_id"`
+	}
+	if err := cursor.All(ctx, &plans); err != nil {
+		return err
+	}
+
+	for _, plan := range plans {
+		if err := pps.purgePlan(ctx, plan.UserID, plan.ID.Hex()); err != nil {
+			log.Printf("Failed to purge plan %s: %v", plan.ID.Hex(), err)
+			continue
+		}
+		log.Printf("Purged deleted plan %s", plan.ID.Hex())
+	}
+	return nil
+}
+
+// deletedPlanRecords returns the configured records policy, hiding records by default
+func deletedPlanRecords(policy string) string {
+	switch policy {
+	case DeletedPlanRecordsKeep, DeletedPlanRecordsPurge:
+		return policy
+	case "", DeletedPlanRecordsHide:
+		return DeletedPlanRecordsHide
+	}
+	log.Printf("Unknown deleted plan records policy %q, hiding records", policy)
+	return DeletedPlanRecordsHide
+}
@@ -0,0 +1,95 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"neuro-guide-go-service/models"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCanTransition(t *testing.T) {
+	assert.True(t, CanTransition(models.PlanStatusDraft, models.PlanStatusActive))
+	assert.True(t, CanTransition(models.PlanStatusActive, models.PlanStatusPaused))
+	assert.True(t, CanTransition(models.PlanStatusPaused, models.PlanStatusAbandoned))
+	assert.True(t, CanTransition(models.PlanStatusCompleted, models.PlanStatusArchived))
+	assert.True(t, CanTransition(models.PlanStatusArchived, models.PlanStatusAbandoned))
+
+	assert.False(t, CanTransition(models.PlanStatusDraft, models.PlanStatusPaused))
+	assert.False(t, CanTransition(models.PlanStatusActive, models.PlanStatusArchived)) // finish or abandon first
+	assert.False(t, CanTransition(models.PlanStatusCompleted, models.PlanStatusActive))
+	assert.False(t, CanTransition(models.PlanStatusAbandoned, models.PlanStatusActive))
+	assert.False(t, CanTransition("", models.PlanStatusActive))
+}
+
+func TestSettleStatusCompletesFinishedPlans(t *testing.T) {
+	loc := time.FixedZone("CST", 8*3600)
+	start := time.Date(2024, 5, 6, 0, 0, 0, 0, loc)
+	plan := &models.PracticePlan{
+		Days:          2,
+		StartDate:     "2024-05-06",
+		Status:        models.PlanStatusActive,
+		StatusHistory: []models.PlanStatusChange{{To: models.PlanStatusActive, At: start}},
+	}
+
+	// Still on its last day
+	assert.False(t, settleStatus(plan, time.Date(2024, 5, 7, 23, 0, 0, 0, loc), loc))
+	assert.Equal(t, models.PlanStatusActive, plan.Status)
+
+	// Completed at midnight after the last day, however late it is noticed
+	assert.True(t, settleStatus(plan, time.Date(2024, 6, 1, 12, 0, 0, 0, loc), loc))
+	assert.Equal(t, models.PlanStatusCompleted, plan.Status)
+	assert.Equal(t, models.PlanStatusChange{
+		From: models.PlanStatusActive,
+		To:   models.PlanStatusCompleted,
+		At:   time.Date(2024, 5, 8, 0, 0, 0, 0, loc),
+	}, plan.StatusHistory[1])
+
+	assert.False(t, settleStatus(plan, time.Date(2024, 6, 2, 12, 0, 0, 0, loc), loc))
+	assert.Len(t, plan.StatusHistory, 2)
+}
+
+func TestSettleStatusOfLegacyPlans(t *testing.T) {
+	loc := time.FixedZone("CST", 8*3600)
+	created := time.Date(2024, 5, 6, 9, 0, 0, 0, loc)
+	now := time.Date(2024, 5, 9, 12, 0, 0, 0, loc)
+
+	running := &models.PracticePlan{Days: 7, StartDate: "2024-05-06", CreatedAt: created}
+	assert.True(t, settleStatus(running, now, loc))
+	assert.Equal(t, models.PlanStatusActive, running.Status)
+	assert.Equal(t, []models.PlanStatusChange{{To: models.PlanStatusActive, At: created}}, running.StatusHistory)
+
+	paused := &models.PracticePlan{Days: 7, StartDate: "2024-05-06", CreatedAt: created,
+		Pauses: []models.PlanPause{{From: "2024-05-08"}}}
+	assert.True(t, settleStatus(paused, now, loc))
+	assert.Equal(t, models.PlanStatusPaused, paused.Status)
+	assert.Equal(t, time.Date(2024, 5, 8, 0, 0, 0, 0, loc), paused.StatusHistory[1].At)
+
+	// A paused plan does not complete, since its days are not counting
+	assert.False(t, settleStatus(paused, now.AddDate(0, 1, 0), loc))
+
+	finished := &models.PracticePlan{Days: 1, StartDate: "2024-05-06", CreatedAt: created}
+	assert.True(t, settleStatus(finished, now, loc))
+	assert.Equal(t, models.PlanStatusCompleted, finished.Status)
+	assert.Len(t, finished.StatusHistory, 2)
+}
+
+func TestFilterPlansByStatus(t *testing.T) {
+	plans := []*models.PracticePlan{
+		{ID: "a", Status: models.PlanStatusActive},
+		{ID: "b", Status: models.PlanStatusArchived},
+		{ID: "c", Status: models.PlanStatusCompleted},
+	}
+
+	filtered := FilterPlansByStatus(plans, []string{models.PlanStatusActive, models.PlanStatusCompleted})
+	assert.Equal(t, []*models.PracticePlan{plans[0], plans[2]}, filtered)
+	assert.Empty(t, FilterPlansByStatus(plans, nil))
+}
+
+func TestDeletedPlanRecords(t *testing.T) {
+	assert.Equal(t, DeletedPlanRecordsHide, deletedPlanRecords(""))
+	assert.Equal(t, DeletedPlanRecordsKeep, deletedPlanRecords("keep"))
+	assert.Equal(t, DeletedPlanRecordsPurge, deletedPlanRecords("purge"))
+	assert.Equal(t, DeletedPlanRecordsHide, deletedPlanRecords("shred"))
+}
@@ -0,0 +1,460 @@
+package services
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"neuro-guide-go-service/models"
+)
+
+// The Markdown plan format of the requirements document, extended with day sections:
+//
+//	[神经可塑性训练]
+//	▶ 天数：7
+//	▶ 每日任务：
+//	  - 早晨10分钟正念呼吸（增强前额叶-杏仁核连接）
+//	    说明：坐姿，关注呼吸
+//	    类型：呼吸
+//	▶ 第3天：
+//	  - 选做：睡前感恩日记
+//	    依据：提升血清素水平
+//	▶ 科学依据：Davidson, 2003 fMRI研究证实...
+//
+// A task line starts with an optional 选做： marker, time of day and duration, and may end
+// with its scientific basis in parentheses, or give it on a 依据： line. The plan-level
+// 科学依据 is used for tasks without one. A backslash marks where the title starts, or
+// escapes a final parenthesis that belongs to the title, e.g. 早晨\早上好冥想\（4-7-8）.
+// Without ▶ 天数 a plan lasts until its last day section, or 7 days.
+
+// PlanMarkdownLineError is a problem on one line of a Markdown plan. Line 0 means the whole plan.
+type PlanMarkdownLineError struct {
+	Line    int    `json:"line"`
+	Message string `json:"message"`
+}
+
+// PlanMarkdownError lists the problems found in a Markdown plan
+type PlanMarkdownError struct {
+	Errors []PlanMarkdownLineError
+}
+
+func (e *PlanMarkdownError) Error() string {
+	messages := make([]string, len(e.Errors))
+	for i, lineErr := range e.Errors {
+		messages[i] = fmt.Sprintf("line %d: %s", lineErr.Line, lineErr.Message)
+	}
+	return "invalid plan markdown: " + strings.Join(messages, "; ")
+}
+
+const (
+	markdownSectionMarker = "▶"
+	markdownOptional      = "选做："
+	markdownEscape        = `\`
+	defaultMarkdownDays   = 7 // 只有每日任务且未写天数时的计划天数
+)
+
+var (
+	markdownTitlePattern   = regexp.MustCompile(`^\[(.*)\]$`)
+	markdownDayPattern     = regexp.MustCompile(`^第\s*(\d+)\s*天$`)
+	markdownDurationPrefix = regexp.MustCompile(`^(\d+)\s*分钟`)
+	markdownBasisSuffix    = regexp.MustCompile(`\s*(\\?)[（(]([^（）()]*)[）)]$`)
+	markdownTaskProblem    = regexp.MustCompile(`^task (\d+): (.*)$`)
+)
+
+// Words for the time of day at the start of a task line, in rendering order
+var markdownTimesOfDay = []struct{ word, value string }{
+	{"早晨", models.TimeOfDayMorning},
+	{"早上", models.TimeOfDayMorning},
+	{"上午", models.TimeOfDayMorning},
+	{"下午", models.TimeOfDayAfternoon},
+	{"晚上", models.TimeOfDayEvening},
+	{"傍晚", models.TimeOfDayEvening},
+	{"睡前", models.TimeOfDayBedtime},
+	{"随时", models.TimeOfDayAnytime},
+}
+
+// Chinese names of task types. English values are accepted as well.
+var markdownTaskTypes = map[string]string{
+	models.TaskTypeBreathing:  "呼吸",
+	models.TaskTypeMeditation: "冥想",
+	models.TaskTypeJournaling: "日记",
+	models.TaskTypeExercise:   "运动",
+	models.TaskTypeReading:    "阅读",
+	models.TaskTypeOther:      "其他",
+}
+
+// markdownTask is a parsed task line with the line it came from
+type markdownTask struct {
+	task  models.PlanTask
+	line  int
+	daily bool
+}
+
+// cutMarkdownKey splits "key：value" with a full-width or ASCII colon
+func cutMarkdownKey(line string) (string, string, bool) {
+	for _, sep := range []string{"：", ":"} {
+		if key, value, ok := strings.Cut(line, sep); ok {
+			return strings.TrimSpace(key), strings.TrimSpace(value), true
+		}
+	}
+	return "", "", false
+}
+
+// parseMarkdownTask parses the text of a task line after "- "
+func parseMarkdownTask(text string) models.PlanTask {
+	var task models.PlanTask
+
+	if rest, ok := strings.CutPrefix(text, markdownOptional); ok {
+		task.Optional = true
+		text = strings.TrimSpace(rest)
+	}
+	if m := markdownBasisSuffix.FindStringSubmatchIndex(text); m != nil {
+		if m[2] == m[3] {
+			task.ScientificBasis = strings.TrimSpace(text[m[4]:m[5]])
+			text = strings.TrimSpace(text[:m[0]])
+		} else {
+			// An escaped parenthesis is part of the title
+			text = text[:m[2]] + text[m[3]:]
+		}
+	}
+
+	// Prefixes are read until the escape that marks the start of the title
+	text, escaped := strings.CutPrefix(text, markdownEscape)
+	if !escaped {
+		for _, tod := range markdownTimesOfDay {
+			if rest, ok := strings.CutPrefix(text, tod.word); ok {
+				task.TimeOfDay = tod.value
+				text = strings.TrimSpace(rest)
+				break
+			}
+		}
+		text, escaped = strings.CutPrefix(text, markdownEscape)
+	}
+	if !escaped {
+		if m := markdownDurationPrefix.FindStringSubmatch(text); m != nil {
+			task.DurationMinutes, _ = strconv.Atoi(m[1])
+			text = strings.TrimSpace(text[len(m[0]):])
+		}
+		text, _ = strings.CutPrefix(text, markdownEscape)
+	}
+	task.Title = text
+
+	return task
+}
+
+// escapeMarkdownTitle escapes a task title so parsing its task line gives it back unchanged
+func escapeMarkdownTitle(title string) string {
+	escaped := title
+	if m := markdownBasisSuffix.FindStringSubmatchIndex(title); m != nil && m[2] == m[3] {
+		escaped = title[:m[2]] + markdownEscape + title[m[2]:]
+	}
+	if parseMarkdownTask(escaped).Title != title {
+		escaped = markdownEscape + escaped
+	}
+	return escaped
+}
+
+// parseMarkdownTaskType accepts a task type by its value or Chinese name
+func parseMarkdownTaskType(name string) (string, bool) {
+	for value, label := range markdownTaskTypes {
+		if name == value || name == label {
+			return value, true
+		}
+	}
+	return "", false
+}
+
+// ParsePlanMarkdown parses a plan in the Markdown plan format. Tasks get no IDs.
+// All problems are returned together as a *PlanMarkdownError.
+func ParsePlanMarkdown(text string) (*models.PracticePlan, error) {
+	plan, _, err := parsePlanMarkdown(text)
+	return plan, err
+}
+
+// parsePlanMarkdown parses a Markdown plan and returns the line of each of its tasks
+func parsePlanMarkdown(text string) (*models.PracticePlan, []int, error) {
+	plan := &models.PracticePlan{}
+	var errs []PlanMarkdownLineError
+	fail := func(line int, format string, args ...interface{}) {
+		errs = append(errs, PlanMarkdownLineError{Line: line, Message: fmt.Sprintf(format, args...)})
+	}
+
+	var tasks []*markdownTask
+	var current *markdownTask
+	var basis string
+	titleSeen, daysLine := false, 0
+	section := ""   // "daily" or "day"
+	sectionDay := 0 // day of a 第N天 section
+
+	for i, raw := range strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n") {
+		lineNo := i + 1
+		line := strings.TrimSpace(raw)
+		if line == "" || strings.HasPrefix(line, "```") {
+			continue
+		}
+		indented := raw != strings.TrimLeft(raw, " \t")
+
+		switch {
+		case !titleSeen:
+			m := markdownTitlePattern.FindStringSubmatch(line)
+			if m == nil {
+				fail(lineNo, "plan must start with its title in brackets, e.g. [神经可塑性训练]")
+				return nil, nil, &PlanMarkdownError{Errors: errs}
+			}
+			plan.Title = strings.TrimSpace(m[1])
+			if plan.Title == "" {
+				fail(lineNo, "title is empty")
+			}
+			titleSeen = true
+
+		case strings.HasPrefix(line, markdownSectionMarker):
+			current = nil
+			key, value, ok := cutMarkdownKey(strings.TrimSpace(strings.TrimPrefix(line, markdownSectionMarker)))
+			if !ok {
+				fail(lineNo, "section must be written as ▶ 名称：")
+				continue
+			}
+			switch {
+			case key == "天数":
+				days, err := strconv.Atoi(value)
+				if err != nil || days < 1 || days > MaxPlanDays {
+					fail(lineNo, "天数 must be a number between 1 and %d", MaxPlanDays)
+					continue
+				}
+				if daysLine > 0 {
+					fail(lineNo, "天数 is already set on line %d", daysLine)
+					continue
+				}
+				plan.Days, daysLine = days, lineNo
+			case key == "每日任务":
+				section = "daily"
+			case key == "科学依据":
+				basis = value
+				section = ""
+			case markdownDayPattern.MatchString(key):
+				sectionDay, _ = strconv.Atoi(markdownDayPattern.FindStringSubmatch(key)[1])
+				if sectionDay < 1 || sectionDay > MaxPlanDays {
+					fail(lineNo, "day must be between 1 and %d", MaxPlanDays)
+				}
+				section = "day"
+			default:
+				fail(lineNo, "unknown section %q", key)
+				section = ""
+			}
+
+		case strings.HasPrefix(line, "- "):
+			if section == "" {
+				fail(lineNo, "task must be under ▶ 每日任务 or ▶ 第N天")
+				current = nil
+				continue
+			}
+			current = &markdownTask{task: parseMarkdownTask(strings.TrimSpace(line[2:])), line: lineNo, daily: section == "daily"}
+			if !current.daily {
+				current.task.Day = sectionDay
+			}
+			if current.task.Title == "" {
+				fail(lineNo, "task title is empty")
+			}
+			if current.task.DurationMinutes > maxTaskMinutes {
+				fail(lineNo, "duration must be at most %d minutes", maxTaskMinutes)
+			}
+			tasks = append(tasks, current)
+
+		case indented && current != nil:
+			key, value, ok := cutMarkdownKey(line)
+			switch {
+			case ok && key == "类型":
+				taskType, known := parseMarkdownTaskType(value)
+				if !known {
+					fail(lineNo, "unknown task type %q", value)
+				}
+				current.task.Type = taskType
+			case ok && key == "依据":
+				current.task.ScientificBasis = value
+			case ok && key == "说明":
+				line = value
+				fallthrough
+			default:
+				if current.task.Description != "" {
+					current.task.Description += "\n"
+				}
+				current.task.Description += line
+			}
+
+		default:
+			fail(lineNo, "unexpected line; expected ▶ section, - task or indented task details")
+		}
+	}
+
+	if !titleSeen {
+		fail(0, "plan is empty")
+		return nil, nil, &PlanMarkdownError{Errors: errs}
+	}
+
+	// Without ▶ 天数 the plan runs until its last day section, or a week
+	if plan.Days == 0 {
+		for _, t := range tasks {
+			if !t.daily && t.task.Day > plan.Days {
+				plan.Days = t.task.Day
+			}
+		}
+		if plan.Days == 0 {
+			plan.Days = defaultMarkdownDays
+		}
+	}
+
+	var daily []*markdownTask
+	byDay := make(map[int][]*markdownTask)
+	for _, t := range tasks {
+		if basis != "" && t.task.ScientificBasis == "" {
+			t.task.ScientificBasis = basis
+		}
+		if t.daily {
+			daily = append(daily, t)
+			continue
+		}
+		if plan.Days > 0 && t.task.Day > plan.Days {
+			fail(t.line, "day %d is after the last day %d", t.task.Day, plan.Days)
+		}
+		byDay[t.task.Day] = append(byDay[t.task.Day], t)
+	}
+	if len(tasks) == 0 {
+		fail(0, "plan has no tasks")
+	}
+	// Checked before the daily tasks are copied onto every day
+	if plan.Days > MaxPlanDays {
+		fail(0, "plan must be at most %d days", MaxPlanDays)
+	}
+
+	if len(errs) > 0 {
+		return nil, nil, &PlanMarkdownError{Errors: errs}
+	}
+
+	// Each day lists the daily tasks first, then its own tasks
+	plan.Tasks = []models.PlanTask{}
+	var lines []int
+	for day := 1; day <= plan.Days; day++ {
+		for _, t := range daily {
+			task := t.task
+			task.Day = day
+			plan.Tasks = append(plan.Tasks, task)
+			lines = append(lines, t.line)
+		}
+		for _, t := range byDay[day] {
+			plan.Tasks = append(plan.Tasks, t.task)
+			lines = append(lines, t.line)
+		}
+	}
+
+	return plan, lines, nil
+}
+
+// markdownLineErrors turns the problems of a plan validation into line errors
+func markdownLineErrors(problems []string, taskLines []int) []PlanMarkdownLineError {
+	errs := make([]PlanMarkdownLineError, 0, len(problems))
+	for _, problem := range problems {
+		lineErr := PlanMarkdownLineError{Message: problem}
+		if m := markdownTaskProblem.FindStringSubmatch(problem); m != nil {
+			if index, _ := strconv.Atoi(m[1]); index >= 1 && index <= len(taskLines) {
+				lineErr = PlanMarkdownLineError{Line: taskLines[index-1], Message: m[2]}
+			}
+		}
+		errs = append(errs, lineErr)
+	}
+	return errs
+}
+
+// markdownTaskKey identifies a task by the content the format keeps, ignoring its ID, day and references
+func markdownTaskKey(task models.PlanTask) models.PlanTask {
+	task.ID = ""
+	task.Day = 0
+	task.ReferenceIDs = nil
+	return task
+}
+
+// dailyMarkdownTasks returns the tasks that occur in the same order at the start of every day
+func dailyMarkdownTasks(plan *models.PracticePlan, byDay map[int][]models.PlanTask) []models.PlanTask {
+	if plan.Days < 2 {
+		return nil
+	}
+
+	var daily []models.PlanTask
+	for i, task := range byDay[1] {
+		key := markdownTaskKey(task)
+		for day := 2; day <= plan.Days; day++ {
+			if len(byDay[day]) <= i || !reflect.DeepEqual(markdownTaskKey(byDay[day][i]), key) {
+				return daily
+			}
+		}
+		daily = append(daily, key)
+	}
+	return daily
+}
+
+// renderMarkdownTask writes one task line and its details
+func renderMarkdownTask(b *strings.Builder, task models.PlanTask) {
+	b.WriteString("  - ")
+	if task.Optional {
+		b.WriteString(markdownOptional)
+	}
+	for _, tod := range markdownTimesOfDay {
+		if tod.value == task.TimeOfDay {
+			b.WriteString(tod.word)
+			break
+		}
+	}
+	if task.DurationMinutes > 0 {
+		fmt.Fprintf(b, "%d分钟", task.DurationMinutes)
+	}
+	b.WriteString(escapeMarkdownTitle(task.Title))
+	b.WriteString("\n")
+
+	if task.Description != "" {
+		for i, line := range strings.Split(task.Description, "\n") {
+			if i == 0 {
+				line = "说明：" + line
+			}
+			fmt.Fprintf(b, "    %s\n", line)
+		}
+	}
+	if label, ok := markdownTaskTypes[task.Type]; ok {
+		fmt.Fprintf(b, "    类型：%s\n", label)
+	}
+	if task.ScientificBasis != "" {
+		fmt.Fprintf(b, "    依据：%s\n", task.ScientificBasis)
+	}
+}
+
+// RenderPlanMarkdown renders a plan in the Markdown plan format. Tasks that repeat on
+// every day are listed once under ▶ 每日任务. Task IDs and the schedule are not included.
+func RenderPlanMarkdown(plan *models.PracticePlan) string {
+	byDay := make(map[int][]models.PlanTask)
+	for _, task := range plan.Tasks {
+		byDay[task.Day] = append(byDay[task.Day], task)
+	}
+	daily := dailyMarkdownTasks(plan, byDay)
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "[%s]\n", plan.Title)
+	fmt.Fprintf(&b, "▶ 天数：%d\n", plan.Days)
+	if len(daily) > 0 {
+		b.WriteString("▶ 每日任务：\n")
+		for _, task := range daily {
+			renderMarkdownTask(&b, task)
+		}
+	}
+	for day := 1; day <= plan.Days; day++ {
+		tasks := byDay[day][len(daily):]
+		if len(tasks) == 0 {
+			continue
+		}
+		fmt.Fprintf(&b, "▶ 第%d天：\n", day)
+		for _, task := range tasks {
+			renderMarkdownTask(&b, task)
+		}
+	}
+
+	return b.String()
+}
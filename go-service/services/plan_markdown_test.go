@@ -0,0 +1,180 @@
+package services
+
+import (
+	"errors"
+	"testing"
+
+	"neuro-guide-go-service/models"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// The example plan of the requirements document
+const documentedPlanMarkdown = "```markdown\n" +
+	"[神经可塑性训练]  \n" +
+	"▶ 每日任务：  \n" +
+	"  - 早晨10分钟正念呼吸（增强前额叶-杏仁核连接）  \n" +
+	"  - 睡前感恩日记（提升血清素水平）  \n" +
+	"▶ 科学依据：Davidson, 2003 fMRI研究证实...\n" +
+	"```\n"
+
+func TestParseDocumentedPlanMarkdown(t *testing.T) {
+	plan, err := ParsePlanMarkdown(documentedPlanMarkdown)
+	assert.NoError(t, err)
+
+	assert.Equal(t, "神经可塑性训练", plan.Title)
+	assert.Equal(t, 7, plan.Days)
+	assert.Len(t, plan.Tasks, 14)
+	assert.Equal(t, models.PlanTask{
+		Day:             1,
+		Title:           "正念呼吸",
+		ScientificBasis: "增强前额叶-杏仁核连接",
+		DurationMinutes: 10,
+		TimeOfDay:       models.TimeOfDayMorning,
+	}, plan.Tasks[0])
+	assert.Equal(t, models.PlanTask{
+		Day:             7,
+		Title:           "感恩日记",
+		ScientificBasis: "提升血清素水平",
+		TimeOfDay:       models.TimeOfDayBedtime,
+	}, plan.Tasks[13])
+}
+
+func TestParsePlanMarkdownSections(t *testing.T) {
+	plan, err := ParsePlanMarkdown(`[睡眠改善]
+▶ 天数：3
+▶ 每日任务：
+  - 晚上5分钟呼吸
+▶ 第2天：
+  - 选做：30分钟散步（运动促进BDNF分泌）
+    说明：饭后进行
+    保持微微出汗
+    类型：运动
+▶ 科学依据：慢呼吸降低交感神经活动
+`)
+	assert.NoError(t, err)
+
+	assert.Equal(t, 3, plan.Days)
+	assert.Len(t, plan.Tasks, 4)
+	assert.Equal(t, "慢呼吸降低交感神经活动", plan.Tasks[0].ScientificBasis)
+	assert.Equal(t, models.PlanTask{
+		Day:             2,
+		Title:           "散步",
+		Description:     "饭后进行\n保持微微出汗",
+		ScientificBasis: "运动促进BDNF分泌",
+		Type:            models.TaskTypeExercise,
+		DurationMinutes: 30,
+		Optional:        true,
+	}, plan.Tasks[2])
+	assert.Equal(t, 3, plan.Tasks[3].Day)
+}
+
+func TestParsePlanMarkdownReportsLines(t *testing.T) {
+	_, err := ParsePlanMarkdown(`[计划]
+▶ 天数：abc
+  - 没有所属段落的任务
+▶ 第9天：
+  - 300分钟冥想
+    类型：瑜伽
+▶ 目标：放松
+随便写的一行
+`)
+
+	var markdownErr *PlanMarkdownError
+	assert.True(t, errors.As(err, &markdownErr))
+	assert.Equal(t, []PlanMarkdownLineError{
+		{Line: 2, Message: "天数 must be a number between 1 and 90"},
+		{Line: 3, Message: "task must be under ▶ 每日任务 or ▶ 第N天"},
+		{Line: 5, Message: "duration must be at most 240 minutes"},
+		{Line: 6, Message: `unknown task type "瑜伽"`},
+		{Line: 7, Message: `unknown section "目标"`},
+		{Line: 8, Message: "unexpected line; expected ▶ section, - task or indented task details"},
+	}, markdownErr.Errors)
+}
+
+func TestParsePlanMarkdownNeedsTitle(t *testing.T) {
+	_, err := ParsePlanMarkdown("\n▶ 每日任务：\n")
+
+	var markdownErr *PlanMarkdownError
+	assert.True(t, errors.As(err, &markdownErr))
+	assert.Equal(t, 2, markdownErr.Errors[0].Line)
+}
+
+func TestParsePlanMarkdownDayAfterLastDay(t *testing.T) {
+	_, err := ParsePlanMarkdown("[计划]\n▶ 天数：2\n▶ 第3天：\n  - 冥想\n")
+
+	var markdownErr *PlanMarkdownError
+	assert.True(t, errors.As(err, &markdownErr))
+	assert.Equal(t, []PlanMarkdownLineError{{Line: 4, Message: "day 3 is after the last day 2"}}, markdownErr.Errors)
+}
+
+func TestParsePlanMarkdownRejectsDayPastLimit(t *testing.T) {
+	plan, err := ParsePlanMarkdown("[计划]\n▶ 每日任务：\n  - 冥想\n  - 散步\n▶ 第2000000天：\n  - 复盘\n")
+
+	assert.Nil(t, plan)
+	var markdownErr *PlanMarkdownError
+	assert.True(t, errors.As(err, &markdownErr))
+	assert.Equal(t, []PlanMarkdownLineError{
+		{Line: 5, Message: "day must be between 1 and 90"},
+		{Line: 0, Message: "plan must be at most 90 days"},
+	}, markdownErr.Errors)
+}
+
+func TestRenderPlanMarkdownRoundTrip(t *testing.T) {
+	plan := &models.PracticePlan{Title: "正念入门", Days: 3}
+	for day := 1; day <= 3; day++ {
+		plan.Tasks = append(plan.Tasks, models.PlanTask{
+			ID: "daily", Day: day, Title: "正念呼吸", ScientificBasis: "增强前额叶-杏仁核连接",
+			TimeOfDay: models.TimeOfDayMorning, DurationMinutes: 10, Type: models.TaskTypeBreathing,
+		})
+	}
+	plan.Tasks = append(plan.Tasks, models.PlanTask{
+		ID: "extra", Day: 2, Title: "感恩日记", Description: "写下三件事\n不求完美", ScientificBasis: "提升血清素水平",
+		TimeOfDay: models.TimeOfDayBedtime, Optional: true,
+	})
+	// Keep the plan in parsing order: daily tasks first on each day
+	plan.Tasks[2], plan.Tasks[3] = plan.Tasks[3], plan.Tasks[2]
+	// Titles that look like a prefix or a basis, and a basis with parentheses
+	plan.Tasks = append(plan.Tasks,
+		models.PlanTask{Day: 3, Title: "正念呼吸（4-7-8）"},
+		models.PlanTask{Day: 3, Title: "早上好冥想"},
+		models.PlanTask{Day: 3, Title: "身体扫描", ScientificBasis: "Hölzel et al. (2011) Psychiatry Research"},
+	)
+
+	markdown := RenderPlanMarkdown(plan)
+	assert.Equal(t, `[正念入门]
+▶ 天数：3
+▶ 每日任务：
+  - 早晨10分钟正念呼吸
+    类型：呼吸
+    依据：增强前额叶-杏仁核连接
+▶ 第2天：
+  - 选做：睡前感恩日记
+    说明：写下三件事
+    不求完美
+    依据：提升血清素水平
+▶ 第3天：
+  - 正念呼吸\（4-7-8）
+  - \早上好冥想
+  - 身体扫描
+    依据：Hölzel et al. (2011) Psychiatry Research
+`, markdown)
+
+	parsed, err := ParsePlanMarkdown(markdown)
+	assert.NoError(t, err)
+	for i := range plan.Tasks {
+		plan.Tasks[i].ID = ""
+	}
+	assert.Equal(t, plan.Title, parsed.Title)
+	assert.Equal(t, plan.Days, parsed.Days)
+	assert.Equal(t, plan.Tasks, parsed.Tasks)
+}
+
+func TestMarkdownLineErrors(t *testing.T) {
+	errs := markdownLineErrors([]string{"task 2: title is empty", "start date must be YYYY-MM-DD", "task 9: x"}, []int{4, 6})
+	assert.Equal(t, []PlanMarkdownLineError{
+		{Line: 6, Message: "title is empty"},
+		{Line: 0, Message: "start date must be YYYY-MM-DD"},
+		{Line: 0, Message: "task 9: x"},
+	}, errs)
+}
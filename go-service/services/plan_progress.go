@@ -0,0 +1,170 @@
+package services
+
+import (
+	"context"
+	"math"
+	"time"
+
+	"neuro-guide-go-service/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// practicedDay is the set of tasks one plan completed on one plan day
+type practicedDay struct {
+	PlanID string   `bson:"plan_id"`
+	Day    int      `bson:"day"`
+	Tasks  []string `bson:"tasks"`
+}
+
+// practicedDaysPipeline groups the completed tasks of the visible records matching filter by plan and plan day
+func practicedDaysPipeline(filter bson.M) mongo.Pipeline {
+	filter["day"] = bson.M{"$gte": 1}
+	return mongo.Pipeline{
+		{{Key: "$match", Value: visibleRecords(filter)}},
+		{{Key: "$unwind", Value: "$completed_tasks"}},
+		{{Key: "$group", Value: bson.M{
+			"_id":   bson.M{"plan_id": "$plan_id", "day": "$day"},
+			"tasks": bson.M{"$addToSet": "$completed_tasks"},
+		}}},
+		{{Key: "$project", Value: bson.M{"_id": 0, "plan_id": "$_id.plan_id", "day": "$_id.day", "tasks": 1}}},
+	}
+}
+
+// GetProgress returns the progress of a user's plan
+func (pps *PracticePlanService) GetProgress(userID, planID string, now time.Time) (*models.PlanProgress, error) {
+	plan, err := pps.GetPlan(userID, planID)
+	if err != nil {
+		return nil, err
+	}
+	return pps.Progress(plan, now)
+}
+
+// Progress computes the progress of a plan from its practice records
+func (pps *PracticePlanService) Progress(plan *models.PracticePlan, now time.Time) (*models.PlanProgress, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	cursor, err := pps.records.Aggregate(ctx, practicedDaysPipeline(bson.M{"user_id": plan.UserID, "plan_id": plan.ID}))
+	if err != nil {
+		return nil, err
+	}
+	var practiced []practicedDay
+	if err := cursor.All(ctx, &practiced); err != nil {
+		return nil, err
+	}
+
+	return planProgress(plan, practiced, now, pps.location), nil
+}
+
+// progressTime returns when the days of a plan stopped counting: now while it runs or after it
+// completed, and when it was abandoned or archived otherwise. It reports false for plans that never ran.
+func progressTime(plan *models.PracticePlan, now time.Time) (time.Time, bool) {
+	switch plan.Status {
+	case models.PlanStatusDraft:
+		return now, false
+	case "", models.PlanStatusActive, models.PlanStatusPaused, models.PlanStatusCompleted:
+		return now, true
+	}
+
+	history := plan.StatusHistory
+	for i := len(history) - 1; i > 0; i-- {
+		if isRunning(history[i-1].To) {
+			return history[i].At, true
+		}
+	}
+	return now, false
+}
+
+// projectedCompletion returns the date of the plan's last day on its current schedule.
+// Paused plans are assumed to resume today; abandoned plans are never completed.
+func projectedCompletion(plan *models.PracticePlan, now time.Time, fallback *time.Location) string {
+	switch plan.Status {
+	case models.PlanStatusAbandoned, models.PlanStatusArchived:
+		return ""
+	}
+
+	scheduled := plan
+	if plan.IsPaused() {
+		resumed := *plan
+		resumed.Pauses = append([]models.PlanPause(nil), plan.Pauses...)
+		resumed.Pauses[len(resumed.Pauses)-1].To = localDate(now, planLocation(plan, fallback)).Format(planDateLayout)
+		scheduled = &resumed
+	}
+
+	if date, ok := PlanDates(scheduled, fallback)[plan.Days]; ok {
+		return date.Format(planDateLayout)
+	}
+	return ""
+}
+
+// planProgress computes the progress of a plan from the tasks it completed on each plan day
+func planProgress(plan *models.PracticePlan, practiced []practicedDay, now time.Time, fallback *time.Location) *models.PlanProgress {
+	progress := &models.PlanProgress{
+		PlanID:                  plan.ID,
+		TotalDays:               plan.Days,
+		MissedDays:              []models.MissedPlanDay{},
+		ProjectedCompletionDate: projectedCompletion(plan, now, fallback),
+	}
+
+	if at, started := progressTime(plan, now); started {
+		today := PlanDayAt(plan, at, fallback)
+		progress.Today = today.Day
+		progress.DaysElapsed = lastEvaluatedDay(plan, today)
+	}
+
+	completed := make(map[int]map[string]bool)
+	for _, p := range practiced {
+		if completed[p.Day] == nil {
+			completed[p.Day] = make(map[string]bool)
+		}
+		for _, id := range p.Tasks {
+			completed[p.Day][id] = true
+		}
+	}
+
+	dates := PlanDates(plan, fallback)
+	for day := 1; day <= progress.DaysElapsed; day++ {
+		var dayRequired, dayRequiredDone, dayDone int
+		for _, task := range plan.Tasks {
+			if task.Day != day {
+				continue
+			}
+			done := completed[day][task.ID]
+			progress.TasksScheduled++
+			if done {
+				dayDone++
+			}
+			if !task.Optional {
+				dayRequired++
+				if done {
+					dayRequiredDone++
+				}
+			}
+		}
+
+		progress.TasksCompleted += dayDone
+		progress.RequiredScheduled += dayRequired
+		progress.RequiredCompleted += dayRequiredDone
+		if dayDone > 0 {
+			progress.PracticedDays++
+		}
+		if dayRequired > 0 && dayRequiredDone == 0 {
+			missed := models.MissedPlanDay{Day: day}
+			if date, ok := dates[day]; ok {
+				missed.Date = date.Format(planDateLayout)
+			}
+			progress.MissedDays = append(progress.MissedDays, missed)
+		}
+	}
+
+	// Nothing was missed when no task was required
+	progress.AdherencePercent = 100
+	if progress.RequiredScheduled > 0 {
+		rate := float64(progress.RequiredCompleted) / float64(progress.RequiredScheduled)
+		progress.AdherencePercent = math.Round(rate*1000) / 10
+	}
+
+	return progress
+}
@@ -0,0 +1,101 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"neuro-guide-go-service/models"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func progressPlan() *models.PracticePlan {
+	return &models.PracticePlan{
+		ID:        "p1",
+		Days:      4,
+		StartDate: "2024-05-06", // Monday
+		Status:    models.PlanStatusActive,
+		Tasks: []models.PlanTask{
+			{ID: "d1-breath", Day: 1},
+			{ID: "d1-walk", Day: 1, Optional: true},
+			{ID: "d2-scan", Day: 2},
+			{ID: "d3-sit", Day: 3},
+			{ID: "d3-journal", Day: 3},
+			{ID: "d4-sit", Day: 4},
+		},
+	}
+}
+
+func TestPlanProgress(t *testing.T) {
+	loc := time.FixedZone("CST", 8*3600)
+	practiced := []practicedDay{
+		{PlanID: "p1", Day: 1, Tasks: []string{"d1-breath", "d1-walk"}},
+		{PlanID: "p1", Day: 3, Tasks: []string{"d3-sit", "removed-task"}},
+		{PlanID: "p1", Day: 4, Tasks: []string{"d4-sit"}}, // today, not counted yet
+	}
+
+	progress := planProgress(progressPlan(), practiced, time.Date(2024, 5, 9, 8, 0, 0, 0, loc), loc)
+	assert.Equal(t, &models.PlanProgress{
+		PlanID:                  "p1",
+		Today:                   4,
+		TotalDays:               4,
+		DaysElapsed:             3,
+		TasksScheduled:          5,
+		TasksCompleted:          3,
+		RequiredScheduled:       4,
+		RequiredCompleted:       2,
+		AdherencePercent:        50,
+		PracticedDays:           2,
+		MissedDays:              []models.MissedPlanDay{{Day: 2, Date: "2024-05-07"}},
+		ProjectedCompletionDate: "2024-05-09",
+	}, progress)
+}
+
+func TestPlanProgressBeforeStart(t *testing.T) {
+	loc := time.FixedZone("CST", 8*3600)
+	progress := planProgress(progressPlan(), nil, time.Date(2024, 5, 1, 8, 0, 0, 0, loc), loc)
+	assert.Zero(t, progress.Today)
+	assert.Zero(t, progress.DaysElapsed)
+	assert.Equal(t, float64(100), progress.AdherencePercent)
+	assert.Empty(t, progress.MissedDays)
+	assert.Equal(t, "2024-05-09", progress.ProjectedCompletionDate)
+}
+
+func TestPlanProgressProjectsPausedPlans(t *testing.T) {
+	loc := time.FixedZone("CST", 8*3600)
+	plan := progressPlan()
+	plan.Status = models.PlanStatusPaused
+	plan.RestWeekdays = []int{0} // Sunday
+	plan.Pauses = []models.PlanPause{{From: "2024-05-08"}}
+
+	// Paused after day 2; resuming on Friday the 10th leaves days 3 and 4 for Friday and Saturday
+	progress := planProgress(plan, nil, time.Date(2024, 5, 10, 8, 0, 0, 0, loc), loc)
+	assert.Equal(t, 2, progress.Today)
+	assert.Equal(t, 2, progress.DaysElapsed)
+	assert.Equal(t, "2024-05-11", progress.ProjectedCompletionDate)
+
+	// Resuming on Saturday moves day 4 past the Sunday rest day
+	progress = planProgress(plan, nil, time.Date(2024, 5, 11, 8, 0, 0, 0, loc), loc)
+	assert.Equal(t, "2024-05-13", progress.ProjectedCompletionDate)
+}
+
+func TestPlanProgressStopsWhenAbandoned(t *testing.T) {
+	loc := time.FixedZone("CST", 8*3600)
+	plan := progressPlan()
+	plan.Status = models.PlanStatusAbandoned
+	plan.StatusHistory = []models.PlanStatusChange{
+		{To: models.PlanStatusActive, At: time.Date(2024, 5, 6, 0, 0, 0, 0, loc)},
+		{From: models.PlanStatusActive, To: models.PlanStatusAbandoned, At: time.Date(2024, 5, 7, 20, 0, 0, 0, loc)},
+	}
+
+	progress := planProgress(plan, nil, time.Date(2024, 6, 1, 8, 0, 0, 0, loc), loc)
+	assert.Equal(t, 2, progress.Today)
+	assert.Equal(t, 1, progress.DaysElapsed)
+	assert.Equal(t, []models.MissedPlanDay{{Day: 1, Date: "2024-05-06"}}, progress.MissedDays)
+	assert.Empty(t, progress.ProjectedCompletionDate)
+
+	// Drafts have not started
+	plan.Status = models.PlanStatusDraft
+	progress = planProgress(plan, nil, time.Date(2024, 6, 1, 8, 0, 0, 0, loc), loc)
+	assert.Zero(t, progress.DaysElapsed)
+}
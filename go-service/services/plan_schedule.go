@@ -0,0 +1,212 @@
+package services
+
+import (
+	"fmt"
+	"time"
+
+	"neuro-guide-go-service/config"
+	"neuro-guide-go-service/models"
+)
+
+const planDateLayout = "2006-01-02"
+
+// Plan day statuses
+const (
+	PlanDayNotStarted = "not_started"
+	PlanDayActive     = "active"
+	PlanDayRest       = "rest"
+	PlanDayPaused     = "paused"
+	PlanDayFinished   = "finished"
+)
+
+// PlanDay is the position of a plan on a calendar date
+type PlanDay struct {
+	Date   string `json:"date"`   // local date in the plan's time zone
+	Day    int    `json:"day"`    // plan day on that date; on rest and paused dates the last practiced day
+	Status string `json:"status"` // one of the PlanDay* values
+}
+
+// PlanSchedule is when a plan runs
+type PlanSchedule struct {
+	StartDate    string `json:"start_date"`    // YYYY-MM-DD, defaults to today
+	TimeZone     string `json:"time_zone"`     // IANA name, defaults to the service time zone
+	RestWeekdays []int  `json:"rest_weekdays"` // 0 is Sunday
+}
+
+// defaultUserLocation returns the time zone used for users who did not choose one:
+// the quota time zone, Asia/Shanghai, or UTC+8 when no zone database is available
+func defaultUserLocation(cfg *config.Config) *time.Location {
+	for _, name := range []string{cfg.QuotaTimeZone, "Asia/Shanghai"} {
+		if name == "" {
+			continue
+		}
+		if loc, err := time.LoadLocation(name); err == nil {
+			return loc
+		}
+	}
+	return time.FixedZone("CST", 8*3600)
+}
+
+// applySchedule sets the schedule of a plan, filling in the start date and time zone
+func applySchedule(plan *models.PracticePlan, schedule PlanSchedule, fallback *time.Location, now time.Time) error {
+	loc := fallback
+	if schedule.TimeZone != "" {
+		var err error
+		if loc, err = time.LoadLocation(schedule.TimeZone); err != nil {
+			return &PlanValidationError{Problems: []string{fmt.Sprintf("unknown time zone %q", schedule.TimeZone)}}
+		}
+		plan.TimeZone = schedule.TimeZone
+	} else if _, err := time.LoadLocation(loc.String()); err == nil {
+		plan.TimeZone = loc.String()
+	}
+
+	plan.StartDate = schedule.StartDate
+	if plan.StartDate == "" {
+		plan.StartDate = now.In(loc).Format(planDateLayout)
+	}
+	plan.RestWeekdays = schedule.RestWeekdays
+
+	return nil
+}
+
+// scheduleProblems checks the schedule fields of a plan
+func scheduleProblems(plan *models.PracticePlan) []string {
+	var problems []string
+
+	if plan.StartDate != "" {
+		if _, err := time.Parse(planDateLayout, plan.StartDate); err != nil {
+			problems = append(problems, "start date must be YYYY-MM-DD")
+		}
+	}
+	if plan.TimeZone != "" {
+		if _, err := time.LoadLocation(plan.TimeZone); err != nil {
+			problems = append(problems, fmt.Sprintf("unknown time zone %q", plan.TimeZone))
+		}
+	}
+
+	rest := make(map[int]bool)
+	for _, weekday := range plan.RestWeekdays {
+		if weekday < 0 || weekday > 6 {
+			problems = append(problems, fmt.Sprintf("rest weekday %d is outside 0-6", weekday))
+		}
+		rest[weekday] = true
+	}
+	if len(rest) >= 7 {
+		problems = append(problems, "a plan needs at least one practice weekday")
+	}
+
+	return problems
+}
+
+// planLocation returns the plan's time zone, or fallback for plans without one
+func planLocation(plan *models.PracticePlan, fallback *time.Location) *time.Location {
+	if plan.TimeZone != "" {
+		if loc, err := time.LoadLocation(plan.TimeZone); err == nil {
+			return loc
+		}
+	}
+	return fallback
+}
+
+// localDate returns midnight of t's date in loc
+func localDate(t time.Time, loc *time.Location) time.Time {
+	local := t.In(loc)
+	return time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, loc)
+}
+
+// planStart returns the first date of a plan. Plans created before start dates start on their creation date.
+func planStart(plan *models.PracticePlan, loc *time.Location) time.Time {
+	if start, err := time.ParseInLocation(planDateLayout, plan.StartDate, loc); err == nil {
+		return start
+	}
+	return localDate(plan.CreatedAt, loc)
+}
+
+// isRestDay reports whether date falls on one of the plan's rest weekdays
+func isRestDay(plan *models.PracticePlan, date time.Time) bool {
+	for _, weekday := range plan.RestWeekdays {
+		if int(date.Weekday()) == weekday {
+			return true
+		}
+	}
+	return false
+}
+
+// isPausedOn reports whether the plan was paused on a date. A pause covers
+// its start date up to, but not including, the date it was resumed.
+func isPausedOn(plan *models.PracticePlan, date string) bool {
+	for _, pause := range plan.Pauses {
+		if date >= pause.From && (pause.To == "" || date < pause.To) {
+			return true
+		}
+	}
+	return false
+}
+
+// PlanDayAt returns the plan day at now. Rest and paused days do not count
+// towards the plan's days, so they move its end date back.
+func PlanDayAt(plan *models.PracticePlan, now time.Time, fallback *time.Location) PlanDay {
+	loc := planLocation(plan, fallback)
+	today := localDate(now, loc)
+	result := PlanDay{Date: today.Format(planDateLayout)}
+
+	start := planStart(plan, loc)
+	if today.Before(start) {
+		result.Status = PlanDayNotStarted
+		return result
+	}
+
+	for date := start; !date.After(today); date = date.AddDate(0, 0, 1) {
+		switch {
+		case result.Day == plan.Days:
+			result.Status = PlanDayFinished
+			return result
+		case isPausedOn(plan, date.Format(planDateLayout)):
+			result.Status = PlanDayPaused
+		case isRestDay(plan, date):
+			result.Status = PlanDayRest
+		default:
+			result.Day++
+			result.Status = PlanDayActive
+		}
+	}
+
+	return result
+}
+
+// currentPlanDay returns the day of the plan at now.
+// It reports false when the plan has not started, is resting, paused or finished.
+func currentPlanDay(plan *models.PracticePlan, now time.Time, fallback *time.Location) (int, bool) {
+	day := PlanDayAt(plan, now, fallback)
+	return day.Day, day.Status == PlanDayActive
+}
+
+// PlanDates returns the calendar date of each plan day in the plan's time zone.
+// Days after a pause that has not ended yet have no date, so they are left out.
+func PlanDates(plan *models.PracticePlan, fallback *time.Location) map[int]time.Time {
+	loc := planLocation(plan, fallback)
+	dates := make(map[int]time.Time, plan.Days)
+
+	rest := make(map[int]bool)
+	for _, weekday := range plan.RestWeekdays {
+		rest[weekday] = true
+	}
+	if len(rest) >= 7 {
+		return dates
+	}
+
+	day := 0
+	for date := planStart(plan, loc); day < plan.Days; date = date.AddDate(0, 0, 1) {
+		d := date.Format(planDateLayout)
+		if plan.IsPaused() && d >= plan.Pauses[len(plan.Pauses)-1].From {
+			break
+		}
+		if isPausedOn(plan, d) || isRestDay(plan, date) {
+			continue
+		}
+		day++
+		dates[day] = date
+	}
+
+	return dates
+}
@@ -0,0 +1,98 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"neuro-guide-go-service/models"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPlanDayAt(t *testing.T) {
+	loc := time.FixedZone("CST", 8*3600)
+	// 2024-05-06 is a Monday
+	plan := &models.PracticePlan{Days: 3, StartDate: "2024-05-06", RestWeekdays: []int{3}} // Wednesdays off
+
+	at := func(date string) PlanDay {
+		now, _ := time.ParseInLocation("2006-01-02 15:04", date+" 12:00", loc)
+		return PlanDayAt(plan, now, loc)
+	}
+
+	assert.Equal(t, PlanDay{Date: "2024-05-05", Day: 0, Status: PlanDayNotStarted}, at("2024-05-05"))
+	assert.Equal(t, PlanDay{Date: "2024-05-06", Day: 1, Status: PlanDayActive}, at("2024-05-06"))
+	assert.Equal(t, PlanDay{Date: "2024-05-07", Day: 2, Status: PlanDayActive}, at("2024-05-07"))
+	assert.Equal(t, PlanDay{Date: "2024-05-08", Day: 2, Status: PlanDayRest}, at("2024-05-08"))
+	assert.Equal(t, PlanDay{Date: "2024-05-09", Day: 3, Status: PlanDayActive}, at("2024-05-09"))
+	assert.Equal(t, PlanDay{Date: "2024-05-10", Day: 3, Status: PlanDayFinished}, at("2024-05-10"))
+
+	// A pause moves the remaining days back
+	plan.Pauses = []models.PlanPause{{From: "2024-05-07", To: "2024-05-10"}}
+	assert.Equal(t, PlanDayPaused, at("2024-05-09").Status)
+	assert.Equal(t, PlanDay{Date: "2024-05-10", Day: 2, Status: PlanDayActive}, at("2024-05-10"))
+	assert.Equal(t, PlanDay{Date: "2024-05-11", Day: 3, Status: PlanDayActive}, at("2024-05-11"))
+
+	// An open pause lasts until the plan is resumed
+	plan.Pauses = []models.PlanPause{{From: "2024-05-07"}}
+	assert.True(t, plan.IsPaused())
+	assert.Equal(t, PlanDay{Date: "2024-06-01", Day: 1, Status: PlanDayPaused}, at("2024-06-01"))
+}
+
+func TestPlanDayAtUsesPlanTimeZone(t *testing.T) {
+	plan := &models.PracticePlan{Days: 2, StartDate: "2024-05-06", TimeZone: "America/New_York"}
+	if _, err := time.LoadLocation(plan.TimeZone); err != nil {
+		t.Skip("time zone database not available")
+	}
+
+	// Already Monday in Shanghai, still Sunday in New York
+	now := time.Date(2024, 5, 5, 20, 0, 0, 0, time.UTC)
+	assert.Equal(t, PlanDayNotStarted, PlanDayAt(plan, now, time.FixedZone("CST", 8*3600)).Status)
+}
+
+func TestApplySchedule(t *testing.T) {
+	loc := time.FixedZone("CST", 8*3600)
+	now := time.Date(2024, 5, 5, 20, 0, 0, 0, time.UTC)
+
+	plan := &models.PracticePlan{}
+	assert.NoError(t, applySchedule(plan, PlanSchedule{RestWeekdays: []int{0}}, loc, now))
+	assert.Equal(t, "2024-05-06", plan.StartDate) // today in the fallback zone
+	assert.Equal(t, "", plan.TimeZone)            // a fixed zone cannot be stored
+	assert.Equal(t, []int{0}, plan.RestWeekdays)
+
+	plan = &models.PracticePlan{}
+	assert.Error(t, applySchedule(plan, PlanSchedule{TimeZone: "Mars/Olympus"}, loc, now))
+}
+
+func TestScheduleProblems(t *testing.T) {
+	assert.Empty(t, scheduleProblems(&models.PracticePlan{StartDate: "2024-05-06", RestWeekdays: []int{0, 6}}))
+	assert.Equal(t, []string{
+		"start date must be YYYY-MM-DD",
+		"rest weekday 7 is outside 0-6",
+	}, scheduleProblems(&models.PracticePlan{StartDate: "6 May", RestWeekdays: []int{7}}))
+	assert.Equal(t, []string{"a plan needs at least one practice weekday"},
+		scheduleProblems(&models.PracticePlan{RestWeekdays: []int{0, 1, 2, 3, 4, 5, 6}}))
+}
+
+func TestPlanDates(t *testing.T) {
+	loc := time.FixedZone("CST", 8*3600)
+	// 2024-05-06 is a Monday
+	plan := &models.PracticePlan{Days: 4, StartDate: "2024-05-06", RestWeekdays: []int{3}} // Wednesdays off
+
+	format := func(dates map[int]time.Time) map[int]string {
+		result := make(map[int]string, len(dates))
+		for day, date := range dates {
+			result[day] = date.Format("2006-01-02")
+		}
+		return result
+	}
+
+	assert.Equal(t, map[int]string{1: "2024-05-06", 2: "2024-05-07", 3: "2024-05-09", 4: "2024-05-10"}, format(PlanDates(plan, loc)))
+
+	// A finished pause moves the remaining days back
+	plan.Pauses = []models.PlanPause{{From: "2024-05-07", To: "2024-05-10"}}
+	assert.Equal(t, map[int]string{1: "2024-05-06", 2: "2024-05-10", 3: "2024-05-11", 4: "2024-05-12"}, format(PlanDates(plan, loc)))
+
+	// Days after an open pause are not scheduled yet
+	plan.Pauses = []models.PlanPause{{From: "2024-05-07"}}
+	assert.Equal(t, map[int]string{1: "2024-05-06"}, format(PlanDates(plan, loc)))
+}
@@ -0,0 +1,344 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"log"
+	"time"
+
+	"neuro-guide-go-service/config"
+	"neuro-guide-go-service/database"
+	"neuro-guide-go-service/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// ErrPlanShareNotFound is returned when a share link does not exist, expired or was revoked
+var ErrPlanShareNotFound = errors.New("plan share not found")
+
+// ErrInvalidShareExpiry is returned when a share link would expire in the past
+var ErrInvalidShareExpiry = errors.New("share expiry must be in the future")
+
+// PlanShareService manages read-only share links of practice plans and cloning from them
+type PlanShareService struct {
+	shares *mongo.Collection
+	plans  *PracticePlanService
+}
+
+// NewPlanShareService creates a new instance of PlanShareService
+func NewPlanShareService(cfg *config.Config) *PlanShareService {
+	return &PlanShareService{
+		shares: database.Database.Collection("plan_shares"),
+		plans:  NewPracticePlanService(cfg),
+	}
+}
+
+// newShareToken returns a random URL-safe token
+func newShareToken() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// activeShareFilter matches share links that are neither revoked nor expired
+func activeShareFilter(now time.Time) bson.M {
+	return bson.M{
+		"revoked_at": bson.M{"$exists": false},
+		"$or": bson.A{
+			bson.M{"expires_at": bson.M{"$exists": false}},
+			bson.M{"expires_at": bson.M{"$gt": now}},
+		},
+	}
+}
+
+// listedShareFilter matches active share links their owners listed publicly
+func listedShareFilter(now time.Time) bson.M {
+	filter := activeShareFilter(now)
+	filter["listed"] = true
+	return filter
+}
+
+// CreateShare creates a share link for one of the user's plans. A nil expiry never expires.
+// Only listed links are shown in the public list of popular plans.
+func (pss *PlanShareService) CreateShare(userID, planID string, expiresAt *time.Time, listed bool) (*models.PlanShare, error) {
+	now := time.Now()
+	if expiresAt != nil && !expiresAt.After(now) {
+		return nil, ErrInvalidShareExpiry
+	}
+
+	plan, err := pss.plans.GetPlan(userID, planID)
+	if err != nil {
+		return nil, err
+	}
+
+	token, err := newShareToken()
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	objID := primitive.NewObjectID()
+	share := &models.PlanShare{
+		ID:        objID.Hex(),
+		Token:     token,
+		PlanID:    plan.ID,
+		UserID:    userID,
+		ExpiresAt: expiresAt,
+		Listed:    listed,
+		CreatedAt: now,
+	}
+
+	doc := bson.M{
+		"_id":        objID,
+		"token":      share.Token,
+		"plan_id":    share.PlanID,
+		"user_id":    share.UserID,
+		"created_at": share.CreatedAt,
+	}
+	if share.ExpiresAt != nil {
+		doc["expires_at"] = share.ExpiresAt
+	}
+	if share.Listed {
+		doc["listed"] = true
+	}
+
+	if _, err := pss.shares.InsertOne(ctx, doc); err != nil {
+		return nil, err
+	}
+	return share, nil
+}
+
+// ListShares returns the share links of a user's plan, newest first, including inactive ones
+func (pss *PlanShareService) ListShares(userID, planID string) ([]*models.PlanShare, error) {
+	if _, err := pss.plans.GetPlan(userID, planID); err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	opts := options.Find().SetSort(bson.M{"created_at": -1})
+	cursor, err := pss.shares.Find(ctx, bson.M{"plan_id": planID, "user_id": userID}, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	shares := []*models.PlanShare{}
+	if err := cursor.All(ctx, &shares); err != nil {
+		return nil, err
+	}
+	return shares, nil
+}
+
+// RevokeShare stops a share link from working. Revoking twice is not an error.
+func (pss *PlanShareService) RevokeShare(userID, planID, shareID string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	objID, err := primitive.ObjectIDFromHex(shareID)
+	if err != nil {
+		return ErrPlanShareNotFound
+	}
+
+	filter := bson.M{"_id": objID, "plan_id": planID, "user_id": userID}
+	result, err := pss.shares.UpdateOne(ctx, filter, bson.M{"$min": bson.M{"revoked_at": time.Now()}})
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return ErrPlanShareNotFound
+	}
+	return nil
+}
+
+// SetShareListed lets the owner publish a share link in the popular plans or take it out again
+func (pss *PlanShareService) SetShareListed(userID, planID, shareID string, listed bool) (*models.PlanShare, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	objID, err := primitive.ObjectIDFromHex(shareID)
+	if err != nil {
+		return nil, ErrPlanShareNotFound
+	}
+
+	update := bson.M{"$unset": bson.M{"listed": ""}}
+	if listed {
+		update = bson.M{"$set": bson.M{"listed": true}}
+	}
+
+	var share models.PlanShare
+	err = pss.shares.FindOneAndUpdate(ctx, bson.M{"_id": objID, "plan_id": planID, "user_id": userID}, update,
+		options.FindOneAndUpdate().SetReturnDocument(options.After)).Decode(&share)
+	if err == mongo.ErrNoDocuments {
+		return nil, ErrPlanShareNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &share, nil
+}
+
+// findActiveShare loads a share link that can still be opened
+func (pss *PlanShareService) findActiveShare(ctx context.Context, token string) (*models.PlanShare, error) {
+	var share models.PlanShare
+	err := pss.shares.FindOne(ctx, bson.M{"token": token}).Decode(&share)
+	if err == mongo.ErrNoDocuments {
+		return nil, ErrPlanShareNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !share.IsActive(time.Now()) {
+		return nil, ErrPlanShareNotFound
+	}
+	return &share, nil
+}
+
+// sharedPlanView returns the public view of a shared plan
+func sharedPlanView(share *models.PlanShare, plan *models.PracticePlan) *models.SharedPlan {
+	tasks := plan.Tasks
+	if tasks == nil {
+		tasks = []models.PlanTask{}
+	}
+
+	return &models.SharedPlan{
+		Token:      share.Token,
+		PlanID:     plan.ID,
+		Title:      plan.Title,
+		Days:       plan.Days,
+		Tasks:      tasks,
+		ClonedFrom: plan.ClonedFrom,
+		CloneCount: plan.CloneCount,
+		ExpiresAt:  share.ExpiresAt,
+	}
+}
+
+// GetSharedPlan returns the current content of the plan behind a share link
+func (pss *PlanShareService) GetSharedPlan(token string) (*models.SharedPlan, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	share, err := pss.findActiveShare(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+
+	plan, err := pss.plans.findPlan(ctx, share.UserID, share.PlanID)
+	if errors.Is(err, ErrPlanNotFound) {
+		return nil, ErrPlanShareNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return sharedPlanView(share, plan), nil
+}
+
+// PlanFromShared copies a shared plan into a new practice plan for a user
+func PlanFromShared(shared *models.SharedPlan, userID string) *models.PracticePlan {
+	tasks := make([]models.PlanTask, len(shared.Tasks))
+	copy(tasks, shared.Tasks)
+
+	return &models.PracticePlan{
+		UserID:     userID,
+		Title:      shared.Title,
+		Days:       shared.Days,
+		Tasks:      tasks,
+		Source:     models.PlanSourceClone,
+		ClonedFrom: shared.PlanID,
+	}
+}
+
+// ClonePlan copies the plan behind a share link into the user's plans and counts the clone on the source plan
+func (pss *PlanShareService) ClonePlan(userID, token string, schedule PlanSchedule) (*models.PracticePlan, error) {
+	shared, err := pss.GetSharedPlan(token)
+	if err != nil {
+		return nil, err
+	}
+
+	plan := PlanFromShared(shared, userID)
+	if err := pss.plans.CreatePlan(plan, schedule); err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	// The clone exists either way, so a failed count is only logged
+	if _, err := pss.plans.collection.UpdateOne(ctx, bson.M{"_id": messageObjectID(shared.PlanID)},
+		bson.M{"$inc": bson.M{"clone_count": 1}}); err != nil {
+		log.Printf("Failed to count clone of plan %s: %v", shared.PlanID, err)
+	}
+
+	return plan, nil
+}
+
+// PopularPlans returns the most cloned plans that still have an active share link the owner
+// listed. Links shared privately never appear, so their tokens are not exposed.
+func (pss *PlanShareService) PopularPlans(limit int64) ([]*models.SharedPlan, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	opts := options.Find().SetSort(bson.M{"created_at": -1})
+	cursor, err := pss.shares.Find(ctx, listedShareFilter(time.Now()), opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var shares []*models.PlanShare
+	if err := cursor.All(ctx, &shares); err != nil {
+		return nil, err
+	}
+
+	// Link each plan with its newest listed share
+	byPlan := make(map[string]*models.PlanShare)
+	var planIDs []primitive.ObjectID
+	for _, share := range shares {
+		if _, ok := byPlan[share.PlanID]; ok {
+			continue
+		}
+		objID, err := primitive.ObjectIDFromHex(share.PlanID)
+		if err != nil {
+			continue
+		}
+		byPlan[share.PlanID] = share
+		planIDs = append(planIDs, objID)
+	}
+
+	popular := []*models.SharedPlan{}
+	if len(planIDs) == 0 {
+		return popular, nil
+	}
+
+	planOpts := options.Find().
+		SetSort(bson.D{{Key: "clone_count", Value: -1}, {Key: "created_at", Value: -1}}).
+		SetLimit(limit)
+	planCursor, err := pss.plans.collection.Find(ctx,
+		bson.M{"_id": bson.M{"$in": planIDs}, "clone_count": bson.M{"$gt": 0}, "deleted_at": bson.M{"$exists": false}}, planOpts)
+	if err != nil {
+		return nil, err
+	}
+	defer planCursor.Close(ctx)
+
+	var plans []*models.PracticePlan
+	if err := planCursor.All(ctx, &plans); err != nil {
+		return nil, err
+	}
+
+	for _, plan := range plans {
+		fillLegacyTaskIDs(plan.Tasks)
+		popular = append(popular, sharedPlanView(byPlan[plan.ID], plan))
+	}
+	return popular, nil
+}
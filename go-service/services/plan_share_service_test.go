@@ -0,0 +1,75 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"neuro-guide-go-service/models"
+
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestPlanShareIsActive(t *testing.T) {
+	now := time.Date(2025, 3, 10, 12, 0, 0, 0, time.UTC)
+	later := now.Add(time.Hour)
+	earlier := now.Add(-time.Hour)
+
+	assert.True(t, (&models.PlanShare{}).IsActive(now))
+	assert.True(t, (&models.PlanShare{ExpiresAt: &later}).IsActive(now))
+	assert.False(t, (&models.PlanShare{ExpiresAt: &earlier}).IsActive(now))
+	assert.False(t, (&models.PlanShare{ExpiresAt: &now}).IsActive(now))
+	assert.False(t, (&models.PlanShare{RevokedAt: &earlier}).IsActive(now))
+}
+
+func TestNewShareToken(t *testing.T) {
+	a, err := newShareToken()
+	assert.NoError(t, err)
+	b, err := newShareToken()
+	assert.NoError(t, err)
+
+	assert.Len(t, a, 22)
+	assert.NotEqual(t, a, b)
+}
+
+func TestListedShareFilter(t *testing.T) {
+	now := time.Date(2025, 3, 10, 12, 0, 0, 0, time.UTC)
+
+	filter := listedShareFilter(now)
+	assert.Equal(t, true, filter["listed"])
+	assert.Equal(t, bson.M{"$exists": false}, filter["revoked_at"])
+	assert.NotContains(t, activeShareFilter(now), "listed")
+}
+
+func TestSharedPlanViewHidesOwner(t *testing.T) {
+	plan := testPlan(2)
+	plan.ID = "plan-1"
+	plan.UserID = "owner"
+	plan.StartDate = "2025-03-01"
+	plan.CloneCount = 3
+	share := &models.PlanShare{Token: "abc", PlanID: "plan-1", UserID: "owner"}
+
+	view := sharedPlanView(share, plan)
+	assert.Equal(t, "abc", view.Token)
+	assert.Equal(t, "plan-1", view.PlanID)
+	assert.Equal(t, plan.Title, view.Title)
+	assert.Equal(t, 3, view.CloneCount)
+	assert.Len(t, view.Tasks, 2)
+}
+
+func TestPlanFromShared(t *testing.T) {
+	plan := testPlan(2)
+	plan.ID = "plan-1"
+	plan.Tasks[0].ID = "t1"
+	shared := sharedPlanView(&models.PlanShare{Token: "abc"}, plan)
+
+	clone := PlanFromShared(shared, "friend")
+	assert.Equal(t, "friend", clone.UserID)
+	assert.Equal(t, models.PlanSourceClone, clone.Source)
+	assert.Equal(t, "plan-1", clone.ClonedFrom)
+	assert.Equal(t, "t1", clone.Tasks[0].ID)
+
+	// Editing the clone must not change the source
+	clone.Tasks[0].Title = "改过的任务"
+	assert.Equal(t, "腹式呼吸", plan.Tasks[0].Title)
+}
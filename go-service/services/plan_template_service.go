@@ -0,0 +1,341 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"neuro-guide-go-service/config"
+	"neuro-guide-go-service/database"
+	"neuro-guide-go-service/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// ErrPlanTemplateNotFound is returned when a template does not exist or is not published
+var ErrPlanTemplateNotFound = errors.New("plan template not found")
+
+// TemplateQuery filters plan templates
+type TemplateQuery struct {
+	Query              string // matched against the title, description, tags and rationale
+	Category           string
+	Difficulty         string
+	Tag                string
+	MaxDays            int // 0 means any duration
+	IncludeUnpublished bool
+}
+
+// PlanTemplateService manages the curated plan template library
+type PlanTemplateService struct {
+	collection *mongo.Collection
+	plans      *PracticePlanService
+}
+
+// NewPlanTemplateService creates a new instance of PlanTemplateService
+func NewPlanTemplateService(cfg *config.Config) *PlanTemplateService {
+	return &PlanTemplateService{
+		collection: database.Database.Collection("plan_templates"),
+		plans:      NewPracticePlanService(cfg),
+	}
+}
+
+// ValidateTemplate checks a template before it is saved. Templates must meet the
+// same bar as generated plans, so every task explains its scientific basis.
+func ValidateTemplate(template *models.PlanTemplate) error {
+	var problems []string
+
+	if template.Days < 1 || template.Days > MaxPlanDays {
+		problems = append(problems, fmt.Sprintf("days must be between 1 and %d", MaxPlanDays))
+	}
+	if !models.IsValidTemplateCategory(template.Category) {
+		problems = append(problems, fmt.Sprintf("unknown category %q", template.Category))
+	}
+	if !models.IsValidDifficulty(template.Difficulty) {
+		problems = append(problems, fmt.Sprintf("unknown difficulty %q", template.Difficulty))
+	}
+	if strings.TrimSpace(template.Rationale) == "" {
+		problems = append(problems, "rationale is empty")
+	}
+
+	plan := &models.PracticePlan{Title: template.Title, Days: template.Days, Tasks: template.Tasks}
+	var invalid *PlanValidationError
+	if err := ValidatePlan(plan, template.Days); errors.As(err, &invalid) {
+		problems = append(problems, invalid.Problems...)
+	}
+
+	if len(problems) > 0 {
+		return &PlanValidationError{Problems: problems}
+	}
+	return nil
+}
+
+// CreateTemplate adds a template to the library
+func (pts *PlanTemplateService) CreateTemplate(template *models.PlanTemplate) error {
+	assignTaskIDs(template.Tasks)
+	template.Tags = normalizeTags(template.Tags)
+	if err := ValidateTemplate(template); err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	objID := primitive.NewObjectID()
+	template.ID = objID.Hex()
+	template.InstanceCount = 0
+	template.CreatedAt = time.Now()
+	template.UpdatedAt = template.CreatedAt
+
+	_, err := pts.collection.InsertOne(ctx, bson.M{
+		"_id":            objID,
+		"title":          template.Title,
+		"description":    template.Description,
+		"category":       template.Category,
+		"difficulty":     template.Difficulty,
+		"days":           template.Days,
+		"tasks":          template.Tasks,
+		"tags":           template.Tags,
+		"rationale":      template.Rationale,
+		"published":      template.Published,
+		"instance_count": template.InstanceCount,
+		"created_at":     template.CreatedAt,
+		"updated_at":     template.UpdatedAt,
+	})
+	return err
+}
+
+// UpdateTemplate replaces the content of a template. Plans created from it are not changed.
+func (pts *PlanTemplateService) UpdateTemplate(id string, template *models.PlanTemplate) (*models.PlanTemplate, error) {
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, ErrPlanTemplateNotFound
+	}
+
+	assignTaskIDs(template.Tasks)
+	template.Tags = normalizeTags(template.Tags)
+	if err := ValidateTemplate(template); err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var updated models.PlanTemplate
+	err = pts.collection.FindOneAndUpdate(ctx,
+		bson.M{"_id": objID},
+		bson.M{"$set": bson.M{
+			"title":       template.Title,
+			"description": template.Description,
+			"category":    template.Category,
+			"difficulty":  template.Difficulty,
+			"days":        template.Days,
+			"tasks":       template.Tasks,
+			"tags":        template.Tags,
+			"rationale":   template.Rationale,
+			"published":   template.Published,
+			"updated_at":  time.Now(),
+		}},
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&updated)
+	if err == mongo.ErrNoDocuments {
+		return nil, ErrPlanTemplateNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &updated, nil
+}
+
+// DeleteTemplate removes a template from the library. Plans created from it are kept.
+func (pts *PlanTemplateService) DeleteTemplate(id string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return ErrPlanTemplateNotFound
+	}
+
+	result, err := pts.collection.DeleteOne(ctx, bson.M{"_id": objID})
+	if err != nil {
+		return err
+	}
+	if result.DeletedCount == 0 {
+		return ErrPlanTemplateNotFound
+	}
+	return nil
+}
+
+// GetTemplate retrieves a template by ID. Unpublished templates are only returned to admins.
+func (pts *PlanTemplateService) GetTemplate(id string, includeUnpublished bool) (*models.PlanTemplate, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	return pts.findTemplate(ctx, id, includeUnpublished)
+}
+
+// findTemplate loads a template by ID
+func (pts *PlanTemplateService) findTemplate(ctx context.Context, id string, includeUnpublished bool) (*models.PlanTemplate, error) {
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, ErrPlanTemplateNotFound
+	}
+
+	filter := bson.M{"_id": objID}
+	if !includeUnpublished {
+		filter["published"] = true
+	}
+
+	var template models.PlanTemplate
+	err = pts.collection.FindOne(ctx, filter).Decode(&template)
+	if err == mongo.ErrNoDocuments {
+		return nil, ErrPlanTemplateNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &template, nil
+}
+
+// ListTemplates returns the templates matching a query, most used first
+func (pts *PlanTemplateService) ListTemplates(query TemplateQuery) ([]*models.PlanTemplate, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	filter := bson.M{}
+	if !query.IncludeUnpublished {
+		filter["published"] = true
+	}
+	if query.Category != "" {
+		filter["category"] = query.Category
+	}
+	if query.Difficulty != "" {
+		filter["difficulty"] = query.Difficulty
+	}
+	if query.Tag != "" {
+		filter["tags"] = query.Tag
+	}
+	if query.MaxDays > 0 {
+		filter["days"] = bson.M{"$lte": query.MaxDays}
+	}
+	opts := options.Find().SetSort(bson.D{{Key: "instance_count", Value: -1}, {Key: "created_at", Value: -1}})
+
+	cursor, err := pts.collection.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var stored []*models.PlanTemplate
+	if err := cursor.All(ctx, &stored); err != nil {
+		return nil, err
+	}
+
+	templates := []*models.PlanTemplate{}
+	for _, template := range stored {
+		if matchesTemplateQuery(template, query.Query) {
+			templates = append(templates, template)
+		}
+	}
+
+	return templates, nil
+}
+
+// matchesTemplateQuery reports whether every word of the query appears in the template
+func matchesTemplateQuery(template *models.PlanTemplate, query string) bool {
+	words := strings.Fields(strings.ToLower(query))
+	if len(words) == 0 {
+		return true
+	}
+
+	text := strings.ToLower(template.Title + "\n" + template.Description + "\n" +
+		template.Rationale + "\n" + strings.Join(template.Tags, " "))
+
+	for _, word := range words {
+		if !strings.Contains(text, word) {
+			return false
+		}
+	}
+	return true
+}
+
+// ListCategories returns every template category with its number of published templates
+func (pts *PlanTemplateService) ListCategories() ([]models.TemplateCategoryCount, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	cursor, err := pts.collection.Aggregate(ctx, mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"published": true}}},
+		{{Key: "$group", Value: bson.M{"_id": "$category", "count": bson.M{"$sum": 1}}}},
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var groups []struct {
+		Category string `bson:"_id"`
+		Count    int    `bson:"count"`
+	}
+	if err := cursor.All(ctx, &groups); err != nil {
+		return nil, err
+	}
+
+	counts := make(map[string]int, len(groups))
+	for _, g := range groups {
+		counts[g.Category] = g.Count
+	}
+
+	categories := make([]models.TemplateCategoryCount, 0, len(models.TemplateCategories))
+	for _, category := range models.TemplateCategories {
+		categories = append(categories, models.TemplateCategoryCount{Category: category, Count: counts[category]})
+	}
+	return categories, nil
+}
+
+// PlanFromTemplate copies a template into a new practice plan for a user
+func PlanFromTemplate(template *models.PlanTemplate, userID string) *models.PracticePlan {
+	tasks := make([]models.PlanTask, len(template.Tasks))
+	copy(tasks, template.Tasks)
+
+	return &models.PracticePlan{
+		UserID:     userID,
+		Title:      template.Title,
+		Days:       template.Days,
+		Tasks:      tasks,
+		Source:     models.PlanSourceTemplate,
+		TemplateID: template.ID,
+	}
+}
+
+// Instantiate creates a practice plan for a user from a published template
+func (pts *PlanTemplateService) Instantiate(userID, templateID string, schedule PlanSchedule) (*models.PracticePlan, error) {
+	template, err := pts.GetTemplate(templateID, false)
+	if err != nil {
+		return nil, err
+	}
+
+	plan := PlanFromTemplate(template, userID)
+	if err := pts.plans.CreatePlan(plan, schedule); err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	// The plan exists either way, so a failed count is only logged
+	if _, err := pts.collection.UpdateOne(ctx, bson.M{"_id": messageObjectID(template.ID)},
+		bson.M{"$inc": bson.M{"instance_count": 1}}); err != nil {
+		log.Printf("Failed to count instance of template %s: %v", template.ID, err)
+	}
+
+	return plan, nil
+}
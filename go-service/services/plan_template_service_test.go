@@ -0,0 +1,79 @@
+package services
+
+import (
+	"errors"
+	"testing"
+
+	"neuro-guide-go-service/models"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func testTemplate(days int) *models.PlanTemplate {
+	plan := testPlan(days)
+	return &models.PlanTemplate{
+		ID:         "tmpl-1",
+		Title:      plan.Title,
+		Category:   models.TemplateCategoryMindfulness,
+		Difficulty: models.DifficultyBeginner,
+		Days:       days,
+		Tasks:      plan.Tasks,
+		Tags:       []string{"呼吸", "入门"},
+		Rationale:  "规律的慢呼吸练习可增强前额叶对杏仁核的调控",
+	}
+}
+
+func TestValidateTemplate(t *testing.T) {
+	assert.NoError(t, ValidateTemplate(testTemplate(7)))
+
+	template := testTemplate(3)
+	template.Category = "yoga"
+	template.Difficulty = ""
+	template.Rationale = " "
+	template.Tasks[0].ScientificBasis = ""
+
+	err := ValidateTemplate(template)
+	var invalid *PlanValidationError
+	assert.True(t, errors.As(err, &invalid))
+	assert.Equal(t, []string{
+		`unknown category "yoga"`,
+		`unknown difficulty ""`,
+		"rationale is empty",
+		"task 1: scientific basis is empty",
+	}, invalid.Problems)
+}
+
+func TestValidateTemplateDays(t *testing.T) {
+	template := testTemplate(1)
+	template.Days = MaxPlanDays + 1
+
+	err := ValidateTemplate(template)
+	var invalid *PlanValidationError
+	assert.True(t, errors.As(err, &invalid))
+	assert.Contains(t, invalid.Problems, "days must be between 1 and 90")
+}
+
+func TestMatchesTemplateQuery(t *testing.T) {
+	template := testTemplate(7)
+
+	assert.True(t, matchesTemplateQuery(template, ""))
+	assert.True(t, matchesTemplateQuery(template, "呼吸 杏仁核"))
+	assert.True(t, matchesTemplateQuery(template, "入门"))
+	assert.False(t, matchesTemplateQuery(template, "呼吸 睡眠"))
+}
+
+func TestPlanFromTemplate(t *testing.T) {
+	template := testTemplate(2)
+	template.Tasks[0].ID = "t1"
+
+	plan := PlanFromTemplate(template, "user-1")
+	assert.Equal(t, "user-1", plan.UserID)
+	assert.Equal(t, models.PlanSourceTemplate, plan.Source)
+	assert.Equal(t, "tmpl-1", plan.TemplateID)
+	assert.Equal(t, 2, plan.Days)
+	assert.Equal(t, "t1", plan.Tasks[0].ID)
+
+	// Editing the plan must not change the template
+	plan.Tasks[0].Title = "改过的任务"
+	assert.Equal(t, "腹式呼吸", template.Tasks[0].Title)
+}
@@ -2,8 +2,14 @@ package services
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"log"
+	"reflect"
+	"strings"
 	"time"
 
+	"neuro-guide-go-service/config"
 	"neuro-guide-go-service/database"
 	"neuro-guide-go-service/models"
 
@@ -15,66 +21,383 @@ import (
 
 // PracticePlanService handles practice plan-related business logic
 type PracticePlanService struct {
-	collection *mongo.Collection
+	collection   *mongo.Collection
+	versions     *mongo.Collection
+	records      *mongo.Collection
+	shares       *mongo.Collection
+	adjustments  *mongo.Collection
+	location     *time.Location // time zone of plans without one
+	recordPolicy string         // what happens to the records of deleted plans
 }
 
 // NewPracticePlanService creates a new instance of PracticePlanService
-func NewPracticePlanService() *PracticePlanService {
+func NewPracticePlanService(cfg *config.Config) *PracticePlanService {
 	return &PracticePlanService{
-		collection: database.Database.Collection("practice_plans"),
+		collection:   database.Database.Collection("practice_plans"),
+		versions:     database.Database.Collection("practice_plan_versions"),
+		records:      database.Database.Collection("practice_records"),
+		shares:       database.Database.Collection("plan_shares"),
+		adjustments:  database.Database.Collection("plan_adjustments"),
+		location:     defaultUserLocation(cfg),
+		recordPolicy: deletedPlanRecords(cfg.DeletedPlanRecords),
 	}
 }
 
-// CreatePlan creates a new practice plan
-func (pps *PracticePlanService) CreatePlan(plan *models.PracticePlan) error {
-	plan.ID = primitive.NewObjectID().Hex()
-	plan.CreatedAt = time.Now()
+// EnsurePlanIndexes creates the indexes practice plans rely on
+func EnsurePlanIndexes() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
 
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	// Previews that were neither accepted nor discarded expire
+	_, err := database.Database.Collection("plan_previews").Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "expires_at", Value: 1}},
+		Options: options.Index().SetExpireAfterSeconds(0),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create plan preview index: %w", err)
+	}
+
+	// Each version number exists once per plan
+	_, err = database.Database.Collection("practice_plan_versions").Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "plan_id", Value: 1}, {Key: "version", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create plan version index: %w", err)
+	}
+
+	// Share links are looked up by token
+	_, err = database.Database.Collection("plan_shares").Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "token", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create plan share index: %w", err)
+	}
+
+	// Each user has one calendar feed, looked up by token
+	_, err = database.Database.Collection("calendar_feeds").Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "user_id", Value: 1}}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.D{{Key: "token", Value: 1}}, Options: options.Index().SetUnique(true)},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create calendar feed index: %w", err)
+	}
+	return nil
+}
+
+// ErrPlanNotFound is returned when a plan does not exist or belongs to another user
+var ErrPlanNotFound = errors.New("plan not found")
+
+// ErrPlanVersionNotFound is returned when a plan has no such version
+var ErrPlanVersionNotFound = errors.New("plan version not found")
+
+// ErrPlanTaskNotFound is returned when a plan has no such task
+var ErrPlanTaskNotFound = errors.New("plan task not found")
+
+// ErrPlanVersionConflict is returned when a plan changed since the version the client edited
+var ErrPlanVersionConflict = errors.New("plan was changed by another request")
+
+// CreatePlan creates a new practice plan running on the given schedule
+func (pps *PracticePlanService) CreatePlan(plan *models.PracticePlan, schedule PlanSchedule) error {
+	if err := applySchedule(plan, schedule, pps.location, time.Now()); err != nil {
+		return err
+	}
+	if err := validatePlanContent(plan); err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
-	objID, err := primitive.ObjectIDFromHex(plan.ID)
+	return withTransaction(ctx, func(ctx context.Context) error {
+		return pps.insertPlan(ctx, plan)
+	}, func(ctx context.Context) {
+		pps.removePlan(ctx, plan.ID)
+	})
+}
+
+// ImportMarkdown parses a plan in the Markdown plan format and creates it for the user.
+// With dryRun the plan is only checked. Problems are reported by line as a *PlanMarkdownError.
+func (pps *PracticePlanService) ImportMarkdown(userID, text string, schedule PlanSchedule, dryRun bool) (*models.PracticePlan, error) {
+	plan, taskLines, err := parsePlanMarkdown(text)
+	if err != nil {
+		return nil, err
+	}
+	plan.UserID = userID
+	plan.Source = models.PlanSourceMarkdown
+
+	err = applySchedule(plan, schedule, pps.location, time.Now())
+	if err == nil {
+		err = validatePlanContent(plan)
+	}
+	var invalid *PlanValidationError
+	if errors.As(err, &invalid) {
+		return nil, &PlanMarkdownError{Errors: markdownLineErrors(invalid.Problems, taskLines)}
+	}
+	if err != nil || dryRun {
+		return plan, err
+	}
+
+	if err := pps.CreatePlan(plan, schedule); err != nil {
+		return nil, err
+	}
+	return plan, nil
+}
+
+// insertPlan assigns IDs to a plan and its tasks and inserts it together with its first version
+func (pps *PracticePlanService) insertPlan(ctx context.Context, plan *models.PracticePlan) error {
+	assignTaskIDs(plan.Tasks)
+
+	objID := primitive.NewObjectID()
+	plan.ID = objID.Hex()
+	plan.Version = 1
+	plan.CreatedAt = time.Now()
+	plan.UpdatedAt = plan.CreatedAt
+	if plan.Source == "" {
+		plan.Source = models.PlanSourceManual
+	}
+	if plan.Status != models.PlanStatusDraft {
+		plan.Status = models.PlanStatusActive
+	}
+	plan.StatusHistory = []models.PlanStatusChange{{To: plan.Status, At: plan.CreatedAt}}
+
+	doc := bson.M{
+		"_id":            objID,
+		"user_id":        plan.UserID,
+		"title":          plan.Title,
+		"days":           plan.Days,
+		"tasks":          plan.Tasks,
+		"source":         plan.Source,
+		"version":        plan.Version,
+		"status":         plan.Status,
+		"status_history": plan.StatusHistory,
+		"created_at":     plan.CreatedAt,
+		"updated_at":     plan.UpdatedAt,
+	}
+	if plan.Variant != nil {
+		doc["variant"] = plan.Variant
+	}
+	if plan.TemplateID != "" {
+		doc["template_id"] = plan.TemplateID
+	}
+	if plan.ClonedFrom != "" {
+		doc["cloned_from"] = plan.ClonedFrom
+	}
+	if plan.CohortID != "" {
+		doc["cohort_id"] = plan.CohortID
+	}
+	if plan.StartDate != "" {
+		doc["start_date"] = plan.StartDate
+	}
+	if plan.TimeZone != "" {
+		doc["time_zone"] = plan.TimeZone
+	}
+	if len(plan.RestWeekdays) > 0 {
+		doc["rest_weekdays"] = plan.RestWeekdays
+	}
+
+	if _, err := pps.collection.InsertOne(ctx, doc); err != nil {
+		return err
+	}
+
+	return pps.insertVersion(ctx, snapshotPlan(plan, models.PlanChangeCreated, 0))
+}
+
+// removePlan deletes a plan and its versions, undoing insertPlan
+func (pps *PracticePlanService) removePlan(ctx context.Context, planID string) {
+	if planID == "" {
+		return
+	}
+	if _, err := pps.collection.DeleteOne(ctx, bson.M{"_id": messageObjectID(planID)}); err != nil {
+		log.Printf("Failed to remove plan %s: %v", planID, err)
+	}
+	if _, err := pps.versions.DeleteMany(ctx, bson.M{"plan_id": planID}); err != nil {
+		log.Printf("Failed to remove versions of plan %s: %v", planID, err)
+	}
+}
+
+// snapshotPlan returns the version document of a plan's current content
+func snapshotPlan(plan *models.PracticePlan, change string, restoredFrom int) *models.PlanVersion {
+	changedAt := plan.UpdatedAt
+	if changedAt.IsZero() {
+		changedAt = plan.CreatedAt
+	}
+
+	return &models.PlanVersion{
+		ID:           primitive.NewObjectID().Hex(),
+		PlanID:       plan.ID,
+		UserID:       plan.UserID,
+		Version:      plan.CurrentVersion(),
+		Title:        plan.Title,
+		Days:         plan.Days,
+		Tasks:        plan.Tasks,
+		Change:       change,
+		RestoredFrom: restoredFrom,
+		CreatedAt:    changedAt,
+	}
+}
+
+// insertVersion stores a plan version. Versions are never updated.
+func (pps *PracticePlanService) insertVersion(ctx context.Context, version *models.PlanVersion) error {
+	objID, err := primitive.ObjectIDFromHex(version.ID)
 	if err != nil {
 		return err
 	}
 
-	_, err = pps.collection.InsertOne(ctx, bson.M{
+	doc := bson.M{
 		"_id":        objID,
-		"user_id":    plan.UserID,
-		"title":      plan.Title,
-		"days":       plan.Days,
-		"tasks":      plan.Tasks,
-		"created_at": plan.CreatedAt,
-	})
+		"plan_id":    version.PlanID,
+		"user_id":    version.UserID,
+		"version":    version.Version,
+		"title":      version.Title,
+		"days":       version.Days,
+		"tasks":      version.Tasks,
+		"change":     version.Change,
+		"created_at": version.CreatedAt,
+	}
+	if version.RestoredFrom > 0 {
+		doc["restored_from"] = version.RestoredFrom
+	}
 
+	_, err = pps.versions.InsertOne(ctx, doc)
 	return err
 }
 
-// GetPlanByID retrieves a practice plan by ID
-func (pps *PracticePlanService) GetPlanByID(id string) (*models.PracticePlan, error) {
+// maxTaskMinutes is the longest expected duration of a task
+const maxTaskMinutes = 240
+
+// validatePlanContent checks a plan written by the user
+func validatePlanContent(plan *models.PracticePlan) error {
+	var problems []string
+
+	if strings.TrimSpace(plan.Title) == "" {
+		problems = append(problems, "title is empty")
+	}
+	if plan.Days < 1 || plan.Days > MaxPlanDays {
+		problems = append(problems, fmt.Sprintf("days must be between 1 and %d", MaxPlanDays))
+	}
+	for i, task := range plan.Tasks {
+		if task.Day < 1 || task.Day > plan.Days {
+			problems = append(problems, fmt.Sprintf("task %d: day %d is outside 1-%d", i+1, task.Day, plan.Days))
+		}
+		if strings.TrimSpace(task.Title) == "" {
+			problems = append(problems, fmt.Sprintf("task %d: title is empty", i+1))
+		}
+	}
+	problems = append(problems, taskProblems(plan.Tasks)...)
+	problems = append(problems, scheduleProblems(plan)...)
+
+	if len(problems) > 0 {
+		return &PlanValidationError{Problems: problems}
+	}
+	return nil
+}
+
+// taskProblems checks the task IDs and the optional task fields
+func taskProblems(tasks []models.PlanTask) []string {
+	var problems []string
+
+	ids := make(map[string]bool, len(tasks))
+	for i, task := range tasks {
+		if task.ID != "" {
+			if ids[task.ID] {
+				problems = append(problems, fmt.Sprintf("task %d: duplicate id %s", i+1, task.ID))
+			}
+			ids[task.ID] = true
+		}
+		if task.Type != "" && !models.IsValidTaskType(task.Type) {
+			problems = append(problems, fmt.Sprintf("task %d: unknown type %q", i+1, task.Type))
+		}
+		if task.TimeOfDay != "" && !models.IsValidTimeOfDay(task.TimeOfDay) {
+			problems = append(problems, fmt.Sprintf("task %d: unknown time of day %q", i+1, task.TimeOfDay))
+		}
+		if task.DurationMinutes < 0 || task.DurationMinutes > maxTaskMinutes {
+			problems = append(problems, fmt.Sprintf("task %d: duration must be between 0 and %d minutes", i+1, maxTaskMinutes))
+		}
+		if len(task.ReferenceIDs) > maxTaskReferences {
+			problems = append(problems, fmt.Sprintf("task %d: at most %d references", i+1, maxTaskReferences))
+		}
+		for _, id := range task.ReferenceIDs {
+			if !primitive.IsValidObjectID(id) {
+				problems = append(problems, fmt.Sprintf("task %d: invalid reference id %q", i+1, id))
+			}
+		}
+	}
+
+	return problems
+}
+
+// assignTaskIDs gives new tasks an ID. Existing IDs are kept so records stay linked.
+func assignTaskIDs(tasks []models.PlanTask) {
+	for i := range tasks {
+		if tasks[i].ID == "" {
+			tasks[i].ID = primitive.NewObjectID().Hex()
+		}
+	}
+}
+
+// fillLegacyTaskIDs names the tasks of plans created before tasks had IDs by position.
+// The IDs are saved with the plan's next version.
+func fillLegacyTaskIDs(tasks []models.PlanTask) {
+	for i := range tasks {
+		if tasks[i].ID == "" {
+			tasks[i].ID = fmt.Sprintf("task-%d", i+1)
+		}
+	}
+}
+
+// FindTask returns the index of a task in a plan, or -1
+func FindTask(plan *models.PracticePlan, taskID string) int {
+	for i, task := range plan.Tasks {
+		if task.ID == taskID {
+			return i
+		}
+	}
+	return -1
+}
+
+// GetPlan retrieves a user's practice plan by ID
+func (pps *PracticePlanService) GetPlan(userID, id string) (*models.PracticePlan, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
-	objID, err := primitive.ObjectIDFromHex(id)
+	plan, err := pps.findPlan(ctx, userID, id)
 	if err != nil {
 		return nil, err
 	}
 
+	plan.Version = plan.CurrentVersion()
+	return plan, nil
+}
+
+// findPlan loads a user's plan that is not in the trash, with its status brought up to date
+func (pps *PracticePlanService) findPlan(ctx context.Context, userID, id string) (*models.PracticePlan, error) {
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, ErrPlanNotFound
+	}
+
 	var plan models.PracticePlan
-	err = pps.collection.FindOne(ctx, bson.M{"_id": objID}).Decode(&plan)
+	err = pps.collection.FindOne(ctx, bson.M{"_id": objID, "user_id": userID, "deleted_at": bson.M{"$exists": false}}).Decode(&plan)
+	if err == mongo.ErrNoDocuments {
+		return nil, ErrPlanNotFound
+	}
 	if err != nil {
 		return nil, err
 	}
 
+	fillLegacyTaskIDs(plan.Tasks)
+	pps.settle(ctx, &plan, time.Now())
 	return &plan, nil
 }
 
-// GetPlansByUserID retrieves all practice plans for a user
+// GetPlansByUserID retrieves all practice plans of a user that are not in the trash
 func (pps *PracticePlanService) GetPlansByUserID(userID string) ([]*models.PracticePlan, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
-	filter := bson.M{"user_id": userID}
+	filter := bson.M{"user_id": userID, "deleted_at": bson.M{"$exists": false}}
 	opts := options.Find().SetSort(bson.M{"created_at": -1})
 
 	cursor, err := pps.collection.Find(ctx, filter, opts)
@@ -88,19 +411,456 @@ func (pps *PracticePlanService) GetPlansByUserID(userID string) ([]*models.Pract
 		return nil, err
 	}
 
+	now := time.Now()
+	for _, plan := range plans {
+		plan.Version = plan.CurrentVersion()
+		fillLegacyTaskIDs(plan.Tasks)
+		pps.settle(ctx, plan, now)
+	}
+
 	return plans, nil
 }
 
-// DeletePlan deletes a practice plan
-func (pps *PracticePlanService) DeletePlan(id string) error {
+// UpdatePlan applies edit to a user's plan and saves the result as a new version.
+// A non-zero expectedVersion must match the plan's current version.
+func (pps *PracticePlanService) UpdatePlan(userID, planID string, expectedVersion int, edit func(plan *models.PracticePlan) error) (*models.PracticePlan, error) {
+	return pps.saveVersion(userID, planID, expectedVersion, models.PlanChangeUpdated, 0, edit)
+}
+
+// RestoreVersion saves the content of an earlier version as a new version of the plan
+func (pps *PracticePlanService) RestoreVersion(userID, planID string, version, expectedVersion int) (*models.PracticePlan, error) {
+	old, err := pps.GetVersion(userID, planID, version)
+	if err != nil {
+		return nil, err
+	}
+
+	return pps.saveVersion(userID, planID, expectedVersion, models.PlanChangeRestored, version, func(plan *models.PracticePlan) error {
+		plan.Title = old.Title
+		plan.Days = old.Days
+		plan.Tasks = old.Tasks
+		return nil
+	})
+}
+
+// saveVersion updates a plan and records the new version in one transaction
+func (pps *PracticePlanService) saveVersion(userID, planID string, expectedVersion int, change string, restoredFrom int, edit func(plan *models.PracticePlan) error) (*models.PracticePlan, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var current, next *models.PracticePlan
+	var legacySnapshot, updated bool
+	err := withTransaction(ctx, func(ctx context.Context) error {
+		legacySnapshot, updated = false, false
+
+		var err error
+		current, err = pps.findPlan(ctx, userID, planID)
+		if err != nil {
+			return err
+		}
+		if !isEditable(current.Status) {
+			return ErrPlanNotEditable
+		}
+		if expectedVersion > 0 && expectedVersion != current.CurrentVersion() {
+			return ErrPlanVersionConflict
+		}
+
+		copied := *current
+		copied.Tasks = append([]models.PlanTask(nil), current.Tasks...)
+		next = &copied
+		if err := edit(next); err != nil {
+			return err
+		}
+		assignTaskIDs(next.Tasks)
+		if err := validatePlanContent(next); err != nil {
+			return err
+		}
+		next.Version = current.CurrentVersion() + 1
+		next.UpdatedAt = time.Now()
+
+		// Plans created before versioning get their original content as version 1
+		if current.Version == 0 {
+			if err := pps.insertVersion(ctx, snapshotPlan(current, models.PlanChangeCreated, 0)); err != nil {
+				return err
+			}
+			legacySnapshot = true
+		}
+
+		result, err := pps.collection.UpdateOne(ctx, versionFilter(current), bson.M{"$set": bson.M{
+			"title":      next.Title,
+			"days":       next.Days,
+			"tasks":      next.Tasks,
+			"version":    next.Version,
+			"updated_at": next.UpdatedAt,
+		}})
+		if err != nil {
+			return err
+		}
+		if result.MatchedCount == 0 {
+			return ErrPlanVersionConflict
+		}
+		updated = true
+
+		return pps.insertVersion(ctx, snapshotPlan(next, change, restoredFrom))
+	}, func(ctx context.Context) {
+		if updated {
+			_, err := pps.collection.UpdateOne(ctx, versionFilter(next), revertPlanUpdate(current))
+			if err != nil {
+				log.Printf("Failed to revert plan %s: %v", planID, err)
+			}
+			pps.versions.DeleteOne(ctx, bson.M{"plan_id": planID, "version": next.Version})
+		}
+		if legacySnapshot {
+			pps.versions.DeleteOne(ctx, bson.M{"plan_id": planID, "version": 1})
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return next, nil
+}
+
+// revertPlanUpdate restores a plan to the given content. A legacy plan gets its version
+// field removed again so versionFilter still matches it.
+func revertPlanUpdate(plan *models.PracticePlan) bson.M {
+	set := bson.M{
+		"title":      plan.Title,
+		"days":       plan.Days,
+		"tasks":      plan.Tasks,
+		"updated_at": plan.UpdatedAt,
+	}
+	if plan.Version == 0 {
+		return bson.M{"$set": set, "$unset": bson.M{"version": ""}}
+	}
+	set["version"] = plan.Version
+	return bson.M{"$set": set}
+}
+
+// versionFilter matches a plan only while it is still at the given plan's version
+func versionFilter(plan *models.PracticePlan) bson.M {
+	filter := bson.M{"_id": messageObjectID(plan.ID), "user_id": plan.UserID}
+	if plan.Version == 0 {
+		filter["version"] = bson.M{"$exists": false}
+	} else {
+		filter["version"] = plan.Version
+	}
+	return filter
+}
+
+// GetVersions lists the versions of a user's plan, newest first
+func (pps *PracticePlanService) GetVersions(userID, planID string) ([]*models.PlanVersion, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
-	objID, err := primitive.ObjectIDFromHex(id)
+	plan, err := pps.findPlan(ctx, userID, planID)
 	if err != nil {
-		return err
+		return nil, err
+	}
+	if plan.Version == 0 {
+		return []*models.PlanVersion{snapshotPlan(plan, models.PlanChangeCreated, 0)}, nil
 	}
 
-	_, err = pps.collection.DeleteOne(ctx, bson.M{"_id": objID})
-	return err
+	opts := options.Find().SetSort(bson.M{"version": -1})
+	cursor, err := pps.versions.Find(ctx, bson.M{"plan_id": planID, "user_id": userID}, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var versions []*models.PlanVersion
+	if err := cursor.All(ctx, &versions); err != nil {
+		return nil, err
+	}
+	for _, version := range versions {
+		fillLegacyTaskIDs(version.Tasks)
+	}
+
+	return versions, nil
+}
+
+// GetVersion retrieves one version of a user's plan
+func (pps *PracticePlanService) GetVersion(userID, planID string, version int) (*models.PlanVersion, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	plan, err := pps.findPlan(ctx, userID, planID)
+	if err != nil {
+		return nil, err
+	}
+	if plan.Version == 0 {
+		if version != 1 {
+			return nil, ErrPlanVersionNotFound
+		}
+		return snapshotPlan(plan, models.PlanChangeCreated, 0), nil
+	}
+
+	var snapshot models.PlanVersion
+	err = pps.versions.FindOne(ctx, bson.M{"plan_id": planID, "user_id": userID, "version": version}).Decode(&snapshot)
+	if err == mongo.ErrNoDocuments {
+		return nil, ErrPlanVersionNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	fillLegacyTaskIDs(snapshot.Tasks)
+	return &snapshot, nil
+}
+
+// DiffVersions compares two versions of a user's plan
+func (pps *PracticePlanService) DiffVersions(userID, planID string, from, to int) (*models.PlanDiff, error) {
+	older, err := pps.GetVersion(userID, planID, from)
+	if err != nil {
+		return nil, err
+	}
+	newer, err := pps.GetVersion(userID, planID, to)
+	if err != nil {
+		return nil, err
+	}
+
+	return DiffPlanVersions(older, newer), nil
+}
+
+// DiffPlanVersions lists the changes from one plan version to another.
+// Tasks are matched by ID; changed and added tasks are listed in the newer version's order,
+// followed by removed tasks.
+func DiffPlanVersions(from, to *models.PlanVersion) *models.PlanDiff {
+	diff := &models.PlanDiff{FromVersion: from.Version, ToVersion: to.Version, Tasks: []models.TaskChange{}}
+
+	if from.Title != to.Title {
+		diff.Title = &models.ValueChange{From: from.Title, To: to.Title}
+	}
+	if from.Days != to.Days {
+		diff.Days = &models.ValueChange{From: from.Days, To: to.Days}
+	}
+
+	before := make(map[string]*models.PlanTask, len(from.Tasks))
+	for i := range from.Tasks {
+		before[from.Tasks[i].ID] = &from.Tasks[i]
+	}
+
+	kept := make(map[string]bool, len(to.Tasks))
+	for i := range to.Tasks {
+		task := &to.Tasks[i]
+		old, ok := before[task.ID]
+		switch {
+		case !ok:
+			diff.Tasks = append(diff.Tasks, models.TaskChange{TaskID: task.ID, Change: models.TaskAdded, After: task})
+		case !reflect.DeepEqual(*old, *task):
+			diff.Tasks = append(diff.Tasks, models.TaskChange{TaskID: task.ID, Change: models.TaskModified, Before: old, After: task})
+		}
+		kept[task.ID] = true
+	}
+
+	for i := range from.Tasks {
+		if task := &from.Tasks[i]; !kept[task.ID] {
+			diff.Tasks = append(diff.Tasks, models.TaskChange{TaskID: task.ID, Change: models.TaskRemoved, Before: task})
+		}
+	}
+
+	return diff
+}
+
+// ErrPlanPaused is returned when pausing a plan that is already paused
+var ErrPlanPaused = errors.New("plan is already paused")
+
+// ErrPlanNotPaused is returned when resuming a plan that is not paused
+var ErrPlanNotPaused = errors.New("plan is not paused")
+
+// ErrPlanFinished is returned when pausing a plan whose days are all done
+var ErrPlanFinished = errors.New("plan is finished")
+
+// UpdateSchedule changes the start date, time zone and rest days of a user's plan.
+// The schedule is not part of the plan's versioned content.
+func (pps *PracticePlanService) UpdateSchedule(userID, planID string, schedule PlanSchedule) (*models.PracticePlan, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	plan, err := pps.findPlan(ctx, userID, planID)
+	if err != nil {
+		return nil, err
+	}
+	if !isEditable(plan.Status) {
+		return nil, ErrPlanNotEditable
+	}
+
+	// Keep what the request leaves out
+	if schedule.StartDate == "" {
+		schedule.StartDate = planStart(plan, planLocation(plan, pps.location)).Format(planDateLayout)
+	}
+	if schedule.TimeZone == "" {
+		schedule.TimeZone = plan.TimeZone
+	}
+	if schedule.RestWeekdays == nil {
+		schedule.RestWeekdays = plan.RestWeekdays
+	}
+	if err := applySchedule(plan, schedule, pps.location, time.Now()); err != nil {
+		return nil, err
+	}
+	if problems := scheduleProblems(plan); len(problems) > 0 {
+		return nil, &PlanValidationError{Problems: problems}
+	}
+
+	_, err = pps.collection.UpdateOne(ctx, bson.M{"_id": messageObjectID(planID), "user_id": userID}, bson.M{"$set": bson.M{
+		"start_date":    plan.StartDate,
+		"time_zone":     plan.TimeZone,
+		"rest_weekdays": plan.RestWeekdays,
+	}})
+	if err != nil {
+		return nil, err
+	}
+
+	plan.Version = plan.CurrentVersion()
+	return plan, nil
+}
+
+// PausePlan pauses a user's plan from today on
+func (pps *PracticePlanService) PausePlan(userID, planID string, now time.Time) (*models.PracticePlan, error) {
+	return pps.updatePauses(userID, planID, now, models.PlanStatusPaused, func(plan *models.PracticePlan, today PlanDay) error {
+		if plan.IsPaused() {
+			return ErrPlanPaused
+		}
+		if today.Status == PlanDayFinished {
+			return ErrPlanFinished
+		}
+		plan.Pauses = append(plan.Pauses, models.PlanPause{From: today.Date})
+		return nil
+	})
+}
+
+// ResumePlan resumes a user's paused plan from today on
+func (pps *PracticePlanService) ResumePlan(userID, planID string, now time.Time) (*models.PracticePlan, error) {
+	return pps.updatePauses(userID, planID, now, models.PlanStatusActive, func(plan *models.PracticePlan, today PlanDay) error {
+		if !plan.IsPaused() {
+			return ErrPlanNotPaused
+		}
+		last := len(plan.Pauses) - 1
+		if plan.Pauses[last].From >= today.Date {
+			// Paused and resumed on the same day: nothing was skipped
+			plan.Pauses = plan.Pauses[:last]
+		} else {
+			plan.Pauses[last].To = today.Date
+		}
+		return nil
+	})
+}
+
+// updatePauses applies change to the pause periods of a plan and moves it to status
+func (pps *PracticePlanService) updatePauses(userID, planID string, now time.Time, status string, change func(plan *models.PracticePlan, today PlanDay) error) (*models.PracticePlan, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	plan, err := pps.findPlan(ctx, userID, planID)
+	if err != nil {
+		return nil, err
+	}
+	if err := change(plan, PlanDayAt(plan, now, pps.location)); err != nil {
+		return nil, err
+	}
+	if !CanTransition(plan.Status, status) {
+		return nil, fmt.Errorf("%w: %s to %s", ErrInvalidPlanTransition, plan.Status, status)
+	}
+
+	stored := plan.Status
+	setStatus(plan, status, now)
+	if err := pps.saveStatus(ctx, plan, stored, bson.M{"pauses": plan.Pauses}); err != nil {
+		return nil, err
+	}
+
+	plan.Version = plan.CurrentVersion()
+	return plan, nil
+}
+
+// TodayTask is a plan task due today
+type TodayTask struct {
+	models.PlanTask
+	Completed bool `json:"completed"`
+}
+
+// TodayPlan is where one of the user's plans stands today
+type TodayPlan struct {
+	PlanID    string `json:"plan_id"`
+	Title     string `json:"title"`
+	TotalDays int    `json:"total_days"`
+	PlanDay
+	Tasks    []TodayTask `json:"tasks"`
+	RecordID string      `json:"record_id,omitempty"` // latest record of today's plan day
+}
+
+// GetToday returns today's tasks of all the user's active and paused plans, including
+// plans that rest today. Completion comes from the practice records.
+func (pps *PracticePlanService) GetToday(userID string, now time.Time) ([]*TodayPlan, error) {
+	plans, err := pps.GetPlansByUserID(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	today := []*TodayPlan{}
+	var practiced []bson.M
+	for _, plan := range plans {
+		if !isRunning(plan.Status) {
+			continue
+		}
+		day := PlanDayAt(plan, now, pps.location)
+		if day.Status == PlanDayNotStarted || day.Status == PlanDayFinished {
+			continue
+		}
+
+		entry := &TodayPlan{PlanID: plan.ID, Title: plan.Title, TotalDays: plan.Days, PlanDay: day, Tasks: []TodayTask{}}
+		if day.Status == PlanDayActive {
+			for _, task := range plan.Tasks {
+				if task.Day == day.Day {
+					entry.Tasks = append(entry.Tasks, TodayTask{PlanTask: task})
+				}
+			}
+			practiced = append(practiced, bson.M{"plan_id": plan.ID, "day": day.Day})
+		}
+		today = append(today, entry)
+	}
+
+	if len(practiced) == 0 {
+		return today, nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	opts := options.Find().
+		SetSort(bson.M{"created_at": 1}).
+		SetProjection(bson.M{"plan_id": 1, "completed_tasks": 1})
+	cursor, err := pps.records.Find(ctx, bson.M{"user_id": userID, "$or": practiced}, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var records []struct {
+		ID             primitive.ObjectID `bson:"_id"`
+		PlanID         string             `bson:"plan_id"`
+		CompletedTasks []string           `bson:"completed_tasks"`
+	}
+	if err := cursor.All(ctx, &records); err != nil {
+		return nil, err
+	}
+
+	completed := make(map[string]map[string]bool)
+	latest := make(map[string]string)
+	for _, record := range records {
+		if completed[record.PlanID] == nil {
+			completed[record.PlanID] = make(map[string]bool)
+		}
+		for _, id := range record.CompletedTasks {
+			completed[record.PlanID][id] = true
+		}
+		latest[record.PlanID] = record.ID.Hex()
+	}
+
+	for _, entry := range today {
+		entry.RecordID = latest[entry.PlanID]
+		for i := range entry.Tasks {
+			entry.Tasks[i].Completed = completed[entry.PlanID][entry.Tasks[i].ID]
+		}
+	}
+
+	return today, nil
 }
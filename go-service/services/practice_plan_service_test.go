@@ -0,0 +1,136 @@
+package services
+
+import (
+	"errors"
+	"testing"
+
+	"neuro-guide-go-service/models"
+
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestValidatePlanContent(t *testing.T) {
+	// Manual plans may be saved without tasks or scientific basis
+	assert.NoError(t, validatePlanContent(&models.PracticePlan{Title: "我的计划", Days: 7}))
+
+	err := validatePlanContent(&models.PracticePlan{
+		Title: " ",
+		Days:  2,
+		Tasks: []models.PlanTask{{Day: 3, Title: "冥想"}, {Day: 1}},
+	})
+	var validationErr *PlanValidationError
+	assert.True(t, errors.As(err, &validationErr))
+	assert.Equal(t, []string{
+		"title is empty",
+		"task 1: day 3 is outside 1-2",
+		"task 2: title is empty",
+	}, validationErr.Problems)
+
+	err = validatePlanContent(&models.PracticePlan{Title: "太长", Days: MaxPlanDays + 1})
+	assert.Error(t, err)
+}
+
+func TestDiffPlanVersions(t *testing.T) {
+	from := &models.PlanVersion{
+		Version: 1,
+		Title:   "睡眠计划",
+		Days:    3,
+		Tasks: []models.PlanTask{
+			{ID: "a", Day: 1, Title: "腹式呼吸"},
+			{ID: "b", Day: 2, Title: "身体扫描"},
+			{ID: "c", Day: 3, Title: "写感恩日记"},
+		},
+	}
+	to := &models.PlanVersion{
+		Version: 2,
+		Title:   "睡眠计划",
+		Days:    2,
+		Tasks: []models.PlanTask{
+			{ID: "b", Day: 2, Title: "身体扫描", Description: "睡前10分钟"},
+			{ID: "a", Day: 1, Title: "腹式呼吸"},
+			{ID: "d", Day: 1, Title: "散步", Optional: true},
+		},
+	}
+
+	diff := DiffPlanVersions(from, to)
+	assert.Equal(t, 1, diff.FromVersion)
+	assert.Equal(t, 2, diff.ToVersion)
+	assert.Nil(t, diff.Title)
+	assert.Equal(t, &models.ValueChange{From: 3, To: 2}, diff.Days)
+	if assert.Len(t, diff.Tasks, 3) {
+		// Reordering alone is not a change
+		assert.Equal(t, "b", diff.Tasks[0].TaskID)
+		assert.Equal(t, models.TaskModified, diff.Tasks[0].Change)
+		assert.Equal(t, "睡前10分钟", diff.Tasks[0].After.Description)
+		assert.Equal(t, "d", diff.Tasks[1].TaskID)
+		assert.Equal(t, models.TaskAdded, diff.Tasks[1].Change)
+		assert.Nil(t, diff.Tasks[1].Before)
+		assert.Equal(t, "c", diff.Tasks[2].TaskID)
+		assert.Equal(t, models.TaskRemoved, diff.Tasks[2].Change)
+		assert.Nil(t, diff.Tasks[2].After)
+	}
+
+	assert.Empty(t, DiffPlanVersions(from, from).Tasks)
+}
+
+func TestTaskProblems(t *testing.T) {
+	tasks := []models.PlanTask{
+		{ID: "a", Type: models.TaskTypeBreathing, TimeOfDay: models.TimeOfDayBedtime, DurationMinutes: 10},
+		{ID: "a", Type: "yoga", TimeOfDay: "noon", DurationMinutes: 600},
+	}
+	assert.Equal(t, []string{
+		"task 2: duplicate id a",
+		`task 2: unknown type "yoga"`,
+		`task 2: unknown time of day "noon"`,
+		"task 2: duration must be between 0 and 240 minutes",
+	}, taskProblems(tasks))
+}
+
+func TestTaskIDs(t *testing.T) {
+	tasks := []models.PlanTask{{Title: "呼吸"}, {ID: "kept", Title: "冥想"}}
+	fillLegacyTaskIDs(tasks)
+	assert.Equal(t, "task-1", tasks[0].ID)
+	assert.Equal(t, "kept", tasks[1].ID)
+
+	tasks = []models.PlanTask{{Title: "呼吸"}, {ID: "kept", Title: "冥想"}}
+	assignTaskIDs(tasks)
+	assert.Len(t, tasks[0].ID, 24)
+	assert.Equal(t, "kept", tasks[1].ID)
+
+	plan := &models.PracticePlan{Tasks: tasks}
+	assert.Equal(t, 1, FindTask(plan, "kept"))
+	assert.Equal(t, -1, FindTask(plan, "missing"))
+}
+
+func TestSnapshotPlanOfLegacyPlan(t *testing.T) {
+	plan := testPlan(2)
+	plan.ID = "plan1"
+
+	snapshot := snapshotPlan(plan, models.PlanChangeCreated, 0)
+	assert.Equal(t, 1, snapshot.Version)
+	assert.Equal(t, plan.CreatedAt, snapshot.CreatedAt)
+	assert.Equal(t, "plan1", snapshot.PlanID)
+
+	filter := versionFilter(plan)
+	assert.Equal(t, bson.M{"$exists": false}, filter["version"])
+
+	plan.Version = 3
+	assert.Equal(t, 3, versionFilter(plan)["version"])
+}
+
+func TestRevertPlanUpdateOfLegacyPlan(t *testing.T) {
+	plan := testPlan(2)
+	plan.ID = "plan1"
+
+	// Rolling back a failed first edit leaves the plan matching the legacy version filter
+	update := revertPlanUpdate(plan)
+	assert.Equal(t, bson.M{"version": ""}, update["$unset"])
+	assert.NotContains(t, update["$set"], "version")
+	assert.Equal(t, plan.Tasks, update["$set"].(bson.M)["tasks"])
+
+	plan.Version = 3
+	update = revertPlanUpdate(plan)
+	assert.NotContains(t, update, "$unset")
+	assert.Equal(t, 3, update["$set"].(bson.M)["version"])
+}
@@ -2,8 +2,11 @@ package services
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"time"
 
+	"neuro-guide-go-service/config"
 	"neuro-guide-go-service/database"
 	"neuro-guide-go-service/models"
 
@@ -16,17 +19,78 @@ import (
 // PracticeRecordService handles practice record-related business logic
 type PracticeRecordService struct {
 	collection *mongo.Collection
+	plans      *PracticePlanService
 }
 
 // NewPracticeRecordService creates a new instance of PracticeRecordService
-func NewPracticeRecordService() *PracticeRecordService {
+func NewPracticeRecordService(cfg *config.Config) *PracticeRecordService {
 	return &PracticeRecordService{
 		collection: database.Database.Collection("practice_records"),
+		plans:      NewPracticePlanService(cfg),
 	}
 }
 
-// CreateRecord creates a new practice record
+// ErrRecordNotFound is returned when a record does not exist or belongs to another user
+var ErrRecordNotFound = errors.New("record not found")
+
+// ErrInvalidCompletedTasks is returned when completed tasks are not tasks of the record's plan day
+var ErrInvalidCompletedTasks = errors.New("invalid completed tasks")
+
+// LinkPlan checks the record's completed tasks against the current version of the user's plan
+// and records that version. Without a day, the day is derived from the record date in the plan's time zone.
+func (prs *PracticeRecordService) LinkPlan(record *models.PracticeRecord) error {
+	plan, err := prs.plans.GetPlan(record.UserID, record.PlanID)
+	if err != nil {
+		return err
+	}
+
+	if record.Day == 0 {
+		if day, ok := currentPlanDay(plan, record.Date, prs.plans.location); ok {
+			record.Day = day
+		}
+	}
+	if err := validateCompletedTasks(plan, record.Day, record.CompletedTasks); err != nil {
+		return err
+	}
+
+	record.PlanVersion = plan.Version
+	return nil
+}
+
+// validateCompletedTasks checks that every completed task ID is a task of the plan on that day
+func validateCompletedTasks(plan *models.PracticePlan, day int, taskIDs []string) error {
+	if len(taskIDs) == 0 {
+		return nil
+	}
+	if day < 1 || day > plan.Days {
+		return fmt.Errorf("%w: day %d is not a day of the plan", ErrInvalidCompletedTasks, day)
+	}
+
+	seen := make(map[string]bool, len(taskIDs))
+	for _, id := range taskIDs {
+		if seen[id] {
+			return fmt.Errorf("%w: task %s is listed twice", ErrInvalidCompletedTasks, id)
+		}
+		seen[id] = true
+
+		i := FindTask(plan, id)
+		if i < 0 {
+			return fmt.Errorf("%w: plan has no task %s", ErrInvalidCompletedTasks, id)
+		}
+		if plan.Tasks[i].Day != day {
+			return fmt.Errorf("%w: task %s belongs to day %d", ErrInvalidCompletedTasks, id, plan.Tasks[i].Day)
+		}
+	}
+
+	return nil
+}
+
+// CreateRecord creates a new practice record against the current version of the user's plan
 func (prs *PracticeRecordService) CreateRecord(record *models.PracticeRecord) error {
+	if err := prs.LinkPlan(record); err != nil {
+		return err
+	}
+
 	record.ID = primitive.NewObjectID().Hex()
 	record.CreatedAt = time.Now()
 	record.UpdatedAt = time.Now()
@@ -39,16 +103,29 @@ func (prs *PracticeRecordService) CreateRecord(record *models.PracticeRecord) er
 		return err
 	}
 
-	_, err = prs.collection.InsertOne(ctx, bson.M{
+	// Encrypt reflection at rest
+	reflection, keyVersion, err := encryptField(record.UserID, record.Reflection)
+	if err != nil {
+		return err
+	}
+
+	doc := bson.M{
 		"_id":             objID,
 		"user_id":         record.UserID,
 		"plan_id":         record.PlanID,
+		"plan_version":    record.PlanVersion,
+		"day":             record.Day,
 		"date":            record.Date,
 		"completed_tasks": record.CompletedTasks,
-		"reflection":      record.Reflection,
+		"reflection":      reflection,
 		"created_at":      record.CreatedAt,
 		"updated_at":      record.UpdatedAt,
-	})
+	}
+	if keyVersion > 0 {
+		doc["key_version"] = keyVersion
+	}
+
+	_, err = prs.collection.InsertOne(ctx, doc)
 
 	return err
 }
@@ -65,34 +142,63 @@ func (prs *PracticeRecordService) UpdateRecord(record *models.PracticeRecord) er
 		return err
 	}
 
-	update := bson.M{
-		"$set": bson.M{
-			"completed_tasks": record.CompletedTasks,
-			"reflection":      record.Reflection,
-			"updated_at":      record.UpdatedAt,
-		},
+	reflection, keyVersion, err := encryptField(record.UserID, record.Reflection)
+	if err != nil {
+		return err
 	}
 
-	_, err = prs.collection.UpdateOne(ctx, bson.M{"_id": objID}, update)
-	return err
+	set := bson.M{
+		"day":             record.Day,
+		"plan_version":    record.PlanVersion,
+		"completed_tasks": record.CompletedTasks,
+		"reflection":      reflection,
+		"updated_at":      record.UpdatedAt,
+	}
+	if keyVersion > 0 {
+		set["key_version"] = keyVersion
+	}
+
+	update := bson.M{"$set": set}
+
+	result, err := prs.collection.UpdateOne(ctx, visibleRecords(bson.M{"_id": objID, "user_id": record.UserID}), update)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return ErrRecordNotFound
+	}
+	return nil
+}
+
+// visibleRecords adds to filter that records hidden with their deleted plan are left out
+func visibleRecords(filter bson.M) bson.M {
+	filter["plan_deleted_at"] = bson.M{"$exists": false}
+	return filter
 }
 
-// GetRecordByID retrieves a practice record by ID
-func (prs *PracticeRecordService) GetRecordByID(id string) (*models.PracticeRecord, error) {
+// GetRecordByID retrieves a user's practice record by ID
+func (prs *PracticeRecordService) GetRecordByID(userID, id string) (*models.PracticeRecord, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
 	objID, err := primitive.ObjectIDFromHex(id)
 	if err != nil {
-		return nil, err
+		return nil, ErrRecordNotFound
 	}
 
 	var record models.PracticeRecord
-	err = prs.collection.FindOne(ctx, bson.M{"_id": objID}).Decode(&record)
+	err = prs.collection.FindOne(ctx, visibleRecords(bson.M{"_id": objID, "user_id": userID})).Decode(&record)
+	if err == mongo.ErrNoDocuments {
+		return nil, ErrRecordNotFound
+	}
 	if err != nil {
 		return nil, err
 	}
 
+	if err := decryptRecord(&record); err != nil {
+		return nil, err
+	}
+
 	return &record, nil
 }
 
@@ -101,7 +207,7 @@ func (prs *PracticeRecordService) GetRecordsByUserID(userID string) ([]*models.P
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
-	filter := bson.M{"user_id": userID}
+	filter := visibleRecords(bson.M{"user_id": userID})
 	opts := options.Find().SetSort(bson.M{"date": -1})
 
 	cursor, err := prs.collection.Find(ctx, filter, opts)
@@ -115,15 +221,21 @@ func (prs *PracticeRecordService) GetRecordsByUserID(userID string) ([]*models.P
 		return nil, err
 	}
 
+	for _, record := range records {
+		if err := decryptRecord(record); err != nil {
+			return nil, err
+		}
+	}
+
 	return records, nil
 }
 
-// GetRecordsByPlanID retrieves all practice records for a plan
-func (prs *PracticeRecordService) GetRecordsByPlanID(planID string) ([]*models.PracticeRecord, error) {
+// GetRecordsByPlanID retrieves a user's practice records for a plan
+func (prs *PracticeRecordService) GetRecordsByPlanID(userID, planID string) ([]*models.PracticeRecord, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
-	filter := bson.M{"plan_id": planID}
+	filter := visibleRecords(bson.M{"user_id": userID, "plan_id": planID})
 	opts := options.Find().SetSort(bson.M{"date": -1})
 
 	cursor, err := prs.collection.Find(ctx, filter, opts)
@@ -137,5 +249,21 @@ func (prs *PracticeRecordService) GetRecordsByPlanID(planID string) ([]*models.P
 		return nil, err
 	}
 
+	for _, record := range records {
+		if err := decryptRecord(record); err != nil {
+			return nil, err
+		}
+	}
+
 	return records, nil
 }
+
+// decryptRecord decrypts the encrypted fields of a practice record in place
+func decryptRecord(record *models.PracticeRecord) error {
+	reflection, err := decryptField(record.UserID, record.Reflection)
+	if err != nil {
+		return err
+	}
+	record.Reflection = reflection
+	return nil
+}
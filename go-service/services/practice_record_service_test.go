@@ -0,0 +1,38 @@
+package services
+
+import (
+	"errors"
+	"testing"
+
+	"neuro-guide-go-service/models"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateCompletedTasks(t *testing.T) {
+	plan := &models.PracticePlan{
+		Days: 2,
+		Tasks: []models.PlanTask{
+			{ID: "breath", Day: 1, Title: "腹式呼吸"},
+			{ID: "journal", Day: 1, Title: "情绪日记", Optional: true},
+			{ID: "scan", Day: 2, Title: "身体扫描"},
+		},
+	}
+
+	assert.NoError(t, validateCompletedTasks(plan, 1, []string{"breath", "journal"}))
+	assert.NoError(t, validateCompletedTasks(plan, 0, nil))
+
+	for _, tc := range []struct {
+		day int
+		ids []string
+	}{
+		{1, []string{"scan"}},             // another day's task
+		{1, []string{"missing"}},          // not a task of the plan
+		{1, []string{"breath", "breath"}}, // listed twice
+		{3, []string{"breath"}},           // outside the plan
+		{0, []string{"breath"}},           // day unknown
+	} {
+		err := validateCompletedTasks(plan, tc.day, tc.ids)
+		assert.True(t, errors.Is(err, ErrInvalidCompletedTasks), "day %d %v", tc.day, tc.ids)
+	}
+}
@@ -0,0 +1,616 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"neuro-guide-go-service/config"
+	"neuro-guide-go-service/database"
+	"neuro-guide-go-service/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// ErrReferenceNotFound is returned when a reference does not exist
+var ErrReferenceNotFound = errors.New("reference not found")
+
+// ErrDuplicateReference is returned when another reference has the same DOI or PubMed ID
+var ErrDuplicateReference = errors.New("a reference with this DOI or PubMed ID already exists")
+
+// ErrInvalidReference is returned when a reference misses required fields or has malformed identifiers
+var ErrInvalidReference = errors.New("invalid reference")
+
+// maxTaskReferences is the number of references a plan task can link
+const maxTaskReferences = 10
+
+var (
+	doiPattern        = regexp.MustCompile(`^10\.\d{4,9}/\S+$`)
+	doiInTextPattern  = regexp.MustCompile(`10\.\d{4,9}/[^\s"<>，。；、）)]+`)
+	pmidPattern       = regexp.MustCompile(`^\d{1,9}$`)
+	pmidInTextPattern = regexp.MustCompile(`(?i)PMID[:：\s]*(\d{1,9})`)
+
+	// "Davidson, 2003", "Hölzel et al., 2011", "Davidson & Kabat-Zinn (2003)"
+	latinCitePattern = regexp.MustCompile(`(\p{Lu}[\p{L}'’-]+)(?:\s+et\s+al\.?|\s*(?:&|and)\s*\p{Lu}[\p{L}'’-]+)?\s*[,，]?\s*[（(]?\s*((?:18|19|20)\d{2})\b`)
+	// "王晓明，2019", "李明等（2020）"
+	hanCitePattern = regexp.MustCompile(`(\p{Han}{1,4})\s*[,，]?\s*[（(]?\s*((?:18|19|20)\d{2})\b`)
+)
+
+// ReferenceQuery filters references
+type ReferenceQuery struct {
+	Query string // matched against the title, journal and author names
+	Year  int    // 0 means any year
+}
+
+// referenceCue is something in a text that points to a reference: a DOI, a PubMed ID,
+// or the first author's name with the year
+type referenceCue struct {
+	DOI      string
+	PubMedID string
+	Author   string
+	Year     int
+}
+
+// ReferenceService manages the scientific references plan tasks and AI answers cite
+type ReferenceService struct {
+	collection *mongo.Collection
+	plans      *PracticePlanService
+}
+
+// NewReferenceService creates a new instance of ReferenceService
+func NewReferenceService(cfg *config.Config) *ReferenceService {
+	return &ReferenceService{
+		collection: database.Database.Collection("references"),
+		plans:      NewPracticePlanService(cfg),
+	}
+}
+
+// EnsureReferenceIndexes creates the indexes references rely on
+func EnsureReferenceIndexes() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	// A publication is stored once, and looked up by its identifiers or year
+	_, err := database.Database.Collection("references").Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			Keys: bson.D{{Key: "doi", Value: 1}},
+			Options: options.Index().SetUnique(true).
+				SetPartialFilterExpression(bson.M{"doi": bson.M{"$exists": true}}),
+		},
+		{
+			Keys: bson.D{{Key: "pubmed_id", Value: 1}},
+			Options: options.Index().SetUnique(true).
+				SetPartialFilterExpression(bson.M{"pubmed_id": bson.M{"$exists": true}}),
+		},
+		{Keys: bson.D{{Key: "year", Value: 1}}},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create reference indexes: %w", err)
+	}
+	return nil
+}
+
+// NormalizeDOI strips resolver prefixes from a DOI and lower-cases it, since DOIs are case-insensitive
+func NormalizeDOI(doi string) string {
+	doi = strings.TrimSpace(doi)
+	lower := strings.ToLower(doi)
+	for _, prefix := range []string{"https://doi.org/", "http://doi.org/", "https://dx.doi.org/", "http://dx.doi.org/", "doi:"} {
+		if strings.HasPrefix(lower, prefix) {
+			lower = strings.TrimSpace(lower[len(prefix):])
+			break
+		}
+	}
+	return lower
+}
+
+// NormalizePubMedID strips a "PMID:" prefix from a PubMed ID
+func NormalizePubMedID(pmid string) string {
+	pmid = strings.TrimSpace(pmid)
+	if len(pmid) >= 4 && strings.EqualFold(pmid[:4], "pmid") {
+		pmid = strings.TrimLeft(pmid[4:], ": ")
+	}
+	return pmid
+}
+
+// ValidateReference normalizes a reference and checks it before it is saved
+func ValidateReference(ref *models.Reference) error {
+	ref.Title = strings.TrimSpace(ref.Title)
+	ref.Journal = strings.TrimSpace(ref.Journal)
+	ref.DOI = NormalizeDOI(ref.DOI)
+	ref.PubMedID = NormalizePubMedID(ref.PubMedID)
+
+	authors := make([]models.ReferenceAuthor, 0, len(ref.Authors))
+	for _, author := range ref.Authors {
+		author.Family = strings.TrimSpace(author.Family)
+		author.Given = strings.TrimSpace(author.Given)
+		if author.Family == "" {
+			return fmt.Errorf("%w: every author needs a family name", ErrInvalidReference)
+		}
+		authors = append(authors, author)
+	}
+	ref.Authors = authors
+
+	switch {
+	case len(ref.Authors) == 0:
+		return fmt.Errorf("%w: at least one author is required", ErrInvalidReference)
+	case ref.Year < 1800 || ref.Year > time.Now().Year()+1:
+		return fmt.Errorf("%w: year %d is out of range", ErrInvalidReference, ref.Year)
+	case ref.Title == "":
+		return fmt.Errorf("%w: title is empty", ErrInvalidReference)
+	case ref.DOI != "" && !doiPattern.MatchString(ref.DOI):
+		return fmt.Errorf("%w: malformed DOI %q", ErrInvalidReference, ref.DOI)
+	case ref.PubMedID != "" && !pmidPattern.MatchString(ref.PubMedID):
+		return fmt.Errorf("%w: malformed PubMed ID %q", ErrInvalidReference, ref.PubMedID)
+	}
+	return nil
+}
+
+// referenceDoc returns the stored fields of a reference
+func referenceDoc(ref *models.Reference) bson.M {
+	doc := bson.M{
+		"authors":    ref.Authors,
+		"year":       ref.Year,
+		"title":      ref.Title,
+		"updated_at": ref.UpdatedAt,
+	}
+	for key, value := range map[string]string{
+		"journal":   ref.Journal,
+		"volume":    ref.Volume,
+		"issue":     ref.Issue,
+		"pages":     ref.Pages,
+		"doi":       ref.DOI,
+		"pubmed_id": ref.PubMedID,
+	} {
+		if value != "" {
+			doc[key] = value
+		}
+	}
+	return doc
+}
+
+// CreateReference adds a reference to the library
+func (rs *ReferenceService) CreateReference(ref *models.Reference) error {
+	if err := ValidateReference(ref); err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	objID := primitive.NewObjectID()
+	ref.ID = objID.Hex()
+	ref.CreatedAt = time.Now()
+	ref.UpdatedAt = ref.CreatedAt
+
+	doc := referenceDoc(ref)
+	doc["_id"] = objID
+	doc["created_at"] = ref.CreatedAt
+
+	_, err := rs.collection.InsertOne(ctx, doc)
+	if mongo.IsDuplicateKeyError(err) {
+		return ErrDuplicateReference
+	}
+	return err
+}
+
+// UpdateReference replaces the content of a reference. Tasks linking it cite the new content.
+func (rs *ReferenceService) UpdateReference(id string, ref *models.Reference) (*models.Reference, error) {
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, ErrReferenceNotFound
+	}
+	if err := ValidateReference(ref); err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	ref.UpdatedAt = time.Now()
+	set := referenceDoc(ref)
+	unset := bson.M{}
+	for _, key := range []string{"journal", "volume", "issue", "pages", "doi", "pubmed_id"} {
+		if _, ok := set[key]; !ok {
+			unset[key] = ""
+		}
+	}
+	update := bson.M{"$set": set}
+	if len(unset) > 0 {
+		update["$unset"] = unset
+	}
+
+	var updated models.Reference
+	err = rs.collection.FindOneAndUpdate(ctx, bson.M{"_id": objID}, update,
+		options.FindOneAndUpdate().SetReturnDocument(options.After)).Decode(&updated)
+	if err == mongo.ErrNoDocuments {
+		return nil, ErrReferenceNotFound
+	}
+	if mongo.IsDuplicateKeyError(err) {
+		return nil, ErrDuplicateReference
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &updated, nil
+}
+
+// DeleteReference removes a reference from the library. Tasks linking it no longer resolve it.
+func (rs *ReferenceService) DeleteReference(id string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return ErrReferenceNotFound
+	}
+
+	result, err := rs.collection.DeleteOne(ctx, bson.M{"_id": objID})
+	if err != nil {
+		return err
+	}
+	if result.DeletedCount == 0 {
+		return ErrReferenceNotFound
+	}
+	return nil
+}
+
+// GetReference retrieves a reference by ID
+func (rs *ReferenceService) GetReference(id string) (*models.Reference, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, ErrReferenceNotFound
+	}
+
+	var ref models.Reference
+	err = rs.collection.FindOne(ctx, bson.M{"_id": objID}).Decode(&ref)
+	if err == mongo.ErrNoDocuments {
+		return nil, ErrReferenceNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &ref, nil
+}
+
+// ListReferences returns the references matching a query, newest publications first
+func (rs *ReferenceService) ListReferences(query ReferenceQuery) ([]*models.Reference, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	filter := bson.M{}
+	if query.Year > 0 {
+		filter["year"] = query.Year
+	}
+	opts := options.Find().SetSort(bson.D{{Key: "year", Value: -1}, {Key: "created_at", Value: -1}})
+
+	cursor, err := rs.collection.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var stored []*models.Reference
+	if err := cursor.All(ctx, &stored); err != nil {
+		return nil, err
+	}
+
+	refs := []*models.Reference{}
+	for _, ref := range stored {
+		if matchesReferenceQuery(ref, query.Query) {
+			refs = append(refs, ref)
+		}
+	}
+	return refs, nil
+}
+
+// matchesReferenceQuery reports whether every word of the query appears in the reference
+func matchesReferenceQuery(ref *models.Reference, query string) bool {
+	words := strings.Fields(strings.ToLower(query))
+	if len(words) == 0 {
+		return true
+	}
+
+	text := []string{ref.Title, ref.Journal, ref.DOI, ref.PubMedID}
+	for _, author := range ref.Authors {
+		text = append(text, author.Family+" "+author.Given, author.Family+author.Given)
+	}
+	joined := strings.ToLower(strings.Join(text, "\n"))
+
+	for _, word := range words {
+		if !strings.Contains(joined, word) {
+			return false
+		}
+	}
+	return true
+}
+
+// referenceCues finds the DOIs, PubMed IDs and author-year citations in a text, in order of appearance
+func referenceCues(text string) []referenceCue {
+	type found struct {
+		at  int
+		cue referenceCue
+	}
+	var cues []found
+
+	for _, m := range doiInTextPattern.FindAllStringIndex(text, -1) {
+		doi := strings.TrimRight(text[m[0]:m[1]], ".,;:")
+		cues = append(cues, found{m[0], referenceCue{DOI: NormalizeDOI(doi)}})
+	}
+	for _, m := range pmidInTextPattern.FindAllStringSubmatchIndex(text, -1) {
+		cues = append(cues, found{m[0], referenceCue{PubMedID: text[m[2]:m[3]]}})
+	}
+	for _, pattern := range []*regexp.Regexp{latinCitePattern, hanCitePattern} {
+		for _, m := range pattern.FindAllStringSubmatchIndex(text, -1) {
+			author := text[m[2]:m[3]]
+			if pattern == hanCitePattern {
+				author = strings.TrimSuffix(strings.TrimSuffix(author, "人"), "等")
+				if author == "" {
+					continue
+				}
+			}
+			year, _ := strconv.Atoi(text[m[4]:m[5]])
+			cues = append(cues, found{m[0], referenceCue{Author: author, Year: year}})
+		}
+	}
+
+	sort.SliceStable(cues, func(i, j int) bool { return cues[i].at < cues[j].at })
+	result := make([]referenceCue, len(cues))
+	for i, f := range cues {
+		result[i] = f.cue
+	}
+	return result
+}
+
+// matchesCue reports whether a reference is the one a cue points to
+func matchesCue(ref *models.Reference, cue referenceCue) bool {
+	switch {
+	case cue.DOI != "":
+		return ref.DOI == cue.DOI
+	case cue.PubMedID != "":
+		return ref.PubMedID == cue.PubMedID
+	case len(ref.Authors) == 0 || ref.Year != cue.Year:
+		return false
+	}
+
+	first := ref.Authors[0]
+	if isCJK(cue.Author) {
+		// The matched run of characters may start before the name
+		return strings.HasSuffix(cue.Author, first.Family+first.Given) || cue.Author == first.Family
+	}
+	return strings.EqualFold(cue.Author, first.Family)
+}
+
+// matchCues returns the references the cues point to, each once, in the order of the cues
+func matchCues(cues []referenceCue, candidates []*models.Reference) []*models.Reference {
+	var matched []*models.Reference
+	seen := make(map[string]bool)
+	for _, cue := range cues {
+		for _, ref := range candidates {
+			if !seen[ref.ID] && matchesCue(ref, cue) {
+				seen[ref.ID] = true
+				matched = append(matched, ref)
+			}
+		}
+	}
+	return matched
+}
+
+// findCandidates loads the references that may match the cues
+func (rs *ReferenceService) findCandidates(ctx context.Context, cues []referenceCue) ([]*models.Reference, error) {
+	var dois, pmids []string
+	var years []int
+	for _, cue := range cues {
+		switch {
+		case cue.DOI != "":
+			dois = append(dois, cue.DOI)
+		case cue.PubMedID != "":
+			pmids = append(pmids, cue.PubMedID)
+		default:
+			years = append(years, cue.Year)
+		}
+	}
+
+	var or bson.A
+	if len(dois) > 0 {
+		or = append(or, bson.M{"doi": bson.M{"$in": dois}})
+	}
+	if len(pmids) > 0 {
+		or = append(or, bson.M{"pubmed_id": bson.M{"$in": pmids}})
+	}
+	if len(years) > 0 {
+		or = append(or, bson.M{"year": bson.M{"$in": years}})
+	}
+	if len(or) == 0 {
+		return nil, nil
+	}
+
+	cursor, err := rs.collection.Find(ctx, bson.M{"$or": or})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var candidates []*models.Reference
+	if err := cursor.All(ctx, &candidates); err != nil {
+		return nil, err
+	}
+	return candidates, nil
+}
+
+// ResolveText returns the references a free-form text cites by DOI, PubMed ID or author and year
+func (rs *ReferenceService) ResolveText(text string) ([]*models.Reference, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	cues := referenceCues(text)
+	candidates, err := rs.findCandidates(ctx, cues)
+	if err != nil {
+		return nil, err
+	}
+
+	refs := matchCues(cues, candidates)
+	if refs == nil {
+		refs = []*models.Reference{}
+	}
+	return refs, nil
+}
+
+// findByIDs loads references by ID. Unknown IDs are left out.
+func (rs *ReferenceService) findByIDs(ctx context.Context, ids []string) (map[string]*models.Reference, error) {
+	var objIDs []primitive.ObjectID
+	for _, id := range ids {
+		if objID, err := primitive.ObjectIDFromHex(id); err == nil {
+			objIDs = append(objIDs, objID)
+		}
+	}
+
+	refs := make(map[string]*models.Reference)
+	if len(objIDs) == 0 {
+		return refs, nil
+	}
+
+	cursor, err := rs.collection.Find(ctx, bson.M{"_id": bson.M{"$in": objIDs}})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var found []*models.Reference
+	if err := cursor.All(ctx, &found); err != nil {
+		return nil, err
+	}
+	for _, ref := range found {
+		refs[ref.ID] = ref
+	}
+	return refs, nil
+}
+
+// PlanReferences resolves the references of a user's plan. Tasks that link references
+// use them; the others are matched from their scientific basis text.
+func (rs *ReferenceService) PlanReferences(userID, planID string) (*models.PlanReferences, error) {
+	plan, err := rs.plans.GetPlan(userID, planID)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var linkedIDs []string
+	var cues []referenceCue
+	taskCues := make([][]referenceCue, len(plan.Tasks))
+	for i, task := range plan.Tasks {
+		if len(task.ReferenceIDs) > 0 {
+			linkedIDs = append(linkedIDs, task.ReferenceIDs...)
+			continue
+		}
+		taskCues[i] = referenceCues(task.ScientificBasis)
+		cues = append(cues, taskCues[i]...)
+	}
+
+	linked, err := rs.findByIDs(ctx, linkedIDs)
+	if err != nil {
+		return nil, err
+	}
+	candidates, err := rs.findCandidates(ctx, cues)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &models.PlanReferences{PlanID: plan.ID, References: []*models.Reference{}, Tasks: []models.TaskReferences{}}
+	cited := make(map[string]bool)
+	for i, task := range plan.Tasks {
+		var refs []*models.Reference
+		if len(task.ReferenceIDs) > 0 {
+			for _, id := range task.ReferenceIDs {
+				if ref, ok := linked[id]; ok {
+					refs = append(refs, ref)
+				}
+			}
+		} else {
+			refs = matchCues(taskCues[i], candidates)
+		}
+
+		entry := models.TaskReferences{
+			TaskID:       task.ID,
+			Day:          task.Day,
+			Title:        task.Title,
+			ReferenceIDs: []string{},
+			Linked:       len(task.ReferenceIDs) > 0,
+		}
+		for _, ref := range refs {
+			entry.ReferenceIDs = append(entry.ReferenceIDs, ref.ID)
+			if !cited[ref.ID] {
+				cited[ref.ID] = true
+				result.References = append(result.References, ref)
+			}
+		}
+		result.Tasks = append(result.Tasks, entry)
+	}
+
+	return result, nil
+}
+
+// PlanBibliography renders the references of a user's plan in a bibliography style
+func (rs *ReferenceService) PlanBibliography(userID, planID, style string) ([]string, error) {
+	refs, err := rs.PlanReferences(userID, planID)
+	if err != nil {
+		return nil, err
+	}
+	return RenderBibliography(refs.References, style), nil
+}
+
+// LinkCitations sets the reference of the citations in an AI answer that carry a DOI or
+// PubMed ID of a reference in the library. Citations without a match are left as they are.
+func (rs *ReferenceService) LinkCitations(parts *models.ResponseParts) error {
+	if parts == nil || len(parts.Citations) == 0 {
+		return nil
+	}
+
+	var cues []referenceCue
+	for i := range parts.Citations {
+		citation := &parts.Citations[i]
+		citation.DOI = NormalizeDOI(citation.DOI)
+		citation.PubMedID = NormalizePubMedID(citation.PubMedID)
+		if citation.DOI != "" {
+			cues = append(cues, referenceCue{DOI: citation.DOI})
+		}
+		if citation.PubMedID != "" {
+			cues = append(cues, referenceCue{PubMedID: citation.PubMedID})
+		}
+	}
+	if len(cues) == 0 {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	candidates, err := rs.findCandidates(ctx, cues)
+	if err != nil {
+		return err
+	}
+
+	for i := range parts.Citations {
+		citation := &parts.Citations[i]
+		for _, ref := range candidates {
+			if (citation.DOI != "" && ref.DOI == citation.DOI) || (citation.PubMedID != "" && ref.PubMedID == citation.PubMedID) {
+				citation.ReferenceID = ref.ID
+				break
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,76 @@
+package services
+
+import (
+	"errors"
+	"testing"
+
+	"neuro-guide-go-service/models"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNormalizeIdentifiers(t *testing.T) {
+	assert.Equal(t, "10.1097/01.psy.0000077505.67574.e3", NormalizeDOI(" https://doi.org/10.1097/01.PSY.0000077505.67574.E3"))
+	assert.Equal(t, "10.1038/nrn3916", NormalizeDOI("doi:10.1038/nrn3916"))
+	assert.Equal(t, "26168376", NormalizePubMedID("PMID: 26168376"))
+}
+
+func TestValidateReference(t *testing.T) {
+	ref := &models.Reference{
+		Authors: []models.ReferenceAuthor{{Family: " Tang ", Given: "Yi-Yuan"}},
+		Year:    2015,
+		Title:   "The neuroscience of mindfulness meditation",
+		DOI:     "https://doi.org/10.1038/NRN3916",
+	}
+	assert.NoError(t, ValidateReference(ref))
+	assert.Equal(t, "Tang", ref.Authors[0].Family)
+	assert.Equal(t, "10.1038/nrn3916", ref.DOI)
+
+	for _, bad := range []*models.Reference{
+		{Year: 2015, Title: "No authors"},
+		{Authors: []models.ReferenceAuthor{{Given: "Only"}}, Year: 2015, Title: "No family name"},
+		{Authors: ref.Authors, Year: 1700, Title: "Too old"},
+		{Authors: ref.Authors, Year: 2015},
+		{Authors: ref.Authors, Year: 2015, Title: "Bad DOI", DOI: "nrn3916"},
+		{Authors: ref.Authors, Year: 2015, Title: "Bad PMID", PubMedID: "abc"},
+	} {
+		err := ValidateReference(bad)
+		assert.True(t, errors.Is(err, ErrInvalidReference), bad.Title)
+	}
+}
+
+func TestReferenceCues(t *testing.T) {
+	cues := referenceCues("Davidson, 2003 fMRI研究证实前额叶激活；另见Hölzel et al.（2011）与王晓明，2019，DOI 10.1038/nrn3916. PMID: 26168376")
+
+	assert.Equal(t, []referenceCue{
+		{Author: "Davidson", Year: 2003},
+		{Author: "Hölzel", Year: 2011},
+		{Author: "与王晓明", Year: 2019},
+		{DOI: "10.1038/nrn3916"},
+		{PubMedID: "26168376"},
+	}, cues)
+
+	assert.Equal(t, []referenceCue{{Author: "李明", Year: 2020}}, referenceCues("李明等（2020）"))
+	assert.Empty(t, referenceCues("腹式呼吸激活副交感神经"))
+}
+
+func TestMatchCues(t *testing.T) {
+	davidson := &models.Reference{ID: "a", Authors: []models.ReferenceAuthor{{Family: "Davidson"}}, Year: 2003}
+	wang := &models.Reference{ID: "b", Authors: []models.ReferenceAuthor{{Family: "王", Given: "晓明"}}, Year: 2019}
+	tang := &models.Reference{ID: "c", Authors: []models.ReferenceAuthor{{Family: "Tang"}}, Year: 2015, DOI: "10.1038/nrn3916"}
+	candidates := []*models.Reference{davidson, wang, tang}
+
+	matched := matchCues([]referenceCue{
+		{DOI: "10.1038/nrn3916"},
+		{Author: "与王晓明", Year: 2019},
+		{Author: "davidson", Year: 2003},
+		{Author: "Davidson", Year: 2004}, // wrong year
+		{DOI: "10.1038/nrn3916"},         // cited twice
+	}, candidates)
+	assert.Equal(t, []*models.Reference{tang, wang, davidson}, matched)
+}
+
+func TestTaskProblemsChecksReferenceIDs(t *testing.T) {
+	tasks := []models.PlanTask{{Day: 1, ReferenceIDs: []string{"65f1c0d2a1b2c3d4e5f60718", "not-an-id"}}}
+	assert.Equal(t, []string{`task 1: invalid reference id "not-an-id"`}, taskProblems(tasks))
+}
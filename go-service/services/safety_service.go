@@ -0,0 +1,428 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+
+	"neuro-guide-go-service/config"
+	"neuro-guide-go-service/database"
+	"neuro-guide-go-service/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// ErrSafetyEventNotFound is returned when a safety event does not exist
+var ErrSafetyEventNotFound = errors.New("safety event not found")
+
+// Risk levels in ascending order of severity
+const (
+	RiskLevelNone   = "none"
+	RiskLevelLow    = "low"
+	RiskLevelMedium = "medium"
+	RiskLevelHigh   = "high"
+)
+
+var riskLevelRank = map[string]int{
+	RiskLevelNone:   0,
+	RiskLevelLow:    1,
+	RiskLevelMedium: 2,
+	RiskLevelHigh:   3,
+}
+
+// DefaultCrisisResponse is the vetted reply sent instead of an AI answer when risk is detected
+const DefaultCrisisResponse = `听到你现在这么难受，我很在意你的安全。你不需要一个人扛着这些。
+
+如果你有伤害自己的想法，或者已经在计划这样做，请现在就联系专业的帮助：
+- 全国心理援助热线：12356
+- 希望24热线（24小时）：400-161-9995
+- 北京心理危机研究与干预中心：010-82951332
+- 紧急情况请拨打 110 或 120，或者前往最近医院的急诊
+
+也可以试着告诉一位你信任的家人或朋友，让他们陪在你身边。
+我是一个神经科学学习助手，不能替代医生或心理咨询师，但我愿意继续听你说。`
+
+// SafetyRule matches crisis language with keywords or regular expressions
+type SafetyRule struct {
+	ID         string   `json:"id"`
+	Category   string   `json:"category"` // e.g. suicide, self_harm, hopelessness
+	Level      string   `json:"level"`
+	Keywords   []string `json:"keywords"`
+	Patterns   []string `json:"patterns"`
+	Exclusions []string `json:"exclusions"` // phrases that suppress the rule, e.g. negations
+
+	compiled []*regexp.Regexp
+}
+
+// SafetyRuleSet is the configurable set of rules and the vetted response
+type SafetyRuleSet struct {
+	Rules    []SafetyRule `json:"rules"`
+	Response string       `json:"response"`
+}
+
+// DefaultSafetyRuleSet returns the built-in rules used when no rule file is configured
+func DefaultSafetyRuleSet() *SafetyRuleSet {
+	return &SafetyRuleSet{
+		Response: DefaultCrisisResponse,
+		Rules: []SafetyRule{
+			{
+				ID:         "suicide_intent",
+				Category:   "suicide",
+				Level:      RiskLevelHigh,
+				Keywords:   []string{"自杀", "轻生", "不想活", "活不下去", "结束自己的生命", "结束生命", "想死", "去死", "寻死", "遗书", "kill myself", "suicide"},
+				Patterns:   []string{`(跳楼|跳河|跳桥|上吊|烧炭)`, `(吞|吃)(了)?(一整瓶|很多|大量|所有的?)(安眠药|药)`},
+				Exclusions: []string{"不想死", "没有想自杀", "不会自杀", "没想过自杀", "预防自杀", "自杀率", "想死你", "想死我了"}, // 想死你了 means "I miss you so much"
+			},
+			{
+				ID:         "self_harm",
+				Category:   "self_harm",
+				Level:      RiskLevelHigh,
+				Keywords:   []string{"自残", "自伤", "割腕", "伤害自己", "划手", "self harm"},
+				Patterns:   []string{`(用刀|刀片).{0,6}(划|割)`},
+				Exclusions: []string{"不会伤害自己", "没有自残"},
+			},
+			{
+				ID:       "hopelessness",
+				Category: "hopelessness",
+				Level:    RiskLevelMedium,
+				Keywords: []string{"活着没意思", "活着没有意义", "撑不下去", "没有希望了", "消失就好了", "不如死了"},
+			},
+		},
+	}
+}
+
+// LoadSafetyRuleSet reads a rule set from a JSON file
+func LoadSafetyRuleSet(path string) (*SafetyRuleSet, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read safety rules: %w", err)
+	}
+
+	var ruleSet SafetyRuleSet
+	if err := json.Unmarshal(data, &ruleSet); err != nil {
+		return nil, fmt.Errorf("failed to parse safety rules: %w", err)
+	}
+	if ruleSet.Response == "" {
+		ruleSet.Response = DefaultCrisisResponse
+	}
+
+	return &ruleSet, nil
+}
+
+// compile validates the rules and compiles their patterns
+func (rs *SafetyRuleSet) compile() error {
+	for i := range rs.Rules {
+		rule := &rs.Rules[i]
+		if _, ok := riskLevelRank[rule.Level]; !ok {
+			return fmt.Errorf("safety rule %q has invalid level %q", rule.ID, rule.Level)
+		}
+
+		rule.compiled = nil
+		for _, pattern := range rule.Patterns {
+			re, err := regexp.Compile(pattern)
+			if err != nil {
+				return fmt.Errorf("safety rule %q has invalid pattern: %w", rule.ID, err)
+			}
+			rule.compiled = append(rule.compiled, re)
+		}
+	}
+	return nil
+}
+
+// SafetyAssessment is the result of classifying a message
+type SafetyAssessment struct {
+	RiskLevel    string   `json:"risk_level"`
+	Categories   []string `json:"categories"`
+	MatchedRules []string `json:"matched_rules"`
+	Sources      []string `json:"sources"`
+}
+
+// merge combines another assessment into this one, keeping the highest risk level
+func (a *SafetyAssessment) merge(other *SafetyAssessment, source string) {
+	if other == nil || riskLevelRank[other.RiskLevel] == 0 {
+		return
+	}
+	if riskLevelRank[other.RiskLevel] > riskLevelRank[a.RiskLevel] {
+		a.RiskLevel = other.RiskLevel
+	}
+	a.Categories = appendUnique(a.Categories, other.Categories...)
+	a.MatchedRules = appendUnique(a.MatchedRules, other.MatchedRules...)
+	a.Sources = appendUnique(a.Sources, source)
+}
+
+func appendUnique(list []string, items ...string) []string {
+	for _, item := range items {
+		found := false
+		for _, existing := range list {
+			if existing == item {
+				found = true
+				break
+			}
+		}
+		if !found {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
+// SafetyClassifier assesses the crisis risk of a message
+type SafetyClassifier interface {
+	Classify(userID, message string) (*SafetyAssessment, error)
+}
+
+// RuleSafetyClassifier classifies messages with keyword and pattern rules
+type RuleSafetyClassifier struct {
+	ruleSet *SafetyRuleSet
+}
+
+// NewRuleSafetyClassifier creates a rule-based classifier
+func NewRuleSafetyClassifier(ruleSet *SafetyRuleSet) (*RuleSafetyClassifier, error) {
+	if err := ruleSet.compile(); err != nil {
+		return nil, err
+	}
+	return &RuleSafetyClassifier{ruleSet: ruleSet}, nil
+}
+
+// Classify matches the message against every rule
+func (rc *RuleSafetyClassifier) Classify(userID, message string) (*SafetyAssessment, error) {
+	text := strings.ToLower(message)
+	assessment := &SafetyAssessment{RiskLevel: RiskLevelNone}
+
+	for _, rule := range rc.ruleSet.Rules {
+		if !ruleMatches(&rule, text) {
+			continue
+		}
+		assessment.merge(&SafetyAssessment{
+			RiskLevel:    rule.Level,
+			Categories:   []string{rule.Category},
+			MatchedRules: []string{rule.ID},
+		}, "rules")
+	}
+
+	return assessment, nil
+}
+
+// ruleMatches reports whether a rule fires for the lower-cased text
+func ruleMatches(rule *SafetyRule, text string) bool {
+	for _, exclusion := range rule.Exclusions {
+		text = strings.ReplaceAll(text, strings.ToLower(exclusion), " ")
+	}
+
+	for _, keyword := range rule.Keywords {
+		if strings.Contains(text, strings.ToLower(keyword)) {
+			return true
+		}
+	}
+	for _, re := range rule.compiled {
+		if re.MatchString(text) {
+			return true
+		}
+	}
+	return false
+}
+
+// AISafetyClassifier asks the Python AI service to classify a message
+type AISafetyClassifier struct {
+	url        string
+	httpClient *http.Client
+}
+
+// NewAISafetyClassifier creates a classifier backed by the AI service
+func NewAISafetyClassifier(pythonAIServiceURL string) *AISafetyClassifier {
+	return &AISafetyClassifier{
+		url:        fmt.Sprintf("%s/safety/classify", pythonAIServiceURL),
+		httpClient: &http.Client{Timeout: 5 * time.Second},
+	}
+}
+
+// Classify posts the message to the AI service classifier endpoint
+func (ac *AISafetyClassifier) Classify(userID, message string) (*SafetyAssessment, error) {
+	jsonData, err := json.Marshal(map[string]string{"user_id": userID, "message": message})
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := ac.httpClient.Post(ac.url, "application/json", bytes.NewBuffer(jsonData))
+	if err != nil {
+		return nil, fmt.Errorf("failed to call safety classifier: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("safety classifier returned status %d", resp.StatusCode)
+	}
+
+	var assessment SafetyAssessment
+	if err := json.NewDecoder(resp.Body).Decode(&assessment); err != nil {
+		return nil, fmt.Errorf("failed to decode safety classifier response: %w", err)
+	}
+	if _, ok := riskLevelRank[assessment.RiskLevel]; !ok {
+		assessment.RiskLevel = RiskLevelNone
+	}
+
+	return &assessment, nil
+}
+
+// SafetyService runs the safety classifiers and records crisis events
+type SafetyService struct {
+	collection  *mongo.Collection
+	rules       *RuleSafetyClassifier
+	ai          SafetyClassifier
+	threshold   string
+	response    string
+	ruleSetName string
+}
+
+// NewSafetyService creates a safety service from config.
+// An invalid rule file falls back to the built-in rules so the safety layer is never disabled.
+func NewSafetyService(cfg *config.Config) *SafetyService {
+	ruleSet := DefaultSafetyRuleSet()
+	ruleSetName := "builtin"
+	if cfg.SafetyRulesFile != "" {
+		loaded, err := LoadSafetyRuleSet(cfg.SafetyRulesFile)
+		if err != nil {
+			log.Printf("Failed to load safety rules, using built-in rules: %v", err)
+		} else {
+			ruleSet = loaded
+			ruleSetName = cfg.SafetyRulesFile
+		}
+	}
+
+	rules, err := NewRuleSafetyClassifier(ruleSet)
+	if err != nil {
+		log.Printf("Invalid safety rules, using built-in rules: %v", err)
+		ruleSet = DefaultSafetyRuleSet()
+		ruleSetName = "builtin"
+		rules, _ = NewRuleSafetyClassifier(ruleSet)
+	}
+
+	threshold := cfg.SafetyRiskThreshold
+	if _, ok := riskLevelRank[threshold]; !ok || threshold == RiskLevelNone {
+		threshold = RiskLevelMedium
+	}
+
+	ss := &SafetyService{
+		collection:  database.Database.Collection("safety_events"),
+		rules:       rules,
+		threshold:   threshold,
+		response:    ruleSet.Response,
+		ruleSetName: ruleSetName,
+	}
+	if cfg.SafetyAIClassifierEnabled {
+		ss.ai = NewAISafetyClassifier(cfg.PythonAIServiceURL)
+	}
+
+	return ss
+}
+
+// Assess classifies a message with the rules and, when enabled, the AI classifier.
+// AI classifier failures are logged and do not block the rule result.
+func (ss *SafetyService) Assess(userID, message string) *SafetyAssessment {
+	assessment := &SafetyAssessment{RiskLevel: RiskLevelNone}
+
+	ruleResult, _ := ss.rules.Classify(userID, message)
+	assessment.merge(ruleResult, "rules")
+
+	if ss.ai != nil {
+		aiResult, err := ss.ai.Classify(userID, message)
+		if err != nil {
+			log.Printf("AI safety classifier failed: %v", err)
+		} else {
+			assessment.merge(aiResult, "ai")
+		}
+	}
+
+	return assessment
+}
+
+// RequiresIntervention reports whether the assessment reaches the configured threshold
+func (ss *SafetyService) RequiresIntervention(assessment *SafetyAssessment) bool {
+	return riskLevelRank[assessment.RiskLevel] >= riskLevelRank[ss.threshold]
+}
+
+// CrisisResponse returns the vetted response with hotline resources
+func (ss *SafetyService) CrisisResponse() string {
+	return ss.response
+}
+
+// RecordEvent stores a safety event flagged for review
+func (ss *SafetyService) RecordEvent(userID, messageID string, assessment *SafetyAssessment) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	_, err := ss.collection.InsertOne(ctx, bson.M{
+		"_id":           primitive.NewObjectID(),
+		"user_id":       userID,
+		"message_id":    messageID,
+		"risk_level":    assessment.RiskLevel,
+		"categories":    assessment.Categories,
+		"matched_rules": assessment.MatchedRules,
+		"sources":       assessment.Sources,
+		"rule_set":      ss.ruleSetName,
+		"status":        models.SafetyEventPendingReview,
+		"created_at":    time.Now(),
+	})
+	return err
+}
+
+// ListEvents returns the most recent safety events with the given status, or all events when status is empty
+func (ss *SafetyService) ListEvents(status string, limit int) ([]*models.SafetyEvent, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	filter := bson.M{}
+	if status != "" {
+		filter["status"] = status
+	}
+	opts := options.Find().SetSort(bson.M{"created_at": -1}).SetLimit(int64(limit))
+
+	cursor, err := ss.collection.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	events := []*models.SafetyEvent{}
+	if err := cursor.All(ctx, &events); err != nil {
+		return nil, err
+	}
+	return events, nil
+}
+
+// ReviewEvent marks a safety event as reviewed with an optional note
+func (ss *SafetyService) ReviewEvent(id, note string) (*models.SafetyEvent, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, ErrSafetyEventNotFound
+	}
+
+	set := bson.M{"status": models.SafetyEventReviewed, "reviewed_at": time.Now()}
+	if note = strings.TrimSpace(note); note != "" {
+		set["review_note"] = note
+	}
+
+	var event models.SafetyEvent
+	err = ss.collection.FindOneAndUpdate(ctx, bson.M{"_id": objID}, bson.M{"$set": set},
+		options.FindOneAndUpdate().SetReturnDocument(options.After)).Decode(&event)
+	if err == mongo.ErrNoDocuments {
+		return nil, ErrSafetyEventNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &event, nil
+}
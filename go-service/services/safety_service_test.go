@@ -0,0 +1,104 @@
+package services
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestRuleClassifier(t *testing.T) *RuleSafetyClassifier {
+	classifier, err := NewRuleSafetyClassifier(DefaultSafetyRuleSet())
+	assert.NoError(t, err)
+	return classifier
+}
+
+func TestRuleSafetyClassifier_DetectsCrisis(t *testing.T) {
+	classifier := newTestRuleClassifier(t)
+
+	cases := map[string]string{
+		"我真的不想活了":               RiskLevelHigh,
+		"昨晚我又用刀片划了手臂":           RiskLevelHigh,
+		"想吃一整瓶安眠药":              RiskLevelHigh,
+		"最近觉得活着没意思":             RiskLevelMedium,
+		"I want to KILL MYSELF": RiskLevelHigh,
+	}
+
+	for message, level := range cases {
+		assessment, err := classifier.Classify("user-1", message)
+		assert.NoError(t, err)
+		assert.Equal(t, level, assessment.RiskLevel, message)
+		assert.Equal(t, []string{"rules"}, assessment.Sources, message)
+	}
+}
+
+func TestRuleSafetyClassifier_IgnoresOrdinaryMessages(t *testing.T) {
+	classifier := newTestRuleClassifier(t)
+
+	for _, message := range []string{
+		"冥想时杏仁核的活动会怎样变化？",
+		"我不会自杀，只是想了解焦虑的神经机制",
+		"知行合一的神经科学解释是什么",
+	} {
+		assessment, err := classifier.Classify("user-1", message)
+		assert.NoError(t, err)
+		assert.Equal(t, RiskLevelNone, assessment.RiskLevel, message)
+		assert.Empty(t, assessment.MatchedRules, message)
+	}
+}
+
+func TestRuleSafetyClassifier_IgnoresMissingSomeone(t *testing.T) {
+	classifier := newTestRuleClassifier(t)
+
+	for _, message := range []string{"好久不见，想死你了！", "终于放假回家，妈妈说想死我了"} {
+		assessment, err := classifier.Classify("user-1", message)
+		assert.NoError(t, err)
+		assert.Equal(t, RiskLevelNone, assessment.RiskLevel, message)
+	}
+
+	// The exclusion does not hide crisis language elsewhere in the message
+	assessment, err := classifier.Classify("user-1", "想死你了，可我现在真的想死")
+	assert.NoError(t, err)
+	assert.Equal(t, RiskLevelHigh, assessment.RiskLevel)
+}
+
+func TestRuleSafetyClassifier_CustomRules(t *testing.T) {
+	classifier, err := NewRuleSafetyClassifier(&SafetyRuleSet{
+		Rules: []SafetyRule{
+			{ID: "custom", Category: "custom", Level: RiskLevelLow, Patterns: []string{`失眠\d+天`}},
+		},
+	})
+	assert.NoError(t, err)
+
+	assessment, _ := classifier.Classify("user-1", "已经失眠5天了")
+	assert.Equal(t, RiskLevelLow, assessment.RiskLevel)
+	assert.Equal(t, []string{"custom"}, assessment.MatchedRules)
+
+	_, err = NewRuleSafetyClassifier(&SafetyRuleSet{
+		Rules: []SafetyRule{{ID: "bad", Level: "severe"}},
+	})
+	assert.Error(t, err)
+
+	_, err = NewRuleSafetyClassifier(&SafetyRuleSet{
+		Rules: []SafetyRule{{ID: "bad", Level: RiskLevelHigh, Patterns: []string{"("}}},
+	})
+	assert.Error(t, err)
+}
+
+func TestSafetyService_Threshold(t *testing.T) {
+	ss := &SafetyService{threshold: RiskLevelHigh}
+
+	assert.True(t, ss.RequiresIntervention(&SafetyAssessment{RiskLevel: RiskLevelHigh}))
+	assert.False(t, ss.RequiresIntervention(&SafetyAssessment{RiskLevel: RiskLevelMedium}))
+	assert.False(t, ss.RequiresIntervention(&SafetyAssessment{RiskLevel: RiskLevelNone}))
+}
+
+func TestSafetyAssessment_MergeKeepsHighestLevel(t *testing.T) {
+	assessment := &SafetyAssessment{RiskLevel: RiskLevelNone}
+	assessment.merge(&SafetyAssessment{RiskLevel: RiskLevelMedium, Categories: []string{"hopelessness"}}, "rules")
+	assessment.merge(&SafetyAssessment{RiskLevel: RiskLevelHigh, Categories: []string{"suicide"}}, "ai")
+	assessment.merge(&SafetyAssessment{RiskLevel: RiskLevelNone}, "ai")
+
+	assert.Equal(t, RiskLevelHigh, assessment.RiskLevel)
+	assert.Equal(t, []string{"hopelessness", "suicide"}, assessment.Categories)
+	assert.Equal(t, []string{"rules", "ai"}, assessment.Sources)
+}
@@ -0,0 +1,439 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"neuro-guide-go-service/config"
+	"neuro-guide-go-service/database"
+	"neuro-guide-go-service/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// maxSummaryMessages caps how many new messages are sent in one summarization request
+const maxSummaryMessages = 200
+
+// ErrNothingToSummarize is returned when no messages arrived since the last summary
+var ErrNothingToSummarize = errors.New("no new messages to summarize")
+
+// summarizing holds the users whose conversation is being summarized. It is shared by
+// every SummaryService in the process so the chat, check-in and idle summarizers do not
+// summarize the same conversation at once.
+var summarizing sync.Map // user_id -> struct{}
+
+// SummaryRequest represents a summarization request to Python AI service
+type SummaryRequest struct {
+	UserID          string              `json:"user_id"`
+	PreviousSummary string              `json:"previous_summary,omitempty"`
+	Messages        []map[string]string `json:"messages"`
+}
+
+// SummaryResponse represents a summarization response from Python AI service
+type SummaryResponse struct {
+	Summary string `json:"summary"`
+	Title   string `json:"title"`
+}
+
+// SummaryService maintains versioned rolling summaries and titles of conversations
+type SummaryService struct {
+	collection         *mongo.Collection
+	messages           *mongo.Collection
+	pythonAIServiceURL string
+	httpClient         *http.Client
+	redactor           *PIIRedactor
+	everyNTurns        int
+	idleAfter          time.Duration
+}
+
+// NewSummaryService creates a new instance of SummaryService
+func NewSummaryService(cfg *config.Config) *SummaryService {
+	everyNTurns := cfg.SummaryEveryNTurns
+	if everyNTurns <= 0 {
+		everyNTurns = 10
+	}
+	idleAfter := cfg.SummaryIdleAfter
+	if idleAfter <= 0 {
+		idleAfter = 30 * time.Minute
+	}
+
+	return &SummaryService{
+		collection:         database.Database.Collection("conversation_summaries"),
+		messages:           database.Database.Collection("chat_messages"),
+		pythonAIServiceURL: cfg.PythonAIServiceURL,
+		httpClient:         &http.Client{Timeout: 60 * time.Second},
+		redactor:           newConfiguredPIIRedactor(cfg.PIIDetectors, cfg.PIIRestoreDetectors),
+		everyNTurns:        everyNTurns,
+		idleAfter:          idleAfter,
+	}
+}
+
+// EnsureSummaryIndexes creates the indexes conversation summaries rely on
+func EnsureSummaryIndexes() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	// Each summary version is stored once, also when several processes summarize at once
+	_, err := database.Database.Collection("conversation_summaries").Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "user_id", Value: 1}, {Key: "version", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create conversation summary index: %w", err)
+	}
+	return nil
+}
+
+// GetLatestSummary returns the newest summary version, or nil when none exists
+func (ss *SummaryService) GetLatestSummary(userID string) (*models.ConversationSummary, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var summary models.ConversationSummary
+	opts := options.FindOne().SetSort(bson.M{"version": -1})
+	err := ss.collection.FindOne(ctx, bson.M{"user_id": userID}, opts).Decode(&summary)
+	if err == mongo.ErrNoDocuments {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := decryptSummary(&summary); err != nil {
+		return nil, err
+	}
+	return &summary, nil
+}
+
+// GetSummaryHistory returns every summary version of a user's conversation, newest first
+func (ss *SummaryService) GetSummaryHistory(userID string) ([]*models.ConversationSummary, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	opts := options.Find().SetSort(bson.M{"version": -1})
+	cursor, err := ss.collection.Find(ctx, bson.M{"user_id": userID}, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var summaries []*models.ConversationSummary
+	if err := cursor.All(ctx, &summaries); err != nil {
+		return nil, err
+	}
+
+	for _, summary := range summaries {
+		if err := decryptSummary(summary); err != nil {
+			return nil, err
+		}
+	}
+	return summaries, nil
+}
+
+// DeleteSummaries removes all summaries of a user's conversation
+func (ss *SummaryService) DeleteSummaries(userID string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	_, err := ss.collection.DeleteMany(ctx, bson.M{"user_id": userID})
+	return err
+}
+
+// MaybeSummarize summarizes the conversation once enough turns accumulated since the last summary.
+// It is meant to be called in the background after each chat exchange.
+func (ss *SummaryService) MaybeSummarize(userID string) {
+	latest, err := ss.GetLatestSummary(userID)
+	if err != nil {
+		log.Printf("Failed to load summary for %s: %v", userID, err)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	filter := bson.M{"user_id": userID, "status": answeredMessages}
+	if latest != nil {
+		filter["timestamp"] = bson.M{"$gt": latest.CoveredUntil}
+	}
+	count, err := ss.messages.CountDocuments(ctx, filter)
+	if err != nil {
+		log.Printf("Failed to count messages for %s: %v", userID, err)
+		return
+	}
+
+	if !needsSummary(count, ss.everyNTurns) {
+		return
+	}
+
+	if _, err := ss.Summarize(userID, "turns"); err != nil && err != ErrNothingToSummarize {
+		log.Printf("Failed to summarize conversation for %s: %v", userID, err)
+	}
+}
+
+// needsSummary reports whether count messages since the last summary make enough turns.
+// A turn is one user message and one assistant reply.
+func needsSummary(count int64, everyNTurns int) bool {
+	return count >= int64(everyNTurns*2)
+}
+
+// Summarize asks the AI service for a new rolling summary and title and stores it as the next version
+func (ss *SummaryService) Summarize(userID, trigger string) (*models.ConversationSummary, error) {
+	if _, busy := summarizing.LoadOrStore(userID, struct{}{}); busy {
+		return nil, ErrNothingToSummarize
+	}
+	defer summarizing.Delete(userID)
+
+	latest, err := ss.GetLatestSummary(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	messages, err := ss.messagesSince(userID, latest)
+	if err != nil {
+		return nil, err
+	}
+	if len(messages) == 0 {
+		return nil, ErrNothingToSummarize
+	}
+
+	req, redactions := ss.buildSummaryRequest(userID, latest, messages)
+	resp, err := ss.callSummarize(req)
+	if err != nil {
+		return nil, err
+	}
+
+	summary := ss.newSummaryVersion(userID, latest, messages, resp, redactions, trigger)
+	err = ss.saveSummary(summary)
+	if mongo.IsDuplicateKeyError(err) {
+		// Another process stored this version first and covered the same messages
+		return nil, ErrNothingToSummarize
+	}
+	if err != nil {
+		return nil, err
+	}
+	return summary, nil
+}
+
+// buildSummaryRequest redacts personal information from the previous summary and the new
+// messages like in regular chat requests, and returns the redactions to restore in the reply
+func (ss *SummaryService) buildSummaryRequest(userID string, latest *models.ConversationSummary, messages []*models.ChatMessage) (*SummaryRequest, []Redaction) {
+	req := &SummaryRequest{UserID: userID}
+	var redactions []Redaction
+	if latest != nil {
+		result := ss.redactor.redactWith(latest.Summary, redactions)
+		req.PreviousSummary, redactions = result.Text, result.Redactions
+	}
+	for _, msg := range messages {
+		result := ss.redactor.redactWith(msg.Message, redactions)
+		redactions = result.Redactions
+		req.Messages = append(req.Messages, map[string]string{"role": msg.Role, "message": result.Text})
+	}
+	return req, redactions
+}
+
+// newSummaryVersion builds the summary version that follows latest (nil for the first one)
+// and covers messages
+func (ss *SummaryService) newSummaryVersion(userID string, latest *models.ConversationSummary, messages []*models.ChatMessage, resp *SummaryResponse, redactions []Redaction, trigger string) *models.ConversationSummary {
+	summary := &models.ConversationSummary{
+		ID:           primitive.NewObjectID().Hex(),
+		UserID:       userID,
+		Version:      1,
+		Title:        strings.TrimSpace(ss.redactor.Restore(resp.Title, redactions)),
+		Summary:      strings.TrimSpace(ss.redactor.Restore(resp.Summary, redactions)),
+		MessageCount: len(messages),
+		CoveredUntil: messages[len(messages)-1].Timestamp,
+		Trigger:      trigger,
+		CreatedAt:    time.Now(),
+	}
+	if latest != nil {
+		summary.Version = latest.Version + 1
+		summary.MessageCount += latest.MessageCount
+	}
+	return summary
+}
+
+// messagesSince returns the decrypted messages after the latest summary in chronological order
+func (ss *SummaryService) messagesSince(userID string, latest *models.ConversationSummary) ([]*models.ChatMessage, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	filter := bson.M{"user_id": userID, "status": answeredMessages}
+	if latest != nil {
+		filter["timestamp"] = bson.M{"$gt": latest.CoveredUntil}
+	}
+	opts := options.Find().SetSort(bson.M{"timestamp": 1}).SetLimit(maxSummaryMessages)
+
+	cursor, err := ss.messages.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var messages []*models.ChatMessage
+	if err := cursor.All(ctx, &messages); err != nil {
+		return nil, err
+	}
+
+	for _, msg := range messages {
+		plaintext, err := decryptField(msg.UserID, msg.Message)
+		if err != nil {
+			return nil, err
+		}
+		msg.Message = plaintext
+	}
+	return messages, nil
+}
+
+// callSummarize posts a summarization request to the Python AI service
+func (ss *SummaryService) callSummarize(req *SummaryRequest) (*SummaryResponse, error) {
+	jsonData, err := json.Marshal(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	resp, err := ss.httpClient.Post(
+		fmt.Sprintf("%s/chat/summarize", ss.pythonAIServiceURL),
+		"application/json",
+		bytes.NewBuffer(jsonData),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to call AI service: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("AI service returned error: %s", string(body))
+	}
+
+	var summaryResp SummaryResponse
+	if err := json.NewDecoder(resp.Body).Decode(&summaryResp); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+	if strings.TrimSpace(summaryResp.Summary) == "" {
+		return nil, errors.New("AI service returned an empty summary")
+	}
+
+	return &summaryResp, nil
+}
+
+// saveSummary stores a summary version with its title and text encrypted
+func (ss *SummaryService) saveSummary(summary *models.ConversationSummary) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	objID, err := primitive.ObjectIDFromHex(summary.ID)
+	if err != nil {
+		return err
+	}
+
+	encrypted, keyVersion, err := encryptFields(summary.UserID, summary.Title, summary.Summary)
+	if err != nil {
+		return err
+	}
+
+	doc := bson.M{
+		"_id":           objID,
+		"user_id":       summary.UserID,
+		"version":       summary.Version,
+		"title":         encrypted[0],
+		"summary":       encrypted[1],
+		"message_count": summary.MessageCount,
+		"covered_until": summary.CoveredUntil,
+		"trigger":       summary.Trigger,
+		"created_at":    summary.CreatedAt,
+	}
+	if keyVersion > 0 {
+		doc["key_version"] = keyVersion
+	}
+
+	_, err = ss.collection.InsertOne(ctx, doc)
+	return err
+}
+
+// StartIdleSummarizer periodically summarizes conversations that have been idle
+// for the configured time and have messages newer than their latest summary
+func (ss *SummaryService) StartIdleSummarizer(interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			if err := ss.summarizeIdleConversations(); err != nil {
+				log.Printf("Idle summarizer failed: %v", err)
+			}
+		}
+	}()
+}
+
+// summarizeIdleConversations runs one pass of the idle summarizer
+func (ss *SummaryService) summarizeIdleConversations() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	now := time.Now()
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"timestamp": bson.M{"$gte": now.AddDate(0, 0, -7)}, "status": answeredMessages}}},
+		{{Key: "$group", Value: bson.M{"_id": "$user_id", "last": bson.M{"$max": "$timestamp"}}}},
+		{{Key: "$match", Value: bson.M{"last": bson.M{"$lte": now.Add(-ss.idleAfter)}}}},
+	}
+
+	cursor, err := ss.messages.Aggregate(ctx, pipeline)
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+
+	var idle []struct {
+		UserID string    `bson:"_id"`
+		Last   time.Time `bson:"last"`
+	}
+	if err := cursor.All(ctx, &idle); err != nil {
+		return err
+	}
+
+	for _, conversation := range idle {
+		latest, err := ss.GetLatestSummary(conversation.UserID)
+		if err != nil {
+			log.Printf("Failed to load summary for %s: %v", conversation.UserID, err)
+			continue
+		}
+		if latest != nil && !latest.CoveredUntil.Before(conversation.Last) {
+			continue
+		}
+
+		if _, err := ss.Summarize(conversation.UserID, "idle"); err != nil && err != ErrNothingToSummarize {
+			log.Printf("Failed to summarize idle conversation for %s: %v", conversation.UserID, err)
+		}
+	}
+
+	return nil
+}
+
+// decryptSummary decrypts the title and text of a summary in place
+func decryptSummary(summary *models.ConversationSummary) error {
+	title, err := decryptField(summary.UserID, summary.Title)
+	if err != nil {
+		return err
+	}
+	text, err := decryptField(summary.UserID, summary.Summary)
+	if err != nil {
+		return err
+	}
+
+	summary.Title = title
+	summary.Summary = text
+	return nil
+}
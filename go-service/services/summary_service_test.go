@@ -0,0 +1,81 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"neuro-guide-go-service/models"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestSummaryService(t *testing.T) *SummaryService {
+	return &SummaryService{redactor: newTestRedactor(t), everyNTurns: 10}
+}
+
+func TestNeedsSummary(t *testing.T) {
+	assert.False(t, needsSummary(0, 10))
+	assert.False(t, needsSummary(19, 10))
+	assert.True(t, needsSummary(20, 10))
+	assert.True(t, needsSummary(2, 1))
+}
+
+func TestSummaryService_BuildSummaryRequest(t *testing.T) {
+	ss := newTestSummaryService(t)
+	latest := &models.ConversationSummary{Summary: "用户手机号13812345678，常失眠"}
+	messages := []*models.ChatMessage{
+		{Role: "user", Message: "换了号码13900001111，还是13812345678能打通"},
+		{Role: "assistant", Message: "好的"},
+	}
+
+	req, redactions := ss.buildSummaryRequest("u1", latest, messages)
+	assert.Equal(t, "u1", req.UserID)
+	assert.Equal(t, "用户手机号[PHONE_1]，常失眠", req.PreviousSummary)
+	// Placeholders are shared between the previous summary and the messages
+	assert.Equal(t, []map[string]string{
+		{"role": "user", "message": "换了号码[PHONE_2]，还是[PHONE_1]能打通"},
+		{"role": "assistant", "message": "好的"},
+	}, req.Messages)
+	assert.Len(t, redactions, 2)
+
+	req, _ = ss.buildSummaryRequest("u1", nil, messages[1:])
+	assert.Empty(t, req.PreviousSummary)
+}
+
+func TestSummaryService_NewSummaryVersion(t *testing.T) {
+	ss := newTestSummaryService(t)
+	coveredUntil := time.Date(2024, 3, 1, 8, 0, 0, 0, time.UTC)
+	messages := []*models.ChatMessage{
+		{Role: "user", Message: "m1", Timestamp: coveredUntil.Add(-time.Minute)},
+		{Role: "assistant", Message: "m2", Timestamp: coveredUntil},
+	}
+	redactions := []Redaction{{Detector: PIIDetectorPhone, Placeholder: "[PHONE_1]", Original: "13812345678"}}
+	resp := &SummaryResponse{Title: " 睡眠 ", Summary: "用户[PHONE_1]睡不好\n"}
+
+	first := ss.newSummaryVersion("u1", nil, messages, resp, redactions, "turns")
+	assert.Equal(t, "u1", first.UserID)
+	assert.Equal(t, 1, first.Version)
+	assert.Equal(t, 2, first.MessageCount)
+	assert.Equal(t, coveredUntil, first.CoveredUntil)
+	assert.Equal(t, "turns", first.Trigger)
+	assert.Equal(t, "睡眠", first.Title)
+	assert.Equal(t, "用户13812345678睡不好", first.Summary)
+
+	second := ss.newSummaryVersion("u1", first, messages[:1], resp, nil, "idle")
+	assert.Equal(t, 2, second.Version)
+	assert.Equal(t, 3, second.MessageCount)
+	assert.Equal(t, "用户[PHONE_1]睡不好", second.Summary)
+	assert.NotEqual(t, first.ID, second.ID)
+}
+
+func TestSummaryService_SummarizeSkipsBusyConversation(t *testing.T) {
+	summarizing.Store("busy-user", struct{}{})
+	defer summarizing.Delete("busy-user")
+
+	// Returns before touching the database
+	_, err := newTestSummaryService(t).Summarize("busy-user", "turns")
+	assert.ErrorIs(t, err, ErrNothingToSummarize)
+
+	_, busy := summarizing.Load("busy-user")
+	assert.True(t, busy)
+}
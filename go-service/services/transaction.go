@@ -0,0 +1,63 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"log"
+	"strings"
+	"sync/atomic"
+
+	"neuro-guide-go-service/database"
+
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// transactionsUnsupported is set once the server rejected a transaction
+var transactionsUnsupported atomic.Bool
+
+// withTransaction runs fn in a MongoDB transaction.
+// Standalone servers, such as the mongo:4.4 container in docker-compose, do not
+// support transactions. There fn runs without one and undo is called when it fails,
+// so callers must write fn so that undo can revert whatever part of it succeeded.
+func withTransaction(ctx context.Context, fn func(ctx context.Context) error, undo func(ctx context.Context)) error {
+	if !transactionsUnsupported.Load() {
+		err := runTransaction(ctx, fn)
+		if !isTransactionUnsupported(err) {
+			return err
+		}
+		transactionsUnsupported.Store(true)
+		log.Println("MongoDB does not support transactions, falling back to compensating writes")
+	}
+
+	err := fn(ctx)
+	if err != nil && undo != nil {
+		undo(ctx)
+	}
+	return err
+}
+
+// runTransaction runs fn in a session transaction
+func runTransaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	session, err := database.Database.Client().StartSession()
+	if err != nil {
+		return err
+	}
+	defer session.EndSession(ctx)
+
+	_, err = session.WithTransaction(ctx, func(sc mongo.SessionContext) (interface{}, error) {
+		return nil, fn(sc)
+	})
+	return err
+}
+
+// isTransactionUnsupported reports whether err means the server cannot run transactions
+func isTransactionUnsupported(err error) bool {
+	if err == nil {
+		return false
+	}
+	var cmdErr mongo.CommandError
+	if errors.As(err, &cmdErr) && cmdErr.Code == 20 { // IllegalOperation
+		return true
+	}
+	return strings.Contains(err.Error(), "Transaction numbers are only allowed on a replica set member or mongos")
+}
@@ -0,0 +1,168 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"neuro-guide-go-service/config"
+)
+
+// ErrTranscriptionUnavailable is returned when no speech-to-text engine is configured
+var ErrTranscriptionUnavailable = errors.New("speech-to-text is not available")
+
+// Transcriber converts a voice message to text
+type Transcriber interface {
+	// Name identifies the engine, it is stored with every audio file
+	Name() string
+	// Transcribe returns the text spoken in audio, format is silk, mp3 or aac
+	Transcribe(ctx context.Context, audio []byte, format string) (string, error)
+}
+
+// NewTranscriber creates the transcriber selected by cfg.Transcriber
+func NewTranscriber(cfg *config.Config) Transcriber {
+	switch cfg.Transcriber {
+	case "http":
+		return &HTTPTranscriber{
+			url:        fmt.Sprintf("%s/speech/transcribe", cfg.PythonAIServiceURL),
+			httpClient: &http.Client{Timeout: 60 * time.Second},
+		}
+	case "command":
+		return &CommandTranscriber{command: strings.Fields(cfg.TranscriberCommand)}
+	default:
+		return &StubTranscriber{text: cfg.TranscriberStubText}
+	}
+}
+
+// StubTranscriber returns a fixed transcript. It is meant for development without a speech engine.
+type StubTranscriber struct {
+	text string
+}
+
+// Name identifies the engine
+func (t *StubTranscriber) Name() string {
+	return "stub"
+}
+
+// Transcribe returns the configured text, or ErrTranscriptionUnavailable when there is none
+func (t *StubTranscriber) Transcribe(ctx context.Context, audio []byte, format string) (string, error) {
+	if t.text == "" {
+		return "", ErrTranscriptionUnavailable
+	}
+	return t.text, nil
+}
+
+// HTTPTranscriber sends the audio to the Python AI service
+type HTTPTranscriber struct {
+	url        string
+	httpClient *http.Client
+}
+
+// Name identifies the engine
+func (t *HTTPTranscriber) Name() string {
+	return "http"
+}
+
+// Transcribe posts the audio as multipart form data and returns the recognized text
+func (t *HTTPTranscriber) Transcribe(ctx context.Context, audio []byte, format string) (string, error) {
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	if err := writer.WriteField("format", format); err != nil {
+		return "", err
+	}
+	part, err := writer.CreateFormFile("file", "voice."+format)
+	if err != nil {
+		return "", err
+	}
+	if _, err := part.Write(audio); err != nil {
+		return "", err
+	}
+	if err := writer.Close(); err != nil {
+		return "", err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, t.url, &body)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+
+	resp, err := t.httpClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to call transcription service: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		respBody, _ := io.ReadAll(resp.Body)
+		return "", fmt.Errorf("transcription service returned error: %s", string(respBody))
+	}
+
+	var result struct {
+		Text string `json:"text"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return "", fmt.Errorf("failed to decode transcription: %w", err)
+	}
+
+	return result.Text, nil
+}
+
+// CommandTranscriber runs an offline speech engine, e.g. a whisper.cpp wrapper script.
+// The arguments "{file}" and "{format}" are replaced with the audio file and its format,
+// and the transcript is read from standard output.
+type CommandTranscriber struct {
+	command []string
+}
+
+// Name identifies the engine
+func (t *CommandTranscriber) Name() string {
+	return "command"
+}
+
+// Transcribe writes the audio to a temporary file and runs the command on it
+func (t *CommandTranscriber) Transcribe(ctx context.Context, audio []byte, format string) (string, error) {
+	if len(t.command) == 0 {
+		return "", ErrTranscriptionUnavailable
+	}
+
+	f, err := os.CreateTemp("", "voice-*."+format)
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(f.Name())
+
+	_, err = f.Write(audio)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return "", err
+	}
+
+	args := make([]string, 0, len(t.command)-1)
+	for _, arg := range t.command[1:] {
+		arg = strings.ReplaceAll(arg, "{file}", f.Name())
+		arg = strings.ReplaceAll(arg, "{format}", format)
+		args = append(args, arg)
+	}
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, t.command[0], args...)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("transcription command failed: %w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+
+	return strings.TrimSpace(stdout.String()), nil
+}
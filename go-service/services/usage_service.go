@@ -0,0 +1,260 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"neuro-guide-go-service/config"
+	"neuro-guide-go-service/database"
+	"neuro-guide-go-service/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const usageDateLayout = "2006-01-02"
+
+// TokenUsage is the token count reported by the AI service for one call
+type TokenUsage struct {
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+}
+
+// QuotaLimits are the daily limits for a group of users. Zero means unlimited.
+type QuotaLimits struct {
+	DailyRequests int `json:"daily_requests"`
+	DailyTokens   int `json:"daily_tokens"`
+}
+
+// QuotaExceededError is returned when a user has used up a daily quota
+type QuotaExceededError struct {
+	LimitType string    `json:"limit_type"` // requests or tokens
+	Limit     int       `json:"limit"`
+	Used      int       `json:"used"`
+	ResetAt   time.Time `json:"reset_at"`
+}
+
+func (e *QuotaExceededError) Error() string {
+	return fmt.Sprintf("daily %s quota exceeded (%d/%d), resets at %s",
+		e.LimitType, e.Used, e.Limit, e.ResetAt.Format(time.RFC3339))
+}
+
+// UsageStatus describes a user's usage and limits for the current day
+type UsageStatus struct {
+	Today   *models.Usage `json:"today"`
+	Limits  QuotaLimits   `json:"limits"`
+	IsGuest bool          `json:"is_guest"`
+	ResetAt time.Time     `json:"reset_at"`
+}
+
+// UsageService tracks per-user AI usage and enforces daily quotas
+type UsageService struct {
+	collection  *mongo.Collection
+	userService *UserService
+	guestLimits QuotaLimits
+	userLimits  QuotaLimits
+	location    *time.Location
+}
+
+// NewUsageService creates a new instance of UsageService
+func NewUsageService(cfg *config.Config) *UsageService {
+	location := time.FixedZone("CST", 8*3600)
+	if cfg.QuotaTimeZone != "" {
+		if loc, err := time.LoadLocation(cfg.QuotaTimeZone); err == nil {
+			location = loc
+		} else {
+			log.Printf("Invalid quota time zone %q, using UTC+8: %v", cfg.QuotaTimeZone, err)
+		}
+	}
+
+	return &UsageService{
+		collection:  database.Database.Collection("usage"),
+		userService: NewUserService(),
+		guestLimits: QuotaLimits{DailyRequests: cfg.GuestDailyRequests, DailyTokens: cfg.GuestDailyTokens},
+		userLimits:  QuotaLimits{DailyRequests: cfg.UserDailyRequests, DailyTokens: cfg.UserDailyTokens},
+		location:    location,
+	}
+}
+
+// dayBounds returns the usage date key for t and the time the next day starts
+func (us *UsageService) dayBounds(t time.Time) (string, time.Time) {
+	local := t.In(us.location)
+	start := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, us.location)
+	return local.Format(usageDateLayout), start.AddDate(0, 0, 1)
+}
+
+// limitsFor returns the quota limits that apply to a user.
+// Unknown users are treated as guests.
+func (us *UsageService) limitsFor(userID string) (QuotaLimits, bool) {
+	user, err := us.userService.GetUserByID(userID)
+	if err != nil || user.IsGuest {
+		return us.guestLimits, true
+	}
+	return us.userLimits, false
+}
+
+// getDailyUsage returns the usage document for a user and day, or an empty one
+func (us *UsageService) getDailyUsage(userID, date string) (*models.Usage, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var usage models.Usage
+	err := us.collection.FindOne(ctx, bson.M{"user_id": userID, "date": date}).Decode(&usage)
+	if err == mongo.ErrNoDocuments {
+		return &models.Usage{UserID: userID, Date: date}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &usage, nil
+}
+
+// CheckQuota returns a QuotaExceededError when the user has no requests or tokens left today
+func (us *UsageService) CheckQuota(userID string) error {
+	limits, _ := us.limitsFor(userID)
+	if limits.DailyRequests <= 0 && limits.DailyTokens <= 0 {
+		return nil
+	}
+
+	date, resetAt := us.dayBounds(time.Now())
+	usage, err := us.getDailyUsage(userID, date)
+	if err != nil {
+		return err
+	}
+
+	return checkLimits(usage, limits, resetAt)
+}
+
+// checkLimits compares a day's usage with the limits
+func checkLimits(usage *models.Usage, limits QuotaLimits, resetAt time.Time) error {
+	if limits.DailyRequests > 0 && usage.Requests >= limits.DailyRequests {
+		return &QuotaExceededError{LimitType: "requests", Limit: limits.DailyRequests, Used: usage.Requests, ResetAt: resetAt}
+	}
+	if limits.DailyTokens > 0 && usage.TotalTokens() >= limits.DailyTokens {
+		return &QuotaExceededError{LimitType: "tokens", Limit: limits.DailyTokens, Used: usage.TotalTokens(), ResetAt: resetAt}
+	}
+	return nil
+}
+
+// EnsureUsageIndexes creates the indexes usage accounting relies on
+func EnsureUsageIndexes() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	// A user has one counter document per day
+	_, err := database.Database.Collection("usage").Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "user_id", Value: 1}, {Key: "date", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create usage index: %w", err)
+	}
+	return nil
+}
+
+// RecordUsage counts one AI request and its token usage for today
+func (us *UsageService) RecordUsage(userID string, tokens *TokenUsage) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	date, _ := us.dayBounds(time.Now())
+	inc := bson.M{"requests": 1}
+	if tokens != nil {
+		inc["prompt_tokens"] = tokens.PromptTokens
+		inc["completion_tokens"] = tokens.CompletionTokens
+	}
+
+	filter := bson.M{"user_id": userID, "date": date}
+	update := bson.M{
+		"$inc": inc,
+		"$set": bson.M{"updated_at": time.Now()},
+	}
+	_, err := us.collection.UpdateOne(ctx, filter, update, options.Update().SetUpsert(true))
+	if mongo.IsDuplicateKeyError(err) {
+		// A concurrent first request of the day created the document; count on it
+		_, err = us.collection.UpdateOne(ctx, filter, update)
+	}
+	return err
+}
+
+// GetUsageStatus returns today's usage and the limits that apply to the user
+func (us *UsageService) GetUsageStatus(userID string) (*UsageStatus, error) {
+	limits, isGuest := us.limitsFor(userID)
+	date, resetAt := us.dayBounds(time.Now())
+
+	usage, err := us.getDailyUsage(userID, date)
+	if err != nil {
+		return nil, err
+	}
+
+	return &UsageStatus{Today: usage, Limits: limits, IsGuest: isGuest, ResetAt: resetAt}, nil
+}
+
+// GetUsageHistory returns a user's daily usage for the last number of days, newest first
+func (us *UsageService) GetUsageHistory(userID string, days int) ([]*models.Usage, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	from, _ := us.dayBounds(time.Now().AddDate(0, 0, -(days - 1)))
+	filter := bson.M{"user_id": userID, "date": bson.M{"$gte": from}}
+	opts := options.Find().SetSort(bson.M{"date": -1})
+
+	cursor, err := us.collection.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var usage []*models.Usage
+	if err := cursor.All(ctx, &usage); err != nil {
+		return nil, err
+	}
+
+	return usage, nil
+}
+
+// GetUsageReport aggregates usage across all users per day in the inclusive date range
+func (us *UsageService) GetUsageReport(from, to string) ([]*models.UsageReportEntry, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"date": bson.M{"$gte": from, "$lte": to}}}},
+		{{Key: "$group", Value: bson.M{
+			"_id":               "$date",
+			"users":             bson.M{"$sum": 1},
+			"requests":          bson.M{"$sum": "$requests"},
+			"prompt_tokens":     bson.M{"$sum": "$prompt_tokens"},
+			"completion_tokens": bson.M{"$sum": "$completion_tokens"},
+		}}},
+		{{Key: "$sort", Value: bson.M{"_id": 1}}},
+	}
+
+	cursor, err := us.collection.Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var report []*models.UsageReportEntry
+	if err := cursor.All(ctx, &report); err != nil {
+		return nil, err
+	}
+
+	return report, nil
+}
+
+// ValidateUsageDate checks a YYYY-MM-DD date parameter
+func ValidateUsageDate(date string) error {
+	_, err := time.Parse(usageDateLayout, date)
+	return err
+}
+
+// Today returns today's date key in the quota time zone
+func (us *UsageService) Today() string {
+	date, _ := us.dayBounds(time.Now())
+	return date
+}
@@ -0,0 +1,41 @@
+package services
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"neuro-guide-go-service/models"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckLimits(t *testing.T) {
+	resetAt := time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)
+	limits := QuotaLimits{DailyRequests: 10, DailyTokens: 1000}
+
+	assert.NoError(t, checkLimits(&models.Usage{Requests: 9, PromptTokens: 500}, limits, resetAt))
+
+	err := checkLimits(&models.Usage{Requests: 10}, limits, resetAt)
+	var quotaErr *QuotaExceededError
+	assert.True(t, errors.As(err, &quotaErr))
+	assert.Equal(t, "requests", quotaErr.LimitType)
+	assert.Equal(t, resetAt, quotaErr.ResetAt)
+
+	err = checkLimits(&models.Usage{Requests: 1, PromptTokens: 600, CompletionTokens: 400}, limits, resetAt)
+	assert.True(t, errors.As(err, &quotaErr))
+	assert.Equal(t, "tokens", quotaErr.LimitType)
+	assert.Equal(t, 1000, quotaErr.Used)
+
+	// Zero limits are unlimited
+	assert.NoError(t, checkLimits(&models.Usage{Requests: 1000, PromptTokens: 1e6}, QuotaLimits{}, resetAt))
+}
+
+func TestUsageService_DayBounds(t *testing.T) {
+	us := &UsageService{location: time.FixedZone("CST", 8*3600)}
+
+	// 2024-01-01 17:30 UTC is already 2024-01-02 in UTC+8
+	date, resetAt := us.dayBounds(time.Date(2024, 1, 1, 17, 30, 0, 0, time.UTC))
+	assert.Equal(t, "2024-01-02", date)
+	assert.True(t, resetAt.Equal(time.Date(2024, 1, 2, 16, 0, 0, 0, time.UTC)))
+}
@@ -0,0 +1,214 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"neuro-guide-go-service/config"
+	"neuro-guide-go-service/database"
+	"neuro-guide-go-service/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// ErrUnsupportedAudioFormat is returned for audio the mini-program cannot record
+var ErrUnsupportedAudioFormat = errors.New("unsupported audio format")
+
+// ErrEmptyTranscript is returned when no speech was recognized
+var ErrEmptyTranscript = errors.New("no speech recognized")
+
+// ErrAudioNotFound is returned when an audio file does not exist or belongs to another user
+var ErrAudioNotFound = errors.New("audio file not found")
+
+// audioContentTypes maps the supported formats to their content types
+var audioContentTypes = map[string]string{
+	"silk": "audio/silk",
+	"mp3":  "audio/mpeg",
+	"aac":  "audio/aac",
+}
+
+// NormalizeAudioFormat returns the supported format named by a format or file name, or ""
+func NormalizeAudioFormat(name string) string {
+	format := strings.ToLower(name)
+	if i := strings.LastIndex(format, "."); i >= 0 {
+		format = format[i+1:]
+	}
+	if _, ok := audioContentTypes[format]; !ok {
+		return ""
+	}
+	return format
+}
+
+// AudioContentType returns the content type of an audio format
+func AudioContentType(format string) string {
+	if contentType, ok := audioContentTypes[format]; ok {
+		return contentType
+	}
+	return "application/octet-stream"
+}
+
+// VoiceService stores voice messages and transcribes them
+type VoiceService struct {
+	collection  *mongo.Collection
+	storage     FileStorage
+	transcriber Transcriber
+}
+
+// NewVoiceService creates a new instance of VoiceService
+func NewVoiceService(cfg *config.Config) *VoiceService {
+	return &VoiceService{
+		collection:  database.Database.Collection("audio_files"),
+		storage:     NewLocalFileStorage(cfg.VoiceStorageDir),
+		transcriber: NewTranscriber(cfg),
+	}
+}
+
+// SaveAndTranscribe stores an uploaded voice message and transcribes it
+func (vs *VoiceService) SaveAndTranscribe(userID, format string, audio []byte) (*models.AudioFile, error) {
+	format = NormalizeAudioFormat(format)
+	if format == "" {
+		return nil, ErrUnsupportedAudioFormat
+	}
+
+	id := primitive.NewObjectID()
+	key := fmt.Sprintf("%s/%s.%s", userID, id.Hex(), format)
+	size, err := vs.storage.Save(key, bytes.NewReader(audio))
+	if err != nil {
+		return nil, fmt.Errorf("failed to store audio: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	defer cancel()
+
+	transcript, err := vs.transcriber.Transcribe(ctx, audio, format)
+	if err == nil && strings.TrimSpace(transcript) == "" {
+		err = ErrEmptyTranscript
+	}
+	if err != nil {
+		vs.storage.Delete(key)
+		return nil, err
+	}
+
+	file := &models.AudioFile{
+		ID:          id.Hex(),
+		UserID:      userID,
+		StorageKey:  key,
+		Format:      format,
+		Size:        size,
+		Transcript:  strings.TrimSpace(transcript),
+		Transcriber: vs.transcriber.Name(),
+		CreatedAt:   time.Now(),
+	}
+
+	// Transcripts are as sensitive as typed messages
+	encrypted, keyVersion, err := encryptField(userID, file.Transcript)
+	if err != nil {
+		vs.storage.Delete(key)
+		return nil, err
+	}
+
+	doc := bson.M{
+		"_id":         id,
+		"user_id":     userID,
+		"storage_key": key,
+		"format":      format,
+		"size":        size,
+		"transcript":  encrypted,
+		"transcriber": file.Transcriber,
+		"created_at":  file.CreatedAt,
+	}
+	if keyVersion > 0 {
+		doc["key_version"] = keyVersion
+	}
+
+	dbCtx, dbCancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer dbCancel()
+
+	if _, err := vs.collection.InsertOne(dbCtx, doc); err != nil {
+		vs.storage.Delete(key)
+		return nil, err
+	}
+
+	return file, nil
+}
+
+// GetAudioFile retrieves a user's audio file by ID
+func (vs *VoiceService) GetAudioFile(userID, audioID string) (*models.AudioFile, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	objID, err := primitive.ObjectIDFromHex(audioID)
+	if err != nil {
+		return nil, ErrAudioNotFound
+	}
+
+	var file models.AudioFile
+	err = vs.collection.FindOne(ctx, bson.M{"_id": objID, "user_id": userID}).Decode(&file)
+	if err == mongo.ErrNoDocuments {
+		return nil, ErrAudioNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	file.Transcript, err = decryptField(userID, file.Transcript)
+	if err != nil {
+		return nil, err
+	}
+
+	return &file, nil
+}
+
+// OpenAudio returns the content of an audio file
+func (vs *VoiceService) OpenAudio(file *models.AudioFile) (io.ReadCloser, error) {
+	return vs.storage.Open(file.StorageKey)
+}
+
+// DeleteAudio removes one audio file and its record
+func (vs *VoiceService) DeleteAudio(file *models.AudioFile) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	objID, err := primitive.ObjectIDFromHex(file.ID)
+	if err != nil {
+		return ErrAudioNotFound
+	}
+	if err := vs.storage.Delete(file.StorageKey); err != nil {
+		return err
+	}
+	_, err = vs.collection.DeleteOne(ctx, bson.M{"_id": objID, "user_id": file.UserID})
+	return err
+}
+
+// DeleteUserAudio removes all audio files of a user
+func (vs *VoiceService) DeleteUserAudio(userID string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	cursor, err := vs.collection.Find(ctx, bson.M{"user_id": userID})
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+
+	var files []*models.AudioFile
+	if err := cursor.All(ctx, &files); err != nil {
+		return err
+	}
+
+	for _, file := range files {
+		if err := vs.storage.Delete(file.StorageKey); err != nil {
+			return err
+		}
+	}
+
+	_, err = vs.collection.DeleteMany(ctx, bson.M{"user_id": userID})
+	return err
+}
@@ -0,0 +1,57 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNormalizeAudioFormat(t *testing.T) {
+	assert.Equal(t, "silk", NormalizeAudioFormat("silk"))
+	assert.Equal(t, "mp3", NormalizeAudioFormat("recording.MP3"))
+	assert.Equal(t, "aac", NormalizeAudioFormat("tmp/voice.aac"))
+	assert.Equal(t, "", NormalizeAudioFormat("voice.wav"))
+	assert.Equal(t, "audio/mpeg", AudioContentType("mp3"))
+}
+
+func TestLocalFileStorage(t *testing.T) {
+	storage := NewLocalFileStorage(t.TempDir())
+
+	n, err := storage.Save("u1/a.silk", strings.NewReader("audio"))
+	assert.NoError(t, err)
+	assert.Equal(t, int64(5), n)
+
+	r, err := storage.Open("u1/a.silk")
+	assert.NoError(t, err)
+	data, _ := io.ReadAll(r)
+	r.Close()
+	assert.Equal(t, "audio", string(data))
+
+	assert.NoError(t, storage.Delete("u1/a.silk"))
+	assert.NoError(t, storage.Delete("u1/a.silk"))
+
+	_, err = storage.Save("../escape.mp3", strings.NewReader("x"))
+	assert.ErrorIs(t, err, ErrInvalidStorageKey)
+	_, err = storage.Open("/etc/passwd")
+	assert.ErrorIs(t, err, ErrInvalidStorageKey)
+}
+
+func TestStubTranscriber(t *testing.T) {
+	text, err := (&StubTranscriber{text: "今天有点焦虑"}).Transcribe(context.Background(), nil, "silk")
+	assert.NoError(t, err)
+	assert.Equal(t, "今天有点焦虑", text)
+
+	_, err = (&StubTranscriber{}).Transcribe(context.Background(), nil, "silk")
+	assert.True(t, errors.Is(err, ErrTranscriptionUnavailable))
+}
+
+func TestCommandTranscriber(t *testing.T) {
+	transcriber := &CommandTranscriber{command: []string{"sh", "-c", "echo converted {format}", "sh", "{file}"}}
+	text, err := transcriber.Transcribe(context.Background(), []byte("audio"), "mp3")
+	assert.NoError(t, err)
+	assert.Equal(t, "converted mp3", text)
+}